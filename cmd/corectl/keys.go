@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"chain/core/mockhsm"
+	"chain/core/rpc"
+	"chain/crypto/sha3pool"
+	"chain/errors"
+)
+
+// A recovery code is a generated passphrase for a key backup, meant
+// to be written down, in the spirit of a BIP39 mnemonic. It encodes
+// recoveryEntropySize random bytes followed by a checksum of
+// recoveryChecksumSize bytes in Crockford's base32, in groups of
+// recoveryGroupSize characters. The checksum catches most
+// transcription errors before they're mistaken for a wrong
+// passphrase.
+const (
+	recoveryEntropySize  = 20
+	recoveryChecksumSize = 5
+	recoveryGroupSize    = 5
+)
+
+var crockford = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ")
+
+var errBadRecoveryCode = errors.New("invalid recovery code")
+
+func exportKeys(client *rpc.Client, args []string) {
+	const usage = "usage: corectl export-keys [flags]"
+	var flags flag.FlagSet
+	flagO := flags.String("o", "", "write the backup to `file` instead of stdout")
+	flagP := flags.String("passphrase-file", "", "read the passphrase from `file`")
+	flagR := flags.Bool("recovery-code", false, "generate a recovery code to use as the passphrase")
+	flagB := flags.Bool("block-keys", false, "include block-signing keys in the backup")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 || *flagR && *flagP != "" {
+		flags.Usage()
+	}
+
+	var passphrase string
+	if *flagR {
+		var code string
+		code, passphrase = newRecoveryCode()
+		fmt.Fprintln(os.Stderr, "Recovery code (write it down; the backup can't be restored without it):")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "\t"+code)
+		fmt.Fprintln(os.Stderr)
+	} else {
+		passphrase = readPassphrase(*flagP, true)
+	}
+
+	var backup mockhsm.Backup
+	req := map[string]interface{}{
+		"passphrase":         passphrase,
+		"include_block_keys": *flagB,
+	}
+	err := client.Call(context.Background(), "/mockhsm/export-keys", req, &backup)
+	dieOnRPCError(err)
+
+	b, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		fatalln("error:", err)
+	}
+	b = append(b, '\n')
+	if *flagO == "" {
+		os.Stdout.Write(b)
+	} else {
+		err = ioutil.WriteFile(*flagO, b, 0600)
+		if err != nil {
+			fatalln("error:", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d keys\n", len(backup.Keys))
+}
+
+func importKeys(client *rpc.Client, args []string) {
+	const usage = "usage: corectl import-keys [flags] [backup file]"
+	var flags flag.FlagSet
+	flagDry := flags.Bool("dry-run", false, "decrypt and check the backup without importing any keys")
+	flagP := flags.String("passphrase-file", "", "read the passphrase from `file`")
+	flagR := flags.Bool("recovery-code", false, "the passphrase is a recovery code made by export-keys")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+	}
+
+	b, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fatalln("error:", err)
+	}
+	var backup mockhsm.Backup
+	err = json.Unmarshal(b, &backup)
+	if err != nil {
+		fatalln("error: reading backup:", err)
+	}
+
+	passphrase := readPassphrase(*flagP, false)
+	if *flagR {
+		passphrase, err = parseRecoveryCode(passphrase)
+		if err != nil {
+			fatalln("error:", errors.Detail(err))
+		}
+	}
+
+	req := struct {
+		Backup     *mockhsm.Backup `json:"backup"`
+		Passphrase string          `json:"passphrase"`
+		DryRun     bool            `json:"dry_run"`
+	}{&backup, passphrase, *flagDry}
+	var resp []*mockhsm.ImportedKey
+	err = client.Call(context.Background(), "/mockhsm/import-keys", req, &resp)
+	dieOnRPCError(err)
+
+	for _, k := range resp {
+		alias := "-"
+		if k.Alias != nil {
+			alias = *k.Alias
+		}
+		fmt.Printf("%s\t%s\t%s\t%x\n", k.Status, k.KeyType, alias, []byte(k.Pub))
+	}
+	if *flagDry {
+		fmt.Fprintf(os.Stderr, "backup is valid; %d keys checked, none imported\n", len(resp))
+	}
+}
+
+// readPassphrase reads a passphrase from file or, if file is empty,
+// from stdin, prompting if stdin is a terminal. If confirm is true,
+// a prompted passphrase must be entered twice.
+func readPassphrase(file string, confirm bool) string {
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			fatalln("error:", err)
+		}
+		return mustPassphrase(strings.TrimRight(string(b), "\r\n"))
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fatalln("error: reading passphrase:", err)
+		}
+		return mustPassphrase(strings.TrimRight(line, "\r\n"))
+	}
+
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	p, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		fatalln("error: reading passphrase:", err)
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		p2, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			fatalln("error: reading passphrase:", err)
+		}
+		if !bytes.Equal(p, p2) {
+			fatalln("error: passphrases don't match")
+		}
+	}
+	return mustPassphrase(string(p))
+}
+
+func mustPassphrase(p string) string {
+	if p == "" {
+		fatalln("error: passphrase is empty")
+	}
+	return p
+}
+
+// newRecoveryCode generates a random recovery code. It returns the
+// code formatted for display and the passphrase it stands for.
+func newRecoveryCode() (code, passphrase string) {
+	b := make([]byte, recoveryEntropySize, recoveryEntropySize+recoveryChecksumSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		fatalln("error: reading random bytes:", err)
+	}
+	b = append(b, recoveryChecksum(b)...)
+	passphrase = crockford.EncodeToString(b)
+
+	var groups []string
+	for s := passphrase; s != ""; s = s[recoveryGroupSize:] {
+		groups = append(groups, s[:recoveryGroupSize])
+	}
+	return strings.Join(groups, "-"), passphrase
+}
+
+// parseRecoveryCode checks the checksum of a recovery code typed in
+// by a person and returns the passphrase it stands for. Case,
+// spaces, and dashes are ignored, and the letters Crockford's
+// base32 leaves out are read as the digits they look like.
+func parseRecoveryCode(code string) (string, error) {
+	s := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '-':
+			return -1
+		case 'O':
+			return '0'
+		case 'I', 'L':
+			return '1'
+		}
+		return r
+	}, strings.ToUpper(code))
+
+	b, err := crockford.DecodeString(s)
+	if err != nil || len(b) != recoveryEntropySize+recoveryChecksumSize {
+		return "", errors.WithDetail(errBadRecoveryCode, "recovery code is malformed")
+	}
+	sum := b[recoveryEntropySize:]
+	if !bytes.Equal(sum, recoveryChecksum(b[:recoveryEntropySize])) {
+		return "", errors.WithDetail(errBadRecoveryCode, "recovery code checksum doesn't match; check for typos")
+	}
+	return s, nil
+}
+
+func recoveryChecksum(entropy []byte) []byte {
+	var h [32]byte
+	sha3pool.Sum256(h[:], entropy)
+	return h[:recoveryChecksumSize]
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestRecoveryCode(t *testing.T) {
+	code, passphrase := newRecoveryCode()
+	if strings.Count(code, "-") != 7 {
+		t.Errorf("code %s has %d groups, want 8", code, strings.Count(code, "-")+1)
+	}
+
+	got, err := parseRecoveryCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != passphrase {
+		t.Errorf("parseRecoveryCode(%s) = %s, want %s", code, got, passphrase)
+	}
+
+	// Case, spacing, and look-alike letters don't matter.
+	sloppy := strings.ToLower(strings.Replace(code, "-", " ", -1))
+	sloppy = strings.Replace(sloppy, "0", "o", -1)
+	sloppy = strings.Replace(sloppy, "1", "l", -1)
+	got, err = parseRecoveryCode(sloppy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != passphrase {
+		t.Errorf("parseRecoveryCode(%s) = %s, want %s", sloppy, got, passphrase)
+	}
+
+	// A mistyped character is caught by the checksum.
+	typo := []byte(code)
+	if typo[0] == 'A' {
+		typo[0] = 'B'
+	} else {
+		typo[0] = 'A'
+	}
+	_, err = parseRecoveryCode(string(typo))
+	if errors.Root(err) != errBadRecoveryCode {
+		t.Errorf("parseRecoveryCode(%s) got error %v, want %v", typo, err, errBadRecoveryCode)
+	}
+
+	_, err = parseRecoveryCode(code[:len(code)-1])
+	if errors.Root(err) != errBadRecoveryCode {
+		t.Errorf("truncated code got error %v, want %v", err, errBadRecoveryCode)
+	}
+}
@@ -48,15 +48,18 @@ type command struct {
 }
 
 type grantReq struct {
-	Policy    string      `json:"policy"`
-	GuardType string      `json:"guard_type"`
-	GuardData interface{} `json:"guard_data"`
+	Policy    string            `json:"policy"`
+	GuardType string            `json:"guard_type"`
+	GuardData interface{}       `json:"guard_data"`
+	Scope     map[string]string `json:"scope,omitempty"`
 }
 
 var commands = map[string]*command{
 	"config-generator":     {configGenerator},
 	"create-block-keypair": {createBlockKeyPair},
 	"create-token":         {createToken},
+	"rotate-token":         {rotateToken},
+	"list-stale-tokens":    {listStaleTokens},
 	"config":               {configNongenerator},
 	"reset":                {reset},
 	"grant":                {grant},
@@ -64,12 +67,18 @@ var commands = map[string]*command{
 	"join":                 {joinCluster},
 	"init":                 {initCluster},
 	"evict":                {evictNode},
+	"raft-status":          {raftStatus},
+	"transfer-leadership":  {transferLeadership},
+	"sinkdb-backup":        {sinkdbBackup},
+	"sinkdb-restore":       {sinkdbRestore},
 	"allow-address":        {allowRaftMember},
 	"get":                  {get},
 	"add":                  {add},
 	"rm":                   {rm},
 	"set":                  {set},
 	"wait":                 {wait},
+	"export-keys":          {exportKeys},
+	"import-keys":          {importKeys},
 }
 
 func main() {
@@ -193,9 +202,10 @@ func createBlockKeyPair(client *rpc.Client, args []string) {
 }
 
 func createToken(client *rpc.Client, args []string) {
-	const usage = "usage: corectl create-token [-net] [name] [policy]"
+	const usage = "usage: corectl create-token [-net] [-expires-in duration] [name] [policy]"
 	var flags flag.FlagSet
 	flagNet := flags.Bool("net", false, "DEPRECATED. create a network token instead of client")
+	flagExpires := flags.Duration("expires-in", 0, "make the token stop working after `duration`")
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
@@ -207,7 +217,14 @@ func createToken(client *rpc.Client, args []string) {
 		fatalln(usage)
 	}
 
-	req := struct{ ID string }{args[0]}
+	req := struct {
+		ID        string     `json:"id"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}{ID: args[0]}
+	if *flagExpires > 0 {
+		t := time.Now().Add(*flagExpires)
+		req.ExpiresAt = &t
+	}
 	var tok accesstoken.Token
 	// TODO(kr): find a way to make this atomic with the grant below
 	err := client.Call(context.Background(), "/create-access-token", req, &tok)
@@ -299,8 +316,14 @@ func revoke(client *rpc.Client, args []string) {
 }
 
 func editAuthz(client *rpc.Client, args []string, action string) {
-	usage := "usage: corectl " + action + " [policy] [guard]"
+	usage := "usage: corectl " + action + " [-accounts filter] [-assets filter] [-transactions filter] [-outputs filter] [policy] [guard]"
 	var flags flag.FlagSet
+	scope := map[string]*string{
+		"accounts":     flags.String("accounts", "", "scope the grant to accounts matching `filter`"),
+		"assets":       flags.String("assets", "", "scope the grant to assets matching `filter`"),
+		"transactions": flags.String("transactions", "", "scope the grant to transactions matching `filter`"),
+		"outputs":      flags.String("outputs", "", "scope the grant to outputs matching `filter`"),
+	}
 
 	flags.Usage = func() {
 		fmt.Fprintln(os.Stderr, usage)
@@ -309,9 +332,16 @@ Where guard is one of:
   token=[id]   to affect an access token
   CN=[name]    to affect an X.509 Common Name
   OU=[name]    to affect an X.509 Organizational Unit
+  sub=[name]   to affect a JWT subject
+  group=[name] to affect members of a JWT group
 
 The type of guard (before the = sign) is case-insensitive.
+
+If any filter flag is given, the grant is scoped: it allows only
+the resources matching its filters, and nothing of a kind with no
+filter. Filters are as in the list endpoints, without placeholders.
 `)
+		flags.PrintDefaults()
 		os.Exit(1)
 	}
 	flags.Parse(args)
@@ -321,6 +351,15 @@ The type of guard (before the = sign) is case-insensitive.
 	}
 
 	req := grantReq{Policy: args[0]}
+	for index, filt := range scope {
+		if *filt == "" {
+			continue
+		}
+		if req.Scope == nil {
+			req.Scope = make(map[string]string)
+		}
+		req.Scope[index] = *filt
+	}
 
 	switch typ, data := splitAfter2(args[1], "="); strings.ToUpper(typ) {
 	case "TOKEN=":
@@ -332,6 +371,12 @@ The type of guard (before the = sign) is case-insensitive.
 	case "OU=":
 		req.GuardType = "x509"
 		req.GuardData = map[string]interface{}{"subject": map[string]string{"OU": data}}
+	case "SUB=":
+		req.GuardType = "jwt"
+		req.GuardData = map[string]interface{}{"subject": data}
+	case "GROUP=":
+		req.GuardType = "jwt"
+		req.GuardData = map[string]interface{}{"groups": []string{data}}
 	default:
 		fmt.Fprintln(os.Stderr, "unknown guard type", typ)
 		fatalln(usage)
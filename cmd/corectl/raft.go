@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"chain/core/rpc"
+	"chain/net/raft"
+)
+
+// raftStatus prints the status of the raft cluster
+// as seen by the Chain Core cored process.
+// Replication progress is shown only when asking the leader.
+func raftStatus(client *rpc.Client, args []string) {
+	if len(args) != 0 {
+		fatalln("error: raft-status takes no args")
+	}
+
+	var st raft.Status
+	err := client.Call(context.Background(), "/raft-status", nil, &st)
+	dieOnRPCError(err)
+
+	fmt.Printf("node %d (%s): %s, term %d, commit index %d, applied index %d\n",
+		st.ID, st.Address, st.State, st.Term, st.CommitIndex, st.AppliedIndex)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tADDRESS\tROLE\tMATCH INDEX\tLAG\tSTATE\tACTIVE")
+	for _, m := range st.Members {
+		role := "follower"
+		if m.Leader {
+			role = "leader"
+		}
+		if m.Progress == nil {
+			fmt.Fprintf(w, "%d\t%s\t%s\t-\t-\t-\t-\n", m.ID, m.Address, role)
+			continue
+		}
+		p := m.Progress
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%t\n", m.ID, m.Address, role, p.MatchIndex, p.Lag, p.State, p.Active)
+	}
+	w.Flush()
+}
+
+// transferLeadership hands raft leadership to the node with the
+// given address, or to the most up-to-date other node.
+// It must be sent to the current leader or to the new leader.
+func transferLeadership(client *rpc.Client, args []string) {
+	const usage = "usage: corectl transfer-leadership [node address]"
+	if len(args) > 1 {
+		fatalln(usage)
+	}
+
+	req := map[string]string{}
+	if len(args) == 1 {
+		req["node_address"] = args[0]
+	}
+	err := client.Call(context.Background(), "/transfer-leadership", req, nil)
+	dieOnRPCError(err)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"chain/core/rpc"
+	"chain/database/sinkdb"
+)
+
+// sinkdbBackup writes a backup of the cluster's replicated data:
+// its configuration, authorization grants, and members.
+func sinkdbBackup(client *rpc.Client, args []string) {
+	const usage = "usage: corectl sinkdb-backup [flags]"
+	var flags flag.FlagSet
+	flagO := flags.String("o", "", "write the backup to `file` instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+	}
+
+	var backup sinkdb.Backup
+	err := client.Call(context.Background(), "/sinkdb-backup", nil, &backup)
+	dieOnRPCError(err)
+
+	b, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		fatalln("error:", err)
+	}
+	b = append(b, '\n')
+	if *flagO == "" {
+		os.Stdout.Write(b)
+	} else {
+		err = ioutil.WriteFile(*flagO, b, 0600)
+		if err != nil {
+			fatalln("error:", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "backed up cluster data at index %d\n", backup.Index)
+}
+
+// sinkdbRestore initializes a new cluster, with the cored process
+// as its only member, from a backup made by sinkdb-backup.
+// Each old=new argument replaces an allowed member address.
+func sinkdbRestore(client *rpc.Client, args []string) {
+	const usage = "usage: corectl sinkdb-restore [backup file] [old address=new address]..."
+	if len(args) < 1 {
+		fatalln(usage)
+	}
+
+	b, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+	var backup sinkdb.Backup
+	err = json.Unmarshal(b, &backup)
+	if err != nil {
+		fatalln("error: reading backup:", err)
+	}
+
+	rewrite := make(map[string]string)
+	for _, arg := range args[1:] {
+		i := strings.Index(arg, "=")
+		if i < 0 {
+			fatalln(usage)
+		}
+		rewrite[arg[:i]] = arg[i+1:]
+	}
+
+	req := struct {
+		Backup           *sinkdb.Backup    `json:"backup"`
+		RewriteAddresses map[string]string `json:"rewrite_addresses"`
+	}{&backup, rewrite}
+	err = client.Call(context.Background(), "/sinkdb-restore", req, nil)
+	dieOnRPCError(err)
+}
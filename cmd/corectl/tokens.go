@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"chain/core/accesstoken"
+	"chain/core/rpc"
+)
+
+func rotateToken(client *rpc.Client, args []string) {
+	const usage = "usage: corectl rotate-token [flags] [name]"
+	var flags flag.FlagSet
+	flagGrace := flags.Duration("grace", accesstoken.DefaultGracePeriod, "keep the old secret working for `duration`")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || *flagGrace <= 0 {
+		flags.Usage()
+	}
+
+	req := struct {
+		ID          string `json:"id"`
+		GracePeriod string `json:"grace_period"`
+	}{flags.Arg(0), flagGrace.String()}
+	var tok accesstoken.Token
+	err := client.Call(context.Background(), "/rotate-access-token", req, &tok)
+	dieOnRPCError(err)
+	fmt.Println(tok.Token)
+}
+
+func listStaleTokens(client *rpc.Client, args []string) {
+	const usage = "usage: corectl list-stale-tokens [flags]"
+	var flags flag.FlagSet
+	flagUnused := flags.Duration("unused-for", 30*24*time.Hour, "list tokens not used for `duration`")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		fmt.Fprint(os.Stderr, `
+Lists access tokens that have expired, or that haven't been used
+to authenticate a request for the given duration. Tokens created
+before use tracking was added count as unused since they were
+created.
+`)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+	}
+
+	var (
+		tokens []*accesstoken.Token
+		after  string
+	)
+	for {
+		var page struct {
+			Items    []*accesstoken.Token `json:"items"`
+			LastPage bool                 `json:"last_page"`
+			Next     struct {
+				After string `json:"after"`
+			} `json:"next"`
+		}
+		req := map[string]interface{}{"after": after, "page_size": 100}
+		err := client.Call(context.Background(), "/list-access-tokens", req, &page)
+		dieOnRPCError(err)
+		tokens = append(tokens, page.Items...)
+		if page.LastPage || len(page.Items) == 0 {
+			break
+		}
+		after = page.Next.After
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tLAST USED\tFROM\tEXPIRES")
+	for _, t := range staleTokens(tokens, time.Now(), *flagUnused) {
+		lastUsed, expires := "never", "never"
+		if t.LastUsedAt != nil {
+			lastUsed = t.LastUsedAt.Format(time.RFC3339)
+		}
+		if t.ExpiresAt != nil {
+			expires = t.ExpiresAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.ID, lastUsed, t.LastUsedAddr, expires)
+	}
+	w.Flush()
+}
+
+// staleTokens returns the tokens that had expired by now or hadn't
+// been used for the given duration.
+func staleTokens(tokens []*accesstoken.Token, now time.Time, unusedFor time.Duration) []*accesstoken.Token {
+	var stale []*accesstoken.Token
+	for _, t := range tokens {
+		lastUsed := t.Created
+		if t.LastUsedAt != nil {
+			lastUsed = *t.LastUsedAt
+		}
+		expired := t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+		if expired || now.Sub(lastUsed) >= unusedFor {
+			stale = append(stale, t)
+		}
+	}
+	return stale
+}
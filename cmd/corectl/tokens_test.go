@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"chain/core/accesstoken"
+)
+
+func TestStaleTokens(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	day := 24 * time.Hour
+
+	fresh := &accesstoken.Token{ID: "fresh", Created: *ago(90 * day), LastUsedAt: ago(day)}
+	unused := &accesstoken.Token{ID: "unused", Created: *ago(90 * day), LastUsedAt: ago(60 * day)}
+	never := &accesstoken.Token{ID: "never", Created: *ago(60 * day)}
+	recent := &accesstoken.Token{ID: "recent", Created: *ago(day)}
+	expired := &accesstoken.Token{ID: "expired", Created: *ago(2 * day), LastUsedAt: ago(day), ExpiresAt: ago(time.Hour)}
+
+	got := staleTokens([]*accesstoken.Token{fresh, unused, never, recent, expired}, now, 30*day)
+	want := []*accesstoken.Token{unused, never, expired}
+	if !reflect.DeepEqual(got, want) {
+		var ids []string
+		for _, t := range got {
+			ids = append(ids, t.ID)
+		}
+		t.Errorf("staleTokens = %v, want [unused never expired]", ids)
+	}
+}
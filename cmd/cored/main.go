@@ -14,19 +14,23 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/kr/secureheader"
 
 	"chain/core"
 	"chain/core/accesstoken"
+	"chain/core/audit"
 	"chain/core/blocksigner"
 	"chain/core/config"
 	"chain/core/generator"
+	"chain/core/keystore"
 	"chain/core/migrate"
 	"chain/core/rpc"
 	"chain/core/txdb"
@@ -40,6 +44,8 @@ import (
 	chainlog "chain/log"
 	"chain/log/rotation"
 	"chain/log/splunk"
+	chainnet "chain/net"
+	"chain/net/http/authn"
 	"chain/net/http/authz"
 	"chain/net/http/limit"
 	"chain/net/http/reqid"
@@ -51,11 +57,18 @@ import (
 const (
 	httpReadTimeout  = 2 * time.Minute
 	httpWriteTimeout = time.Hour
+
+	// tlsReloadPeriod is how often the TLS files
+	// are checked for changes.
+	tlsReloadPeriod = 30 * time.Second
 )
 
 var (
 	// config vars
 	rootCAs       = env.String("ROOT_CA_CERTS", "") // file path
+	tlsCRL        = env.String("TLS_CRL", "")       // file path
+	tlsMinVersion = env.String("TLS_MIN_VERSION", "1.2")
+	tlsCiphers    = env.String("TLS_CIPHER_SUITES", "") // comma-separated
 	listenAddr    = env.String("LISTEN", ":1999")
 	dbURL         = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
 	splunkAddr    = os.Getenv("SPLUNKADDR")
@@ -67,6 +80,13 @@ var (
 	rpsToken      = env.Int("RATELIMIT_TOKEN", 0)       // reqs/sec
 	rpsRemoteAddr = env.Int("RATELIMIT_REMOTE_ADDR", 0) // reqs/sec
 	indexTxs      = env.Bool("INDEX_TRANSACTIONS", true)
+	lightClient   = env.Bool("LIGHT_CLIENT", false)
+	keystoreKey   = env.String("KEYSTORE_MASTER_KEY_FILE", "") // file path
+	jwks          = env.String("JWT_JWKS", "")                 // file path or URL
+	jwtIssuer     = env.String("JWT_ISSUER", "")
+	jwtAudience   = env.String("JWT_AUDIENCE", "")
+	jwtGroups     = env.String("JWT_GROUPS_CLAIM", "groups")
+	auditLogFile  = env.String("AUDIT_LOGFILE", "") // file path
 	home          = config.HomeDirFromEnvironment()
 
 	version string // initialized in init()
@@ -141,16 +161,14 @@ func main() {
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
-	listener, tlsConfig, err := maybeUseTLS(listener)
+	listener, tlsFiles, err := maybeUseTLS(listener)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
 
 	// TODO(kr): make core.UseTLS take just an http client
 	// and use this object in it.
-	httpClient := new(http.Client)
-	httpClient.Transport = &http.Transport{
-		TLSClientConfig: tlsConfig,
+	transport := &http.Transport{
 
 		// The following fields are default values
 		// copied from DefaultTransport.
@@ -166,6 +184,15 @@ func main() {
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	if tlsFiles != nil {
+		// TLSClientConfig is not used to make connections
+		// when DialTLS is set, but package raft reads the
+		// local cert from it.
+		transport.TLSClientConfig = tlsFiles.Config()
+		transport.DialTLS = tlsFiles.DialTLS
+		go reloadTLS(ctx, tlsFiles)
+	}
+	httpClient := &http.Client{Transport: transport}
 
 	raftDir := filepath.Join(home, "raft") // TODO(kr): better name for this
 	sdb, err := sinkdb.Open(*listenAddr, raftDir, httpClient)
@@ -200,6 +227,7 @@ func main() {
 	}
 
 	accessTokens := &accesstoken.CredentialStore{DB: db}
+	keys := openKeystore(ctx, db)
 
 	// We add handlers to our serve mux in two phases. In the first phase, we start
 	// listening on the raft routes (`/raft`). This allows us to do things like
@@ -220,7 +248,8 @@ func main() {
 	mux.Handle("/", &coreHandler)
 
 	var handler http.Handler = mux
-	handler = core.AuthHandler(handler, sdb, accessTokens, tlsConfig, builtinGrants)
+	handler = core.AuthHandler(handler, sdb, accessTokens, tlsFiles, jwtVerifier(ctx), builtinGrants)
+	handler = core.AuditHandler(handler, &audit.Store{DB: db, Sink: auditSink(ctx)})
 	handler = core.RedirectHandler(handler)
 	handler = reqid.Handler(handler)
 
@@ -285,10 +314,11 @@ func main() {
 
 	var h http.Handler
 	if conf != nil {
-		h = launchConfiguredCore(ctx, confOpts, sdb, db, conf, processID, httpClient, core.UseTLS(tlsConfig))
+		h = launchConfiguredCore(ctx, confOpts, sdb, db, conf, processID, httpClient, keys, core.UseTLS(tlsFiles), core.Keystore(keys))
 	} else {
 		var opts []core.RunOption
-		opts = append(opts, core.UseTLS(tlsConfig))
+		opts = append(opts, core.UseTLS(tlsFiles))
+		opts = append(opts, core.Keystore(keys))
 		opts = append(opts, enableMockHSM(db)...)
 		chainlog.Printf(ctx, "Launching as unconfigured Core.")
 		h = core.RunUnconfigured(ctx, confOpts, db, sdb, *listenAddr, opts...)
@@ -309,25 +339,127 @@ func main() {
 }
 
 // maybeUseTLS loads the TLS cert and key (if so configured)
-// and wraps ln in a TLS listener. If using TLS the config
-// will be returned. Otherwise the second return arg will
+// and wraps ln in a TLS listener. If using TLS the loaded
+// files will be returned. Otherwise the second return arg will
 // be nil.
-func maybeUseTLS(ln net.Listener) (net.Listener, *tls.Config, error) {
-	c, err := core.TLSConfig(
+func maybeUseTLS(ln net.Listener) (net.Listener, *core.TLSReloader, error) {
+	opts := core.TLSOptions{RootCAs: *rootCAs, CRL: *tlsCRL}
+	var err error
+	opts.MinVersion, err = chainnet.ParseTLSVersion(*tlsMinVersion)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "TLS_MIN_VERSION")
+	}
+	opts.CipherSuites, err = chainnet.ParseCipherSuites(*tlsCiphers)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "TLS_CIPHER_SUITES")
+	}
+
+	t, err := core.LoadTLS(
 		filepath.Join(home, "tls.crt"),
 		filepath.Join(home, "tls.key"),
-		*rootCAs,
+		opts,
 	)
 	if err == core.ErrNoTLS && config.BuildConfig.HTTPOk {
 		return ln, nil, nil // files & env vars don't exist; don't want TLS
 	} else if err != nil {
 		return nil, nil, err
 	}
-	ln = tls.NewListener(ln, c)
-	return ln, c, nil
+	ln = tls.NewListener(ln, t.Config())
+	return ln, t, nil
+}
+
+// reloadTLS reloads the TLS files on SIGHUP, and whenever
+// they change, so that certificates can be rotated without
+// restarting cored. Errors are logged, and the previously
+// loaded files stay in use.
+func reloadTLS(ctx context.Context, t *core.TLSReloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticks := time.NewTicker(tlsReloadPeriod)
+	defer ticks.Stop()
+	for {
+		var (
+			reloaded bool
+			err      error
+		)
+		select {
+		case <-hup:
+			reloaded, err = true, t.Reload()
+		case <-ticks.C:
+			reloaded, err = t.ReloadIfChanged()
+		}
+		if err != nil {
+			chainlog.Error(ctx, err, "at", "reloading TLS files")
+		} else if reloaded {
+			chainlog.Printf(ctx, "Reloaded TLS files; certificate expires %s", t.Leaf().NotAfter.Format(time.RFC3339))
+		}
+	}
+}
+
+// jwtVerifier returns a verifier for JWT bearer tokens if a JWKS
+// is configured, or nil otherwise.
+//
+// The key set is usually served by a public identity provider, so it
+// is fetched with the default client and the system's root CAs, not
+// with the cross-core client, which trusts only ROOT_CA_CERTS.
+func jwtVerifier(ctx context.Context) *authn.JWTVerifier {
+	if *jwks == "" {
+		return nil
+	}
+	v, err := authn.NewJWTVerifier(ctx, authn.JWTConfig{
+		JWKS:        *jwks,
+		Issuer:      *jwtIssuer,
+		Audience:    *jwtAudience,
+		GroupsClaim: *jwtGroups,
+	})
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err, "at", "configuring JWT authentication")
+	}
+	return v
 }
 
-func launchConfiguredCore(ctx context.Context, confOpts *config.Options, sdb *sinkdb.DB, db *sql.DB, conf *config.Config, processID string, httpClient *http.Client, opts ...core.RunOption) http.Handler {
+// auditSink returns the configured audit log file, if there is one,
+// for exporting audit events.
+func auditSink(ctx context.Context) io.Writer {
+	if *auditLogFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(*auditLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err, "at", "opening audit log")
+	}
+	return f
+}
+
+// openKeystore returns the Core's keystore. If a master key file
+// is configured, the keystore is unlocked with it, and initialized
+// with it if it's new; the operator configuring the file is the
+// explicit request to initialize. Otherwise the keystore stays locked
+// until an internal caller initializes it through /keystore/init or
+// unlocks it through /keystore/unlock.
+func openKeystore(ctx context.Context, db pg.DB) *keystore.Store {
+	keys := keystore.New(db)
+	if *keystoreKey == "" {
+		return keys
+	}
+	masterKey, err := keystore.ReadMasterKeyFile(*keystoreKey)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+	}
+	err = keys.UnlockWithKey(ctx, masterKey)
+	if errors.Root(err) == keystore.ErrUninitialized {
+		err = keys.InitWithKey(ctx, masterKey)
+		if err == nil {
+			chainlog.Printkv(ctx, "at", "initialized keystore")
+		}
+	}
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err, "at", "unlocking keystore")
+	}
+	return keys
+}
+
+func launchConfiguredCore(ctx context.Context, confOpts *config.Options, sdb *sinkdb.DB, db *sql.DB, conf *config.Config, processID string, httpClient *http.Client, keys *keystore.Store, opts ...core.RunOption) http.Handler {
 	// Initialize the protocol.Chain.
 	heights, err := txdb.ListenBlocks(ctx, *dbURL)
 	if err != nil {
@@ -342,6 +474,7 @@ func launchConfiguredCore(ctx context.Context, confOpts *config.Options, sdb *si
 	var localSigner *blocksigner.BlockSigner
 
 	opts = append(opts, core.IndexTransactions(*indexTxs))
+	opts = append(opts, core.LightClient(*lightClient))
 	opts = append(opts, enableMockHSM(db)...)
 	// Add any configured API request rate limits.
 	if *rpsToken > 0 {
@@ -352,7 +485,7 @@ func launchConfiguredCore(ctx context.Context, confOpts *config.Options, sdb *si
 	}
 	// If the Core is configured as a block signer, add the sign-block RPC handler.
 	if conf.IsSigner {
-		localSigner = initializeLocalSigner(ctx, confOpts, conf, db, c, processID, httpClient)
+		localSigner = initializeLocalSigner(ctx, confOpts, conf, db, c, processID, httpClient, keys)
 		opts = append(opts, core.BlockSigner(localSigner.ValidateAndSignBlock))
 	}
 
@@ -396,10 +529,16 @@ func launchConfiguredCore(ctx context.Context, confOpts *config.Options, sdb *si
 	return api
 }
 
-func initializeLocalSigner(ctx context.Context, confOpts *config.Options, conf *config.Config, db pg.DB, c *protocol.Chain, processID string, httpClient *http.Client) *blocksigner.BlockSigner {
+func initializeLocalSigner(ctx context.Context, confOpts *config.Options, conf *config.Config, db pg.DB, c *protocol.Chain, processID string, httpClient *http.Client, keys *keystore.Store) *blocksigner.BlockSigner {
 	var hsm blocksigner.Signer
 	hsm = mockHSM(db)
 
+	// A keystore unlocked with a master key file at startup can
+	// sign blocks without an operator present, so prefer it to a
+	// remote enclave.
+	if hsm == nil && *keystoreKey != "" {
+		hsm = keys
+	}
 	if hsm == nil {
 		hsm = &blocksigner.EnclaveClient{
 			URLs: confOpts.ListFunc("enclave"),
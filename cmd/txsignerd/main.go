@@ -0,0 +1,155 @@
+// Command txsignerd is a reference implementation of a remote
+// transaction signer, serving the protocol defined in package
+// chain/core/txsigner. It keeps its keys in an encrypted keystore
+// in its own Postgres database, unlocked at startup with a master
+// key file (and initialized with it on first start).
+//
+// Usage:
+//
+//     txsignerd                    serve signature requests
+//     txsignerd create-key [alias] create a key and print its xpub
+//
+// It serves HTTPS, and won't start without a certificate and key,
+// since Chain Core only sends signature requests over https. The
+// -http flag serves plain HTTP instead, for use behind a proxy that
+// terminates TLS.
+//
+// It is configured with environment variables:
+//
+//     DATABASE_URL              Postgres database for the keystore
+//     LISTEN                    address to listen on
+//     KEYSTORE_MASTER_KEY_FILE  file containing the keystore master key
+//     ACCESS_TOKEN              <username>:<password> required of clients
+//     TLSCRT, TLSKEY            PEM-encoded certificate and key for HTTPS
+//
+// Chain Core uses txsignerd once its URL and access token are added
+// to the tx_signer configuration option.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"database/sql"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	_ "github.com/lib/pq"
+
+	"chain/core/keystore"
+	"chain/core/migrate"
+	"chain/core/txsigner"
+	"chain/crypto/ed25519/chainkd"
+	"chain/env"
+	"chain/errors"
+	"chain/log"
+	"chain/net"
+)
+
+var (
+	dbURL         = env.String("DATABASE_URL", "postgres:///txsignerd?sslmode=disable")
+	listen        = env.String("LISTEN", ":2001")
+	masterKeyFile = env.String("KEYSTORE_MASTER_KEY_FILE", "")
+	accessToken   = env.String("ACCESS_TOKEN", "")
+	tlsCrt        = env.String("TLSCRT", "")
+	tlsKey        = env.String("TLSKEY", "")
+
+	plainHTTP = flag.Bool("http", false, "serve plain HTTP, behind a proxy that terminates TLS")
+)
+
+func main() {
+	env.Parse()
+	flag.Parse()
+	ctx := context.Background()
+
+	if *masterKeyFile == "" {
+		log.Fatalkv(ctx, log.KeyError, "KEYSTORE_MASTER_KEY_FILE must be set")
+	}
+	db, err := sql.Open("postgres", *dbURL)
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, err)
+	}
+	err = migrate.Run(db)
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, err)
+	}
+	masterKey, err := keystore.ReadMasterKeyFile(*masterKeyFile)
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, err)
+	}
+	keys := keystore.New(db)
+	err = keys.UnlockWithKey(ctx, masterKey)
+	if errors.Root(err) == keystore.ErrUninitialized {
+		err = keys.InitWithKey(ctx, masterKey)
+		if err == nil {
+			log.Printkv(ctx, "at", "initialized keystore")
+		}
+	}
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, err, "at", "unlocking keystore")
+	}
+
+	if flag.Arg(0) == "create-key" {
+		xpub, err := keys.XCreate(ctx, flag.Arg(1))
+		if err != nil {
+			log.Fatalkv(ctx, log.KeyError, err)
+		}
+		fmt.Println(xpub.XPub.String())
+		return
+	} else if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		os.Exit(2)
+	}
+
+	if !strings.Contains(*accessToken, ":") {
+		log.Fatalkv(ctx, log.KeyError, "ACCESS_TOKEN must be of the form <username>:<password>")
+	}
+	if (*tlsCrt == "" || *tlsKey == "") && !*plainHTTP {
+		log.Fatalkv(ctx, log.KeyError, "TLSCRT and TLSKEY must be set; use -http to serve plain HTTP behind a TLS proxy")
+	}
+	handler := requireToken(*accessToken, txsigner.Handler(func(ctx context.Context, xpub chainkd.XPub, path [][]byte, hardened int, _ *chainkd.XPub, hash [32]byte) ([]byte, error) {
+		sig, err := keys.XSignHardened(ctx, xpub, path, hardened, hash[:])
+		if err == keystore.ErrNoKey {
+			return nil, nil
+		}
+		return sig, err
+	}))
+
+	if !*plainHTTP {
+		cert, err := tls.X509KeyPair([]byte(*tlsCrt), []byte(*tlsKey))
+		if err != nil {
+			log.Fatalkv(ctx, log.KeyError, errors.Wrap(err, "parsing tls X509 key pair"))
+		}
+
+		tlsConfig := net.DefaultTLSConfig()
+		tlsConfig.Certificates = []tls.Certificate{cert}
+
+		server := &http.Server{
+			Addr:      *listen,
+			Handler:   handler,
+			TLSConfig: tlsConfig,
+		}
+		err = server.ListenAndServeTLS("", "")
+		log.Error(ctx, err)
+	} else {
+		err := http.ListenAndServe(*listen, handler)
+		log.Error(ctx, err)
+	}
+}
+
+// requireToken rejects requests that don't carry token, of the form
+// <username>:<password>, in their basic auth credentials.
+func requireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, pw, _ := req.BasicAuth()
+		if subtle.ConstantTimeCompare([]byte(user+":"+pw), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="txsignerd"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
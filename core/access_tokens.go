@@ -3,8 +3,10 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"chain/core/accesstoken"
+	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
 	"chain/net/http/authz"
@@ -13,8 +15,11 @@ import (
 
 var errCurrentToken = errors.New("token cannot delete itself")
 
-func (a *API) createAccessToken(ctx context.Context, x struct{ ID, Type string }) (*accesstoken.Token, error) {
-	token, err := a.accessTokens.Create(ctx, x.ID, x.Type)
+func (a *API) createAccessToken(ctx context.Context, x struct {
+	ID, Type  string
+	ExpiresAt time.Time `json:"expires_at"`
+}) (*accesstoken.Token, error) {
+	token, err := a.accessTokens.Create(ctx, x.ID, x.Type, x.ExpiresAt)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
@@ -83,6 +88,24 @@ func (a *API) listAccessTokens(ctx context.Context, x requestQuery) (*page, erro
 	}, nil
 }
 
+// rotateAccessToken gives an access token a new secret. The old
+// secret keeps working for the grace period, an hour by default.
+//
+// POST /rotate-access-token
+func (a *API) rotateAccessToken(ctx context.Context, x struct {
+	ID          string
+	GracePeriod chainjson.Duration `json:"grace_period"`
+	ExpiresAt   time.Time          `json:"expires_at"`
+}) (*accesstoken.Token, error) {
+	grace := x.GracePeriod.Duration
+	if grace == 0 {
+		grace = accesstoken.DefaultGracePeriod
+	} else if grace < 0 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "grace_period can't be negative")
+	}
+	return a.accessTokens.Rotate(ctx, x.ID, grace, x.ExpiresAt)
+}
+
 func (a *API) deleteAccessToken(ctx context.Context, x struct{ ID string }) error {
 	currentID, _, _ := httpjson.Request(ctx).BasicAuth()
 	if currentID == x.ID {
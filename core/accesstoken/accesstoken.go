@@ -12,6 +12,8 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/lib/pq"
+
 	"chain/crypto/sha3pool"
 	"chain/database/pg"
 	"chain/errors"
@@ -20,6 +22,10 @@ import (
 const (
 	tokenSize    = 32
 	defaultLimit = 100
+
+	// DefaultGracePeriod is how long the old secret of a rotated
+	// token keeps working if the caller doesn't say.
+	DefaultGracePeriod = time.Hour
 )
 
 var (
@@ -29,6 +35,9 @@ var (
 	ErrDuplicateID = errors.New("duplicate access token ID")
 	// ErrBadType is returned when Create is called with a bad type.
 	ErrBadType = errors.New("type must be client or network")
+	// ErrBadExpiry is returned when Create or Rotate is called with an
+	// expiration time in the past.
+	ErrBadExpiry = errors.New("expiration time must be in the future")
 
 	// validIDRegexp checks that all characters are alphumeric, _ or -.
 	// It also must have a length of at least 1.
@@ -40,30 +49,54 @@ type Token struct {
 	Token   string    `json:"token,omitempty"`
 	Type    string    `json:"type,omitempty"` // deprecated in 1.2
 	Created time.Time `json:"created_at"`
-	sortID  string
+
+	// ExpiresAt, if set, is when the token stops working.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// RotatedAt is when the token's secret was last replaced, and
+	// PreviousExpiresAt is when the secret it replaced stops working.
+	RotatedAt         *time.Time `json:"rotated_at,omitempty"`
+	PreviousExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+
+	// LastUsedAt and LastUsedAddr record the last time the token
+	// authenticated a request and where the request came from. They
+	// are updated in the background, and only every few minutes for
+	// a token in constant use, so they lag a little behind.
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	LastUsedAddr string     `json:"last_used_address,omitempty"`
+
+	sortID string
+}
+
+// Use is a use of an access token to authenticate a request.
+type Use struct {
+	ID   string
+	Addr string
+	Time time.Time
 }
 
 type CredentialStore struct {
 	DB pg.DB
 }
 
-// Create generates a new access token with the given ID.
-func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token, error) {
+// Create generates a new access token with the given ID. If
+// expiresAt is not the zero time, the token stops working then.
+func (cs *CredentialStore) Create(ctx context.Context, id, typ string, expiresAt time.Time) (*Token, error) {
 	if !validIDRegexp.MatchString(id) {
 		return nil, errors.WithDetailf(ErrBadID, "invalid id %q", id)
 	}
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return nil, errors.WithDetailf(ErrBadExpiry, "expires_at %s", expiresAt.Format(time.RFC3339))
+	}
 
-	var secret [tokenSize]byte
-	_, err := rand.Read(secret[:])
+	secret, hashedSecret, err := newSecret()
 	if err != nil {
 		return nil, err
 	}
-	var hashedSecret [32]byte
-	sha3pool.Sum256(hashedSecret[:], secret[:])
 
 	const q = `
-		INSERT INTO access_tokens (id, type, hashed_secret)
-		VALUES($1, $2, $3)
+		INSERT INTO access_tokens (id, type, hashed_secret, expires_at)
+		VALUES($1, $2, $3, $4)
 		RETURNING created, sort_id
 	`
 	var (
@@ -71,7 +104,7 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 		sortID    string
 		maybeType = sql.NullString{String: typ, Valid: typ != ""}
 	)
-	err = cs.DB.QueryRowContext(ctx, q, id, maybeType, hashedSecret[:]).Scan(&created, &sortID)
+	err = cs.DB.QueryRowContext(ctx, q, id, maybeType, hashedSecret[:], nullTime(expiresAt)).Scan(&created, &sortID)
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetailf(ErrDuplicateID, "id %q already in use", id)
 	}
@@ -79,17 +112,73 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 		return nil, errors.Wrap(err)
 	}
 
-	return &Token{
+	token := &Token{
 		ID:      id,
 		Token:   fmt.Sprintf("%s:%x", id, secret),
 		Type:    typ,
 		Created: created,
 		sortID:  sortID,
-	}, nil
+	}
+	if !expiresAt.IsZero() {
+		token.ExpiresAt = &expiresAt
+	}
+	return token, nil
+}
+
+// Rotate replaces the secret of the access token with the given ID
+// with a new one, which it returns in the token. The old secret
+// keeps working for the grace period, so that clients can switch
+// over without downtime. If expiresAt is not the zero time, it
+// becomes the token's new expiration time.
+func (cs *CredentialStore) Rotate(ctx context.Context, id string, grace time.Duration, expiresAt time.Time) (*Token, error) {
+	now := time.Now()
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return nil, errors.WithDetailf(ErrBadExpiry, "expires_at %s", expiresAt.Format(time.RFC3339))
+	}
+
+	secret, hashedSecret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// The old secret can't outlive the token itself.
+	const q = `
+		UPDATE access_tokens SET
+			previous_hashed_secret = hashed_secret,
+			previous_expires_at = LEAST($3, expires_at),
+			hashed_secret = $2,
+			rotated_at = now(),
+			expires_at = COALESCE($4, expires_at)
+		WHERE id = $1 AND (expires_at IS NULL OR expires_at > now())
+		RETURNING type, created, sort_id, expires_at, rotated_at, previous_expires_at
+	`
+	var (
+		maybeType sql.NullString
+		token     = &Token{ID: id}
+	)
+	err = cs.DB.QueryRowContext(ctx, q, id, hashedSecret[:], now.Add(grace), nullTime(expiresAt)).Scan(
+		&maybeType,
+		&token.Created,
+		&token.sortID,
+		&token.ExpiresAt,
+		&token.RotatedAt,
+		&token.PreviousExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "access token id %s", id)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	token.Type = maybeType.String
+	token.Token = fmt.Sprintf("%s:%x", id, secret)
+	return token, nil
 }
 
-// Check returns whether or not an id-secret pair is a valid access token.
-func (cs *CredentialStore) Check(ctx context.Context, id string, secret []byte) (bool, error) {
+// Check returns whether or not an id-secret pair is a valid access
+// token. If it is, it also returns when the pair stops being valid,
+// or the zero time if it never does.
+func (cs *CredentialStore) Check(ctx context.Context, id string, secret []byte) (bool, time.Time, error) {
 	var (
 		toHash [tokenSize]byte
 		hashed [32]byte
@@ -97,14 +186,59 @@ func (cs *CredentialStore) Check(ctx context.Context, id string, secret []byte)
 	copy(toHash[:], secret)
 	sha3pool.Sum256(hashed[:], toHash[:])
 
-	const q = `SELECT EXISTS(SELECT 1 FROM access_tokens WHERE id=$1 AND hashed_secret=$2)`
-	var valid bool
-	err := cs.DB.QueryRowContext(ctx, q, id, hashed[:]).Scan(&valid)
+	const q = `
+		SELECT CASE WHEN hashed_secret=$2 THEN expires_at ELSE previous_expires_at END
+		FROM access_tokens
+		WHERE id=$1 AND (
+			(hashed_secret=$2 AND (expires_at IS NULL OR expires_at > now())) OR
+			(previous_hashed_secret=$2 AND previous_expires_at > now())
+		)
+	`
+	var expiresAt pq.NullTime
+	err := cs.DB.QueryRowContext(ctx, q, id, hashed[:]).Scan(&expiresAt)
+	if err == sql.ErrNoRows {
+		return false, time.Time{}, nil
+	}
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	return true, expiresAt.Time, nil
+}
+
+// RecordUses saves the last use of each token in uses. Uses older
+// than the last recorded use of their token are ignored.
+func (cs *CredentialStore) RecordUses(ctx context.Context, uses []Use) error {
+	var (
+		ids   []string
+		addrs []string
+		times []string
+	)
+	for _, u := range uses {
+		ids = append(ids, u.ID)
+		addrs = append(addrs, u.Addr)
+		times = append(times, u.Time.Format(time.RFC3339Nano))
+	}
+	const q = `
+		UPDATE access_tokens t SET last_used_at = u.time, last_used_addr = u.addr
+		FROM unnest($1::text[], $2::text[], $3::timestamptz[]) AS u(id, addr, time)
+		WHERE t.id = u.id AND (t.last_used_at IS NULL OR t.last_used_at < u.time)
+	`
+	_, err := cs.DB.ExecContext(ctx, q, pq.StringArray(ids), pq.StringArray(addrs), pq.StringArray(times))
+	return errors.Wrap(err, "recording access token uses")
+}
+
+func newSecret() (secret [tokenSize]byte, hashed [32]byte, err error) {
+	_, err = rand.Read(secret[:])
 	if err != nil {
-		return false, err
+		return secret, hashed, err
 	}
+	sha3pool.Sum256(hashed[:], secret[:])
+	return secret, hashed, nil
+}
 
-	return valid, nil
+func nullTime(t time.Time) pq.NullTime {
+	return pq.NullTime{Time: t, Valid: !t.IsZero()}
 }
 
 // Exists returns whether an id is part of a valid access token. It does not validate a secret.
@@ -124,18 +258,25 @@ func (cs *CredentialStore) List(ctx context.Context, typ, after string, limit in
 		limit = defaultLimit
 	}
 	const q = `
-		SELECT id, type, sort_id, created FROM access_tokens
+		SELECT id, type, sort_id, created, expires_at, rotated_at, previous_expires_at,
+			last_used_at, COALESCE(last_used_addr, '')
+		FROM access_tokens
 		WHERE ($1='' OR type=$1::access_token_type) AND ($2='' OR sort_id<$2)
 		ORDER BY sort_id DESC
 		LIMIT $3
 	`
 	var tokens []*Token
-	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, func(id string, maybeType sql.NullString, sortID string, created time.Time) {
+	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, func(id string, maybeType sql.NullString, sortID string, created time.Time, expiresAt, rotatedAt, prevExpiresAt, lastUsedAt pq.NullTime, lastUsedAddr string) {
 		t := Token{
-			ID:      id,
-			Created: created,
-			Type:    maybeType.String,
-			sortID:  sortID,
+			ID:                id,
+			Created:           created,
+			Type:              maybeType.String,
+			ExpiresAt:         timePtr(expiresAt),
+			RotatedAt:         timePtr(rotatedAt),
+			PreviousExpiresAt: timePtr(prevExpiresAt),
+			LastUsedAt:        timePtr(lastUsedAt),
+			LastUsedAddr:      lastUsedAddr,
+			sortID:            sortID,
 		}
 		tokens = append(tokens, &t)
 	})
@@ -169,3 +310,10 @@ func (cs *CredentialStore) Delete(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+func timePtr(t pq.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/hex"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 	"chain/testutil"
@@ -29,7 +31,7 @@ func TestCreate(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		_, err := cs.Create(ctx, c.id, c.net)
+		_, err := cs.Create(ctx, c.id, c.net, time.Time{})
 		if errors.Root(err) != c.want {
 			t.Errorf("Create(%s, %s) error = %s want %s", c.id, c.net, err, c.want)
 		}
@@ -114,7 +116,7 @@ func TestCheck(t *testing.T) {
 		t.Fatal("bad token secret")
 	}
 
-	valid, err := cs.Check(ctx, tokenID, tokenSecret)
+	valid, _, err := cs.Check(ctx, tokenID, tokenSecret)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -122,7 +124,7 @@ func TestCheck(t *testing.T) {
 		t.Fatal("expected token and secret to be valid")
 	}
 
-	valid, err = cs.Check(ctx, "x", []byte("badsecret"))
+	valid, _, err = cs.Check(ctx, "x", []byte("badsecret"))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -131,6 +133,121 @@ func TestCheck(t *testing.T) {
 	}
 }
 
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	_, err := cs.Create(ctx, "past", "", time.Now().Add(-time.Minute))
+	if errors.Root(err) != ErrBadExpiry {
+		t.Errorf("Create with past expiry error = %v, want %v", err, ErrBadExpiry)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, err := cs.Create(ctx, "x", "", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, secret := splitToken(t, token)
+	valid, until, err := cs.Check(ctx, id, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid || !until.Equal(expiresAt) {
+		t.Errorf("Check = %t, %s, want true, %s", valid, until, expiresAt)
+	}
+
+	_, err = cs.DB.ExecContext(ctx, `UPDATE access_tokens SET expires_at = now() - interval '1 second'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, _, err = cs.Check(ctx, id, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Error("expected expired token to be invalid")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	old := mustCreateToken(t, ctx, cs, "x", "client")
+	rotated, err := cs.Rotate(ctx, "x", time.Hour, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Token == old.Token {
+		t.Fatal("expected rotation to change the secret")
+	}
+	if rotated.RotatedAt == nil || rotated.PreviousExpiresAt == nil || rotated.ExpiresAt != nil {
+		t.Errorf("rotated token = %+v, want rotation times and no expiry", rotated)
+	}
+
+	oldID, oldSecret := splitToken(t, old)
+	newID, newSecret := splitToken(t, rotated)
+	for _, c := range []struct {
+		id     string
+		secret []byte
+	}{{oldID, oldSecret}, {newID, newSecret}} {
+		valid, _, err := cs.Check(ctx, c.id, c.secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !valid {
+			t.Errorf("expected secret %x to be valid during the grace period", c.secret)
+		}
+	}
+
+	_, err = cs.DB.ExecContext(ctx, `UPDATE access_tokens SET previous_expires_at = now() - interval '1 second'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, _, err := cs.Check(ctx, oldID, oldSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Error("expected old secret to be invalid after the grace period")
+	}
+
+	_, err = cs.Rotate(ctx, "nonexistent", time.Hour, time.Time{})
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("Rotate(nonexistent) error = %v, want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
+func TestRecordUses(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+	mustCreateToken(t, ctx, cs, "x", "client")
+
+	used := time.Now().Truncate(time.Second)
+	uses := []Use{
+		{ID: "x", Addr: "10.0.0.1", Time: used},
+		{ID: "nonexistent", Addr: "10.0.0.2", Time: used},
+	}
+	err := cs.RecordUses(ctx, uses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An older use doesn't replace a newer one.
+	err = cs.RecordUses(ctx, []Use{{ID: "x", Addr: "10.0.0.3", Time: used.Add(-time.Minute)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, _, err := cs.List(ctx, "", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tokens[0]
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(used) || got.LastUsedAddr != "10.0.0.1" {
+		t.Errorf("last use = %v from %q, want %s from 10.0.0.1", got.LastUsedAt, got.LastUsedAddr, used)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	ctx := context.Background()
 	cs := &CredentialStore{DB: pgtest.NewTx(t)}
@@ -142,8 +259,17 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func splitToken(t *testing.T, token *Token) (string, []byte) {
+	parts := strings.Split(token.Token, ":")
+	secret, err := hex.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal("bad token secret")
+	}
+	return parts[0], secret
+}
+
 func mustCreateToken(t *testing.T, ctx context.Context, cs *CredentialStore, id, typ string) *Token {
-	token, err := cs.Create(ctx, id, typ)
+	token, err := cs.Create(ctx, id, typ, time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -19,7 +19,7 @@ import (
 	"chain/errors"
 	"chain/log"
 	"chain/protocol"
-	"chain/protocol/vm/vmutil"
+	"chain/protocol/bc"
 )
 
 const maxAccountCache = 1000
@@ -89,9 +89,13 @@ type Account struct {
 	Tags  map[string]interface{}
 }
 
-// Create creates a new Account.
-func (m *Manager) Create(ctx context.Context, xpubs []chainkd.XPub, quorum int, alias string, tags map[string]interface{}, clientToken string) (*Account, error) {
-	signer, err := signers.Create(ctx, m.db, "account", xpubs, quorum, clientToken)
+// Create creates a new Account. If pathPrefix is not empty, the
+// account's keys are derived from xpubs along paths starting with
+// pathPrefix. If accountXPubs is not empty, pathPrefix is derived
+// with hardened derivation, and accountXPubs[i] must be xpubs[i]
+// derived that way.
+func (m *Manager) Create(ctx context.Context, xpubs []chainkd.XPub, quorum int, pathPrefix [][]byte, accountXPubs []chainkd.XPub, alias string, tags map[string]interface{}, clientToken string) (*Account, error) {
+	signer, err := signers.Create(ctx, m.db, "account", xpubs, quorum, pathPrefix, accountXPubs, clientToken)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
@@ -234,6 +238,25 @@ func (m *Manager) findByID(ctx context.Context, id string) (*signers.Signer, err
 	return account, nil
 }
 
+// OutputAccounts returns the IDs of the accounts that control any
+// of the given unspent outputs. Outputs that don't belong to an
+// account in this Core are ignored.
+func (m *Manager) OutputAccounts(ctx context.Context, outputIDs []bc.Hash) ([]string, error) {
+	var ids pq.ByteaArray
+	for _, id := range outputIDs {
+		ids = append(ids, id.Bytes())
+	}
+	const q = `
+		SELECT DISTINCT account_id FROM account_utxos
+		WHERE output_id IN (SELECT unnest($1::bytea[]))
+	`
+	var accountIDs []string
+	err := pg.ForQueryRows(ctx, m.db, q, ids, func(accountID string) {
+		accountIDs = append(accountIDs, accountID)
+	})
+	return accountIDs, errors.Wrap(err, "finding output accounts")
+}
+
 type controlProgram struct {
 	accountID      string
 	keyIndex       uint64
@@ -253,17 +276,14 @@ func (m *Manager) createControlProgram(ctx context.Context, accountID string, ch
 		return nil, err
 	}
 
-	path := signers.Path(account, signers.AccountKeySpace, idx)
-	derivedXPubs := chainkd.DeriveXPubs(account.XPubs, path)
-	derivedPKs := chainkd.XPubKeys(derivedXPubs)
-	control, err := vmutil.P2SPMultiSigProgram(derivedPKs, account.Quorum)
+	key, err := receiverKey(account, idx)
 	if err != nil {
 		return nil, err
 	}
 	return &controlProgram{
 		accountID:      account.ID,
 		keyIndex:       idx,
-		controlProgram: control,
+		controlProgram: key.ControlProgram,
 		change:         change,
 		expiresAt:      expiresAt,
 	}, nil
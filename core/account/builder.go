@@ -157,7 +157,7 @@ func utxoToInputs(ctx context.Context, account *signers.Signer, u *utxo, refData
 	sigInst := &txbuilder.SigningInstruction{}
 
 	path := signers.Path(account, signers.AccountKeySpace, u.ControlProgramIndex)
-	sigInst.AddWitnessKeys(account.XPubs, path, account.Quorum)
+	sigInst.AddHardenedWitnessKeys(account.XPubs, account.HardenedXPubs, path, account.HardenedLevels(), account.Quorum)
 
 	return txInput, sigInst, nil
 }
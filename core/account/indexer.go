@@ -59,11 +59,13 @@ func Annotated(a *Account) (*query.AnnotatedAccount, error) {
 	for _, p := range path {
 		jsonPath = append(jsonPath, p)
 	}
-	for _, xpub := range a.XPubs {
+	accountXPubs := a.DeriveXPubs(path)
+	for i, xpub := range a.XPubs {
 		aa.Keys = append(aa.Keys, &query.AccountKey{
-			RootXPub:              xpub,
-			AccountXPub:           xpub.Derive(path),
-			AccountDerivationPath: jsonPath,
+			RootXPub:                  xpub,
+			AccountXPub:               accountXPubs[i],
+			AccountDerivationPath:     jsonPath,
+			AccountDerivationHardened: a.HardenedLevels() > 0,
 		})
 	}
 	return aa, nil
@@ -2,10 +2,21 @@ package account
 
 import (
 	"context"
+	stdsql "database/sql"
+	"math"
+	"strconv"
 	"time"
 
+	"github.com/lib/pq"
+
+	"chain/core/query"
+	"chain/core/signers"
 	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
 	"chain/errors"
+	"chain/protocol/vm/vmutil"
 )
 
 const defaultReceiverExpiry = 30 * 24 * time.Hour // 30 days
@@ -36,3 +47,156 @@ func (m *Manager) CreateReceiver(ctx context.Context, accID, accAlias string, ex
 		ExpiresAt:      expiresAt,
 	}, nil
 }
+
+// ReceiverKey describes how the control program of one of an
+// account's receivers is derived from the account's root xpubs, so
+// that a wallet holding those xpubs can check that the control
+// program belongs to the account before paying to it.
+//
+// If HardenedLevels is not zero, the first HardenedLevels elements
+// of DerivationPath are derived with hardened derivation, and the
+// rest of the path is derived from AccountXPubs instead of
+// RootXPubs.
+type ReceiverKey struct {
+	AccountID      string               `json:"account_id"`
+	KeyIndex       uint64               `json:"key_index"`
+	DerivationPath []chainjson.HexBytes `json:"derivation_path"`
+	HardenedLevels int                  `json:"hardened_levels,omitempty"`
+	RootXPubs      []chainkd.XPub       `json:"root_xpubs"`
+	AccountXPubs   []chainkd.XPub       `json:"account_xpubs,omitempty"`
+	DerivedXPubs   []chainkd.XPub       `json:"derived_xpubs"`
+	Pubkeys        []chainjson.HexBytes `json:"pubkeys"`
+	Quorum         int                  `json:"quorum"`
+	ControlProgram chainjson.HexBytes   `json:"control_program"`
+	ExpiresAt      *time.Time           `json:"expires_at,omitempty"`
+}
+
+// receiverKey derives the keys and control program of account at
+// key index idx.
+func receiverKey(account *signers.Signer, idx uint64) (*ReceiverKey, error) {
+	path := signers.Path(account, signers.AccountKeySpace, idx)
+	derivedXPubs := account.DeriveXPubs(path)
+	derivedPKs := chainkd.XPubKeys(derivedXPubs)
+	control, err := vmutil.P2SPMultiSigProgram(derivedPKs, account.Quorum)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &ReceiverKey{
+		AccountID:      account.ID,
+		KeyIndex:       idx,
+		HardenedLevels: account.HardenedLevels(),
+		RootXPubs:      account.XPubs,
+		AccountXPubs:   account.HardenedXPubs,
+		DerivedXPubs:   derivedXPubs,
+		Quorum:         account.Quorum,
+		ControlProgram: control,
+	}
+	for _, p := range path {
+		key.DerivationPath = append(key.DerivationPath, p)
+	}
+	for _, pk := range derivedPKs {
+		key.Pubkeys = append(key.Pubkeys, chainjson.HexBytes(pk))
+	}
+	return key, nil
+}
+
+// find returns the account identified by exactly one of id and
+// alias.
+func (m *Manager) find(ctx context.Context, id, alias string) (*signers.Signer, error) {
+	if (id == "") == (alias == "") {
+		return nil, errors.Wrap(ErrBadIdentifier)
+	}
+	if alias != "" {
+		return m.FindByAlias(ctx, alias)
+	}
+	return m.findByID(ctx, id)
+}
+
+// DeriveKey derives the keys and control program of the account at
+// the given key index. It doesn't create a receiver; payments to
+// the control program are only tracked if the account already has
+// a receiver for it.
+func (m *Manager) DeriveKey(ctx context.Context, accID, accAlias string, keyIndex uint64) (*ReceiverKey, error) {
+	account, err := m.find(ctx, accID, accAlias)
+	if err != nil {
+		return nil, err
+	}
+	return receiverKey(account, keyIndex)
+}
+
+// FindReceiverKey looks up the account control program prog and
+// returns how it was derived. It returns pg.ErrUserInputNotFound if
+// prog is not a control program of the account.
+func (m *Manager) FindReceiverKey(ctx context.Context, accID, accAlias string, prog []byte) (*ReceiverKey, error) {
+	account, err := m.find(ctx, accID, accAlias)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		idx       uint64
+		expiresAt pq.NullTime
+	)
+	const q = `
+		SELECT key_index, expires_at FROM account_control_programs
+		WHERE signer_id=$1 AND control_program=$2
+	`
+	err = m.db.QueryRowContext(ctx, q, account.ID, prog).Scan(&idx, &expiresAt)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "control program %x is not in account %s", prog, account.ID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return receiverKeyWithExpiry(account, idx, expiresAt)
+}
+
+// ListReceivers returns the account's receivers, newest first,
+// starting after the receiver with key index after. Change control
+// programs are not included.
+func (m *Manager) ListReceivers(ctx context.Context, accID, accAlias, after string, limit int) ([]*ReceiverKey, string, error) {
+	account, err := m.find(ctx, accID, accAlias)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var zafter int64 = math.MaxInt64
+	if after != "" {
+		zafter, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(query.ErrBadAfter, "value: %q", after)
+		}
+	}
+
+	const q = `
+		SELECT key_index, expires_at FROM account_control_programs
+		WHERE signer_id=$1 AND NOT change AND key_index < $2
+		ORDER BY key_index DESC LIMIT $3
+	`
+	keys := make([]*ReceiverKey, 0, limit)
+	err = pg.ForQueryRows(ctx, m.db, q, account.ID, zafter, limit, func(idx uint64, expiresAt pq.NullTime) error {
+		key, err := receiverKeyWithExpiry(account, idx, expiresAt)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		after = strconv.FormatUint(idx, 10)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return keys, after, nil
+}
+
+func receiverKeyWithExpiry(account *signers.Signer, idx uint64, expiresAt pq.NullTime) (*ReceiverKey, error) {
+	key, err := receiverKey(account, idx)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	return key, nil
+}
@@ -1,12 +1,15 @@
 package account
 
 import (
+	"bytes"
 	"context"
 	"testing"
 	"time"
 
 	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/prottest"
 	"chain/testutil"
 )
@@ -17,7 +20,7 @@ func TestCreateReceiver(t *testing.T) {
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
 
-	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "alias", nil, "")
+	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, nil, "alias", nil, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -33,3 +36,64 @@ func TestCreateReceiver(t *testing.T) {
 		testutil.FatalErr(t, err)
 	}
 }
+
+func TestReceiverKeys(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	prefix := [][]byte{{0x2c}, {0x00, 0x01}}
+	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, prefix, nil, "alias", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	var progs [][]byte
+	for i := 0; i < 3; i++ {
+		r, err := m.CreateReceiver(ctx, account.ID, "", time.Time{})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		progs = append(progs, r.ControlProgram)
+	}
+
+	key, err := m.FindReceiverKey(ctx, "", "alias", progs[1])
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !bytes.Equal(key.ControlProgram, progs[1]) || key.ExpiresAt == nil {
+		t.Errorf("FindReceiverKey got %+v, want control program %x with an expiry", key, progs[1])
+	}
+	if len(key.DerivationPath) != 3 || !bytes.Equal(key.DerivationPath[0], prefix[0]) || !bytes.Equal(key.DerivationPath[1], prefix[1]) {
+		t.Errorf("derivation path = %x, want prefix %x", key.DerivationPath, prefix)
+	}
+
+	// Anyone with the root xpub can derive the same control program.
+	derived, err := m.DeriveKey(ctx, account.ID, "", key.KeyIndex)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !bytes.Equal(derived.ControlProgram, progs[1]) {
+		t.Errorf("DeriveKey got control program %x, want %x", derived.ControlProgram, progs[1])
+	}
+
+	_, err = m.FindReceiverKey(ctx, account.ID, "", []byte{0x51})
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("FindReceiverKey of unknown program got error %v, want %v", err, pg.ErrUserInputNotFound)
+	}
+
+	keys, after, err := m.ListReceivers(ctx, account.ID, "", "", 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(keys) != 2 || !bytes.Equal(keys[0].ControlProgram, progs[2]) || !bytes.Equal(keys[1].ControlProgram, progs[1]) {
+		t.Fatalf("ListReceivers got %+v, want receivers 2 and 1", keys)
+	}
+	keys, _, err = m.ListReceivers(ctx, account.ID, "", after, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(keys) != 1 || !bytes.Equal(keys[0].ControlProgram, progs[0]) {
+		t.Fatalf("ListReceivers second page got %+v, want receiver 0", keys)
+	}
+}
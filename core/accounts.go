@@ -5,7 +5,9 @@ import (
 	"sync"
 
 	"chain/core/account"
+	"chain/core/signers"
 	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
 	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 )
@@ -17,6 +19,28 @@ func (a *API) createAccount(ctx context.Context, ins []struct {
 	Alias     string
 	Tags      map[string]interface{}
 
+	// PathPrefix, if set, is the start of the derivation path of
+	// every key of the account. It's intended for root xpubs from
+	// external wallets that expect keys at particular paths.
+	PathPrefix []chainjson.HexBytes `json:"path_prefix"`
+
+	// AccountXPubs, if set, makes path_prefix a hardened path:
+	// each account xpub must be the root xpub at the same position
+	// derived along path_prefix with hardened derivation, as by
+	// /mockhsm/derive-xpub. The account's keys are derived from the
+	// account xpubs, so the leak of one of its private keys
+	// doesn't expose the root xpubs' other keys.
+	AccountXPubs []chainkd.XPub `json:"account_xpubs"`
+
+	// AccountXPubProofs are needed when this Core doesn't hold the
+	// private keys of the root xpubs, in its MockHSM or keystore,
+	// so it can't check the account xpubs itself. Each proof is a
+	// signature, by the private key of the root xpub at the same
+	// position, of signers.HardenedXPubProofHash; a remote signer
+	// makes it when asked to sign that hash with the root xpub and
+	// an empty derivation path.
+	AccountXPubProofs []chainjson.HexBytes `json:"account_xpub_proofs"`
+
 	// ClientToken is the application's unique token for the account. Every account
 	// should have a unique client token. The client token is used to ensure
 	// idempotency of create account requests. Duplicate create account requests
@@ -33,7 +57,20 @@ func (a *API) createAccount(ctx context.Context, ins []struct {
 			defer wg.Done()
 			defer batchRecover(subctx, &responses[i])
 
-			acc, err := a.accounts.Create(subctx, ins[i].RootXPubs, ins[i].Quorum, ins[i].Alias, ins[i].Tags, ins[i].ClientToken)
+			var pathPrefix [][]byte
+			for _, p := range ins[i].PathPrefix {
+				pathPrefix = append(pathPrefix, p)
+			}
+			var proofs [][]byte
+			for _, p := range ins[i].AccountXPubProofs {
+				proofs = append(proofs, p)
+			}
+			err := signers.CheckHardenedXPubs(subctx, ins[i].RootXPubs, pathPrefix, ins[i].AccountXPubs, proofs, a.hardenedXPubs)
+			if err != nil {
+				responses[i] = err
+				return
+			}
+			acc, err := a.accounts.Create(subctx, ins[i].RootXPubs, ins[i].Quorum, pathPrefix, ins[i].AccountXPubs, ins[i].Alias, ins[i].Tags, ins[i].ClientToken)
 			if err != nil {
 				responses[i] = err
 				return
@@ -67,7 +104,11 @@ func (a *API) updateAccountTags(ctx context.Context, ins []struct {
 			defer wg.Done()
 			defer batchRecover(subctx, &responses[i])
 
-			err := a.accounts.UpdateTags(subctx, ins[i].ID, ins[i].Alias, ins[i].Tags)
+			field, value := scopeRef(ins[i].ID, ins[i].Alias)
+			err := a.checkAccountScope(subctx, field, value)
+			if err == nil {
+				err = a.accounts.UpdateTags(subctx, ins[i].ID, ins[i].Alias, ins[i].Tags)
+			}
 			if err != nil {
 				responses[i] = err
 			} else {
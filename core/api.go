@@ -3,8 +3,6 @@ package core
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"crypto/x509/pkix"
 	"expvar"
 	"fmt"
@@ -16,16 +14,21 @@ import (
 	"chain/core/accesstoken"
 	"chain/core/account"
 	"chain/core/asset"
+	"chain/core/audit"
 	"chain/core/config"
 	"chain/core/fetch"
 	"chain/core/generator"
 	"chain/core/leader"
+	"chain/core/light"
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/rpc"
+	"chain/core/signers"
+	"chain/core/signsession"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
+	"chain/core/txsigner"
 	"chain/database/pg"
 	"chain/database/sinkdb"
 	"chain/encoding/json"
@@ -40,6 +43,7 @@ import (
 	"chain/net/http/static"
 	"chain/protocol"
 	"chain/protocol/bc/legacy"
+	"chain/protocol/state"
 )
 
 const (
@@ -64,7 +68,9 @@ type API struct {
 	accounts        *account.Manager
 	indexer         *query.Indexer
 	txFeeds         *txfeed.Tracker
+	signSessions    *signsession.Store
 	accessTokens    *accesstoken.CredentialStore
+	audit           *audit.Store
 	grants          *authz.Store
 	config          *config.Config
 	options         *config.Options
@@ -79,6 +85,10 @@ type API struct {
 	requestLimits   []requestLimit
 	generator       *generator.Generator
 	replicator      *fetch.Replicator
+	light           *light.Syncer
+	txSigner        *txsigner.Client
+	hardenedXPubs   []signers.HardenedDeriver // local keys for checking account xpubs
+	lightClient     bool
 	remoteGenerator *rpc.Client
 	indexTxs        bool
 	internalSubj    pkix.Name
@@ -87,6 +97,12 @@ type API struct {
 	downloadingSnapshotMu sync.Mutex
 	downloadingSnapshot   *fetch.SnapshotProgress
 
+	// pastStateMu serializes reconstructing past states, and
+	// guards pastBlock and pastState, the last one reconstructed.
+	pastStateMu sync.Mutex
+	pastBlock   *legacy.Block
+	pastState   *state.Snapshot
+
 	healthMu     sync.Mutex
 	healthErrors map[string]string
 }
@@ -106,8 +122,9 @@ type requestLimit struct {
 	perSecond int
 }
 
+const maxReqSize = 1e7 // 10MB
+
 func maxBytes(h http.Handler) http.Handler {
-	const maxReqSize = 1e7 // 10MB
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		// A block can easily be bigger than maxReqSize, but everything
 		// else should be pretty small.
@@ -145,27 +162,44 @@ func (a *API) buildHandler() {
 	m.Handle("/update-asset-tags", needConfig(a.updateAssetTags))
 	m.Handle("/build-transaction", needConfig(a.build))
 	m.Handle("/submit-transaction", needConfig(a.submit))
+	m.Handle("/sign-transaction", needConfig(a.signTemplates))
 	m.Handle("/create-control-program", needConfig(a.createControlProgram)) // DEPRECATED
 	m.Handle("/create-account-receiver", needConfig(a.createAccountReceiver))
+	m.Handle("/derive-account-key", needConfig(a.deriveAccountKey))
+	m.Handle("/list-account-receivers", needConfig(a.listAccountReceivers))
 	m.Handle("/create-transaction-feed", needConfig(a.createTxFeed))
 	m.Handle("/get-transaction-feed", needConfig(a.getTxFeed))
 	m.Handle("/update-transaction-feed", needConfig(a.updateTxFeed))
 	m.Handle("/delete-transaction-feed", needConfig(a.deleteTxFeed))
+	m.Handle("/create-signing-session", needConfig(a.createSigningSession))
+	m.Handle("/get-signing-session", needConfig(a.getSigningSession))
+	m.Handle("/list-signing-sessions", needConfig(a.listSigningSessions))
+	m.Handle("/add-signing-session-signatures", needConfig(a.addSigningSessionSignatures))
 	m.Handle("/mockhsm", alwaysError(errNoMockHSM))
+	m.Handle("/keystore", alwaysError(errNoKeystore))
 	m.Handle("/list-accounts", needConfig(a.listAccounts))
 	m.Handle("/list-assets", needConfig(a.listAssets))
+	m.Handle("/get-asset-supply", needConfig(a.getAssetSupply))
 	m.Handle("/list-transaction-feeds", needConfig(a.listTxFeeds))
 	m.Handle("/list-transactions", needConfig(a.listTransactions))
 	m.Handle("/list-balances", needConfig(a.listBalances))
 	m.Handle("/list-unspent-outputs", needConfig(a.listUnspentOutputs))
+	m.Handle("/get-transaction-proof", needConfig(a.getTransactionProof))
+	m.Handle("/get-output-proof", needConfig(a.getOutputProof))
+	m.Handle("/get-block-header", needConfig(a.getBlockHeader))
+	m.Handle("/list-watched-transactions", needConfig(a.listWatchedTransactions))
 	m.Handle("/reset", resetAllowed(needConfig(a.reset)))
 
 	m.Handle(crosscoreRPCPrefix+"submit", needConfig(func(ctx context.Context, tx *legacy.Tx) error {
 		return a.submitter.Submit(ctx, tx)
 	}))
 	m.Handle(crosscoreRPCPrefix+"get-block", needConfig(a.getBlockRPC))
+	m.Handle(crosscoreRPCPrefix+"get-blocks", http.HandlerFunc(a.getBlocksRPC))
+	m.Handle(crosscoreRPCPrefix+"get-block-headers", needConfig(a.getBlockHeadersRPC))
 	m.Handle(crosscoreRPCPrefix+"get-snapshot-info", needConfig(a.getSnapshotInfoRPC))
 	m.Handle(crosscoreRPCPrefix+"get-snapshot", http.HandlerFunc(a.getSnapshotRPC))
+	m.Handle(crosscoreRPCPrefix+"get-snapshot-manifest", needConfig(a.getSnapshotManifestRPC))
+	m.Handle(crosscoreRPCPrefix+"get-snapshot-chunk", http.HandlerFunc(a.getSnapshotChunkRPC))
 	m.Handle(crosscoreRPCPrefix+"signer/sign-block", needConfig(a.leaderSignHandler(a.signer)))
 	m.Handle(crosscoreRPCPrefix+"block-height", needConfig(func(ctx context.Context) map[string]uint64 {
 		h := a.chain.Height()
@@ -180,10 +214,16 @@ func (a *API) buildHandler() {
 	m.Handle("/create-access-token", jsonHandler(a.createAccessToken))
 	m.Handle("/list-access-tokens", jsonHandler(a.listAccessTokens))
 	m.Handle("/delete-access-token", jsonHandler(a.deleteAccessToken))
+	m.Handle("/rotate-access-token", jsonHandler(a.rotateAccessToken))
+	m.Handle("/list-audit-events", jsonHandler(a.listAuditEvents))
 	m.Handle("/add-allowed-member", jsonHandler(a.addAllowedMember))
 	m.Handle("/init-cluster", jsonHandler(a.initCluster))
 	m.Handle("/join-cluster", jsonHandler(a.joinCluster))
 	m.Handle("/evict", jsonHandler(a.evict))
+	m.Handle("/raft-status", jsonHandler(a.raftStatus))
+	m.Handle("/transfer-leadership", jsonHandler(a.transferLeadership))
+	m.Handle("/sinkdb-backup", jsonHandler(a.sinkdbBackup))
+	m.Handle("/sinkdb-restore", jsonHandler(a.sinkdbRestore))
 	m.Handle("/configure", jsonHandler(a.configure))
 	m.Handle("/config", jsonHandler(a.retrieveConfig))
 	m.Handle("/info", jsonHandler(a.info))
@@ -207,6 +247,9 @@ func (a *API) buildHandler() {
 	for _, l := range a.requestLimits {
 		handler = limit.Handler(handler, alwaysError(errRateLimited), l.perSecond, l.burst, l.key)
 	}
+	if a.options != nil {
+		handler = newRequestLimiter(a.db, a.options.ListFunc("request_limit")).handler(handler)
+	}
 	handler = gzip.Handler{Handler: handler}
 	handler = coreCounter(handler)
 	handler = timeoutContextHandler(handler)
@@ -246,8 +289,22 @@ type requestQuery struct {
 	// Value must be "client" or "network"
 	Type string `json:"type"`
 
+	// These identify the account for /list-account-receivers
+	AccountID    string `json:"account_id,omitempty"`
+	AccountAlias string `json:"account_alias,omitempty"`
+
 	// Aliases is used to filter results from /mockshm/list-keys
+	// and /mockhsm/list-sign-events
 	Aliases []string `json:"aliases,omitempty"`
+
+	// These filter results from /list-audit-events, along with
+	// StartTimeMS and EndTimeMS.
+	Route         string `json:"route,omitempty"`
+	RequestID     string `json:"request_id,omitempty"`
+	AccessTokenID string `json:"access_token,omitempty"`
+	CertSubject   string `json:"cert_subject,omitempty"`
+	JWTSubject    string `json:"jwt_subject,omitempty"`
+	Outcome       string `json:"outcome,omitempty"`
 }
 
 // Used as a response object for api queries
@@ -257,23 +314,19 @@ type page struct {
 	LastPage bool         `json:"last_page"`
 }
 
-func AuthHandler(handler http.Handler, sdb *sinkdb.DB, accessTokens *accesstoken.CredentialStore, tlsConfig *tls.Config, extraGrants []*authz.Grant) http.Handler {
+func AuthHandler(handler http.Handler, sdb *sinkdb.DB, accessTokens *accesstoken.CredentialStore, tlsFiles *TLSReloader, jwt *authn.JWTVerifier, extraGrants []*authz.Grant) http.Handler {
 	var subj *pkix.Name
-	rootCAs := x509.NewCertPool()
-	if tlsConfig != nil {
-		x509Cert, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
-		if err != nil {
-			log.Fatalkv(context.Background(), log.KeyError, err)
-		}
-		subj = &x509Cert.Subject
-		rootCAs = tlsConfig.ClientCAs
+	var certs authn.CertVerifier
+	if tlsFiles != nil {
+		subj = &tlsFiles.Leaf().Subject
+		certs = tlsFiles
 	}
 
 	authorizer := authz.NewAuthorizer(
 		grantStore(sdb, extraGrants, subj),
 		policyByRoute,
 	)
-	authenticator := authn.NewAPI(accessTokens, crosscoreRPCPrefix, rootCAs)
+	authenticator := authn.NewAPI(accessTokens, crosscoreRPCPrefix, certs, jwt)
 
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		// TODO(tessr): check that this path exists; return early if this path isn't legit
@@ -283,12 +336,18 @@ func AuthHandler(handler http.Handler, sdb *sinkdb.DB, accessTokens *accesstoken
 			errorFormatter.Write(req.Context(), rw, err)
 			return
 		}
+		auditCaller(req.Context())
 
-		err = authorizer.Authorize(req)
+		req, err = authorizer.Authorize(req)
 		if err != nil {
 			errorFormatter.Write(req.Context(), rw, err)
 			return
 		}
+		if _, scoped := authz.Scopes(req.Context()); scoped && !scopedRoutes[req.URL.Path] {
+			err = errors.WithDetail(errOutOfScope, "this route can't be used with a scoped grant")
+			errorFormatter.Write(req.Context(), rw, err)
+			return
+		}
 		handler.ServeHTTP(rw, req)
 	})
 }
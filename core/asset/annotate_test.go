@@ -25,14 +25,14 @@ func TestAnnotateTxs(t *testing.T) {
 	rawdef1 := json.RawMessage(`{
   "baz": "bar"
 }`)
-	asset1, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, def1, "", tags1, "")
+	asset1, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, def1, "", tags1, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	tags2 := map[string]interface{}{"foo": "baz"}
 	rawtags2 := json.RawMessage(`{"foo": "baz"}`)
-	asset2, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", tags2, "")
+	asset2, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, nil, "", tags2, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -71,6 +71,7 @@ type Asset struct {
 	IssuanceProgram  []byte
 	InitialBlockHash bc.Hash
 	Signer           *signers.Signer
+	IssuanceRules    *IssuanceRules
 	Tags             map[string]interface{}
 	rawDefinition    []byte
 	definition       map[string]interface{}
@@ -101,24 +102,55 @@ func (asset *Asset) SetDefinition(def map[string]interface{}) error {
 	return nil
 }
 
-// Define defines a new Asset.
-func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum int, definition map[string]interface{}, alias string, tags map[string]interface{}, clientToken string) (*Asset, error) {
-	assetSigner, err := signers.Create(ctx, reg.db, "asset", xpubs, quorum, clientToken)
-	if err != nil {
+// Define defines a new Asset. If rules is not nil, the asset's
+// issuance program enforces them.
+//
+// An asset with a custom issuance program in rules.Program needs no
+// xpubs. If it has them, issue actions for it will sign with them,
+// using the derivation path reported in its annotated keys.
+func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum int, rules *IssuanceRules, definition map[string]interface{}, alias string, tags map[string]interface{}, clientToken string) (*Asset, error) {
+	if rules.empty() {
+		rules = nil
+	} else if err := rules.validate(len(xpubs)); err != nil {
 		return nil, err
 	}
 
+	var assetSigner *signers.Signer
+	if rules == nil || len(rules.Program) == 0 || len(xpubs) > 0 {
+		var err error
+		assetSigner, err = signers.Create(ctx, reg.db, "asset", xpubs, quorum, nil, nil, clientToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	rawDefinition, err := serializeAssetDef(definition)
 	if err != nil {
 		return nil, errors.Wrap(err, "serializing asset definition")
 	}
 
-	path := signers.Path(assetSigner, signers.AssetKeySpace)
-	derivedXPubs := chainkd.DeriveXPubs(assetSigner.XPubs, path)
-	derivedPKs := chainkd.XPubKeys(derivedXPubs)
-	issuanceProgram, vmver, err := multisigIssuanceProgram(derivedPKs, assetSigner.Quorum)
-	if err != nil {
-		return nil, err
+	var (
+		issuanceProgram []byte
+		vmver           uint64 = 1
+	)
+	if rules != nil && len(rules.Program) > 0 {
+		issuanceProgram = rules.Program
+	} else {
+		path := signers.Path(assetSigner, signers.AssetKeySpace)
+		derivedXPubs := chainkd.DeriveXPubs(assetSigner.XPubs, path)
+		derivedPKs := chainkd.XPubKeys(derivedXPubs)
+		if rules == nil {
+			issuanceProgram, vmver, err = multisigIssuanceProgram(derivedPKs, assetSigner.Quorum)
+		} else {
+			var delegatePKs []ed25519.PublicKey
+			if rules.Delegate != nil {
+				delegatePKs = chainkd.XPubKeys(chainkd.DeriveXPubs(rules.Delegate.XPubs, path))
+			}
+			issuanceProgram, err = rulesIssuanceProgram(derivedPKs, assetSigner.Quorum, rules, delegatePKs)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	defhash := bc.NewHash(sha3.Sum256(rawDefinition))
@@ -130,6 +162,7 @@ func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum in
 		InitialBlockHash: reg.initialBlockHash,
 		AssetID:          bc.ComputeAssetID(issuanceProgram, &reg.initialBlockHash, vmver, &defhash),
 		Signer:           assetSigner,
+		IssuanceRules:    rules,
 		Tags:             tags,
 	}
 	if alias != "" {
@@ -267,8 +300,8 @@ func (reg *Registry) FindByAlias(ctx context.Context, alias string) (*Asset, err
 func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken string) (*Asset, error) {
 	const q = `
 		INSERT INTO assets
-			(id, alias, signer_id, initial_block_hash, vm_version, issuance_program, definition, client_token)
-		VALUES($1::bytea, $2, $3, $4, $5, $6, $7, $8)
+			(id, alias, signer_id, initial_block_hash, vm_version, issuance_program, definition, client_token, issuance_rules)
+		VALUES($1::bytea, $2, $3, $4, $5, $6, $7, $8, $9::jsonb)
 		ON CONFLICT (client_token) DO NOTHING
 		RETURNING sort_id
   `
@@ -282,11 +315,20 @@ func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken
 		Valid:  clientToken != "",
 	}
 
+	var rules sql.NullString
+	if asset.IssuanceRules != nil {
+		b, err := json.Marshal(asset.IssuanceRules)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		rules = sql.NullString{String: string(b), Valid: true}
+	}
+
 	err := reg.db.QueryRowContext(
 		ctx, q,
 		asset.AssetID, asset.Alias, signerID,
 		asset.InitialBlockHash, asset.VMVersion, asset.IssuanceProgram,
-		asset.rawDefinition, nullToken,
+		asset.rawDefinition, nullToken, rules,
 	).Scan(&asset.sortID)
 
 	if pg.IsUniqueViolation(err) {
@@ -335,7 +377,7 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 			assets.initial_block_hash, assets.sort_id,
 			signers.id, COALESCE(signers.type, ''), COALESCE(signers.xpubs, '{}'),
 			COALESCE(signers.quorum, 0), COALESCE(signers.key_index, 0),
-			asset_tags.tags
+			asset_tags.tags, assets.issuance_rules
 		FROM assets
 		LEFT JOIN signers ON signers.id=assets.signer_id
 		LEFT JOIN asset_tags ON asset_tags.asset_id=assets.id
@@ -351,6 +393,7 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		keyIndex   uint64
 		xpubs      [][]byte
 		tags       []byte
+		rules      []byte
 	)
 	err := db.QueryRowContext(ctx, fmt.Sprintf(baseQ, pred), args...).Scan(
 		&a.AssetID,
@@ -366,6 +409,7 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		&quorum,
 		&keyIndex,
 		&tags,
+		&rules,
 	)
 	if err == sql.ErrNoRows {
 		return nil, pg.ErrUserInputNotFound
@@ -390,6 +434,12 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 			return nil, errors.Wrap(err)
 		}
 	}
+	if len(rules) > 0 {
+		err := json.Unmarshal(rules, &a.IssuanceRules)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding issuance rules")
+		}
+	}
 	if len(a.rawDefinition) > 0 {
 		// ignore errors; non-JSON asset definitions can still end up
 		// on the blockchain from non-Chain Core clients.
@@ -18,7 +18,7 @@ func TestDefineAsset(t *testing.T) {
 	ctx := context.Background()
 
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, "")
+	asset, err := r.Define(ctx, keys, 1, nil, nil, "", nil, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -43,11 +43,11 @@ func TestDefineAssetIdempotency(t *testing.T) {
 	ctx := context.Background()
 	token := "test_token"
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset0, err := r.Define(ctx, keys, 1, nil, "alias", nil, token)
+	asset0, err := r.Define(ctx, keys, 1, nil, nil, "alias", nil, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
-	asset1, err := r.Define(ctx, keys, 1, nil, "alias", nil, token)
+	asset1, err := r.Define(ctx, keys, 1, nil, nil, "alias", nil, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -62,7 +62,7 @@ func TestFindAssetByID(t *testing.T) {
 	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
 	ctx := context.Background()
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, "")
+	asset, err := r.Define(ctx, keys, 1, nil, nil, "", nil, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -82,7 +82,7 @@ func TestAssetByClientToken(t *testing.T) {
 	keys := []chainkd.XPub{testutil.TestXPub}
 	token := "test_token"
 
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, token)
+	asset, err := r.Define(ctx, keys, 1, nil, nil, "", nil, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -54,6 +54,14 @@ func Annotated(a *Asset) (*query.AnnotatedAsset, error) {
 	if a.Alias != nil {
 		aa.Alias = *a.Alias
 	}
+	if a.IssuanceRules != nil {
+		b, err := json.Marshal(a.IssuanceRules)
+		if err != nil {
+			return nil, err
+		}
+		rules := json.RawMessage(b)
+		aa.IssuanceRules = &rules
+	}
 	if a.Signer != nil {
 		path := signers.Path(a.Signer, signers.AssetKeySpace)
 		var jsonPath []chainjson.HexBytes
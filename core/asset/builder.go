@@ -13,6 +13,7 @@ import (
 	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
+	"chain/protocol/vm"
 )
 
 func (reg *Registry) NewIssueAction(assetAmount bc.AssetAmount, referenceData chainjson.Map) txbuilder.Action {
@@ -33,6 +34,14 @@ type issueAction struct {
 	assets *Registry
 	bc.AssetAmount
 	ReferenceData chainjson.Map `json:"reference_data"`
+
+	// Delegate says to issue with the keys of the asset's delegate
+	// instead of its signers.
+	Delegate bool `json:"delegate"`
+
+	// Arguments are the witness arguments for an asset with a custom
+	// issuance program, added after any signatures.
+	Arguments []chainjson.HexBytes `json:"arguments"`
 }
 
 func (a *issueAction) Build(ctx context.Context, builder *txbuilder.TemplateBuilder) error {
@@ -58,10 +67,72 @@ func (a *issueAction) Build(ctx context.Context, builder *txbuilder.TemplateBuil
 
 	txin := legacy.NewIssuanceInput(nonce[:], a.Amount, a.ReferenceData, asset.InitialBlockHash, asset.IssuanceProgram, nil, assetdef)
 
+	rules := asset.IssuanceRules
+	if rules == nil {
+		rules = new(IssuanceRules)
+	}
+	if a.Delegate && rules.Delegate == nil {
+		return errors.WithDetailf(ErrNoDelegate, "asset %x has no delegate", a.AssetId.Bytes())
+	}
+	if len(a.Arguments) > 0 && len(rules.Program) == 0 {
+		return errors.WithDetail(ErrBadIssuanceRules, "arguments are only allowed for assets with a custom issuance program")
+	}
+
+	now := time.Now()
+	if rules.NotBefore != nil && now.Before(*rules.NotBefore) {
+		return errors.WithDetailf(ErrIssuanceWindow, "asset can't be issued before %s", rules.NotBefore)
+	}
+	if rules.NotAfter != nil {
+		if !now.Before(*rules.NotAfter) {
+			return errors.WithDetailf(ErrIssuanceWindow, "asset can't be issued after %s", rules.NotAfter)
+		}
+		builder.RestrictMaxTime(*rules.NotAfter)
+	}
+
 	tplIn := &txbuilder.SigningInstruction{}
-	path := signers.Path(asset.Signer, signers.AssetKeySpace)
-	tplIn.AddWitnessKeys(asset.Signer.XPubs, path, asset.Signer.Quorum)
+	if asset.Signer != nil {
+		path := signers.Path(asset.Signer, signers.AssetKeySpace)
+		if a.Delegate {
+			tplIn.AddWitnessKeys(rules.Delegate.XPubs, path, rules.Delegate.Quorum)
+		} else {
+			tplIn.AddWitnessKeys(asset.Signer.XPubs, path, asset.Signer.Quorum)
+		}
+	}
+	tplIn.Arguments = a.Arguments
+
+	// The arguments for rulesIssuanceProgram go in reverse order of
+	// consumption: first the choice of delegate, then the index of
+	// the supply retirement, which is known only once every action
+	// is built.
+	if rules.Delegate != nil {
+		if a.Delegate && a.Amount > rules.Delegate.MaxAmount {
+			return errors.WithDetailf(ErrDelegateLimit, "delegate may issue at most %d", rules.Delegate.MaxAmount)
+		}
+		tplIn.Arguments = append(tplIn.Arguments, vm.BoolBytes(a.Delegate))
+	}
+	if rules.SupplyAssetID != nil {
+		retirement := legacy.NewTxOutput(*rules.SupplyAssetID, a.Amount, retirementProgram, nil)
+		err = builder.AddOutput(retirement)
+		if err != nil {
+			return err
+		}
+		builder.OnBuild(func() error {
+			index := builder.OutputIndex(retirement)
+			tplIn.Arguments = append(tplIn.Arguments, vm.Int64Bytes(int64(index)))
+			return nil
+		})
+		builder.OnTx(func(tx *legacy.TxData) error {
+			for i, in := range tx.Inputs {
+				if in == txin {
+					id := legacy.MapTx(tx).InputIDs[i]
+					retirement.ReferenceData = id.Bytes()
+					return nil
+				}
+			}
+			return errors.New("issuance input missing from transaction")
+		})
+	}
 
-	builder.RestrictMinTime(time.Now())
+	builder.RestrictMinTime(now)
 	return builder.AddInput(txin, tplIn)
 }
@@ -0,0 +1,166 @@
+package asset
+
+import (
+	"math"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/protocol/vm/vmutil"
+)
+
+var (
+	ErrBadIssuanceRules = errors.New("invalid issuance rules")
+	ErrIssuanceWindow   = errors.New("asset cannot be issued at this time")
+	ErrNoDelegate       = errors.New("asset has no delegate")
+	ErrDelegateLimit    = errors.New("amount exceeds delegate issuance limit")
+)
+
+// IssuanceRules constrain the issuance of an asset beyond requiring
+// a quorum of its signers. They're compiled into the asset's
+// issuance program, so they're enforced by every node validating the
+// blockchain, not just by the Core that defined the asset.
+type IssuanceRules struct {
+	// NotBefore and NotAfter, if set, bound the times at which the
+	// asset may be issued.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+
+	// Delegate, if set, is a second set of keys that may issue a
+	// limited amount of the asset at a time, without the signers.
+	Delegate *Delegate `json:"delegate,omitempty"`
+
+	// SupplyAssetID, if set, caps the total supply of the asset.
+	// Each unit issued must be paid for by retiring a unit of the
+	// supply asset in the same transaction, so no more of the asset
+	// can ever exist than there were units of the supply asset.
+	//
+	// The retirement's reference data must be the entry ID of the
+	// issuance it pays for, so one retirement can't pay for several
+	// issuances. Issue actions for the asset add the retirement, so
+	// the transaction must also spend the supply asset, e.g. with a
+	// spend_account action. The entry ID covers the transaction's
+	// time range, which therefore mustn't change after the issue
+	// action is built.
+	//
+	// For the cap to mean anything, the supply asset must not be
+	// issuable again once its units are issued. Give it a NotAfter
+	// time in the near future and issue all of it before then.
+	SupplyAssetID *bc.AssetID `json:"supply_asset_id,omitempty"`
+
+	// Program, if set, is the issuance program to use instead of
+	// one compiled from the other rules, which must be empty. It
+	// may be, for instance, a compiled Ivy contract. Issue actions
+	// for the asset supply its witness arguments.
+	Program chainjson.HexBytes `json:"program,omitempty"`
+}
+
+// Delegate is a set of keys allowed to issue up to MaxAmount units
+// of an asset in each issuance. The blockchain can't track how much
+// a delegate has issued over time; to limit that too, combine a
+// delegate with a supply asset and give the delegate only as many
+// units of the supply asset as it may issue, e.g., each day.
+type Delegate struct {
+	XPubs     []chainkd.XPub `json:"root_xpubs"`
+	Quorum    int            `json:"quorum"`
+	MaxAmount uint64         `json:"max_amount"`
+}
+
+// retirementProgram is the control program of retirement outputs.
+var retirementProgram = []byte{byte(vm.OP_FAIL)}
+
+func (r *IssuanceRules) validate(nxpubs int) error {
+	if len(r.Program) > 0 {
+		if r.NotBefore != nil || r.NotAfter != nil || r.Delegate != nil || r.SupplyAssetID != nil {
+			return errors.WithDetail(ErrBadIssuanceRules, "a custom program can't be combined with other issuance rules")
+		}
+		_, err := vm.ParseProgram(r.Program)
+		if err != nil {
+			return errors.WithDetailf(ErrBadIssuanceRules, "custom program: %s", err)
+		}
+		return nil
+	}
+	if nxpubs == 0 {
+		return errors.WithDetail(ErrBadIssuanceRules, "root xpubs are required unless there's a custom program")
+	}
+	if r.NotBefore != nil && r.NotAfter != nil && !r.NotAfter.After(*r.NotBefore) {
+		return errors.WithDetail(ErrBadIssuanceRules, "not_after must be later than not_before")
+	}
+	if d := r.Delegate; d != nil {
+		if len(d.XPubs) == 0 || d.Quorum < 1 || d.Quorum > len(d.XPubs) {
+			return errors.WithDetail(ErrBadIssuanceRules, "delegate quorum must be between 1 and the number of delegate xpubs")
+		}
+		if d.MaxAmount == 0 || d.MaxAmount > math.MaxInt64 {
+			return errors.WithDetail(ErrBadIssuanceRules, "delegate max_amount must be between 1 and 2^63-1")
+		}
+	}
+	if r.SupplyAssetID != nil && r.SupplyAssetID.IsZero() {
+		return errors.WithDetail(ErrBadIssuanceRules, "supply_asset_id is empty")
+	}
+	return nil
+}
+
+// empty reports whether r imposes no rules, so that the asset gets a
+// plain multisig issuance program.
+func (r *IssuanceRules) empty() bool {
+	return r == nil || (r.NotBefore == nil && r.NotAfter == nil && r.Delegate == nil && r.SupplyAssetID == nil && len(r.Program) == 0)
+}
+
+// rulesIssuanceProgram compiles an issuance program requiring
+// quorum of pubkeys to sign, subject to rules. delegatePubkeys are
+// the derived keys of rules.Delegate, if any.
+//
+// Besides the signature witness, the program takes up to two
+// arguments, consumed from the top of the stack: the index of the
+// output retiring the supply asset, if there is one, and then, if
+// there's a delegate, whether the delegate is issuing.
+func rulesIssuanceProgram(pubkeys []ed25519.PublicKey, quorum int, rules *IssuanceRules, delegatePubkeys []ed25519.PublicKey) ([]byte, error) {
+	signersProg, err := vmutil.P2SPMultiSigProgram(pubkeys, quorum)
+	if err != nil {
+		return nil, err
+	}
+
+	b := vmutil.NewBuilder()
+	if rules.NotBefore != nil {
+		b.AddOp(vm.OP_MINTIME).AddInt64(int64(bc.Millis(*rules.NotBefore)))
+		b.AddOp(vm.OP_GREATERTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	}
+	if rules.NotAfter != nil {
+		// A max time of zero means the transaction has none.
+		b.AddOp(vm.OP_MAXTIME).AddOp(vm.OP_DUP).AddOp(vm.OP_0NOTEQUAL).AddOp(vm.OP_VERIFY)
+		b.AddInt64(int64(bc.Millis(*rules.NotAfter)))
+		b.AddOp(vm.OP_LESSTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	}
+	if rules.SupplyAssetID != nil {
+		// Retirements have VM version 0 and an empty program as far
+		// as CHECKOUTPUT is concerned. Their reference data is the
+		// issuance's entry ID, which CHECKOUTPUT sees hashed.
+		b.AddOp(vm.OP_ENTRYID).AddOp(vm.OP_SHA3)
+		b.AddOp(vm.OP_AMOUNT).AddData(rules.SupplyAssetID.Bytes())
+		b.AddInt64(0).AddData(nil).AddOp(vm.OP_CHECKOUTPUT).AddOp(vm.OP_VERIFY)
+	}
+	if rules.Delegate == nil {
+		b.AddRawBytes(signersProg)
+		return b.Build()
+	}
+
+	delegateProg, err := vmutil.P2SPMultiSigProgram(delegatePubkeys, rules.Delegate.Quorum)
+	if err != nil {
+		return nil, err
+	}
+	delegate := b.NewJumpTarget()
+	end := b.NewJumpTarget()
+	b.AddJumpIf(delegate)
+	b.AddRawBytes(signersProg)
+	b.AddJump(end)
+	b.SetJumpTarget(delegate)
+	b.AddOp(vm.OP_AMOUNT).AddInt64(int64(rules.Delegate.MaxAmount))
+	b.AddOp(vm.OP_LESSTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	b.AddRawBytes(delegateProg)
+	b.SetJumpTarget(end)
+	return b.Build()
+}
@@ -0,0 +1,248 @@
+package asset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+
+	"github.com/golang/groupcache/lru"
+
+	"chain/core/signers"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/validation"
+	"chain/protocol/vm"
+)
+
+var trueProg = []byte{byte(vm.OP_TRUE)}
+
+func TestRulesIssuanceProgram(t *testing.T) {
+	signerPrv, signerPub := newKey(t)
+	delegatePrv, delegatePub := newKey(t)
+	notBefore := time.Unix(1000, 0)
+	notAfter := time.Unix(2000, 0)
+	supply := bc.NewAssetID([32]byte{1})
+	rules := &IssuanceRules{
+		NotBefore:     &notBefore,
+		NotAfter:      &notAfter,
+		Delegate:      &Delegate{Quorum: 1, MaxAmount: 10},
+		SupplyAssetID: &supply,
+	}
+	prog, err := rulesIssuanceProgram(
+		[]ed25519.PublicKey{signerPub.PublicKey()}, 1, rules,
+		[]ed25519.PublicKey{delegatePub.PublicKey()},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var predHash [32]byte
+	sha3pool.Sum256(predHash[:], trueProg)
+
+	inWindow := bc.Millis(time.Unix(1500, 0))
+	cases := []struct {
+		name             string
+		amount, retired  uint64
+		delegate         bool
+		key              chainkd.XPrv
+		minTime, maxTime uint64
+		retireIndex      int64
+		unbound          bool
+		ok               bool
+	}{
+		{"signers", 100, 100, false, signerPrv, inWindow, inWindow, 1, false, true},
+		{"delegate", 10, 10, true, delegatePrv, inWindow, inWindow, 1, false, true},
+		{"delegate over limit", 11, 11, true, delegatePrv, inWindow, inWindow, 1, false, false},
+		{"delegate as signers", 10, 10, false, delegatePrv, inWindow, inWindow, 1, false, false},
+		{"too little supply", 100, 99, false, signerPrv, inWindow, inWindow, 1, false, false},
+		{"wrong retirement index", 100, 100, false, signerPrv, inWindow, inWindow, 0, false, false},
+		{"retirement not bound", 100, 100, false, signerPrv, inWindow, inWindow, 1, true, false},
+		{"too early", 100, 100, false, signerPrv, bc.Millis(notBefore) - 1, inWindow, 1, false, false},
+		{"too late", 100, 100, false, signerPrv, inWindow, bc.Millis(notAfter) + 1, 1, false, false},
+		{"no max time", 100, 100, false, signerPrv, inWindow, 0, 1, false, false},
+	}
+	for _, c := range cases {
+		args := [][]byte{
+			vm.Int64Bytes(0), c.key.Sign(predHash[:]), trueProg,
+			vm.BoolBytes(c.delegate), vm.Int64Bytes(c.retireIndex),
+		}
+		iss := legacy.NewIssuanceInput([]byte{1}, c.amount, nil, bc.Hash{}, prog, args, nil)
+		retirement := legacy.NewTxOutput(supply, c.retired, retirementProgram, nil)
+		txdata := legacy.TxData{
+			Version: 1,
+			MinTime: c.minTime,
+			MaxTime: c.maxTime,
+			Inputs: []*legacy.TxInput{
+				iss,
+				legacy.NewSpendInput(nil, bc.Hash{}, supply, c.retired, 0, trueProg, bc.Hash{}, nil),
+			},
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(iss.AssetID(), c.amount, trueProg, nil),
+				retirement,
+			},
+		}
+		if !c.unbound {
+			retirement.ReferenceData = legacy.MapTx(&txdata).InputIDs[0].Bytes()
+		}
+		tx := legacy.NewTx(txdata)
+		err := validation.ValidateTx(tx.Tx, bc.Hash{})
+		if c.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		} else if !c.ok && err == nil {
+			t.Errorf("%s: expected the issuance to be invalid", c.name)
+		}
+	}
+}
+
+func TestSharedRetirement(t *testing.T) {
+	signerPrv, signerPub := newKey(t)
+	supply := bc.NewAssetID([32]byte{1})
+	rules := &IssuanceRules{SupplyAssetID: &supply}
+	prog, err := rulesIssuanceProgram([]ed25519.PublicKey{signerPub.PublicKey()}, 1, rules, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var predHash [32]byte
+	sha3pool.Sum256(predHash[:], trueProg)
+	sig := signerPrv.Sign(predHash[:])
+
+	// Two issuances of 100 units each, paid for by retirements of the
+	// supply asset at the given indexes.
+	build := func(index1, index2 int64, retirements int) *legacy.Tx {
+		iss1 := legacy.NewIssuanceInput([]byte{1}, 100, nil, bc.Hash{}, prog, [][]byte{vm.Int64Bytes(0), sig, trueProg, vm.Int64Bytes(index1)}, nil)
+		iss2 := legacy.NewIssuanceInput([]byte{2}, 100, nil, bc.Hash{}, prog, [][]byte{vm.Int64Bytes(0), sig, trueProg, vm.Int64Bytes(index2)}, nil)
+		txdata := legacy.TxData{
+			Version: 1,
+			MinTime: bc.Millis(time.Now()),
+			MaxTime: bc.Millis(time.Now().Add(time.Hour)),
+			Inputs: []*legacy.TxInput{
+				iss1,
+				iss2,
+				legacy.NewSpendInput(nil, bc.Hash{}, supply, uint64(100*retirements), 0, trueProg, bc.Hash{}, nil),
+			},
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(iss1.AssetID(), 200, trueProg, nil),
+			},
+		}
+		for i := 0; i < retirements; i++ {
+			txdata.Outputs = append(txdata.Outputs, legacy.NewTxOutput(supply, 100, retirementProgram, nil))
+		}
+		ids := legacy.MapTx(&txdata).InputIDs
+		for i := 0; i < retirements; i++ {
+			txdata.Outputs[1+i].ReferenceData = ids[i].Bytes()
+		}
+		return legacy.NewTx(txdata)
+	}
+
+	tx := build(1, 2, 2)
+	err = validation.ValidateTx(tx.Tx, bc.Hash{})
+	if err != nil {
+		t.Fatalf("issuances with a retirement each are invalid: %v", err)
+	}
+
+	tx = build(1, 1, 1)
+	err = validation.ValidateTx(tx.Tx, bc.Hash{})
+	if err == nil {
+		t.Error("expected two issuances sharing one retirement to be invalid")
+	}
+}
+
+func TestIssueWithRules(t *testing.T) {
+	ctx := context.Background()
+	_, signerPub := newKey(t)
+	delegatePrv, delegatePub := newKey(t)
+	supply := bc.NewAssetID([32]byte{1})
+	notAfter := time.Now().Add(time.Hour)
+	rules := &IssuanceRules{
+		NotAfter:      &notAfter,
+		Delegate:      &Delegate{XPubs: []chainkd.XPub{delegatePub}, Quorum: 1, MaxAmount: 10},
+		SupplyAssetID: &supply,
+	}
+	signer := &signers.Signer{ID: "signer", Type: "asset", XPubs: []chainkd.XPub{signerPub}, Quorum: 1, KeyIndex: 1}
+	path := signers.Path(signer, signers.AssetKeySpace)
+	prog, err := rulesIssuanceProgram(
+		chainkd.XPubKeys(chainkd.DeriveXPubs(signer.XPubs, path)), 1, rules,
+		chainkd.XPubKeys(chainkd.DeriveXPubs(rules.Delegate.XPubs, path)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defhash := bc.NewHash(sha3.Sum256(nil))
+	var initialBlockHash bc.Hash
+	asset := &Asset{
+		AssetID:          bc.ComputeAssetID(prog, &initialBlockHash, 1, &defhash),
+		VMVersion:        1,
+		IssuanceProgram:  prog,
+		InitialBlockHash: initialBlockHash,
+		Signer:           signer,
+		IssuanceRules:    rules,
+	}
+	reg := &Registry{cache: lru.New(1), aliasCache: lru.New(1)}
+	reg.cache.Add(asset.AssetID, asset)
+
+	// The base transaction spends the supply asset and already has
+	// an output, so the retirement isn't the first output.
+	base := &legacy.TxData{
+		Version: 1,
+		Inputs: []*legacy.TxInput{
+			legacy.NewSpendInput(nil, bc.Hash{}, supply, 10, 0, trueProg, bc.Hash{}, nil),
+		},
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(asset.AssetID, 10, trueProg, nil),
+		},
+	}
+
+	issue := reg.NewIssueAction(bc.AssetAmount{AssetId: &asset.AssetID, Amount: 11}, nil).(*issueAction)
+	issue.Delegate = true
+	_, err = txbuilder.Build(ctx, base, []txbuilder.Action{issue}, time.Now().Add(time.Minute))
+	if errors.Root(err) != txbuilder.ErrAction {
+		t.Fatalf("issuing too much as delegate got error %v, want %v", err, txbuilder.ErrAction)
+	}
+
+	issue.Amount = 10
+	tpl, err := txbuilder.Build(ctx, base, []txbuilder.Action{issue}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tpl.Transaction.MaxTime; got > bc.Millis(notAfter) {
+		t.Errorf("max time = %d, want at most %d", got, bc.Millis(notAfter))
+	}
+	si := tpl.SigningInstructions[0]
+	if len(si.Arguments) != 2 || !vm.AsBool(si.Arguments[0]) {
+		t.Fatalf("arguments = %x, want delegate flag and retirement index", si.Arguments)
+	}
+	if index, _ := vm.AsInt64(si.Arguments[1]); index != 1 {
+		t.Errorf("retirement index = %d, want 1", index)
+	}
+
+	signFn := func(_ context.Context, _ chainkd.XPub, path [][]byte, hardened int, _ *chainkd.XPub, h [32]byte) ([]byte, error) {
+		return delegatePrv.DeriveHardened(path, hardened).Sign(h[:]), nil
+	}
+	err = txbuilder.Sign(ctx, tpl, []chainkd.XPub{delegatePub}, signFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The spend of the supply asset has no signing instruction here,
+	// so give it its (empty) witness by hand.
+	tpl.Transaction.SetInputArguments(0, nil)
+	err = validation.ValidateTx(tpl.Transaction.Tx, initialBlockHash)
+	if err != nil {
+		t.Errorf("delegated issuance is invalid: %v", err)
+	}
+}
+
+func newKey(t *testing.T) (chainkd.XPrv, chainkd.XPub) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return xprv, xpub
+}
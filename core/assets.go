@@ -2,12 +2,15 @@ package core
 
 import (
 	"context"
+	"math"
 	"sync"
 
 	"chain/core/asset"
 	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
 	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
+	"chain/protocol/bc"
 )
 
 // POST /create-asset
@@ -18,6 +21,10 @@ func (a *API) createAsset(ctx context.Context, ins []struct {
 	Definition map[string]interface{}
 	Tags       map[string]interface{}
 
+	// IssuanceRules, if present, are compiled into the asset's
+	// issuance program.
+	IssuanceRules *asset.IssuanceRules `json:"issuance_rules"`
+
 	// ClientToken is the application's unique token for the asset. Every asset
 	// should have a unique client token. The client token is used to ensure
 	// idempotency of create asset requests. Duplicate create asset requests
@@ -38,6 +45,7 @@ func (a *API) createAsset(ctx context.Context, ins []struct {
 				subctx,
 				ins[i].RootXPubs,
 				ins[i].Quorum,
+				ins[i].IssuanceRules,
 				ins[i].Definition,
 				ins[i].Alias,
 				ins[i].Tags,
@@ -76,7 +84,11 @@ func (a *API) updateAssetTags(ctx context.Context, ins []struct {
 			defer wg.Done()
 			defer batchRecover(subctx, &responses[i])
 
-			err := a.assets.UpdateTags(subctx, ins[i].ID, ins[i].Alias, ins[i].Tags)
+			field, value := scopeRef(ins[i].ID, ins[i].Alias)
+			err := a.checkAssetScope(subctx, field, value)
+			if err == nil {
+				err = a.assets.UpdateTags(subctx, ins[i].ID, ins[i].Alias, ins[i].Tags)
+			}
 			if err != nil {
 				responses[i] = err
 			} else {
@@ -88,3 +100,64 @@ func (a *API) updateAssetTags(ctx context.Context, ins []struct {
 	wg.Wait()
 	return responses
 }
+
+type assetSupplyRequest struct {
+	AssetID    *bc.AssetID `json:"asset_id"`
+	AssetAlias string      `json:"asset_alias"`
+
+	// Timestamp asks for the supply as of a point in time. If it
+	// and the range are all zero, the latest supply is returned.
+	TimestampMS uint64 `json:"timestamp"`
+
+	// StartTimeMS and EndTimeMS ask for the change in supply over a
+	// range of time, inclusive. A zero EndTimeMS means now.
+	StartTimeMS uint64 `json:"start_time"`
+	EndTimeMS   uint64 `json:"end_time"`
+}
+
+// getAssetSupply returns the amount of an asset issued, retired,
+// and outstanding, either at a point in time or over a range of
+// time, as counted by the transaction indexer.
+//
+// POST /get-asset-supply
+func (a *API) getAssetSupply(ctx context.Context, req assetSupplyRequest) (interface{}, error) {
+	if (req.AssetID == nil) == (req.AssetAlias == "") {
+		return nil, errors.Wrap(asset.ErrBadIdentifier)
+	}
+	assetID := req.AssetID
+	if assetID == nil {
+		ast, err := a.assets.FindByAlias(ctx, req.AssetAlias)
+		if err != nil {
+			return nil, errors.Wrap(err, "finding asset by alias")
+		}
+		assetID = &ast.AssetID
+	}
+	err := a.checkAssetScope(ctx, "id", assetID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	isRange := req.StartTimeMS != 0 || req.EndTimeMS != 0
+	if isRange && req.TimestampMS != 0 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "timestamp can't be combined with start_time or end_time")
+	}
+	if req.TimestampMS > math.MaxInt64 || req.StartTimeMS > math.MaxInt64 || req.EndTimeMS > math.MaxInt64 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
+	}
+	if !isRange {
+		timestampMS := req.TimestampMS
+		if timestampMS == 0 {
+			timestampMS = math.MaxInt64
+		}
+		return a.indexer.AssetSupply(ctx, *assetID, timestampMS)
+	}
+
+	endTimeMS := req.EndTimeMS
+	if endTimeMS == 0 {
+		endTimeMS = math.MaxInt64
+	}
+	if endTimeMS < req.StartTimeMS {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "end_time is before start_time")
+	}
+	return a.indexer.AssetSupplyRange(ctx, *assetID, req.StartTimeMS, endTimeMS)
+}
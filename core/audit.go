@@ -0,0 +1,304 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"chain/core/audit"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/authn"
+	"chain/net/http/httpjson"
+	"chain/net/http/reqid"
+	"chain/protocol/bc"
+)
+
+// auditedRoutes are the routes whose requests are recorded in the
+// audit log: those that change the Core's state or use its keys.
+var auditedRoutes = map[string]bool{
+	"/create-account":                 true,
+	"/create-asset":                   true,
+	"/update-account-tags":            true,
+	"/update-asset-tags":              true,
+	"/build-transaction":              true,
+	"/submit-transaction":             true,
+	"/sign-transaction":               true,
+	"/create-control-program":         true,
+	"/create-account-receiver":        true,
+	"/create-transaction-feed":        true,
+	"/update-transaction-feed":        true,
+	"/delete-transaction-feed":        true,
+	"/create-signing-session":         true,
+	"/add-signing-session-signatures": true,
+	"/reset":                          true,
+
+	"/create-authorization-grant": true,
+	"/delete-authorization-grant": true,
+	"/create-access-token":        true,
+	"/delete-access-token":        true,
+	"/rotate-access-token":        true,
+
+	"/add-allowed-member":  true,
+	"/init-cluster":        true,
+	"/join-cluster":        true,
+	"/evict":               true,
+	"/transfer-leadership": true,
+	"/sinkdb-backup":       true,
+	"/sinkdb-restore":      true,
+	"/configure":           true,
+
+	"/mockhsm/create-block-key": true,
+	"/mockhsm/create-key":       true,
+	"/mockhsm/delkey":           true,
+	"/mockhsm/sign-transaction": true,
+	"/mockhsm/set-key-policy":   true,
+	"/mockhsm/export-keys":      true,
+	"/mockhsm/import-keys":      true,
+
+	"/keystore/init":             true,
+	"/keystore/unlock":           true,
+	"/keystore/lock":             true,
+	"/keystore/create-block-key": true,
+	"/keystore/create-key":       true,
+	"/keystore/delkey":           true,
+	"/keystore/sign-transaction": true,
+	"/keystore/export-key":       true,
+	"/keystore/import-key":       true,
+
+	crosscoreRPCPrefix + "submit": true,
+}
+
+type auditEventKey struct{}
+
+// auditState is the audit event of a request in progress, and
+// whether the request has been authenticated.
+type auditState struct {
+	ev            *audit.Event
+	authenticated bool
+}
+
+// AuditHandler records requests to audited routes in store. It
+// should wrap AuthHandler, so that requests refused for want of
+// authorization, or by a rate limit, are recorded along with those
+// that are served. A request is served even if recording it fails;
+// the failure is logged.
+//
+// Requests refused before they are authenticated are cheap to
+// make, so they mustn't be expensive to record. Their bodies are
+// never read or digested, and they are written only to store's
+// sink, if it has one, not to the database.
+func AuditHandler(next http.Handler, store *audit.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if store == nil || !auditedRoutes[req.URL.Path] {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		ctx := req.Context()
+		ev := &audit.Event{
+			Time:          time.Now().UTC(),
+			Route:         req.URL.Path,
+			RequestID:     reqid.FromContext(ctx),
+			RemoteAddress: req.RemoteAddr,
+		}
+		if h, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			ev.RemoteAddress = h
+		}
+		state := &auditState{ev: ev}
+		ctx = context.WithValue(ctx, auditEventKey{}, state)
+		req = req.WithContext(ctx)
+
+		// The body is digested as the handler reads it, so it's
+		// never buffered here.
+		body := &digestBody{ReadCloser: req.Body, h: sha256.New()}
+		req.Body = body
+
+		rw := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, req)
+
+		ev.StatusCode = rw.status
+		ev.Outcome = audit.Succeeded
+		if rw.status >= 400 {
+			ev.Outcome = audit.Failed
+			ev.ErrorCode = rw.errorCode()
+		}
+
+		if !state.authenticated {
+			err := store.Export(ev)
+			if err != nil {
+				log.Printkv(ctx, log.KeyError, err, "at", "exporting audit event")
+			}
+			return
+		}
+
+		// Digest whatever the handler left unread.
+		io.Copy(ioutil.Discard, io.LimitReader(body, maxReqSize))
+		ev.RequestDigest = body.h.Sum(nil)
+
+		// Record the event even if the client has gone away
+		// or the request timed out.
+		err := store.Record(context.Background(), ev)
+		if err != nil {
+			log.Printkv(ctx, log.KeyError, err, "at", "recording audit event")
+		}
+	})
+}
+
+// digestBody is a request body that hashes what is read from it.
+type digestBody struct {
+	io.ReadCloser
+	h hash.Hash
+}
+
+func (b *digestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.h.Write(p[:n])
+	return n, err
+}
+
+// auditCaller records the credentials that authenticated a request
+// in the request's audit event, if it has one. AuthHandler calls it
+// as soon as the request is authenticated, so that the event names
+// the caller even if the request is then refused.
+func auditCaller(ctx context.Context) {
+	state, ok := ctx.Value(auditEventKey{}).(*auditState)
+	if !ok {
+		return
+	}
+	state.authenticated = true
+	ev := state.ev
+	ev.AccessToken = authn.Token(ctx)
+	if certs := authn.X509Certs(ctx); len(certs) > 0 {
+		ev.CertSubject = formatSubject(certs[0].Subject)
+	}
+	if claims := authn.JWT(ctx); claims != nil {
+		ev.JWTSubject = claims.Subject
+	}
+}
+
+// maxAuditErrBody is how much of the body of an error response
+// auditResponseWriter keeps, to find its error code.
+const maxAuditErrBody = 4096
+
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	errBody     bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// errorCode returns the Chain error code in the error response
+// written to w, if any. The response may have been compressed by
+// the Core's gzip handler.
+func (w *auditResponseWriter) errorCode() string {
+	var r io.Reader = &w.errBody
+	if w.Header().Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return ""
+		}
+		r = zr
+	}
+	var resp struct{ Code string }
+	b, _ := ioutil.ReadAll(io.LimitReader(r, maxAuditErrBody))
+	if json.Unmarshal(b, &resp) != nil {
+		return ""
+	}
+	return resp.Code
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if w.status >= 400 && w.errBody.Len() < maxAuditErrBody {
+		n := maxAuditErrBody - w.errBody.Len()
+		if n > len(b) {
+			n = len(b)
+		}
+		w.errBody.Write(b[:n])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// formatSubject formats a certificate subject as a distinguished
+// name, most specific attribute first, as in "CN=alice,O=Acme".
+func formatSubject(n pkix.Name) string {
+	var parts []string
+	add := func(attr string, vals ...string) {
+		for _, v := range vals {
+			if v != "" {
+				parts = append(parts, attr+"="+v)
+			}
+		}
+	}
+	add("CN", n.CommonName)
+	add("SERIALNUMBER", n.SerialNumber)
+	add("OU", n.OrganizationalUnit...)
+	add("O", n.Organization...)
+	add("STREET", n.StreetAddress...)
+	add("L", n.Locality...)
+	add("ST", n.Province...)
+	add("POSTALCODE", n.PostalCode...)
+	add("C", n.Country...)
+	return strings.Join(parts, ",")
+}
+
+// listAuditEvents returns audit events, most recent first,
+// optionally filtered by route, request ID, credentials, outcome,
+// and time range.
+//
+// POST /list-audit-events
+func (a *API) listAuditEvents(ctx context.Context, x requestQuery) (*page, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	if x.Outcome != "" && x.Outcome != audit.Succeeded && x.Outcome != audit.Failed {
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "outcome must be %q or %q", audit.Succeeded, audit.Failed)
+	}
+
+	f := audit.Filter{
+		Route:       x.Route,
+		RequestID:   x.RequestID,
+		AccessToken: x.AccessTokenID,
+		CertSubject: x.CertSubject,
+		JWTSubject:  x.JWTSubject,
+		Outcome:     x.Outcome,
+	}
+	if x.StartTimeMS != 0 {
+		f.Start = time.Unix(0, 0).Add(bc.MillisDuration(x.StartTimeMS))
+	}
+	if x.EndTimeMS != 0 {
+		f.End = time.Unix(0, 0).Add(bc.MillisDuration(x.EndTimeMS))
+	}
+
+	events, after, err := a.audit.List(ctx, f, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	x.After = after
+	return &page{
+		Items:    httpjson.Array(events),
+		LastPage: len(events) < limit,
+		Next:     x,
+	}, nil
+}
@@ -0,0 +1,201 @@
+// Package audit records a durable trail of the API calls that
+// change a Core's state: who made each one, what they asked for,
+// and how it turned out.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// ErrInvalidAfter is returned by List for a malformed page cursor.
+var ErrInvalidAfter = errors.New("invalid after")
+
+// Outcomes of a request.
+const (
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+// Event records one API request. Events are kept in an append-only
+// table: they are never updated or deleted.
+type Event struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Route     string    `json:"route"`
+	RequestID string    `json:"request_id"`
+
+	// AccessToken, CertSubject, and JWTSubject identify the
+	// credentials the request was authenticated with: the ID of
+	// an access token, the subject of a client certificate, and
+	// the subject of a JWT bearer token. A request from localhost
+	// may have none of them.
+	AccessToken   string `json:"access_token,omitempty"`
+	CertSubject   string `json:"cert_subject,omitempty"`
+	JWTSubject    string `json:"jwt_subject,omitempty"`
+	RemoteAddress string `json:"remote_address"`
+
+	// RequestDigest is the SHA-256 hash of the request body. It
+	// is empty for requests refused before they were
+	// authenticated, whose bodies aren't read.
+	RequestDigest chainjson.HexBytes `json:"request_digest"`
+
+	// Outcome is Succeeded or Failed, according to the HTTP
+	// status of the response. Batch requests that fail only for
+	// some items count as having succeeded.
+	Outcome    string `json:"outcome"`
+	StatusCode int    `json:"status_code"`
+
+	// ErrorCode is the Chain error code of a failed request,
+	// if it had one.
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// Filter selects events in List. Empty fields match any event.
+type Filter struct {
+	Route       string
+	RequestID   string
+	AccessToken string
+	CertSubject string
+	JWTSubject  string
+	Outcome     string
+	Start, End  time.Time
+}
+
+// Store stores audit events in Postgres and, if Sink is set,
+// also writes each one to Sink as a line of JSON.
+type Store struct {
+	DB   pg.DB
+	Sink io.Writer
+
+	sinkMu sync.Mutex
+}
+
+// Record saves ev, setting its ID.
+func (s *Store) Record(ctx context.Context, ev *Event) error {
+	const q = `
+		INSERT INTO audit_events
+			(route, request_id, access_token, cert_subject, jwt_subject,
+			remote_addr, request_digest, outcome, status_code, error_code, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`
+	var id int64
+	err := s.DB.QueryRowContext(ctx, q, ev.Route, ev.RequestID,
+		nullString(ev.AccessToken), nullString(ev.CertSubject), nullString(ev.JWTSubject),
+		ev.RemoteAddress, []byte(ev.RequestDigest), ev.Outcome, ev.StatusCode,
+		nullString(ev.ErrorCode), ev.Time).Scan(&id)
+	if err != nil {
+		return errors.Wrap(err, "recording audit event")
+	}
+	ev.ID = strconv.FormatInt(id, 10)
+	return s.Export(ev)
+}
+
+// Export writes ev to Sink, if it is set, without saving it in
+// Postgres. It's for events that aren't worth a row, such as
+// requests refused before they were authenticated.
+func (s *Store) Export(ev *Event) error {
+	if s.Sink == nil {
+		return nil
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+	_, err = s.Sink.Write(append(b, '\n'))
+	return errors.Wrap(err, "exporting audit event")
+}
+
+// List returns events matching f, most recent first, starting
+// after the event with ID after.
+func (s *Store) List(ctx context.Context, f Filter, after string, limit int) ([]*Event, string, error) {
+	var (
+		zafter int64
+		err    error
+	)
+	if after != "" {
+		zafter, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(ErrInvalidAfter, "value: %q", after)
+		}
+	}
+
+	var (
+		events []*Event
+		params []interface{}
+	)
+	q := `
+		SELECT id, route, request_id, COALESCE(access_token, ''),
+			COALESCE(cert_subject, ''), COALESCE(jwt_subject, ''), remote_addr,
+			request_digest, outcome, status_code, COALESCE(error_code, ''), created_at
+		FROM audit_events WHERE true
+	`
+	where := func(cond string, v interface{}) {
+		params = append(params, v)
+		q += fmt.Sprintf(" AND "+cond, len(params))
+	}
+	for _, c := range []struct{ col, v string }{
+		{"route", f.Route},
+		{"request_id", f.RequestID},
+		{"access_token", f.AccessToken},
+		{"cert_subject", f.CertSubject},
+		{"jwt_subject", f.JWTSubject},
+		{"outcome", f.Outcome},
+	} {
+		if c.v != "" {
+			where(c.col+" = $%d", c.v)
+		}
+	}
+	if !f.Start.IsZero() {
+		where("created_at >= $%d", f.Start)
+	}
+	if !f.End.IsZero() {
+		where("created_at < $%d", f.End)
+	}
+	if zafter != 0 {
+		where("id < $%d", zafter)
+	}
+	q += fmt.Sprintf(" ORDER BY id DESC LIMIT %d", limit)
+
+	consumeRow := func(id int64, route, reqID, token, certSubj, jwtSubj, addr string, digest []byte, outcome string, status int, code string, ts time.Time) {
+		events = append(events, &Event{
+			ID:            strconv.FormatInt(id, 10),
+			Time:          ts,
+			Route:         route,
+			RequestID:     reqID,
+			AccessToken:   token,
+			CertSubject:   certSubj,
+			JWTSubject:    jwtSubj,
+			RemoteAddress: addr,
+			RequestDigest: digest,
+			Outcome:       outcome,
+			StatusCode:    status,
+			ErrorCode:     code,
+		})
+		zafter = id
+	}
+	params = append(params, consumeRow)
+
+	err = pg.ForQueryRows(ctx, s.DB, q, params...)
+	if err != nil {
+		return nil, "", err
+	}
+	return events, strconv.FormatInt(zafter, 10), nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
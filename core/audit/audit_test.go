@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"chain/database/pg/pgtest"
+	"chain/testutil"
+)
+
+func TestRecordList(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	var sink bytes.Buffer
+	store := &Store{DB: db, Sink: &sink}
+
+	start := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	events := []*Event{
+		{Route: "/create-asset", RequestID: "r1", AccessToken: "alice", Outcome: Succeeded, StatusCode: 200},
+		{Route: "/build-transaction", RequestID: "r2", CertSubject: "CN=bob", Outcome: Succeeded, StatusCode: 200},
+		{Route: "/build-transaction", RequestID: "r3", AccessToken: "alice", Outcome: Failed, StatusCode: 400, ErrorCode: "CH706"},
+	}
+	for i, ev := range events {
+		ev.Time = start.Add(time.Duration(i) * time.Second)
+		ev.RemoteAddress = "127.0.0.1"
+		ev.RequestDigest = []byte{byte(i)}
+		err := store.Record(ctx, ev)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	got, after, err := store.List(ctx, Filter{}, "", 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 2 || got[0].RequestID != "r3" || got[1].RequestID != "r2" {
+		t.Fatalf("List got %+v, want events r3 and r2", got)
+	}
+	got, _, err = store.List(ctx, Filter{}, after, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) == 1 {
+		got[0].Time = got[0].Time.UTC()
+	}
+	if len(got) != 1 || !testutil.DeepEqual(got[0], events[0]) {
+		t.Fatalf("List second page got %+v, want %+v", got, events[0])
+	}
+
+	cases := []struct {
+		f    Filter
+		want []string
+	}{
+		{Filter{AccessToken: "alice"}, []string{"r3", "r1"}},
+		{Filter{Route: "/build-transaction", Outcome: Succeeded}, []string{"r2"}},
+		{Filter{CertSubject: "CN=bob"}, []string{"r2"}},
+		{Filter{Start: start.Add(time.Second), End: start.Add(2 * time.Second)}, []string{"r2"}},
+		{Filter{RequestID: "nope"}, nil},
+	}
+	for _, c := range cases {
+		got, _, err := store.List(ctx, c.f, "", 10)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		var ids []string
+		for _, ev := range got {
+			ids = append(ids, ev.RequestID)
+		}
+		if !testutil.DeepEqual(ids, c.want) {
+			t.Errorf("List(%+v) = %v, want %v", c.f, ids, c.want)
+		}
+	}
+
+	// Every event is exported to the sink too.
+	dec := json.NewDecoder(&sink)
+	for _, want := range events {
+		var ev Event
+		err = dec.Decode(&ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.ID != want.ID || ev.RequestID != want.RequestID {
+			t.Errorf("exported event = %+v, want %+v", ev, want)
+		}
+	}
+
+	_, err = db.ExecContext(ctx, `UPDATE audit_events SET outcome='succeeded'`)
+	if err == nil {
+		t.Error("updating audit events succeeded, want error")
+	}
+	_, err = db.ExecContext(ctx, `DELETE FROM audit_events`)
+	if err == nil {
+		t.Error("deleting audit events succeeded, want error")
+	}
+}
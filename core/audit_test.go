@@ -0,0 +1,131 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chain/core/audit"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/net/http/gzip"
+	"chain/testutil"
+)
+
+func TestAuditHandler(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	store := &audit.Store{DB: db}
+
+	var gotBody string
+	h := AuditHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auditCaller(req.Context()) // as AuthHandler does
+		b, _ := ioutil.ReadAll(req.Body)
+		gotBody = string(b)
+		if req.URL.Path == "/create-asset" {
+			errorFormatter.Write(req.Context(), w, errors.WithDetail(errBadAction, "bad"))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}), store)
+
+	for _, path := range []string{"/create-account", "/list-accounts", "/create-asset"} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"alias":"x"}`))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if gotBody != `{"alias":"x"}` {
+			t.Errorf("%s: handler read body %q", path, gotBody)
+		}
+	}
+
+	events, _, err := store.List(ctx, audit.Filter{}, "", 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2 (list routes aren't audited)", len(events))
+	}
+	failed, ok := events[0], events[1]
+	if failed.Route != "/create-asset" || failed.Outcome != audit.Failed || failed.StatusCode != 400 || failed.ErrorCode != "CH703" {
+		t.Errorf("failed event = %+v", failed)
+	}
+	if ok.Route != "/create-account" || ok.Outcome != audit.Succeeded || ok.StatusCode != 200 || ok.ErrorCode != "" {
+		t.Errorf("succeeded event = %+v", ok)
+	}
+	if ok.RemoteAddress != "192.0.2.1" || len(ok.RequestDigest) != 32 {
+		t.Errorf("event address %q, digest %x", ok.RemoteAddress, ok.RequestDigest)
+	}
+}
+
+func TestAuditUnauthenticated(t *testing.T) {
+	// Requests refused before they're authenticated never reach
+	// the database, so the store has none.
+	var sink bytes.Buffer
+	store := &audit.Store{Sink: &sink}
+	body := &countingReader{r: strings.NewReader(`{"id":"x"}`)}
+	h := AuditHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		errorFormatter.Write(req.Context(), w, errNotAuthenticated)
+	}), store)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/delete-access-token", body))
+
+	if body.n != 0 {
+		t.Errorf("read %d bytes of the body, want 0", body.n)
+	}
+	var ev audit.Event
+	err := json.Unmarshal(sink.Bytes(), &ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Route != "/delete-access-token" || ev.StatusCode != 401 || ev.ErrorCode != "CH009" || len(ev.RequestDigest) != 0 {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestAuditRefusal(t *testing.T) {
+	// Refusals from inside the Core's handler chain reach the audit
+	// handler compressed.
+	h := gzip.Handler{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		errorFormatter.Write(req.Context(), w, errRateLimited)
+	})}
+	req := httptest.NewRequest("POST", "/create-account", strings.NewReader(`{}`))
+	req.Header.Set("Accept-Encoding", "gzip")
+	rw := &auditResponseWriter{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	h.ServeHTTP(rw, req)
+
+	if rw.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("response was not compressed")
+	}
+	if rw.status != 429 || rw.errorCode() != "CH007" {
+		t.Errorf("refusal recorded as status %d code %q, want 429 CH007", rw.status, rw.errorCode())
+	}
+}
+
+func TestFormatSubject(t *testing.T) {
+	got := formatSubject(pkix.Name{
+		CommonName:         "alice",
+		OrganizationalUnit: []string{"treasury"},
+		Organization:       []string{"Acme"},
+		Country:            []string{"US"},
+	})
+	const want = "CN=alice,OU=treasury,O=Acme,C=US"
+	if got != want {
+		t.Errorf("formatSubject = %q, want %q", got, want)
+	}
+}
@@ -19,6 +19,7 @@ var policyByRoute = map[string][]string{
 	"/update-asset-tags":        {"client-readwrite"},
 	"/build-transaction":        {"client-readwrite", "internal"},
 	"/submit-transaction":       {"client-readwrite", "internal"},
+	"/sign-transaction":         {"client-readwrite"},
 	"/create-control-program":   {"client-readwrite"},
 	"/create-account-receiver":  {"client-readwrite"},
 	"/create-transaction-feed":  {"client-readwrite"},
@@ -32,20 +33,60 @@ var policyByRoute = map[string][]string{
 	"/mockhsm/delkey":           {"client-readwrite"},
 	"/mockhsm/sign-transaction": {"client-readwrite"},
 
-	"/list-accounts":          {"client-readwrite", "client-readonly"},
-	"/list-assets":            {"client-readwrite", "client-readonly"},
-	"/list-transaction-feeds": {"client-readwrite", "client-readonly"},
-	"/list-transactions":      {"client-readwrite", "client-readonly"},
-	"/list-balances":          {"client-readwrite", "client-readonly"},
-	"/list-unspent-outputs":   {"client-readwrite", "client-readonly"},
-	"/reset":                  {"client-readwrite", "internal"},
-
-	crosscoreRPCPrefix + "submit":            {"crosscore", "crosscore-signblock"},
-	crosscoreRPCPrefix + "get-block":         {"crosscore", "crosscore-signblock"},
-	crosscoreRPCPrefix + "get-snapshot-info": {"crosscore", "crosscore-signblock"},
-	crosscoreRPCPrefix + "get-snapshot":      {"crosscore", "crosscore-signblock"},
-	crosscoreRPCPrefix + "signer/sign-block": {"internal", "crosscore-signblock"},
-	crosscoreRPCPrefix + "block-height":      {"crosscore", "crosscore-signblock"},
+	"/mockhsm/set-key-policy":   {"internal"},
+	"/mockhsm/list-sign-events": {"client-readwrite", "client-readonly"},
+
+	"/mockhsm/export-keys": {"internal"},
+	"/mockhsm/import-keys": {"client-readwrite"},
+
+	"/derive-account-key":     {"client-readwrite", "client-readonly"},
+	"/list-account-receivers": {"client-readwrite", "client-readonly"},
+
+	"/create-signing-session":         {"client-readwrite"},
+	"/get-signing-session":            {"client-readwrite", "client-readonly"},
+	"/list-signing-sessions":          {"client-readwrite", "client-readonly"},
+	"/add-signing-session-signatures": {"client-readwrite"},
+
+	"/keystore":                  {"client-readwrite"},
+	"/keystore/init":             {"internal"},
+	"/keystore/unlock":           {"internal"},
+	"/keystore/lock":             {"internal"},
+	"/keystore/create-block-key": {"internal"},
+	"/keystore/create-key":       {"client-readwrite"},
+	"/keystore/list-keys":        {"client-readwrite", "client-readonly"},
+	"/keystore/delkey":           {"client-readwrite"},
+	"/keystore/sign-transaction": {"client-readwrite"},
+	"/keystore/export-key":       {"internal"},
+	"/keystore/import-key":       {"client-readwrite"},
+	"/keystore/list-sign-events": {"client-readwrite", "client-readonly"},
+
+	"/mockhsm/derive-xpub":  {"client-readwrite"},
+	"/keystore/derive-xpub": {"client-readwrite"},
+
+	"/list-accounts":             {"client-readwrite", "client-readonly"},
+	"/list-assets":               {"client-readwrite", "client-readonly"},
+	"/get-asset-supply":          {"client-readwrite", "client-readonly"},
+	"/list-transaction-feeds":    {"client-readwrite", "client-readonly"},
+	"/list-transactions":         {"client-readwrite", "client-readonly"},
+	"/list-balances":             {"client-readwrite", "client-readonly"},
+	"/list-unspent-outputs":      {"client-readwrite", "client-readonly"},
+	"/list-watched-transactions": {"client-readwrite", "client-readonly"},
+	"/get-transaction-proof":     {"client-readwrite", "client-readonly"},
+	"/get-output-proof":          {"client-readwrite", "client-readonly"},
+	"/get-block-header":          {"client-readwrite", "client-readonly"},
+	"/list-audit-events":         {"client-readwrite", "client-readonly", "monitoring"},
+	"/reset":                     {"client-readwrite", "internal"},
+
+	crosscoreRPCPrefix + "submit":                {"crosscore", "crosscore-signblock"},
+	crosscoreRPCPrefix + "get-block":             {"crosscore", "crosscore-signblock"},
+	crosscoreRPCPrefix + "get-blocks":            {"crosscore", "crosscore-signblock"},
+	crosscoreRPCPrefix + "get-block-headers":     {"crosscore", "crosscore-signblock"},
+	crosscoreRPCPrefix + "get-snapshot-info":     {"crosscore", "crosscore-signblock"},
+	crosscoreRPCPrefix + "get-snapshot":          {"crosscore", "crosscore-signblock"},
+	crosscoreRPCPrefix + "get-snapshot-manifest": {"crosscore", "crosscore-signblock"},
+	crosscoreRPCPrefix + "get-snapshot-chunk":    {"crosscore", "crosscore-signblock"},
+	crosscoreRPCPrefix + "signer/sign-block":     {"internal", "crosscore-signblock"},
+	crosscoreRPCPrefix + "block-height":          {"crosscore", "crosscore-signblock"},
 
 	"/list-authorization-grants":  {"client-readwrite", "client-readonly", "internal"},
 	"/create-authorization-grant": {"client-readwrite", "internal"},
@@ -53,10 +94,15 @@ var policyByRoute = map[string][]string{
 	"/create-access-token":        {"client-readwrite", "internal"},
 	"/list-access-tokens":         {"client-readwrite", "client-readonly"},
 	"/delete-access-token":        {"client-readwrite"},
+	"/rotate-access-token":        {"client-readwrite"},
 	"/add-allowed-member":         {"internal"},
 	"/init-cluster":               {"internal"},
 	"/join-cluster":               {"internal"},
 	"/evict":                      {"internal"},
+	"/raft-status":                {"client-readwrite", "client-readonly", "monitoring", "internal"},
+	"/transfer-leadership":        {"internal"},
+	"/sinkdb-backup":              {"internal"},
+	"/sinkdb-restore":             {"internal"},
 	"/configure":                  {"client-readwrite", "internal"},
 	"/config":                     {"client-readwrite", "client-readonly", "monitoring", "internal"},
 	"/info":                       {"client-readwrite", "client-readonly", "crosscore", "crosscore-signblock", "monitoring", "internal"},
@@ -68,3 +114,23 @@ var policyByRoute = map[string][]string{
 	"/dashboard":  {"public"},
 	"/dashboard/": {"public"},
 }
+
+// scopedRoutes are the routes that enforce the scopes of
+// authorization grants. Requests allowed only by scoped grants
+// can't use any other route.
+var scopedRoutes = map[string]bool{
+	"/build-transaction":         true,
+	"/submit-transaction":        true,
+	"/sign-transaction":          true,
+	"/mockhsm/sign-transaction":  true,
+	"/keystore/sign-transaction": true,
+	"/update-account-tags":       true,
+	"/update-asset-tags":         true,
+	"/list-accounts":             true,
+	"/list-assets":               true,
+	"/list-transactions":         true,
+	"/list-balances":             true,
+	"/list-unspent-outputs":      true,
+	"/get-asset-supply":          true,
+	"/info":                      true,
+}
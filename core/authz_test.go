@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"chain/core/accesstoken"
 	"chain/database/pg/pgtest"
@@ -25,7 +26,7 @@ func TestAuthz(t *testing.T) {
 	mux.Handle("/raft/", sdb.RaftService())
 
 	var handler http.Handler = mux
-	handler = AuthHandler(handler, sdb, accessTokens, nil, nil)
+	handler = AuthHandler(handler, sdb, accessTokens, nil, nil, nil)
 
 	api := &API{
 		mux:          http.NewServeMux(),
@@ -49,7 +50,7 @@ func TestAuthz(t *testing.T) {
 	}
 	tokens := make(map[string]*accesstoken.Token)
 	for i := 0; i < len(testPolicies); i++ {
-		token, err := accessTokens.Create(ctx, fmt.Sprintf("token%d", i), "")
+		token, err := accessTokens.Create(ctx, fmt.Sprintf("token%d", i), "", time.Time{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"encoding/hex"
 	"net"
 	"net/url"
 	"path"
@@ -43,6 +44,69 @@ func Config(ctx context.Context, db pg.DB, sdb *sinkdb.DB) (*config.Options, err
 	// the URL, not the access token.
 	opts.DefineSet("enclave", 2, cleanEnclaveTuple, equalFirst)
 
+	cleanBlockPeerTuple := func(tup []string) error {
+		normalized, err := normalizeURL(tup[0])
+		if err != nil {
+			return errors.WithDetailf(err, "Provided URL is invalid: %s", err.Error())
+		}
+		if normalized.Scheme != "https" && normalized.Scheme != "http" {
+			return errors.WithDetailf(config.ErrConfigOp, "Block peer URL must use http or https.")
+		}
+		tup[0] = normalized.String()
+		if tup[1] != "" && !strings.Contains(tup[1], ":") {
+			return errors.WithDetailf(config.ErrConfigOp, "Access token must be of the form <username>:<password>.")
+		}
+		return nil
+	}
+
+	// block_peer defines a set of (URL, access token) tuples for
+	// additional Cores, besides the generator, that a participant
+	// may download blocks from. Tuple equality is defined on the
+	// URL, not the access token.
+	opts.DefineSet("block_peer", 2, cleanBlockPeerTuple, equalFirst)
+
+	cleanWatchProgramTuple := func(tup []string) error {
+		prog, err := hex.DecodeString(tup[0])
+		if err != nil || len(prog) == 0 {
+			return errors.WithDetailf(config.ErrConfigOp, "Control program must be non-empty and hex-encoded.")
+		}
+		tup[0] = hex.EncodeToString(prog)
+		return nil
+	}
+
+	// watch_program defines a set of 1-tuples of hex-encoded control
+	// programs. A light client Core stores the transactions paying
+	// to these programs, along with the block headers it syncs.
+	opts.DefineSet("watch_program", 1, cleanWatchProgramTuple, equalFirst)
+
+	cleanTxSignerTuple := func(tup []string) error {
+		normalized, err := normalizeURL(tup[0])
+		if err != nil {
+			return errors.WithDetailf(config.ErrConfigOp, "Provided URL is invalid: %s", err.Error())
+		}
+		if normalized.Scheme != "https" {
+			return errors.WithDetailf(config.ErrConfigOp, "Transaction signer URL must use https.")
+		}
+		tup[0] = normalized.String()
+		if !strings.Contains(tup[1], ":") {
+			return errors.WithDetailf(config.ErrConfigOp, "Access token must be of the form <username>:<password>.")
+		}
+		return nil
+	}
+
+	// tx_signer defines a set of (URL, access token) tuples for
+	// remote signers used by /sign-transaction, in order of
+	// preference. Tuple equality is defined on the URL, not the
+	// access token.
+	opts.DefineSet("tx_signer", 2, cleanTxSignerTuple, equalFirst)
+
+	// request_limit defines a set of (policy, route class, requests
+	// per second, requests per day) tuples limiting the requests
+	// each client may make. Tuple equality is defined on the policy
+	// and route class. See requestLimiter.
+	equalFirstTwo := func(a, b []string) bool { return a[0] == b[0] && a[1] == b[1] }
+	opts.DefineSet("request_limit", 4, cleanRequestLimitTuple, equalFirstTwo)
+
 	// migrate any old-style existing configuration options
 	monolith, err := config.Load(ctx, db, sdb)
 	if errors.Root(err) == raft.ErrUninitialized {
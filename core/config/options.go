@@ -6,7 +6,6 @@ import (
 	"path"
 	"strings"
 	"sync"
-	"sync/atomic"
 
 	"chain/core/config/internal/configpb"
 	"chain/database/sinkdb"
@@ -21,6 +20,7 @@ func New(sdb *sinkdb.DB) *Options {
 	return &Options{
 		sdb:    sdb,
 		schema: make(map[string]option),
+		errs:   make(map[string]error),
 	}
 }
 
@@ -112,9 +112,9 @@ func (opts *Options) List(ctx context.Context, key string) ([][]string, error) {
 // defined as a scalar.
 //
 // The returned function performs a stale read of the configuration
-// value. If an error occurs while reading the value the old
-// value is returned, and the error is saved on the Options
-// type to be returned in Err.
+// value, and caches it until the value changes. If an error occurs
+// while reading the value the old value is returned, and the error
+// is saved on the Options type to be returned in Err.
 func (opts *Options) ListFunc(key string) func() [][]string {
 	opt, ok := opts.schema[key]
 	if !ok {
@@ -123,33 +123,12 @@ func (opts *Options) ListFunc(key string) func() [][]string {
 		panic(fmt.Errorf("config option %q is a scalar, not a set", key))
 	}
 
-	// old is the last successfully retrieved tuple set for this
-	// configuration key. The returned closure updates it on
-	// every successful lookup. If an error occurs, the closure
-	// returns this value.
-	var old atomic.Value
-	old.Store([][]string(nil))
-
+	get := opts.watchedValue(key)
 	return func() [][]string {
-		var set configpb.ValueSet
-		_, err := opts.sdb.GetStale(path.Join(sinkdbPrefix, key), &set)
-		if err != nil {
-			opts.errsMu.Lock()
-			opts.errs[key] = err
-			opts.errsMu.Unlock()
-			return old.Load().([][]string)
-		}
-
-		// clear any error for this key bc we succeeded
-		opts.errsMu.Lock()
-		delete(opts.errs, key)
-		opts.errsMu.Unlock()
-
 		var tuples [][]string
-		for _, tup := range set.Tuples {
+		for _, tup := range get().Tuples {
 			tuples = append(tuples, tup.Values)
 		}
-		old.Store(tuples)
 		return tuples
 	}
 }
@@ -162,9 +141,9 @@ func (opts *Options) ListFunc(key string) func() [][]string {
 // undefined or is defined as a set of tuples.
 //
 // The returned function performs a stale read of the configuration
-// value. If an error occurs while reading the value, the old
-// value is returned, and the error is saved on the Options
-// type to be returned in Err.
+// value, and caches it until the value changes. If an error occurs
+// while reading the value, the old value is returned, and the error
+// is saved on the Options type to be returned in Err.
 func (opts *Options) GetFunc(key string) func() []string {
 	opt, ok := opts.schema[key]
 	if !ok {
@@ -173,21 +152,56 @@ func (opts *Options) GetFunc(key string) func() []string {
 		panic(fmt.Errorf("config option %q is a set, not a scalar", key))
 	}
 
-	// old is the last successfully retrieved tuple for this
-	// configuration key. The returned closure updates it on
-	// every successful lookup. If an error occurs, the closure
-	// returns this value.
-	var old atomic.Value
-	old.Store([]string(nil))
-
+	get := opts.watchedValue(key)
 	return func() []string {
-		var set configpb.ValueSet
-		_, err := opts.sdb.GetStale(path.Join(sinkdbPrefix, key), &set)
+		set := get()
+		if len(set.Tuples) == 0 {
+			return nil
+		}
+		return set.Tuples[0].Values
+	}
+}
+
+// watchedValue returns a closure that returns the stored value
+// for the provided key. It reads the value from sinkdb the first
+// time it's called, and again only after sinkdb reports that the
+// value has changed.
+//
+// If an error occurs while reading the value, the closure
+// returns the last value it read, and saves the error to be
+// returned in Err. It tries again on the next call.
+func (opts *Options) watchedValue(key string) func() *configpb.ValueSet {
+	sinkdbKey := path.Join(sinkdbPrefix, key)
+
+	// Start watching before the first read, so that
+	// no change goes unnoticed.
+	changed := opts.sdb.Watch(context.Background(), sinkdbKey)
+
+	var (
+		mu    sync.Mutex
+		valid bool
+		last  = new(configpb.ValueSet)
+	)
+	return func() *configpb.ValueSet {
+		mu.Lock()
+		defer mu.Unlock()
+
+		select {
+		case <-changed:
+			valid = false
+		default:
+		}
+		if valid {
+			return last
+		}
+
+		set := new(configpb.ValueSet)
+		_, err := opts.sdb.GetStale(sinkdbKey, set)
 		if err != nil {
 			opts.errsMu.Lock()
 			opts.errs[key] = err
 			opts.errsMu.Unlock()
-			return old.Load().([]string)
+			return last
 		}
 
 		// clear any error for this key bc we succeeded
@@ -195,12 +209,8 @@ func (opts *Options) GetFunc(key string) func() []string {
 		delete(opts.errs, key)
 		opts.errsMu.Unlock()
 
-		if len(set.Tuples) == 0 {
-			old.Store([]string(nil))
-			return nil
-		}
-		old.Store(set.Tuples[0].Values)
-		return set.Tuples[0].Values
+		last, valid = set, true
+		return last
 	}
 }
 
@@ -85,11 +85,21 @@ func TestListFunc(t *testing.T) {
 	// want a deterministic test case
 	must(t, sdb.RaftService().WaitRead(ctx))
 
-	got := opts.ListFunc("example")()
+	list := opts.ListFunc("example")
+	got := list()
 	want := [][]string{{"foo"}, {"bar"}}
 	if !reflect.DeepEqual(got, want) {
 		t.Errorf("got %#v, want %#v", got, want)
 	}
+
+	// The cached value is replaced once the option changes.
+	must(t, sdb.Exec(ctx, opts.Remove("example", []string{"foo"})))
+	must(t, sdb.RaftService().WaitRead(ctx))
+	got = list()
+	want = [][]string{{"bar"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after Remove, got %#v, want %#v", got, want)
+	}
 }
 
 func TestSet(t *testing.T) {
@@ -24,6 +24,8 @@ var (
 	errAlreadyConfigured = errors.New("core is already configured; must reset first")
 	errUnconfigured      = errors.New("core is not configured")
 	errNoMockHSM         = errors.New("core is not configured with a mockhsm")
+	errNoKeystore        = errors.New("core is not configured with a keystore")
+	errNoTxSigners       = errors.New("core is not configured with transaction signers")
 	errNoReset           = errors.New("core is not configured with reset capabilities")
 	errBadBlockPub       = errors.New("supplied block pub key is invalid")
 	errNoClientTokens    = errors.New("cannot enable client auth without client access tokens")
@@ -75,6 +77,9 @@ func (a *API) leaderInfo(ctx context.Context) (map[string]interface{}, error) {
 	a.downloadingSnapshotMu.Unlock()
 
 	localHeight := a.chain.Height()
+	if a.light != nil {
+		localHeight = a.light.Height()
+	}
 
 	if a.config.IsGenerator {
 		now := time.Now()
@@ -108,6 +113,7 @@ func (a *API) leaderInfo(ctx context.Context) (map[string]interface{}, error) {
 		"configured_at":                     time.Unix(configuredAtSecs, configuredAtNSecs).UTC(),
 		"is_signer":                         a.config.IsSigner,
 		"is_generator":                      a.config.IsGenerator,
+		"is_light_client":                   a.light != nil,
 		"generator_url":                     a.config.GeneratorUrl,
 		"generator_access_token":            obfuscateTokenSecret(a.config.GeneratorAccessToken),
 		"blockchain_id":                     a.config.BlockchainId,
@@ -124,6 +130,11 @@ func (a *API) leaderInfo(ctx context.Context) (map[string]interface{}, error) {
 		"health":                            a.health(),
 	}
 
+	// Add in the health of each peer we replicate blocks from.
+	if a.replicator != nil {
+		m["block_peers"] = a.replicator.Peers()
+	}
+
 	// Add in snapshot information if we're downloading a snapshot.
 	if snapshot != nil {
 		downloadedBytes, totalBytes := snapshot.Progress()
@@ -293,6 +304,75 @@ func (a *API) evict(ctx context.Context, x struct {
 	return a.sdb.RaftService().Evict(ctx, x.NodeAddress)
 }
 
+// raftStatus returns the status of the raft cluster
+// as seen by this node.
+//
+// POST /raft-status
+func (a *API) raftStatus(ctx context.Context) (*raft.Status, error) {
+	return a.sdb.RaftService().Status()
+}
+
+// transferLeadership hands raft leadership to the node at
+// x.NodeAddress, or, if it's empty, to the node furthest
+// caught up.
+//
+// POST /transfer-leadership
+func (a *API) transferLeadership(ctx context.Context, x struct {
+	NodeAddress string `json:"node_address"`
+}) error {
+	if x.NodeAddress != "" {
+		if err := validateAddress(x.NodeAddress); err != nil {
+			return err
+		}
+	}
+	return a.sdb.RaftService().TransferLeadership(ctx, x.NodeAddress)
+}
+
+// sinkdbBackup returns a copy of the cluster's replicated data:
+// its configuration, authorization grants, and members.
+//
+// POST /sinkdb-backup
+func (a *API) sinkdbBackup(ctx context.Context) (*sinkdb.Backup, error) {
+	return a.sdb.Backup(ctx)
+}
+
+// sinkdbRestore initializes a new cluster, with this process as
+// its only member, from a backup made by /sinkdb-backup. Entries
+// in x.RewriteAddresses replace allowed member addresses in the
+// backup. It's for recovering a cluster that has lost a majority
+// of its members.
+//
+// POST /sinkdb-restore
+func (a *API) sinkdbRestore(ctx context.Context, x struct {
+	Backup           *sinkdb.Backup    `json:"backup"`
+	RewriteAddresses map[string]string `json:"rewrite_addresses"`
+}) error {
+	if x.Backup == nil {
+		return errors.WithDetail(httpjson.ErrBadRequest, "missing backup")
+	}
+	for _, addr := range x.RewriteAddresses {
+		if err := validateAddress(addr); err != nil {
+			return err
+		}
+	}
+	err := a.sdb.Restore(x.Backup, x.RewriteAddresses)
+	if err != nil {
+		return err
+	}
+
+	// add this process's address as an allowed member
+	err = a.addAllowedMember(ctx, struct{ Addr string }{a.addr})
+	if err != nil {
+		return err
+	}
+
+	// The backup might hold a configuration. Exec self
+	// to restart cored and attempt to load the config.
+	closeConnOK(httpjson.ResponseWriter(ctx), httpjson.Request(ctx))
+	execSelf("")
+	panic("unreached")
+}
+
 func validateAddress(addr string) error {
 	_, _, err := net.SplitHostPort(addr)
 	if err != nil {
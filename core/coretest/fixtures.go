@@ -36,7 +36,7 @@ func CreatePins(ctx context.Context, t testing.TB, s *pin.Store) {
 
 func CreateAccount(ctx context.Context, t testing.TB, accounts *account.Manager, alias string, tags map[string]interface{}) string {
 	keys := []chainkd.XPub{testutil.TestXPub}
-	acc, err := accounts.Create(ctx, keys, 1, alias, tags, "")
+	acc, err := accounts.Create(ctx, keys, 1, nil, nil, alias, tags, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -45,7 +45,7 @@ func CreateAccount(ctx context.Context, t testing.TB, accounts *account.Manager,
 
 func CreateAsset(ctx context.Context, t testing.TB, assets *asset.Registry, def map[string]interface{}, alias string, tags map[string]interface{}) bc.AssetID {
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset, err := assets.Define(ctx, keys, 1, def, alias, tags, "")
+	asset, err := assets.Define(ctx, keys, 1, nil, def, alias, tags, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -96,8 +96,8 @@ func SignTxTemplate(t testing.TB, ctx context.Context, template *txbuilder.Templ
 	if priv == nil {
 		priv = &testutil.TestXPrv
 	}
-	err := txbuilder.Sign(ctx, template, []chainkd.XPub{priv.XPub()}, func(_ context.Context, _ chainkd.XPub, path [][]byte, data [32]byte) ([]byte, error) {
-		derived := priv.Derive(path)
+	err := txbuilder.Sign(ctx, template, []chainkd.XPub{priv.XPub()}, func(_ context.Context, _ chainkd.XPub, path [][]byte, hardened int, _ *chainkd.XPub, data [32]byte) ([]byte, error) {
+		derived := priv.DeriveHardened(path, hardened)
 		return derived.Sign(data[:]), nil
 	})
 	if err != nil {
@@ -20,13 +20,21 @@ import (
 )
 
 var (
-	persistBlockchainReset = []string{"mockhsm", "access_tokens"}
-	neverReset             = []string{"migrations"}
+	persistBlockchainReset = []string{
+		"mockhsm",
+		"mockhsm_sign_events",
+		"keystore",
+		"keystore_keys",
+		"keystore_sign_events",
+		"access_tokens",
+		"audit_events",
+	}
+	neverReset = []string{"migrations"}
 )
 
 // ResetBlockchain deletes all blockchain data, resulting in an
-// unconfigured core. It does not delete access tokens or mockhsm
-// keys.
+// unconfigured core. It does not delete access tokens, keys in the
+// mockhsm or keystore, their sign events, or the audit log.
 func ResetBlockchain(ctx context.Context, db pg.DB, sdb *sinkdb.DB) error {
 	if !config.BuildConfig.Reset {
 		// Shouldn't ever happen; This package shouldn't even be
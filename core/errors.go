@@ -6,13 +6,17 @@ import (
 	"chain/core/accesstoken"
 	"chain/core/account"
 	"chain/core/asset"
+	"chain/core/audit"
 	"chain/core/blocksigner"
 	"chain/core/config"
+	"chain/core/keystore"
 	"chain/core/leader"
+	"chain/core/light"
 	"chain/core/query"
 	"chain/core/query/filter"
 	"chain/core/rpc"
 	"chain/core/signers"
+	"chain/core/signsession"
 	"chain/core/txbuilder"
 	"chain/core/txfeed"
 	"chain/database/pg"
@@ -66,11 +70,14 @@ var errorFormatter = httperror.Formatter{
 		txbuilder.ErrMissingFields: {400, "CH010", "One or more fields are missing"},
 		authz.ErrNotAuthorized:     {403, "CH011", "Request is unauthorized"},
 		sinkdb.ErrConflict:         {409, "CH012", "Conflict processing request"},
+		errOutOfScope:              {403, "CH013", "Request is outside the scope of its authorization grants"},
+		errQuotaExceeded:           {429, "CH014", "Daily request quota exceeded"},
 		asset.ErrDuplicateAlias:    {400, "CH050", "Alias already exists"},
 		account.ErrDuplicateAlias:  {400, "CH050", "Alias already exists"},
 		txfeed.ErrDuplicateAlias:   {400, "CH050", "Alias already exists"},
 		account.ErrBadIdentifier:   {400, "CH051", "Either an ID or alias must be provided, but not both"},
 		asset.ErrBadIdentifier:     {400, "CH051", "Either an ID or alias must be provided, but not both"},
+		asset.ErrBadIssuanceRules:  {400, "CH052", "Invalid asset issuance rules"},
 
 		// Core error namespace
 		errUnconfigured:                {400, "CH100", "This core still needs to be configured"},
@@ -84,7 +91,10 @@ var errorFormatter = httperror.Formatter{
 		config.ErrBadQuorum:            {400, "CH108", "Quorum must be greater than 0 if there are signers"},
 		config.ErrNoBlockPub:           {400, "CH109", "Block Pub cannot be empty when configuring a mockhsm disabled signer"},
 		errNoMockHSM:                   {400, "CH110", "This endpoint is disabled for this server's configuration"},
+		errNoKeystore:                  {400, "CH110", "This endpoint is disabled for this server's configuration"},
+		errNoTxSigners:                 {400, "CH110", "This endpoint is disabled for this server's configuration"},
 		errNoReset:                     {400, "CH110", "This endpoint is disabled for this server's configuration"},
+		errNotLightClient:              {400, "CH110", "This endpoint is disabled for this server's configuration"},
 		config.ErrNoBlockHSMURL:        {400, "CH111", "Block HSM URL cannot be empty when configuring a non mockhsm signer"},
 		errNoClientTokens:              {400, "CH120", "Cannot enable client authentication with no client tokens"},
 		blocksigner.ErrConsensusChange: {400, "CH150", "Refuse to sign block with consensus change"},
@@ -95,19 +105,27 @@ var errorFormatter = httperror.Formatter{
 		raft.ErrExistingCluster:        {400, "CH164", "Already connected to a cluster"},
 		raft.ErrPeerUninitialized:      {400, "CH165", "Peer node is uninitialized"},
 		raft.ErrUnknownPeer:            {400, "CH166", "Unknown peer"},
+		raft.ErrNotLeader:              {400, "CH167", "This node is not the cluster leader"},
+		raft.ErrLeaderTransfer:         {503, "CH168", "Leadership transfer did not complete; try again soon"},
+		sinkdb.ErrBadBackup:            {400, "CH169", "Invalid cluster backup"},
 		config.ErrConfigOp:             {400, "CH170", "Invalid configuration operation"},
+		errNoStateAtHeight:             {400, "CH180", "State is unavailable at the requested height"},
 
 		// Signers error namespace (2xx)
-		signers.ErrBadQuorum: {400, "CH200", "Quorum must be greater than 1 and less than or equal to the length of xpubs"},
-		signers.ErrBadXPub:   {400, "CH201", "Invalid xpub format"},
-		signers.ErrNoXPubs:   {400, "CH202", "At least one xpub is required"},
-		signers.ErrBadType:   {400, "CH203", "Retrieved type does not match expected type"},
-		signers.ErrDupeXPub:  {400, "CH204", "Root XPubs cannot contain the same key more than once"},
+		signers.ErrBadQuorum:   {400, "CH200", "Quorum must be greater than 1 and less than or equal to the length of xpubs"},
+		signers.ErrBadXPub:     {400, "CH201", "Invalid xpub format"},
+		signers.ErrNoXPubs:     {400, "CH202", "At least one xpub is required"},
+		signers.ErrBadType:     {400, "CH203", "Retrieved type does not match expected type"},
+		signers.ErrDupeXPub:    {400, "CH204", "Root XPubs cannot contain the same key more than once"},
+		signers.ErrBadHardened: {400, "CH205", "Hardened derivation requires a path prefix and an account xpub for each root xpub"},
+
+		signers.ErrBadHardenedXPub: {400, "CH206", "Account xpub could not be verified against its root xpub"},
 
 		// Access token and grant error namespace (3xx)
 		accesstoken.ErrBadID:       {400, "CH300", "Malformed or empty access token id"},
 		accesstoken.ErrBadType:     {400, "CH301", "Access tokens must be type client or network"},
 		accesstoken.ErrDuplicateID: {400, "CH302", "Access token id is already in use"},
+		accesstoken.ErrBadExpiry:   {400, "CH304", "Access token expiration time must be in the future"},
 		errMissingTokenID:          {400, "CH303", "Access token id does not exist"},
 		errCurrentToken:            {400, "CH310", "The access token used to authenticate this request cannot be deleted"},
 		errProtectedGrant:          {400, "CH320", "Protected grants cannot be manually deleted"},
@@ -115,6 +133,8 @@ var errorFormatter = httperror.Formatter{
 
 		// Query error namespace (6xx)
 		query.ErrBadAfter:               {400, "CH600", "Malformed pagination parameter `after`"},
+		light.ErrBadCursor:              {400, "CH600", "Malformed pagination parameter `after`"},
+		audit.ErrInvalidAfter:           {400, "CH600", "Malformed pagination parameter `after`"},
 		query.ErrParameterCountMismatch: {400, "CH601", "Incorrect number of parameters to filter"},
 		filter.ErrBadFilter:             {400, "CH602", "Malformed query filter"},
 
@@ -127,6 +147,9 @@ var errorFormatter = httperror.Formatter{
 		txbuilder.ErrBadAmount:  {400, "CH704", "Invalid asset amount"},
 		txbuilder.ErrBlankCheck: {400, "CH705", "Unsafe transaction: leaves assets to be taken without requiring payment"},
 		txbuilder.ErrAction:     {400, "CH706", "One or more actions had an error: see attached data"},
+		asset.ErrIssuanceWindow: {400, "CH707", "Asset cannot be issued at this time"},
+		asset.ErrNoDelegate:     {400, "CH708", "Asset has no issuance delegate"},
+		asset.ErrDelegateLimit:  {400, "CH709", "Amount exceeds the issuance delegate's limit"},
 
 		// Submit error namespace (73x)
 		txbuilder.ErrMissingRawTx:          {400, "CH730", "Missing raw transaction"},
@@ -138,10 +161,29 @@ var errorFormatter = httperror.Formatter{
 		txbuilder.ErrTxSignatureFailure:    {400, "CH737", "Transaction signature missing, client may be missing signature key"},
 		txbuilder.ErrNoTxSighashAttempt:    {400, "CH738", "Transaction signature was not attempted"},
 
+		// Signing session error namespace (74x)
+		signsession.ErrDuplicateAlias: {400, "CH050", "Alias already exists"},
+		txbuilder.ErrBadSignature:     {400, "CH740", "Invalid signature in template"},
+		txbuilder.ErrTemplateMismatch: {400, "CH741", "Template does not match the signing session's template"},
+		signsession.ErrNoMaxTime:      {400, "CH742", "Template must have a max time to start a signing session"},
+		signsession.ErrExpired:        {400, "CH743", "Signing session has expired"},
+
 		// account action error namespace (76x)
 		account.ErrInsufficient: {400, "CH760", "Insufficient funds for tx"},
 		account.ErrReserved:     {400, "CH761", "Some outputs are reserved; try again"},
 
 		// Mock HSM error namespace (80x)
+
+		// Keystore error namespace (81x)
+		keystore.ErrDuplicateKeyAlias:    {400, "CH050", "Alias already exists"},
+		keystore.ErrInvalidAfter:         {400, "CH801", "Invalid `after` in query"},
+		keystore.ErrTooManyAliasesToList: {400, "CH802", "Too many aliases to list"},
+		keystore.ErrLocked:               {400, "CH810", "Keystore is locked"},
+		keystore.ErrBadPassphrase:        {400, "CH811", "Invalid keystore passphrase or master key"},
+		keystore.ErrBadExport:            {400, "CH812", "Invalid exported key"},
+		keystore.ErrNoKey:                {400, "CH813", "Key not found in keystore"},
+		keystore.ErrUninitialized:        {400, "CH814", "Keystore is not initialized"},
+		keystore.ErrInitialized:          {400, "CH815", "Keystore is already initialized"},
+		keystore.ErrBlockKey:             {400, "CH816", "Block signing keys are only exported on request"},
 	},
 }
@@ -0,0 +1,147 @@
+package fetch
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"chain/core/rpc"
+	"chain/errors"
+	"chain/protocol/bc/legacy"
+)
+
+const (
+	// MaxBatchBlocks is the maximum number of blocks returned
+	// by a single call to /rpc/get-blocks.
+	MaxBatchBlocks = 1000
+
+	// MaxBatchBytes is the size, in uncompressed bytes, beyond
+	// which /rpc/get-blocks stops adding blocks to a response.
+	MaxBatchBytes = 16 << 20
+
+	// MaxFrameSize bounds the size of a single framed block, so
+	// a corrupt or hostile stream can't make a reader allocate
+	// much memory. /rpc/get-blocks stops before a larger block,
+	// and the Replicator downloads it by itself.
+	MaxFrameSize = 4 << 20
+
+	// batchThreshold is how far behind its peers a Replicator
+	// must be before it downloads blocks in batches.
+	batchThreshold = 100
+
+	// batchTimeout is the time allowed for downloading a batch.
+	batchTimeout = time.Minute
+)
+
+var errBadFrame = errors.New("malformed block frame")
+
+// WriteBlockFrame writes a serialized block to w, prefixed by its
+// length as a 4-byte big-endian integer. A response from
+// /rpc/get-blocks is a sequence of such frames.
+func WriteBlockFrame(w io.Writer, rawBlock []byte) error {
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(len(rawBlock)))
+	_, err := w.Write(prefix[:])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(rawBlock)
+	return err
+}
+
+// readBlockFrames reads framed blocks from r until EOF. If the
+// stream is truncated or malformed, it returns the blocks read so
+// far along with an error.
+func readBlockFrames(r io.Reader) ([]*legacy.Block, error) {
+	var blocks []*legacy.Block
+	for {
+		var prefix [4]byte
+		_, err := io.ReadFull(r, prefix[:])
+		if err == io.EOF {
+			return blocks, nil
+		} else if err != nil {
+			return blocks, errors.Wrap(err, "reading frame length")
+		}
+		n := binary.BigEndian.Uint32(prefix[:])
+		if n > MaxFrameSize {
+			return blocks, errors.WithDetailf(errBadFrame, "frame length %d exceeds maximum", n)
+		}
+		data := make([]byte, n)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return blocks, errors.Wrap(err, "reading frame")
+		}
+		b := new(legacy.Block)
+		err = b.UnmarshalBinary(data)
+		if err != nil {
+			return blocks, errors.Sub(errBadFrame, err)
+		}
+		blocks = append(blocks, b)
+	}
+}
+
+// getBlocks sends a get-blocks RPC request to another Core for
+// consecutive blocks starting at height. It returns the blocks it
+// received before any error, as long as they're in sequence.
+func getBlocks(ctx context.Context, peer *rpc.Client, height uint64, count int) ([]*legacy.Block, error) {
+	ctx, cancel := context.WithTimeout(ctx, batchTimeout)
+	defer cancel()
+
+	path := fmt.Sprintf("/rpc/get-blocks?from=%d&count=%d", height, count)
+	r, err := peer.CallRaw(ctx, path, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "get blocks rpc")
+	}
+	defer r.Close()
+
+	blocks, err := readBlockFrames(r)
+	for i, b := range blocks {
+		if b.Height != height+uint64(i) {
+			return blocks[:i], errors.WithDetailf(errBadFrame, "got block %d, want %d", b.Height, height+uint64(i))
+		}
+	}
+	return blocks, errors.Wrap(err, "get blocks rpc")
+}
+
+// getBlockBatch requests a batch of blocks starting at height from
+// each eligible peer in order of preference until one of them
+// provides at least one block. Only peers known to have a block
+// far ahead of height are eligible. It returns no blocks and a nil
+// error if no peer is eligible.
+func (rep *Replicator) getBlockBatch(ctx context.Context, height uint64) ([]*legacy.Block, *peer, error) {
+	rep.mu.Lock()
+	var peers []*peer
+	for _, p := range rankPeers(rep.peers, height+batchThreshold, time.Now()) {
+		if p.height >= height+batchThreshold && !p.noBatch {
+			peers = append(peers, p)
+		}
+	}
+	rep.mu.Unlock()
+
+	var lastErr error
+	for _, p := range peers {
+		start := time.Now()
+		blocks, err := getBlocks(ctx, p.client, height, MaxBatchBlocks)
+
+		rep.mu.Lock()
+		if len(blocks) > 0 {
+			p.recordSuccess(time.Since(start))
+		} else if statusErr, ok := errors.Root(err).(rpc.ErrStatusCode); ok && statusErr.StatusCode == 404 {
+			// The peer predates /rpc/get-blocks. Stop asking.
+			p.noBatch = true
+		} else if err != nil {
+			p.recordFailure(err)
+		}
+		rep.mu.Unlock()
+
+		if len(blocks) > 0 {
+			return blocks, p, nil
+		}
+		if err != nil {
+			lastErr = errors.Wrap(err, p.client.BaseURL)
+		}
+	}
+	return nil, nil, lastErr
+}
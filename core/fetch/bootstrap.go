@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +17,11 @@ import (
 	"chain/protocol/bc"
 )
 
+var (
+	errBadSnapshotManifest = errors.New("invalid snapshot manifest")
+	errBadSnapshotChunk    = errors.New("invalid snapshot chunk")
+)
+
 // SnapshotProgress describes a snapshot being downloaded from a peer Core.
 type SnapshotProgress struct {
 	mu               sync.Mutex
@@ -24,6 +30,13 @@ type SnapshotProgress struct {
 	size             uint64
 	downloadProgress *progressReader
 
+	// manifest and data hold the verified chunks downloaded
+	// so far, so that a failed attempt can resume where the
+	// previous attempt left off.
+	manifest *txdb.SnapshotManifest
+	data     []byte
+	chunks   int
+
 	stopped chan struct{}
 }
 
@@ -50,10 +63,39 @@ func (s *SnapshotProgress) Height() uint64 {
 func (s *SnapshotProgress) Progress() (downloaded, total uint64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.downloadProgress == nil {
-		return 0, s.size
+	downloaded = uint64(len(s.data))
+	if s.downloadProgress != nil {
+		downloaded += s.downloadProgress.BytesRead()
+	}
+	return downloaded, s.size
+}
+
+// chunksDone returns the number of verified snapshot chunks
+// downloaded so far.
+func (s *SnapshotProgress) chunksDone() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chunks
+}
+
+// partial returns the manifest of a partially downloaded snapshot,
+// or nil if no chunks have been downloaded.
+func (s *SnapshotProgress) partial() *txdb.SnapshotManifest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chunks == 0 {
+		return nil
 	}
-	return s.downloadProgress.BytesRead(), s.size
+	return s.manifest
+}
+
+// reset discards any partially downloaded snapshot.
+func (s *SnapshotProgress) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.manifest = nil
+	s.data = nil
+	s.chunks = 0
 }
 
 // Wait blocks until the snapshot is either successfully downloaded and
@@ -65,25 +107,38 @@ func (s *SnapshotProgress) Wait() {
 // BootstrapSnapshot downloads and stores the most recent snapshot from the
 // provided peer. It's run when bootstrapping a new Core to an existing
 // network. It should be run before invoking Chain.Recover.
+//
+// If the peer supports it, the snapshot is downloaded in chunks,
+// each of which is verified against the snapshot's manifest. A failed
+// attempt resumes from the last verified chunk, and attempts that
+// make progress don't count against the limit on failed attempts.
 func BootstrapSnapshot(ctx context.Context, c *protocol.Chain, store protocol.Store, peer *rpc.Client, health func(error)) *SnapshotProgress {
-	const maxAttempts = 5
+	const maxFailures = 5
 
 	// Return a *SnapshotProgress so that the caller can track the
 	// progress of the download.
 	progress := &SnapshotProgress{stopped: make(chan struct{})}
 	go func() {
-		for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var failures int
+		for attempt := 1; failures < maxFailures; attempt++ {
 			progress.mu.Lock()
 			progress.attempt = attempt
 			progress.downloadProgress = nil
 			progress.mu.Unlock()
 
+			chunks := progress.chunksDone()
 			err := fetchSnapshot(ctx, peer, store, progress)
 			health(err)
 			if err == nil {
 				break
 			}
 			logNetworkError(ctx, err)
+
+			if progress.chunksDone() > chunks {
+				failures = 0
+			} else {
+				failures++
+			}
 		}
 		close(progress.stopped)
 	}()
@@ -96,7 +151,6 @@ func BootstrapSnapshot(ctx context.Context, c *protocol.Chain, store protocol.St
 // they can index them properly.
 func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, progress *SnapshotProgress) error {
 	const getBlockTimeout = 30 * time.Second
-	const readSnapshotTimeout = 30 * time.Second
 
 	var info struct {
 		Height       uint64  `json:"height"`
@@ -111,31 +165,26 @@ func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, prog
 		return nil
 	}
 
-	downloadCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	// Download the snapshot, recording our progress as we go.
-	body, err := peer.CallRaw(downloadCtx, "/rpc/get-snapshot", info.Height)
-	if err != nil {
-		return errors.Wrap(err, "getting snapshot")
+	// If a previous attempt partially downloaded an older snapshot,
+	// keep going with it rather than starting over. Peers retain
+	// snapshots for a while after newer ones are taken.
+	height, size := info.Height, info.Size
+	if m := progress.partial(); m != nil && m.Height != info.Height {
+		height, size = m.Height, m.Size
 	}
-	defer body.Close()
 
-	// Wrap the response body reader in our progress reader and save
-	// snapshot metadata.
-	progress.mu.Lock()
-	progress.size = info.Size
-	progress.height = info.Height
-	progress.downloadProgress = new(progressReader)
-	progress.downloadProgress.reader = body
-	progress.downloadProgress.setTimeout(readSnapshotTimeout, cancel)
-	progress.mu.Unlock()
-
-	b, err := ioutil.ReadAll(progress.downloadProgress)
-	if err != nil {
+	b, err := downloadSnapshot(ctx, peer, height, size, progress)
+	if _, ok := errors.Root(err).(rpc.ErrStatusCode); ok && height != info.Height {
+		// The older snapshot is probably no longer available. Start
+		// over with the latest snapshot on the next attempt.
+		progress.reset()
+		return err
+	} else if err != nil {
 		return err
 	}
 	snapshot, err := txdb.DecodeSnapshot(b)
 	if err != nil {
+		progress.reset()
 		return err
 	}
 	// Delete the snapshot issuances because we don't have any commitment
@@ -155,7 +204,7 @@ func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, prog
 	}
 
 	// Also get the corresponding block.
-	snapshotBlock, err := getBlock(ctx, peer, info.Height, getBlockTimeout)
+	snapshotBlock, err := getBlock(ctx, peer, height, getBlockTimeout)
 	if err != nil {
 		return err
 	}
@@ -163,7 +212,10 @@ func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, prog
 		// Something seriously funny is still afoot.
 		return errors.New("generator provided snapshot but could not provide block")
 	}
+	// Verify the reconstructed state tree against the block header's
+	// commitment before using it.
 	if snapshotBlock.AssetsMerkleRoot != snapshot.Tree.RootHash() {
+		progress.reset()
 		return errors.New("snapshot merkle root doesn't match block")
 	}
 
@@ -180,6 +232,161 @@ func fetchSnapshot(ctx context.Context, peer *rpc.Client, s protocol.Store, prog
 	return errors.Wrap(err, "saving bootstrap snaphot")
 }
 
+// downloadSnapshot downloads the raw snapshot at the provided height,
+// verifying each chunk against the peer's manifest. It resumes from
+// any chunks already recorded in progress. If the peer doesn't serve
+// snapshot manifests, it falls back to downloading the whole snapshot
+// in a single request.
+func downloadSnapshot(ctx context.Context, peer *rpc.Client, height, size uint64, progress *SnapshotProgress) ([]byte, error) {
+	manifest := new(txdb.SnapshotManifest)
+	err := peer.Call(ctx, "/rpc/get-snapshot-manifest", height, manifest)
+	if statusErr, ok := errors.Root(err).(rpc.ErrStatusCode); ok && statusErr.StatusCode == http.StatusNotFound {
+		// The peer predates chunked snapshots.
+		progress.reset()
+		return downloadWholeSnapshot(ctx, peer, height, size, progress)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "getting snapshot manifest")
+	}
+	err = checkManifest(manifest, height)
+	if err != nil {
+		return nil, err
+	}
+
+	progress.mu.Lock()
+	if !resumable(progress.manifest, manifest, progress.chunks) {
+		progress.manifest = manifest
+		progress.data = nil
+		progress.chunks = 0
+	}
+	progress.size = manifest.Size
+	progress.height = manifest.Height
+	next := progress.chunks
+	progress.mu.Unlock()
+
+	for i := next; i < len(manifest.Chunks); i++ {
+		chunk, err := downloadSnapshotChunk(ctx, peer, manifest, i, progress)
+		if err != nil {
+			return nil, errors.Wrapf(err, "downloading snapshot chunk %d", i)
+		}
+
+		progress.mu.Lock()
+		progress.data = append(progress.data, chunk...)
+		progress.chunks = i + 1
+		progress.downloadProgress = nil
+		progress.mu.Unlock()
+	}
+
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	return progress.data, nil
+}
+
+// downloadSnapshotChunk downloads and verifies the i'th chunk of the
+// snapshot described by manifest.
+func downloadSnapshotChunk(ctx context.Context, peer *rpc.Client, manifest *txdb.SnapshotManifest, i int, progress *SnapshotProgress) ([]byte, error) {
+	const readChunkTimeout = 30 * time.Second
+
+	downloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req := struct {
+		Height uint64 `json:"height"`
+		Index  int    `json:"index"`
+	}{manifest.Height, i}
+	body, err := peer.CallRaw(downloadCtx, "/rpc/get-snapshot-chunk", req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	pr := &progressReader{reader: body}
+	pr.setTimeout(readChunkTimeout, cancel)
+	progress.mu.Lock()
+	progress.downloadProgress = pr
+	progress.mu.Unlock()
+
+	start, end := manifest.ChunkRange(i)
+	chunk, err := ioutil.ReadAll(io.LimitReader(pr, int64(end-start)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(chunk)) != end-start {
+		return nil, errors.Wrapf(errBadSnapshotChunk, "got %d bytes, want %d", len(chunk), end-start)
+	}
+	if txdb.SnapshotChunkHash(chunk) != manifest.Chunks[i] {
+		return nil, errors.Wrap(errBadSnapshotChunk, "hash mismatch")
+	}
+	return chunk, nil
+}
+
+// downloadWholeSnapshot downloads the raw snapshot at the provided
+// height in a single request.
+func downloadWholeSnapshot(ctx context.Context, peer *rpc.Client, height, size uint64, progress *SnapshotProgress) ([]byte, error) {
+	const readSnapshotTimeout = 30 * time.Second
+
+	downloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Download the snapshot, recording our progress as we go.
+	body, err := peer.CallRaw(downloadCtx, "/rpc/get-snapshot", height)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting snapshot")
+	}
+	defer body.Close()
+
+	// Wrap the response body reader in our progress reader and save
+	// snapshot metadata.
+	progress.mu.Lock()
+	progress.size = size
+	progress.height = height
+	progress.downloadProgress = new(progressReader)
+	progress.downloadProgress.reader = body
+	progress.downloadProgress.setTimeout(readSnapshotTimeout, cancel)
+	progress.mu.Unlock()
+
+	data, err := ioutil.ReadAll(io.LimitReader(progress.downloadProgress, txdb.MaxSnapshotSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > txdb.MaxSnapshotSize {
+		return nil, errors.New("snapshot exceeds maximum size")
+	}
+	return data, nil
+}
+
+// checkManifest performs sanity checks on a manifest received
+// from a peer.
+func checkManifest(m *txdb.SnapshotManifest, height uint64) error {
+	if m.Height != height {
+		return errors.Wrapf(errBadSnapshotManifest, "got height %d, want %d", m.Height, height)
+	}
+	if m.ChunkSize != txdb.SnapshotChunkSize {
+		return errors.Wrapf(errBadSnapshotManifest, "got chunk size %d, want %d", m.ChunkSize, txdb.SnapshotChunkSize)
+	}
+	if m.Size > txdb.MaxSnapshotSize {
+		return errors.Wrapf(errBadSnapshotManifest, "size %d exceeds maximum %d", m.Size, txdb.MaxSnapshotSize)
+	}
+	if want := (m.Size + m.ChunkSize - 1) / m.ChunkSize; uint64(len(m.Chunks)) != want {
+		return errors.Wrapf(errBadSnapshotManifest, "got %d chunks, want %d", len(m.Chunks), want)
+	}
+	return nil
+}
+
+// resumable returns whether the first n chunks downloaded for
+// manifest old may be reused for manifest new.
+func resumable(old, new *txdb.SnapshotManifest, n int) bool {
+	if old == nil || old.Height != new.Height || old.Size != new.Size || old.ChunkSize != new.ChunkSize {
+		return false
+	}
+	if n > len(old.Chunks) || n > len(new.Chunks) {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if old.Chunks[i] != new.Chunks[i] {
+			return false
+		}
+	}
+	return true
+}
+
 type progressReader struct {
 	reader io.Reader
 	read   uint64
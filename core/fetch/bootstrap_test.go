@@ -0,0 +1,144 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"chain/core/rpc"
+	"chain/core/txdb"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestDownloadSnapshotResume(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 2*txdb.SnapshotChunkSize+4)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	manifest := &txdb.SnapshotManifest{Height: 7, Size: uint64(len(data)), ChunkSize: txdb.SnapshotChunkSize}
+	for i := 0; i < 3; i++ {
+		start, end := manifest.ChunkRange(i)
+		manifest.Chunks = append(manifest.Chunks, txdb.SnapshotChunkHash(data[start:end]))
+	}
+
+	var (
+		mu        sync.Mutex // protects requested and failed
+		requested []int
+		failed    bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rpc/get-snapshot-manifest":
+			json.NewEncoder(rw).Encode(manifest)
+		case "/rpc/get-snapshot-chunk":
+			var chunkReq struct{ Index int }
+			json.NewDecoder(req.Body).Decode(&chunkReq)
+			mu.Lock()
+			requested = append(requested, chunkReq.Index)
+			drop := chunkReq.Index == 2 && !failed
+			failed = failed || drop
+			mu.Unlock()
+			if drop {
+				http.Error(rw, "dropped", http.StatusServiceUnavailable)
+				return
+			}
+			start, end := manifest.ChunkRange(chunkReq.Index)
+			rw.Write(data[start:end])
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer server.Close()
+
+	peer := &rpc.Client{BaseURL: server.URL}
+	progress := &SnapshotProgress{stopped: make(chan struct{})}
+
+	_, err := downloadSnapshot(ctx, peer, 7, manifest.Size, progress)
+	if err == nil {
+		t.Fatal("expected error from first attempt")
+	}
+	if got := progress.chunksDone(); got != 2 {
+		t.Fatalf("got %d chunks after first attempt, want 2", got)
+	}
+
+	got, err := downloadSnapshot(ctx, peer, 7, manifest.Size, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got a different snapshot than was served")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []int{0, 1, 2, 2}
+	if len(requested) != len(want) {
+		t.Fatalf("requested chunks %v, want %v", requested, want)
+	}
+	for i := range want {
+		if requested[i] != want[i] {
+			t.Fatalf("requested chunks %v, want %v", requested, want)
+		}
+	}
+	if downloaded, total := progress.Progress(); downloaded != total || total != manifest.Size {
+		t.Errorf("got progress %d/%d, want %d/%d", downloaded, total, manifest.Size, manifest.Size)
+	}
+}
+
+func TestCheckManifest(t *testing.T) {
+	cases := []struct {
+		m  txdb.SnapshotManifest
+		ok bool
+	}{
+		{txdb.SnapshotManifest{Height: 7, Size: 4, ChunkSize: txdb.SnapshotChunkSize, Chunks: make([]bc.Hash, 1)}, true},
+		{txdb.SnapshotManifest{Height: 8, Size: 4, ChunkSize: txdb.SnapshotChunkSize, Chunks: make([]bc.Hash, 1)}, false},
+		{txdb.SnapshotManifest{Height: 7, Size: 4, ChunkSize: 0, Chunks: make([]bc.Hash, 1)}, false},
+		{txdb.SnapshotManifest{Height: 7, Size: 4, ChunkSize: 8, Chunks: make([]bc.Hash, 1)}, false},
+		{txdb.SnapshotManifest{Height: 7, Size: 4, ChunkSize: txdb.SnapshotChunkSize, Chunks: make([]bc.Hash, 2)}, false},
+		// A size near the top of the range mustn't wrap the chunk count.
+		{txdb.SnapshotManifest{Height: 7, Size: math.MaxUint64, ChunkSize: txdb.SnapshotChunkSize}, false},
+		{txdb.SnapshotManifest{Height: 7, Size: txdb.MaxSnapshotSize + 1, ChunkSize: txdb.SnapshotChunkSize, Chunks: make([]bc.Hash, 257)}, false},
+	}
+	for i, c := range cases {
+		err := checkManifest(&c.m, 7)
+		if c.ok && err != nil {
+			t.Errorf("case %d: unexpected error %v", i, err)
+		} else if !c.ok && errors.Root(err) != errBadSnapshotManifest {
+			t.Errorf("case %d: got error %v, want %v", i, err, errBadSnapshotManifest)
+		}
+	}
+}
+
+func TestDownloadSnapshotBadChunk(t *testing.T) {
+	ctx := context.Background()
+	manifest := &txdb.SnapshotManifest{
+		Height:    7,
+		Size:      4,
+		ChunkSize: txdb.SnapshotChunkSize,
+		Chunks:    []bc.Hash{txdb.SnapshotChunkHash([]byte("good"))},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rpc/get-snapshot-manifest":
+			json.NewEncoder(rw).Encode(manifest)
+		case "/rpc/get-snapshot-chunk":
+			rw.Write([]byte("evil"))
+		}
+	}))
+	defer server.Close()
+
+	progress := &SnapshotProgress{stopped: make(chan struct{})}
+	_, err := downloadSnapshot(ctx, &rpc.Client{BaseURL: server.URL}, 7, 4, progress)
+	if errors.Root(err) != errBadSnapshotChunk {
+		t.Errorf("got error %v, want %v", err, errBadSnapshotChunk)
+	}
+	if got := progress.chunksDone(); got != 0 {
+		t.Errorf("got %d chunks, want 0", got)
+	}
+}
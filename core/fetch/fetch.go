@@ -19,59 +19,159 @@ import (
 
 const heightPollingPeriod = 3 * time.Second
 
+// ErrNoPeers is returned when no peer is eligible to serve
+// a block.
+var ErrNoPeers = errors.New("no peers available")
+
 // New initializes a new Replicator to replicate blocks from the
-// Chain Core specified by peer. It immediately begins polling for
-// the peer's blockchain height, and will stop only when ctx is
-// cancelled. To begin replicating blocks, the caller must call
-// Fetch.
-func New(peer *rpc.Client) *Replicator {
-	return &Replicator{peer: peer}
+// generator and, optionally, from other participant Cores serving
+// blocks over RPC.
+//
+// The peers function is called periodically to retrieve the
+// current set of (URL, access token) tuples for additional peers.
+// Each additional peer is contacted using a copy of generator with
+// the BaseURL and AccessToken replaced. The peers function may be
+// nil.
+//
+// To begin polling peers for their blockchain heights, the caller
+// must call PollRemoteHeight. To begin replicating blocks, the
+// caller must call Fetch.
+func New(generator *rpc.Client, peers func() [][]string) *Replicator {
+	rep := &Replicator{
+		generator: &peer{client: generator, generator: true},
+		peerURLs:  peers,
+	}
+	rep.refreshPeers()
+	return rep
 }
 
 // Replicator implements block replication.
 type Replicator struct {
-	peer *rpc.Client // peer to replicate
+	generator *peer
+	peerURLs  func() [][]string
 
-	mu              sync.Mutex
-	peerHeight      uint64
-	heightFetchedAt time.Time
+	mu    sync.Mutex
+	peers []*peer // includes generator
 }
 
-// PeerHeight returns the height of the peer Chain Core and the
-// timestamp of the moment when that height was observed.
+// PeerHeight returns the greatest blockchain height known among
+// the peer Chain Cores and the timestamp of the moment when that
+// height was observed.
 func (rep *Replicator) PeerHeight() (uint64, time.Time) {
 	rep.mu.Lock()
-	h := rep.peerHeight
-	t := rep.heightFetchedAt
-	rep.mu.Unlock()
+	defer rep.mu.Unlock()
+
+	var (
+		h uint64
+		t time.Time
+	)
+	for _, p := range rep.peers {
+		if p.fetchedAt.IsZero() {
+			continue
+		}
+		if p.height > h || (p.height == h && p.fetchedAt.After(t)) {
+			h, t = p.height, p.fetchedAt
+		}
+	}
 	return h, t
 }
 
+// Peers returns the replication status of every configured peer,
+// starting with the generator.
+func (rep *Replicator) Peers() []PeerStatus {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	statuses := make([]PeerStatus, 0, len(rep.peers))
+	for _, p := range rep.peers {
+		statuses = append(statuses, p.status())
+	}
+	return statuses
+}
+
+// refreshPeers reconciles the set of tracked peers with the
+// configured peer list, preserving the health of peers that
+// remain configured.
+func (rep *Replicator) refreshPeers() {
+	var tuples [][]string
+	if rep.peerURLs != nil {
+		tuples = rep.peerURLs()
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	existing := make(map[string]*peer, len(rep.peers))
+	for _, p := range rep.peers {
+		existing[p.client.BaseURL] = p
+	}
+
+	peers := []*peer{rep.generator}
+	seen := map[string]bool{rep.generator.client.BaseURL: true}
+	for _, tup := range tuples {
+		if seen[tup[0]] {
+			continue
+		}
+		seen[tup[0]] = true
+
+		if p, ok := existing[tup[0]]; ok && p.client.AccessToken == tup[1] {
+			peers = append(peers, p)
+			continue
+		}
+		client := *rep.generator.client
+		client.BaseURL = tup[0]
+		client.AccessToken = tup[1]
+		peers = append(peers, &peer{client: &client})
+	}
+	rep.peers = peers
+}
+
 // Fetch runs in a loop, fetching blocks from the configured
-// peer (e.g. the generator) and applying them to the local
-// Chain.
+// peers and applying them to the local Chain. Blocks are
+// requested from the healthiest peer that has them, failing
+// over to other peers (including the generator) as necessary.
+// Every block is validated, including its signatures against
+// the previous block's consensus program, before it's applied.
 //
 // It returns when its context is canceled.
 // After each attempt to fetch and apply a block, it calls health
 // to report either an error or nil to indicate success.
 func (rep *Replicator) Fetch(ctx context.Context, c *protocol.Chain, health func(error)) {
-	blockch, errch := DownloadBlocks(ctx, rep.peer, c.Height()+1)
+	downloadCtx, cancel := context.WithCancel(ctx)
+	blockch, errch := rep.download(downloadCtx, c.Height()+1)
 
 	var err error
 	var nfailures uint
 	for {
 		select {
 		case <-ctx.Done():
+			cancel()
 			log.Printf(ctx, "Deposed, Fetch exiting")
 			return
 		case err = <-errch:
 			health(err)
 			logNetworkError(ctx, err)
-		case b := <-blockch:
+		case fb := <-blockch:
 			prevBlock, prevSnapshot := c.State()
 			for {
-				err = applyBlock(ctx, c, prevSnapshot, prevBlock, b)
-				if err == protocol.ErrBadBlock {
+				err = applyBlock(ctx, c, prevSnapshot, prevBlock, fb.block)
+				if errors.Root(err) == protocol.ErrBadBlock && !fb.peer.generator {
+					// A participant served us a block that doesn't
+					// validate. Stop trusting it for a while and
+					// download the block again from somebody else.
+					rep.mu.Lock()
+					fb.peer.recordFailure(err)
+					fb.peer.ban(time.Now())
+					rep.mu.Unlock()
+					log.Error(ctx, err, "peer", fb.peer.client.BaseURL)
+
+					cancel()
+					downloadCtx, cancel = context.WithCancel(ctx)
+					blockch, errch = rep.download(downloadCtx, c.Height()+1)
+					break
+				} else if errors.Root(err) == protocol.ErrBadBlock {
+					// The generator served a block that doesn't
+					// validate. There's no one else to ask.
 					log.Fatalkv(ctx, log.KeyError, err)
 				} else if err != nil {
 					// This is a serious I/O error.
@@ -82,19 +182,19 @@ func (rep *Replicator) Fetch(ctx context.Context, c *protocol.Chain, health func
 					time.Sleep(backoffDur(nfailures))
 					continue
 				}
+
+				health(nil)
+				nfailures = 0
 				break
 			}
-
-			health(nil)
-			nfailures = 0
 		}
 	}
 }
 
-// PollRemoteHeight periodically polls the configured peer for
-// its blockchain height. It blocks until the ctx is canceled.
+// PollRemoteHeight periodically polls the configured peers for
+// their blockchain heights. It blocks until the ctx is canceled.
 func (rep *Replicator) PollRemoteHeight(ctx context.Context) {
-	rep.updateGeneratorHeight(ctx)
+	rep.updatePeerHeights(ctx)
 
 	ticker := time.NewTicker(heightPollingPeriod)
 	for {
@@ -104,22 +204,172 @@ func (rep *Replicator) PollRemoteHeight(ctx context.Context) {
 			ticker.Stop()
 			return
 		case <-ticker.C:
-			rep.updateGeneratorHeight(ctx)
+			rep.updatePeerHeights(ctx)
 		}
 	}
 }
 
-func (rep *Replicator) updateGeneratorHeight(ctx context.Context) {
-	h, err := getHeight(ctx, rep.peer)
-	if err != nil {
-		logNetworkError(ctx, err)
-		return
+func (rep *Replicator) updatePeerHeights(ctx context.Context) {
+	rep.refreshPeers()
+
+	rep.mu.Lock()
+	peers := append([]*peer(nil), rep.peers...)
+	rep.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, heightPollingPeriod)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p *peer) {
+			defer wg.Done()
+
+			start := time.Now()
+			h, err := getHeight(ctx, p.client)
+
+			rep.mu.Lock()
+			defer rep.mu.Unlock()
+			if err != nil {
+				p.recordFailure(err)
+				logNetworkError(ctx, errors.Wrap(err, p.client.BaseURL))
+				return
+			}
+			p.recordSuccess(time.Since(start))
+			p.height = h
+			p.fetchedAt = time.Now()
+		}(p)
 	}
+	wg.Wait()
+}
+
+// fetchedBlock is a block along with the peer that served it.
+type fetchedBlock struct {
+	block *legacy.Block
+	peer  *peer
+}
 
+// download starts a goroutine to download blocks from the
+// Replicator's peers, starting at the given height and incrementing
+// from there. It behaves like DownloadBlocks, except that each
+// block may be served by a different peer, and that blocks are
+// downloaded in batches while the Replicator is far behind its
+// peers.
+func (rep *Replicator) download(ctx context.Context, height uint64) (chan fetchedBlock, chan error) {
+	blockch := make(chan fetchedBlock)
+	errch := make(chan error)
+	go func() {
+		var nfailures uint // for backoff
+		var ntimeouts uint // for backoff
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				blocks, p, err := rep.getBlockBatch(ctx, height)
+				if err != nil {
+					// Fall back to downloading a single block. The
+					// batch error is only logged: a peer without
+					// /rpc/get-blocks shouldn't make the Core
+					// unhealthy when the fallback works. If the
+					// fallback fails too, its error is reported.
+					logNetworkError(ctx, err)
+				}
+				if len(blocks) > 0 {
+					for _, block := range blocks {
+						select {
+						case blockch <- fetchedBlock{block: block, peer: p}:
+						case <-ctx.Done():
+							return
+						}
+					}
+					ntimeouts, nfailures = 0, 0
+					height += uint64(len(blocks))
+					continue
+				}
+
+				block, p, err := rep.getBlock(ctx, height, timeoutBackoffDur(ntimeouts))
+				if err != nil {
+					select {
+					case errch <- err:
+					case <-ctx.Done():
+						return
+					}
+					nfailures++
+					time.Sleep(backoffDur(nfailures))
+					continue
+				}
+				if block == nil {
+					// Request time out. There might not have been any blocks published,
+					// or there was a network error or it just took too long to process the
+					// request.
+					ntimeouts++
+					continue
+				}
+
+				select {
+				case blockch <- fetchedBlock{block: block, peer: p}:
+				case <-ctx.Done():
+					return
+				}
+				ntimeouts, nfailures = 0, 0
+				height++
+			}
+		}
+	}()
+	return blockch, errch
+}
+
+// getBlock requests the block at height from each eligible peer
+// in order of preference until one of them provides it. It returns
+// a nil block and nil error if the block isn't available yet.
+func (rep *Replicator) getBlock(ctx context.Context, height uint64, timeout time.Duration) (*legacy.Block, *peer, error) {
 	rep.mu.Lock()
-	defer rep.mu.Unlock()
-	rep.peerHeight = h
-	rep.heightFetchedAt = time.Now()
+	peers := rankPeers(rep.peers, height, time.Now())
+	rep.mu.Unlock()
+	if len(peers) == 0 {
+		return nil, nil, ErrNoPeers
+	}
+
+	var lastErr error
+	for _, p := range peers {
+		rep.mu.Lock()
+		hasBlock := p.height >= height
+		rep.mu.Unlock()
+
+		start := time.Now()
+		block, err := getBlock(ctx, p.client, height, timeout)
+
+		rep.mu.Lock()
+		switch {
+		case err != nil:
+			p.recordFailure(err)
+		case block == nil && hasBlock:
+			// The peer claimed to have this block but
+			// didn't produce it in time.
+			p.recordFailure(context.DeadlineExceeded)
+		case block != nil:
+			p.recordSuccess(time.Since(start))
+			if block.Height > p.height {
+				p.height = block.Height
+			}
+		}
+		rep.mu.Unlock()
+
+		if err != nil {
+			lastErr = errors.Wrap(err, p.client.BaseURL)
+			continue
+		}
+		if block == nil && !hasBlock {
+			// Nobody is known to have this block yet. The
+			// peer timed out waiting for it to be published.
+			return nil, nil, nil
+		}
+		if block != nil {
+			return block, p, nil
+		}
+	}
+	return nil, nil, lastErr
 }
 
 // DownloadBlocks starts a goroutine to download blocks from
@@ -0,0 +1,187 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chain/core/rpc"
+	"chain/errors"
+	"chain/net/http/gzip"
+	"chain/protocol/bc/legacy"
+)
+
+func TestRankPeers(t *testing.T) {
+	now := time.Now()
+	gen := &peer{client: &rpc.Client{BaseURL: "gen"}, generator: true, height: 10, latency: 50 * time.Millisecond}
+	fast := &peer{client: &rpc.Client{BaseURL: "fast"}, height: 10, latency: 10 * time.Millisecond}
+	slow := &peer{client: &rpc.Client{BaseURL: "slow"}, height: 10, latency: 90 * time.Millisecond}
+	lagging := &peer{client: &rpc.Client{BaseURL: "lagging"}, height: 5}
+	failing := &peer{client: &rpc.Client{BaseURL: "failing"}, height: 10, failures: 3}
+	banned := &peer{client: &rpc.Client{BaseURL: "banned"}, height: 10, bannedUntil: now.Add(time.Minute)}
+	peers := []*peer{lagging, failing, slow, banned, fast, gen}
+
+	cases := []struct {
+		height uint64
+		want   []string
+	}{
+		// everyone but the lagging peer has block 8
+		{8, []string{"gen", "fast", "slow", "failing", "lagging"}},
+		// nobody has block 11 yet
+		{11, []string{"gen", "lagging", "fast", "slow", "failing"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, p := range rankPeers(peers, c.height, now) {
+			got = append(got, p.client.BaseURL)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("rankPeers(%d) = %v, want %v", c.height, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("rankPeers(%d) = %v, want %v", c.height, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestGetBlockFailover(t *testing.T) {
+	ctx := context.Background()
+
+	var genCalls int32
+	gen := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&genCalls, 1)
+		http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer gen.Close()
+
+	block := &legacy.Block{BlockHeader: legacy.BlockHeader{Version: 1, Height: 2}}
+	participant := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/rpc/get-block" {
+			t.Errorf("got path %s, want /rpc/get-block", req.URL.Path)
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(block)
+	}))
+	defer participant.Close()
+
+	rep := New(&rpc.Client{BaseURL: gen.URL}, func() [][]string {
+		return [][]string{{participant.URL, "user:secret"}}
+	})
+
+	got, p, err := rep.getBlock(ctx, 2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Height != 2 {
+		t.Fatalf("got block %v, want block at height 2", got)
+	}
+	if p.client.BaseURL != participant.URL {
+		t.Errorf("got block from %s, want %s", p.client.BaseURL, participant.URL)
+	}
+	if p.client.AccessToken != "user:secret" {
+		t.Errorf("got access token %q, want %q", p.client.AccessToken, "user:secret")
+	}
+	if n := atomic.LoadInt32(&genCalls); n != 1 {
+		t.Errorf("got %d calls to the generator, want 1", n)
+	}
+
+	statuses := rep.Peers()
+	if len(statuses) != 2 {
+		t.Fatalf("got %d peer statuses, want 2", len(statuses))
+	}
+	if !statuses[0].IsGenerator || statuses[0].Failures != 1 {
+		t.Errorf("got generator status %+v, want 1 failure", statuses[0])
+	}
+	if statuses[1].Height != 2 || statuses[1].Failures != 0 {
+		t.Errorf("got participant status %+v, want height 2 and no failures", statuses[1])
+	}
+}
+
+func TestGetBlockBatch(t *testing.T) {
+	ctx := context.Background()
+
+	var blocks []*legacy.Block
+	for h := uint64(1); h <= 5; h++ {
+		blocks = append(blocks, &legacy.Block{BlockHeader: legacy.BlockHeader{Version: 1, Height: h, TimestampMS: h}})
+	}
+	batching := httptest.NewServer(gzip.Handler{Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/rpc/get-blocks" {
+			http.NotFound(rw, req)
+			return
+		}
+		from, _ := strconv.Atoi(req.URL.Query().Get("from"))
+		for _, b := range blocks[from-1:] {
+			var buf bytes.Buffer
+			b.WriteTo(&buf)
+			WriteBlockFrame(rw, buf.Bytes())
+		}
+	})})
+	defer batching.Close()
+
+	old := httptest.NewServer(http.NotFoundHandler())
+	defer old.Close()
+
+	rep := New(&rpc.Client{BaseURL: old.URL}, func() [][]string {
+		return [][]string{{batching.URL, ""}}
+	})
+	for _, p := range rep.peers {
+		p.height = 2 + batchThreshold
+	}
+
+	got, p, err := rep.getBlockBatch(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.client.BaseURL != batching.URL {
+		t.Errorf("got blocks from %s, want %s", p.client.BaseURL, batching.URL)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d blocks, want 4", len(got))
+	}
+	for i, b := range got {
+		if b.Height != uint64(i+2) {
+			t.Errorf("got block %d at index %d, want %d", b.Height, i, i+2)
+		}
+	}
+	if !rep.generator.noBatch {
+		t.Error("expected generator without /rpc/get-blocks to be marked")
+	}
+}
+
+func TestReadBlockFramesTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	for h := uint64(1); h <= 2; h++ {
+		var raw bytes.Buffer
+		b := &legacy.Block{BlockHeader: legacy.BlockHeader{Version: 1, Height: h}}
+		b.WriteTo(&raw)
+		WriteBlockFrame(&buf, raw.Bytes())
+	}
+	data := buf.Bytes()[:buf.Len()-1]
+
+	got, err := readBlockFrames(bytes.NewReader(data))
+	if err == nil {
+		t.Error("expected error reading truncated stream")
+	}
+	if len(got) != 1 || got[0].Height != 1 {
+		t.Errorf("got %d blocks, want block 1 only", len(got))
+	}
+}
+
+func TestReadBlockFramesTooLarge(t *testing.T) {
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], MaxFrameSize+1)
+	_, err := readBlockFrames(bytes.NewReader(prefix[:]))
+	if errors.Root(err) != errBadFrame {
+		t.Errorf("got error %v, want %v", err, errBadFrame)
+	}
+}
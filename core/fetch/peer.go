@@ -0,0 +1,113 @@
+package fetch
+
+import (
+	"sort"
+	"time"
+
+	"chain/core/rpc"
+)
+
+const (
+	// peerBanPeriod is how long a peer that served an invalid
+	// block is excluded from block fetching.
+	peerBanPeriod = 10 * time.Minute
+
+	// latencyWeight is the weight given to the most recent
+	// observation in a peer's moving average latency.
+	latencyWeight = 0.25
+)
+
+// PeerStatus describes the replication health of a peer as
+// observed by a Replicator.
+type PeerStatus struct {
+	URL         string        `json:"url"`
+	IsGenerator bool          `json:"is_generator"`
+	Height      uint64        `json:"block_height"`
+	FetchedAt   time.Time     `json:"block_height_fetched_at"`
+	Latency     time.Duration `json:"latency"`
+	Failures    uint          `json:"failures"`
+	BannedUntil time.Time     `json:"banned_until,omitempty"`
+	LastError   string        `json:"last_error,omitempty"`
+}
+
+// peer holds the replication state of a single remote Core.
+// All fields other than client and generator are protected by
+// the owning Replicator's mutex.
+type peer struct {
+	client    *rpc.Client
+	generator bool
+
+	height      uint64
+	fetchedAt   time.Time
+	latency     time.Duration // moving average of successful calls
+	failures    uint          // consecutive failed calls
+	bannedUntil time.Time
+	lastErr     error
+	noBatch     bool // peer doesn't support /rpc/get-blocks
+}
+
+func (p *peer) recordSuccess(d time.Duration) {
+	if p.latency == 0 {
+		p.latency = d
+	} else {
+		p.latency = time.Duration(latencyWeight*float64(d) + (1-latencyWeight)*float64(p.latency))
+	}
+	p.failures = 0
+	p.lastErr = nil
+}
+
+func (p *peer) recordFailure(err error) {
+	p.failures++
+	p.lastErr = err
+}
+
+func (p *peer) ban(now time.Time) {
+	p.bannedUntil = now.Add(peerBanPeriod)
+}
+
+func (p *peer) status() PeerStatus {
+	s := PeerStatus{
+		URL:         p.client.BaseURL,
+		IsGenerator: p.generator,
+		Height:      p.height,
+		FetchedAt:   p.fetchedAt,
+		Latency:     p.latency,
+		Failures:    p.failures,
+		BannedUntil: p.bannedUntil,
+	}
+	if p.lastErr != nil {
+		s.LastError = p.lastErr.Error()
+	}
+	return s
+}
+
+// rankPeers returns the peers that may be asked for the block at
+// height, most preferred first. Banned peers are excluded.
+//
+// Peers known to already have the block are preferred over
+// peers that would need to wait for it. Within each group, peers
+// with fewer recent failures come first, then the generator,
+// then peers with lower latency.
+func rankPeers(peers []*peer, height uint64, now time.Time) []*peer {
+	var ranked []*peer
+	for _, p := range peers {
+		if now.Before(p.bannedUntil) {
+			continue
+		}
+		ranked = append(ranked, p)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if aHas, bHas := a.height >= height, b.height >= height; aHas != bHas {
+			return aHas
+		}
+		if a.failures != b.failures {
+			return a.failures < b.failures
+		}
+		if a.generator != b.generator {
+			return a.generator
+		}
+		return a.latency < b.latency
+	})
+	return ranked
+}
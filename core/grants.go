@@ -19,6 +19,7 @@ type apiGrant struct {
 	Policy    string                 `json:"policy"`
 	CreatedAt string                 `json:"created_at"`
 	Protected bool                   `json:"protected"`
+	Scope     *grantScope            `json:"scope,omitempty"`
 }
 
 var (
@@ -115,10 +116,27 @@ func (a *API) createGrant(ctx context.Context, x apiGrant) (*apiGrant, error) {
 		} else {
 			return nil, errors.WithDetail(httpjson.ErrBadRequest, "map of subject attributes required")
 		}
+	} else if x.GuardType == "jwt" {
+		err := validateJWTGuardData(x.GuardData)
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		return nil, errors.WithDetail(httpjson.ErrBadRequest, "invalid guard type: "+x.GuardType)
 	}
 
+	var scope []byte
+	if x.Scope != nil {
+		err := x.Scope.validate()
+		if err != nil {
+			return nil, err
+		}
+		scope, err = json.Marshal(x.Scope)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+
 	// NOTE: package json produces consistent serialization output,
 	// effectively an ad hoc canonical form. We rely on this to
 	// grant data for equality.
@@ -134,6 +152,7 @@ func (a *API) createGrant(ctx context.Context, x apiGrant) (*apiGrant, error) {
 		GuardData: guardData,
 		Policy:    x.Policy,
 		Protected: false, // grants created through the createGrant RPC cannot be protected
+		Scope:     scope,
 	}
 	err = a.sdb.Exec(ctx, a.grants.Save(ctx, g))
 	if err != nil {
@@ -154,9 +173,40 @@ func (a *API) createGrant(ctx context.Context, x apiGrant) (*apiGrant, error) {
 		Policy:    g.Policy,
 		CreatedAt: g.CreatedAt,
 		Protected: g.Protected,
+		Scope:     x.Scope,
 	}, nil
 }
 
+// validateJWTGuardData checks that data has a non-empty "subject"
+// string, a non-empty "groups" list of strings, or both, and no
+// other fields.
+func validateJWTGuardData(data map[string]interface{}) error {
+	if len(data) == 0 {
+		return errors.WithDetail(httpjson.ErrBadRequest, `guard data should contain "subject", "groups", or both`)
+	}
+	for k, v := range data {
+		switch k {
+		case "subject":
+			if s, _ := v.(string); s == "" {
+				return errors.WithDetail(httpjson.ErrBadRequest, "subject must be a non-empty string")
+			}
+		case "groups":
+			groups, _ := v.([]interface{})
+			if len(groups) == 0 {
+				return errors.WithDetail(httpjson.ErrBadRequest, "groups must be a non-empty list of strings")
+			}
+			for _, g := range groups {
+				if s, _ := g.(string); s == "" {
+					return errors.WithDetail(httpjson.ErrBadRequest, "groups must be a non-empty list of strings")
+				}
+			}
+		default:
+			return errors.WithDetail(httpjson.ErrBadRequest, "bad jwt guard field "+k)
+		}
+	}
+	return nil
+}
+
 func (a *API) listGrants(ctx context.Context) (map[string]interface{}, error) {
 	var grants []apiGrant
 	for _, p := range Policies {
@@ -182,6 +232,13 @@ func (a *API) listGrants(ctx context.Context) (map[string]interface{}, error) {
 				CreatedAt: g.CreatedAt,
 				Protected: g.Protected,
 			}
+			if len(g.Scope) > 0 {
+				grant.Scope = new(grantScope)
+				err = json.Unmarshal(g.Scope, grant.Scope)
+				if err != nil {
+					return nil, errors.Wrap(err)
+				}
+			}
 			grants = append(grants, grant)
 		}
 	}
@@ -200,10 +257,19 @@ func (a *API) deleteGrant(ctx context.Context, x apiGrant) error {
 		return errors.Wrap(err)
 	}
 
+	var scope []byte
+	if x.Scope != nil {
+		scope, err = json.Marshal(x.Scope)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+	}
+
 	toDelete := authz.Grant{
 		GuardType: x.GuardType,
 		GuardData: guardData,
 		Protected: x.Protected, // should always be false
+		Scope:     scope,
 	}
 
 	err = a.sdb.Exec(ctx, a.grants.Delete(x.Policy, func(g *authz.Grant) bool {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"chain/core/accesstoken"
 	"chain/database/pg/pgtest"
@@ -16,7 +17,7 @@ func TestCreatGrantValidation(t *testing.T) {
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 
 	accessTokens := &accesstoken.CredentialStore{db}
-	_, err := accessTokens.Create(ctx, "test-token", "")
+	_, err := accessTokens.Create(ctx, "test-token", "", time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -130,7 +131,7 @@ func TestDeleteGrants(t *testing.T) {
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 
 	accessTokens := &accesstoken.CredentialStore{db}
-	_, err := accessTokens.Create(ctx, "test-token", "")
+	_, err := accessTokens.Create(ctx, "test-token", "", time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -227,12 +228,12 @@ func TestDeleteGrantsByAccessToken(t *testing.T) {
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 
 	accessTokens := &accesstoken.CredentialStore{db}
-	_, err := accessTokens.Create(ctx, "test-token-0", "")
+	_, err := accessTokens.Create(ctx, "test-token-0", "", time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = accessTokens.Create(ctx, "test-token-1", "")
+	_, err = accessTokens.Create(ctx, "test-token-1", "", time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
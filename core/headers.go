@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"context"
+
+	"chain/core/light"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+)
+
+var errNotLightClient = errors.New("core is not configured as a light client")
+
+// getBlockHeadersRPC returns the headers of consecutive blocks
+// starting at the requested height, along with merkle proofs for the
+// transactions in those blocks that pay to any of the requested
+// control programs. Light Cores use it to sync without downloading
+// full blocks.
+//
+// If successful, it always returns at least one header, waiting if
+// necessary until one is created. It is an error to request blocks
+// very far in the future.
+func (a *API) getBlockHeadersRPC(ctx context.Context, req light.HeadersRequest) ([]*light.Header, error) {
+	if req.Height == 0 {
+		req.Height = 1
+	}
+	err := <-a.chain.BlockSoonWaiter(ctx, req.Height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for block at height %d", req.Height)
+	}
+
+	count := uint64(req.Count)
+	if req.Count <= 0 || req.Count > light.MaxHeaders {
+		count = light.MaxHeaders
+	}
+	end := req.Height + count - 1
+	if h := a.chain.Height(); end > h {
+		end = h
+	}
+
+	var headers []*light.Header
+	for height := req.Height; height <= end; height++ {
+		b, err := a.chain.GetBlock(ctx, height)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting block %d", height)
+		}
+		txs, err := watchedTxs(b, req.ControlPrograms)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, &light.Header{
+			Header:       &b.BlockHeader,
+			Transactions: txs,
+		})
+	}
+	return headers, nil
+}
+
+// watchedTxs returns the transactions in b with an output paying
+// to any of programs, along with proofs of their inclusion in b.
+func watchedTxs(b *legacy.Block, programs []chainjson.HexBytes) ([]*light.ProvenTx, error) {
+	if len(programs) == 0 {
+		return nil, nil
+	}
+
+	var (
+		txs    []*bc.Tx
+		proven []*light.ProvenTx
+	)
+	for _, tx := range b.Transactions {
+		txs = append(txs, tx.Tx)
+	}
+	for i, tx := range b.Transactions {
+		if !paysToAny(tx, programs) {
+			continue
+		}
+		path, err := bc.TxMerklePath(txs, i)
+		if err != nil {
+			return nil, errors.Wrap(err, "computing merkle path")
+		}
+		proven = append(proven, &light.ProvenTx{Tx: tx, Position: i, Path: path})
+	}
+	return proven, nil
+}
+
+func paysToAny(tx *legacy.Tx, programs []chainjson.HexBytes) bool {
+	for _, out := range tx.Outputs {
+		for _, prog := range programs {
+			if bytes.Equal(out.ControlProgram, prog) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type blockHeaderResponse struct {
+	ID     bc.Hash             `json:"id"`
+	Height uint64              `json:"height"`
+	Header *legacy.BlockHeader `json:"header"`
+}
+
+// getBlockHeader returns the header of the block at the requested
+// height. Light Cores return only headers whose signatures they've
+// verified.
+//
+// POST /get-block-header
+func (a *API) getBlockHeader(ctx context.Context, req struct {
+	Height uint64 `json:"height"`
+}) (*blockHeaderResponse, error) {
+	var header *legacy.BlockHeader
+	if a.light != nil {
+		h, err := a.light.GetHeader(ctx, req.Height)
+		if err != nil {
+			return nil, err
+		}
+		header = h
+	} else {
+		if req.Height == 0 || req.Height > a.chain.Height() {
+			return nil, errors.WithDetailf(errNotFound, "no block at height %d", req.Height)
+		}
+		b, err := a.chain.GetBlock(ctx, req.Height)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting block")
+		}
+		header = &b.BlockHeader
+	}
+	return &blockHeaderResponse{
+		ID:     header.Hash(),
+		Height: header.Height,
+		Header: header,
+	}, nil
+}
+
+// listWatchedTransactions returns the transactions paying to watched
+// control programs that a light Core has proven to be included in
+// verified blocks, in blockchain order.
+//
+// POST /list-watched-transactions
+func (a *API) listWatchedTransactions(ctx context.Context, in requestQuery) (*page, error) {
+	if a.light == nil {
+		return nil, errNotLightClient
+	}
+	limit := in.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	txs, after, err := a.light.ListTransactions(ctx, in.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	out := in
+	out.After = after
+	return &page{
+		Items:    httpjson.Array(txs),
+		LastPage: len(txs) < limit,
+		Next:     out,
+	}, nil
+}
@@ -8,6 +8,8 @@ import (
 	"chain/core/mockhsm"
 	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
 	"chain/net/http/httperror"
 	"chain/net/http/httpjson"
 )
@@ -16,25 +18,43 @@ func init() {
 	errorFormatter.Errors[mockhsm.ErrDuplicateKeyAlias] = httperror.Info{400, "CH050", "Alias already exists"}
 	errorFormatter.Errors[mockhsm.ErrInvalidAfter] = httperror.Info{400, "CH801", "Invalid `after` in query"}
 	errorFormatter.Errors[mockhsm.ErrTooManyAliasesToList] = httperror.Info{400, "CH802", "Too many aliases to list"}
+	errorFormatter.Errors[mockhsm.ErrPolicyViolation] = httperror.Info{400, "CH803", "Signing policy does not allow signature"}
+	errorFormatter.Errors[mockhsm.ErrInvalidPolicy] = httperror.Info{400, "CH804", "Invalid signing policy"}
+	errorFormatter.Errors[mockhsm.ErrNoKey] = httperror.Info{400, "CH805", "Key not found in MockHSM"}
+	errorFormatter.Errors[mockhsm.ErrBadBackup] = httperror.Info{400, "CH806", "Invalid key backup"}
+	errorFormatter.Errors[mockhsm.ErrBadPassphrase] = httperror.Info{400, "CH807", "Invalid key backup passphrase"}
 }
 
 // MockHSM configures the Core to expose the MockHSM endpoints. It
 // is only included in non-production builds.
 func MockHSM(hsm *mockhsm.HSM) RunOption {
 	return func(a *API) {
-		h := &mockHSMHandler{MockHSM: hsm}
+		h := &mockHSMHandler{MockHSM: hsm, checkScope: a.checkTemplateScope}
+		a.hardenedXPubs = append(a.hardenedXPubs, func(ctx context.Context, xpub chainkd.XPub, path [][]byte) (chainkd.XPub, bool, error) {
+			derived, err := hsm.XDeriveHardened(ctx, xpub, path)
+			if errors.Root(err) == mockhsm.ErrNoKey {
+				return derived, false, nil
+			}
+			return derived, err == nil, err
+		})
 
 		needConfig := a.needConfig()
 		a.mux.Handle("/mockhsm/create-block-key", jsonHandler(h.mockhsmCreateBlockKey))
 		a.mux.Handle("/mockhsm/create-key", needConfig(h.mockhsmCreateKey))
 		a.mux.Handle("/mockhsm/list-keys", needConfig(h.mockhsmListKeys))
 		a.mux.Handle("/mockhsm/delkey", needConfig(h.mockhsmDelKey))
+		a.mux.Handle("/mockhsm/derive-xpub", needConfig(h.mockhsmDeriveXPub))
 		a.mux.Handle("/mockhsm/sign-transaction", needConfig(h.mockhsmSignTemplates))
+		a.mux.Handle("/mockhsm/set-key-policy", needConfig(h.mockhsmSetKeyPolicy))
+		a.mux.Handle("/mockhsm/list-sign-events", needConfig(h.mockhsmListSignEvents))
+		a.mux.Handle("/mockhsm/export-keys", needConfig(h.mockhsmExportKeys))
+		a.mux.Handle("/mockhsm/import-keys", needConfig(h.mockhsmImportKeys))
 	}
 }
 
 type mockHSMHandler struct {
-	MockHSM *mockhsm.HSM
+	MockHSM    *mockhsm.HSM
+	checkScope func(context.Context, *txbuilder.Template) error
 }
 
 func (h *mockHSMHandler) mockhsmCreateBlockKey(ctx context.Context) (result *mockhsm.Pub, err error) {
@@ -74,13 +94,34 @@ func (h *mockHSMHandler) mockhsmDelKey(ctx context.Context, xpub chainkd.XPub) e
 	return h.MockHSM.DeleteChainKDKey(ctx, xpub)
 }
 
+// mockhsmDeriveXPub derives an xpub along a path with hardened
+// derivation, for use as one of the account_xpubs of a hardened
+// account.
+func (h *mockHSMHandler) mockhsmDeriveXPub(ctx context.Context, in struct {
+	XPub         chainkd.XPub         `json:"xpub"`
+	HardenedPath []chainjson.HexBytes `json:"hardened_path"`
+}) (interface{}, error) {
+	var path [][]byte
+	for _, p := range in.HardenedPath {
+		path = append(path, p)
+	}
+	xpub, err := h.MockHSM.XDeriveHardened(ctx, in.XPub, path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"xpub": xpub}, nil
+}
+
 func (h *mockHSMHandler) mockhsmSignTemplates(ctx context.Context, x struct {
 	Txs   []*txbuilder.Template `json:"transactions"`
 	XPubs []chainkd.XPub        `json:"xpubs"`
 }) []interface{} {
 	resp := make([]interface{}, 0, len(x.Txs))
 	for _, tx := range x.Txs {
-		err := txbuilder.Sign(ctx, tx, x.XPubs, h.mockhsmSignTemplate)
+		err := h.checkScope(ctx, tx)
+		if err == nil {
+			err = txbuilder.Sign(ctx, tx, x.XPubs, h.mockhsmSignTemplate(tx))
+		}
 		if err != nil {
 			info := errorFormatter.Format(err)
 			resp = append(resp, info)
@@ -91,10 +132,75 @@ func (h *mockHSMHandler) mockhsmSignTemplates(ctx context.Context, x struct {
 	return resp
 }
 
-func (h *mockHSMHandler) mockhsmSignTemplate(ctx context.Context, xpub chainkd.XPub, path [][]byte, data [32]byte) ([]byte, error) {
-	sigBytes, err := h.MockHSM.XSign(ctx, xpub, path, data[:])
-	if err == mockhsm.ErrNoKey {
-		return nil, nil
+// mockhsmSignTemplate returns a signing function for tpl, so that
+// the HSM can check the transaction against its keys' policies.
+// The transaction is only given to the HSM for hashes of programs
+// derived from it. A program supplied by the client could commit to
+// another transaction, so keys with policies won't sign it.
+func (h *mockHSMHandler) mockhsmSignTemplate(tpl *txbuilder.Template) txbuilder.SignFunc {
+	var derived map[[32]byte]bool
+	return func(ctx context.Context, xpub chainkd.XPub, path [][]byte, hardened int, _ *chainkd.XPub, data [32]byte) ([]byte, error) {
+		if derived == nil {
+			derived = txbuilder.InferredSigHashes(tpl)
+		}
+		tx := tpl.Transaction
+		if !derived[data] {
+			tx = nil
+		}
+		sigBytes, err := h.MockHSM.XSignTx(ctx, xpub, path, hardened, data[:], tx)
+		if err == mockhsm.ErrNoKey {
+			return nil, nil
+		}
+		return sigBytes, err
+	}
+}
+
+// mockhsmSetKeyPolicy sets the signing policy of a key. A null
+// policy removes any restrictions. It is restricted to internal
+// callers, so that a client allowed to sign with a key can't
+// loosen the policy that limits it.
+func (h *mockHSMHandler) mockhsmSetKeyPolicy(ctx context.Context, in struct {
+	XPub   chainkd.XPub    `json:"xpub"`
+	Policy *mockhsm.Policy `json:"policy"`
+}) error {
+	return h.MockHSM.SetPolicy(ctx, in.XPub, in.Policy)
+}
+
+func (h *mockHSMHandler) mockhsmListSignEvents(ctx context.Context, query requestQuery) (page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	events, after, err := h.MockHSM.ListSignEvents(ctx, query.Aliases, query.After, limit)
+	if err != nil {
+		return page{}, err
 	}
-	return sigBytes, err
+
+	query.After = after
+	return page{
+		Items:    httpjson.Array(events),
+		LastPage: len(events) < limit,
+		Next:     query,
+	}, nil
+}
+
+// mockhsmExportKeys returns an encrypted backup of the keys in the
+// MockHSM. Block-signing keys are included only if requested.
+func (h *mockHSMHandler) mockhsmExportKeys(ctx context.Context, in struct {
+	Passphrase       string `json:"passphrase"`
+	IncludeBlockKeys bool   `json:"include_block_keys"`
+}) (*mockhsm.Backup, error) {
+	return h.MockHSM.ExportKeys(ctx, []byte(in.Passphrase), in.IncludeBlockKeys)
+}
+
+// mockhsmImportKeys restores the keys in a backup made by
+// mockhsmExportKeys. With dry_run, it only decrypts and checks the
+// backup and reports which keys would be imported.
+func (h *mockHSMHandler) mockhsmImportKeys(ctx context.Context, in struct {
+	Backup     *mockhsm.Backup `json:"backup"`
+	Passphrase string          `json:"passphrase"`
+	DryRun     bool            `json:"dry_run"`
+}) ([]*mockhsm.ImportedKey, error) {
+	return h.MockHSM.ImportKeys(ctx, in.Backup, []byte(in.Passphrase), in.DryRun)
 }
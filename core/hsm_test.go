@@ -15,8 +15,10 @@ import (
 	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
+	"chain/net/http/httperror"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
+	"chain/protocol/vm"
 	"chain/testutil"
 )
 
@@ -31,13 +33,13 @@ func TestMockHSM(t *testing.T) {
 	coretest.CreatePins(ctx, t, pinStore)
 	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
 	go accounts.ProcessBlocks(ctx)
-	mockhsm := mockhsm.New(db)
+	hsm := mockhsm.New(db)
 
-	xpub1, err := mockhsm.XCreate(ctx, "")
+	xpub1, err := hsm.XCreate(ctx, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	acct1, err := accounts.Create(ctx, []chainkd.XPub{xpub1.XPub}, 1, "", nil, "")
+	acct1, err := accounts.Create(ctx, []chainkd.XPub{xpub1.XPub}, 1, nil, nil, "", nil, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,7 +48,7 @@ func TestMockHSM(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	acct2, err := accounts.Create(ctx, []chainkd.XPub{xpub2}, 1, "", nil, "")
+	acct2, err := accounts.Create(ctx, []chainkd.XPub{xpub2}, 1, nil, nil, "", nil, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -84,7 +86,7 @@ func TestMockHSM(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	handler := &mockHSMHandler{MockHSM: mockhsm}
+	handler := &mockHSMHandler{MockHSM: hsm, checkScope: new(API).checkTemplateScope}
 	outTmpls := handler.mockhsmSignTemplates(ctx, struct {
 		Txs   []*txbuilder.Template `json:"transactions"`
 		XPubs []chainkd.XPub        `json:"xpubs"`
@@ -102,6 +104,37 @@ func TestMockHSM(t *testing.T) {
 
 	inspectSigInst(t, outTmpl.SigningInstructions[0], true)
 	inspectSigInst(t, outTmpl.SigningInstructions[1], false)
+
+	// A key with a policy won't sign a program supplied with the
+	// template, which could commit to some other transaction.
+	past := time.Now().Add(-time.Hour)
+	err = hsm.SetPolicy(ctx, xpub1.XPub, &mockhsm.Policy{NotBefore: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw := tmpl.SigningInstructions[0].SignatureWitnesses[0]
+	sw.Sigs = nil
+	sw.Program = []byte{byte(vm.OP_TRUE)}
+	outTmpls = handler.mockhsmSignTemplates(ctx, struct {
+		Txs   []*txbuilder.Template `json:"transactions"`
+		XPubs []chainkd.XPub        `json:"xpubs"`
+	}{[]*txbuilder.Template{tmpl}, []chainkd.XPub{xpub1.XPub}})
+	if resp, ok := outTmpls[0].(httperror.Response); !ok || resp.ChainCode != "CH803" {
+		t.Fatalf("signing a supplied program got %#v, want error CH803", outTmpls[0])
+	}
+
+	// The program derived from the template is signed as before.
+	sw.Sigs = nil
+	sw.Program = nil
+	outTmpls = handler.mockhsmSignTemplates(ctx, struct {
+		Txs   []*txbuilder.Template `json:"transactions"`
+		XPubs []chainkd.XPub        `json:"xpubs"`
+	}{[]*txbuilder.Template{tmpl}, []chainkd.XPub{xpub1.XPub}})
+	outTmpl, ok = outTmpls[0].(*txbuilder.Template)
+	if !ok {
+		t.Fatalf("expected a *txbuilder.Template, got %T (%v)", outTmpls[0], outTmpls[0])
+	}
+	inspectSigInst(t, outTmpl.SigningInstructions[0], true)
 }
 
 func inspectSigInst(t *testing.T, si *txbuilder.SigningInstruction, expectSig bool) {
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/keystore"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// Keystore configures the Core to expose the keystore endpoints,
+// which mirror the MockHSM endpoints but keep private keys
+// encrypted at rest. Except for listing keys and sign events, they
+// fail until the keystore is unlocked with /keystore/unlock. A new
+// keystore must first be initialized with /keystore/init. Only
+// internal callers may initialize, lock, or unlock the keystore, or
+// export keys from it.
+func Keystore(ks *keystore.Store) RunOption {
+	return func(a *API) {
+		h := &keystoreHandler{ks: ks, checkScope: a.checkTemplateScope}
+		a.hardenedXPubs = append(a.hardenedXPubs, func(ctx context.Context, xpub chainkd.XPub, path [][]byte) (chainkd.XPub, bool, error) {
+			derived, err := ks.XDeriveHardened(ctx, xpub, path)
+			// A locked keystore can't say whether it holds the key,
+			// so the account xpub must be proven instead.
+			if root := errors.Root(err); root == keystore.ErrNoKey || root == keystore.ErrLocked {
+				return derived, false, nil
+			}
+			return derived, err == nil, err
+		})
+
+		needConfig := a.needConfig()
+		a.mux.Handle("/keystore/init", jsonHandler(h.init))
+		a.mux.Handle("/keystore/unlock", jsonHandler(h.unlock))
+		a.mux.Handle("/keystore/lock", jsonHandler(h.lock))
+		a.mux.Handle("/keystore/create-block-key", jsonHandler(h.createBlockKey))
+		a.mux.Handle("/keystore/create-key", needConfig(h.createKey))
+		a.mux.Handle("/keystore/list-keys", needConfig(h.listKeys))
+		a.mux.Handle("/keystore/delkey", needConfig(h.delKey))
+		a.mux.Handle("/keystore/derive-xpub", needConfig(h.deriveXPub))
+		a.mux.Handle("/keystore/sign-transaction", needConfig(h.signTemplates))
+		a.mux.Handle("/keystore/export-key", needConfig(h.exportKey))
+		a.mux.Handle("/keystore/import-key", needConfig(h.importKey))
+		a.mux.Handle("/keystore/list-sign-events", needConfig(h.listSignEvents))
+	}
+}
+
+type keystoreHandler struct {
+	ks         *keystore.Store
+	checkScope func(context.Context, *txbuilder.Template) error
+}
+
+func (h *keystoreHandler) init(ctx context.Context, in struct {
+	Passphrase string `json:"passphrase"`
+}) error {
+	return h.ks.Init(ctx, []byte(in.Passphrase))
+}
+
+func (h *keystoreHandler) unlock(ctx context.Context, in struct {
+	Passphrase string `json:"passphrase"`
+}) error {
+	return h.ks.Unlock(ctx, []byte(in.Passphrase))
+}
+
+func (h *keystoreHandler) lock() {
+	h.ks.Lock()
+}
+
+func (h *keystoreHandler) createBlockKey(ctx context.Context) (*keystore.Pub, error) {
+	return h.ks.Create(ctx, "block_key")
+}
+
+func (h *keystoreHandler) createKey(ctx context.Context, in struct{ Alias string }) (*keystore.XPub, error) {
+	return h.ks.XCreate(ctx, in.Alias)
+}
+
+func (h *keystoreHandler) listKeys(ctx context.Context, query requestQuery) (page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	xpubs, after, err := h.ks.ListKeys(ctx, query.Aliases, query.After, limit)
+	if err != nil {
+		return page{}, err
+	}
+
+	query.After = after
+	return page{
+		Items:    httpjson.Array(xpubs),
+		LastPage: len(xpubs) < limit,
+		Next:     query,
+	}, nil
+}
+
+func (h *keystoreHandler) delKey(ctx context.Context, xpub chainkd.XPub) error {
+	return h.ks.DeleteChainKDKey(ctx, xpub)
+}
+
+// deriveXPub derives an xpub along a path with hardened derivation,
+// for use as one of the account_xpubs of a hardened account.
+func (h *keystoreHandler) deriveXPub(ctx context.Context, in struct {
+	XPub         chainkd.XPub         `json:"xpub"`
+	HardenedPath []chainjson.HexBytes `json:"hardened_path"`
+}) (interface{}, error) {
+	var path [][]byte
+	for _, p := range in.HardenedPath {
+		path = append(path, p)
+	}
+	xpub, err := h.ks.XDeriveHardened(ctx, in.XPub, path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"xpub": xpub}, nil
+}
+
+func (h *keystoreHandler) signTemplates(ctx context.Context, x struct {
+	Txs   []*txbuilder.Template `json:"transactions"`
+	XPubs []chainkd.XPub        `json:"xpubs"`
+}) []interface{} {
+	resp := make([]interface{}, 0, len(x.Txs))
+	for _, tx := range x.Txs {
+		err := h.checkScope(ctx, tx)
+		if err == nil {
+			err = txbuilder.Sign(ctx, tx, x.XPubs, h.signTemplate)
+		}
+		if err != nil {
+			info := errorFormatter.Format(err)
+			resp = append(resp, info)
+		} else {
+			resp = append(resp, tx)
+		}
+	}
+	return resp
+}
+
+func (h *keystoreHandler) signTemplate(ctx context.Context, xpub chainkd.XPub, path [][]byte, hardened int, _ *chainkd.XPub, data [32]byte) ([]byte, error) {
+	sigBytes, err := h.ks.XSignHardened(ctx, xpub, path, hardened, data[:])
+	if err == keystore.ErrNoKey {
+		return nil, nil
+	}
+	return sigBytes, err
+}
+
+func (h *keystoreHandler) exportKey(ctx context.Context, in struct {
+	Pub        chainjson.HexBytes `json:"pub"`
+	Passphrase string             `json:"passphrase"`
+	BlockKey   bool               `json:"block_key"`
+}) (*keystore.ExportedKey, error) {
+	return h.ks.Export(ctx, in.Pub, []byte(in.Passphrase), in.BlockKey)
+}
+
+func (h *keystoreHandler) importKey(ctx context.Context, in struct {
+	Key        *keystore.ExportedKey `json:"key"`
+	Passphrase string                `json:"passphrase"`
+	Alias      string                `json:"alias"`
+}) error {
+	if in.Key == nil {
+		return keystore.ErrBadExport
+	}
+	return h.ks.Import(ctx, in.Key, []byte(in.Passphrase), in.Alias)
+}
+
+func (h *keystoreHandler) listSignEvents(ctx context.Context, query requestQuery) (*page, error) {
+	limit := query.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	events, after, err := h.ks.ListSignEvents(ctx, query.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query.After = after
+	return &page{
+		Items:    httpjson.Array(events),
+		LastPage: len(events) < limit,
+		Next:     query,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package keystore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// SignEvent records a single signature produced by the keystore.
+type SignEvent struct {
+	ID      string               `json:"id"`
+	KeyType string               `json:"key_type"`
+	Pub     chainjson.HexBytes   `json:"pub"`
+	Path    []chainjson.HexBytes `json:"derivation_path"`
+	Message chainjson.HexBytes   `json:"message"`
+	Time    time.Time            `json:"time"`
+}
+
+// recordSignEvent appends an entry to the sign audit log. Signing
+// fails if the entry can't be recorded, so that every signature the
+// keystore produces appears in the log.
+func (s *Store) recordSignEvent(ctx context.Context, keyType string, pub []byte, path [][]byte, msg []byte) error {
+	if path == nil {
+		path = [][]byte{}
+	}
+	const q = `
+		INSERT INTO keystore_sign_events (key_type, pub, path, message)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := s.db.ExecContext(ctx, q, keyType, pub, pq.ByteaArray(path), msg)
+	return errors.Wrap(err, "recording sign event")
+}
+
+// ListSignEvents returns entries from the sign audit log, most
+// recent first.
+func (s *Store) ListSignEvents(ctx context.Context, after string, limit int) ([]*SignEvent, string, error) {
+	var (
+		zafter int64
+		err    error
+	)
+	if after != "" {
+		zafter, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(ErrInvalidAfter, "value: %q", after)
+		}
+	}
+
+	var params []interface{}
+	q := `SELECT id, key_type, pub, path, message, created_at FROM keystore_sign_events`
+	if zafter != 0 {
+		params = append(params, zafter)
+		q += " WHERE id < $1"
+	}
+	q += fmt.Sprintf(" ORDER BY id DESC LIMIT %d", limit)
+
+	var events []*SignEvent
+	params = append(params, func(id int64, keyType string, pub []byte, path pq.ByteaArray, msg []byte, t time.Time) {
+		e := &SignEvent{
+			ID:      strconv.FormatInt(id, 10),
+			KeyType: keyType,
+			Pub:     pub,
+			Path:    make([]chainjson.HexBytes, 0, len(path)),
+			Message: msg,
+			Time:    t,
+		}
+		for _, p := range path {
+			e.Path = append(e.Path, p)
+		}
+		events = append(events, e)
+		zafter = id
+	})
+	err = pg.ForQueryRows(ctx, s.db, q, params...)
+	if err != nil {
+		return nil, "", err
+	}
+	return events, strconv.FormatInt(zafter, 10), nil
+}
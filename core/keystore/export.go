@@ -0,0 +1,178 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+
+	"golang.org/x/crypto/scrypt"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// exportVersion is the version of the ExportedKey format produced
+// by Export.
+const exportVersion = 1
+
+// maxScryptMemory bounds the memory scrypt may use to decrypt an
+// imported key, so that a crafted export can't exhaust the Core's
+// memory.
+const maxScryptMemory = 256 << 20
+
+// ErrBadExport is returned when an exported key is malformed, was
+// encrypted with a different passphrase, or doesn't match its
+// public key.
+var ErrBadExport = errors.New("invalid exported key")
+
+// ErrBlockKey is returned when Export is asked for an ed25519 block
+// signing key without being told to export block keys.
+var ErrBlockKey = errors.New("block signing keys are only exported on request")
+
+// ExportedKey is a private key encrypted under a key derived from
+// an export passphrase, independent of the keystore's master key.
+// It can be imported into any keystore that knows the passphrase.
+type ExportedKey struct {
+	Version int                `json:"version"`
+	KeyType string             `json:"key_type"`
+	Alias   *string            `json:"alias"`
+	Pub     chainjson.HexBytes `json:"pub"`
+	Salt    chainjson.HexBytes `json:"salt"`
+	ScryptN int                `json:"scrypt_n"`
+	ScryptR int                `json:"scrypt_r"`
+	ScryptP int                `json:"scrypt_p"`
+	Sealed  chainjson.HexBytes `json:"sealed_key"`
+}
+
+// Export decrypts the private key for pub and re-encrypts it under
+// a key derived from passphrase. The Store must be unlocked. Block
+// signing keys are only exported if blockKey is true; anyone holding
+// one can sign blocks for the network.
+func (s *Store) Export(ctx context.Context, pub []byte, passphrase []byte, blockKey bool) (*ExportedKey, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.WithDetail(ErrBadPassphrase, "export passphrase is empty")
+	}
+	if s.Locked() {
+		return nil, ErrLocked
+	}
+
+	var (
+		keyType string
+		alias   *string
+		sealed  []byte
+	)
+	const q = `SELECT key_type, alias, sealed_prv FROM keystore_keys WHERE pub = $1`
+	err := s.db.QueryRowContext(ctx, q, pub).Scan(&keyType, &alias, &sealed)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoKey
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading private key")
+	}
+	if keyType == typeEd25519 && !blockKey {
+		return nil, ErrBlockKey
+	}
+
+	s.mu.Lock()
+	prv, err := s.openKey(keyType, pub, sealed)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	defer zero(prv)
+	return sealExport(keyType, pub, prv, alias, passphrase)
+}
+
+// sealExport encrypts prv under a key derived from passphrase.
+func sealExport(keyType string, pub, prv []byte, alias *string, passphrase []byte) (*ExportedKey, error) {
+	salt, err := randomBytes(saltSize)
+	if err != nil {
+		return nil, err
+	}
+	ek, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "deriving export key")
+	}
+	sealed, err := seal(ek, prv, exportAD(keyType, pub))
+	if err != nil {
+		return nil, err
+	}
+	return &ExportedKey{
+		Version: exportVersion,
+		KeyType: keyType,
+		Alias:   alias,
+		Pub:     pub,
+		Salt:    salt,
+		ScryptN: scryptN,
+		ScryptR: scryptR,
+		ScryptP: scryptP,
+		Sealed:  sealed,
+	}, nil
+}
+
+// Import decrypts an exported key with passphrase and stores it,
+// sealed under this keystore's data key. If alias is non-empty, it
+// replaces the alias recorded in the export. The Store must be
+// unlocked.
+func (s *Store) Import(ctx context.Context, k *ExportedKey, passphrase []byte, alias string) error {
+	if s.Locked() {
+		return ErrLocked
+	}
+	prv, err := openExport(k, passphrase)
+	if err != nil {
+		return err
+	}
+	defer zero(prv)
+
+	if alias == "" && k.Alias != nil {
+		alias = *k.Alias
+	}
+	_, err = s.insertKey(ctx, k.KeyType, k.Pub, prv, alias, false)
+	return err
+}
+
+// openExport decrypts k and checks that the private key it contains
+// corresponds to its public key.
+func openExport(k *ExportedKey, passphrase []byte) ([]byte, error) {
+	if k.Version != exportVersion {
+		return nil, errors.WithDetailf(ErrBadExport, "unsupported version %d", k.Version)
+	}
+	if 128*int64(k.ScryptN)*int64(k.ScryptR) > maxScryptMemory || k.ScryptP > 16 {
+		return nil, errors.WithDetail(ErrBadExport, "scrypt parameters too large")
+	}
+	ek, err := scrypt.Key(passphrase, k.Salt, k.ScryptN, k.ScryptR, k.ScryptP, keySize)
+	if err != nil {
+		return nil, errors.Sub(ErrBadExport, err)
+	}
+	prv, err := open(ek, k.Sealed, exportAD(k.KeyType, k.Pub))
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadExport, "wrong passphrase or corrupt key")
+	}
+
+	var pub []byte
+	switch k.KeyType {
+	case typeChainKD:
+		if len(prv) != len(chainkd.XPrv{}) {
+			return nil, errors.WithDetail(ErrBadExport, "wrong private key size")
+		}
+		var xprv chainkd.XPrv
+		copy(xprv[:], prv)
+		pub = xprv.XPub().Bytes()
+	case typeEd25519:
+		if len(prv) != ed25519.PrivateKeySize {
+			return nil, errors.WithDetail(ErrBadExport, "wrong private key size")
+		}
+		pub = ed25519.PrivateKey(prv).Public().(ed25519.PublicKey)
+	default:
+		return nil, errors.WithDetailf(ErrBadExport, "unknown key type %q", k.KeyType)
+	}
+	if !bytes.Equal(pub, k.Pub) {
+		return nil, errors.WithDetail(ErrBadExport, "private key doesn't match public key")
+	}
+	return prv, nil
+}
+
+func exportAD(keyType string, pub []byte) []byte {
+	return append([]byte("chain keystore export v1\x00"), keyAD(keyType, pub)...)
+}
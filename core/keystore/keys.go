@@ -0,0 +1,272 @@
+package keystore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc/legacy"
+)
+
+// Types of keys held in the keystore.
+const (
+	typeChainKD = "chain_kd"
+	typeEd25519 = "ed25519"
+)
+
+// XCreate produces a new random xprv and stores it, encrypted, in
+// the db.
+func (s *Store) XCreate(ctx context.Context, alias string) (*XPub, error) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.insertKey(ctx, typeChainKD, xpub.Bytes(), xprv.Bytes(), alias, false)
+	if err != nil {
+		return nil, err
+	}
+	return &XPub{XPub: xpub, Alias: aliasPtr(alias)}, nil
+}
+
+// Create produces a new random prv and stores it, encrypted, in the
+// db.
+func (s *Store) Create(ctx context.Context, alias string) (*Pub, error) {
+	pub, _, err := s.createEd25519Key(ctx, alias, false)
+	return pub, err
+}
+
+// GetOrCreate looks for the Ed25519 key with the given alias, generating a
+// new one if it's not found.
+func (s *Store) GetOrCreate(ctx context.Context, alias string) (*Pub, bool, error) {
+	return s.createEd25519Key(ctx, alias, true)
+}
+
+func (s *Store) createEd25519Key(ctx context.Context, alias string, get bool) (*Pub, bool, error) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, false, err
+	}
+	existing, err := s.insertKey(ctx, typeEd25519, pub, prv, alias, get)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return &Pub{Pub: ed25519.PublicKey(existing), Alias: aliasPtr(alias)}, false, nil
+	}
+	return &Pub{Pub: pub, Alias: aliasPtr(alias)}, true, nil
+}
+
+// insertKey seals prv and stores it. If alias is already in use and
+// get is true, it returns the public key with that alias instead.
+func (s *Store) insertKey(ctx context.Context, keyType string, pub, prv []byte, alias string, get bool) (existing []byte, err error) {
+	sealed, err := s.sealKey(keyType, pub, prv)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlAlias := sql.NullString{String: alias, Valid: alias != ""}
+	const q = `INSERT INTO keystore_keys (pub, sealed_prv, alias, key_type) VALUES ($1, $2, $3, $4)`
+	_, err = s.db.ExecContext(ctx, q, pub, sealed, sqlAlias, keyType)
+	if pg.IsUniqueViolation(err) {
+		if !get {
+			return nil, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
+		}
+		const q = `SELECT pub FROM keystore_keys WHERE alias = $1 AND key_type = $2`
+		err = s.db.QueryRowContext(ctx, q, alias, keyType).Scan(&existing)
+		return existing, errors.Wrapf(err, "reading existing pub with alias %s", alias)
+	}
+	return nil, errors.Wrap(err, "storing new key")
+}
+
+// ListKeys returns a list of all xpubs from the db. It doesn't
+// require the Store to be unlocked.
+func (s *Store) ListKeys(ctx context.Context, aliases []string, after string, limit int) ([]*XPub, string, error) {
+	if len(aliases) > listKeyMaxAliases {
+		return nil, "", errors.WithDetailf(ErrTooManyAliasesToList, "max: %d", listKeyMaxAliases)
+	}
+
+	var (
+		zafter int64
+		err    error
+	)
+
+	if after != "" {
+		zafter, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(ErrInvalidAfter, "value: %q", after)
+		}
+	}
+
+	var (
+		xpubs  []*XPub
+		params []interface{}
+	)
+	q := `
+		SELECT pub, alias, sort_id FROM keystore_keys
+		WHERE key_type = 'chain_kd'
+	`
+
+	if len(aliases) > 0 {
+		params = append(params, pq.StringArray(aliases))
+		q += fmt.Sprintf(" AND alias = ANY($%d)", len(params))
+	}
+
+	if zafter != 0 {
+		params = append(params, zafter)
+		q += fmt.Sprintf(" AND sort_id < $%d", len(params))
+	}
+
+	q += fmt.Sprintf(" ORDER BY sort_id DESC LIMIT %d", limit)
+
+	consumeRow := func(b []byte, alias sql.NullString, sortID int64) {
+		var hdxpub chainkd.XPub
+		copy(hdxpub[:], b)
+		xpub := &XPub{XPub: hdxpub}
+		if alias.Valid {
+			xpub.Alias = &alias.String
+		}
+		xpubs = append(xpubs, xpub)
+		zafter = sortID
+	}
+	params = append(params, consumeRow)
+
+	err = pg.ForQueryRows(ctx, s.db, q, params...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return xpubs, strconv.FormatInt(zafter, 10), nil
+}
+
+func (s *Store) loadChainKDKey(ctx context.Context, xpub chainkd.XPub) (xprv chainkd.XPrv, err error) {
+	if s.Locked() {
+		return xprv, ErrLocked
+	}
+	s.mu.Lock()
+	cached, ok := s.kdCache[xpub]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	sealed, err := s.loadSealedKey(ctx, typeChainKD, xpub.Bytes())
+	if err != nil {
+		return xprv, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.openKey(typeChainKD, xpub.Bytes(), sealed)
+	if err != nil {
+		return xprv, err
+	}
+	copy(xprv[:], b)
+	zero(b)
+	s.kdCache[xpub] = xprv
+	return xprv, nil
+}
+
+// XSign looks up the xprv given the xpub, optionally derives a new
+// xprv with the given path (but does not store the new xprv), and
+// signs the given msg. It records the signature in the sign audit
+// log before returning it.
+func (s *Store) XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg []byte) ([]byte, error) {
+	return s.XSignHardened(ctx, xpub, path, 0, msg)
+}
+
+// XSignHardened is like XSign, but derives the first hardened
+// elements of path with hardened derivation.
+func (s *Store) XSignHardened(ctx context.Context, xpub chainkd.XPub, path [][]byte, hardened int, msg []byte) ([]byte, error) {
+	xprv, err := s.loadChainKDKey(ctx, xpub)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) > 0 {
+		xprv = xprv.DeriveHardened(path, hardened)
+	}
+	err = s.recordSignEvent(ctx, typeChainKD, xpub.Bytes(), path, msg)
+	if err != nil {
+		return nil, err
+	}
+	return xprv.Sign(msg), nil
+}
+
+// XDeriveHardened returns the xpub derived from xpub along path
+// with hardened derivation, which can't be done without the xprv.
+func (s *Store) XDeriveHardened(ctx context.Context, xpub chainkd.XPub, path [][]byte) (chainkd.XPub, error) {
+	xprv, err := s.loadChainKDKey(ctx, xpub)
+	if err != nil {
+		return chainkd.XPub{}, err
+	}
+	return xprv.DeriveHardened(path, len(path)).XPub(), nil
+}
+
+func (s *Store) DeleteChainKDKey(ctx context.Context, xpub chainkd.XPub) error {
+	s.mu.Lock()
+	delete(s.kdCache, xpub)
+	s.mu.Unlock()
+	_, err := s.db.ExecContext(ctx, "DELETE FROM keystore_keys WHERE pub = $1 AND key_type='chain_kd'", xpub.Bytes())
+	return err
+}
+
+func (s *Store) loadEd25519Key(ctx context.Context, pub ed25519.PublicKey) (ed25519.PrivateKey, error) {
+	if s.Locked() {
+		return nil, ErrLocked
+	}
+	s.mu.Lock()
+	cached, ok := s.edCache[string(pub)]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	sealed, err := s.loadSealedKey(ctx, typeEd25519, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.openKey(typeEd25519, pub, sealed)
+	if err != nil {
+		return nil, err
+	}
+	prv := ed25519.PrivateKey(b)
+	s.edCache[string(pub)] = prv
+	return prv, nil
+}
+
+// Sign looks up the prv given the pub and signs the hash of the
+// given block header. It records the signature in the sign audit
+// log before returning it.
+func (s *Store) Sign(ctx context.Context, pub ed25519.PublicKey, bh *legacy.BlockHeader) ([]byte, error) {
+	prv, err := s.loadEd25519Key(ctx, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	// ed25519.Sign will panic if prv is the wrong size. Protect against that.
+	if len(prv) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidKeySize
+	}
+	msg := bh.Hash()
+	err = s.recordSignEvent(ctx, typeEd25519, pub, nil, msg.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(prv, msg.Bytes()), nil
+}
+
+func aliasPtr(alias string) *string {
+	if alias == "" {
+		return nil
+	}
+	return &alias
+}
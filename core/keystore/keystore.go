@@ -0,0 +1,367 @@
+// Package keystore provides a software key store that keeps private
+// keys encrypted at rest. It exposes the same operations as the
+// mockhsm, but every private key is sealed with a data key that is
+// itself sealed under a master key. The master key is derived from a
+// passphrase with scrypt, or read directly from a key file. The
+// store must be unlocked before keys can be created or used to sign.
+package keystore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// listKeyMaxAliases limits the alias filter to a sane maximum size.
+const listKeyMaxAliases = 200
+
+// MasterKeySize is the size in bytes of a file-provided master key.
+const MasterKeySize = 32
+
+// Parameters for scrypt, used both to derive the master key from a
+// passphrase and to encrypt exported keys.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	saltSize = 16
+	keySize  = 32
+)
+
+// Ways of obtaining the master key, recorded when the keystore is
+// initialized.
+const (
+	kdfScrypt = "scrypt"
+	kdfRaw    = "raw"
+)
+
+// masterAD is the additional data authenticated along with the
+// sealed data key.
+var masterAD = []byte("chain keystore data key v1")
+
+var (
+	ErrDuplicateKeyAlias    = errors.New("duplicate key alias")
+	ErrInvalidAfter         = errors.New("invalid after")
+	ErrNoKey                = errors.New("key not found")
+	ErrInvalidKeySize       = errors.New("key invalid size")
+	ErrTooManyAliasesToList = errors.New("requested aliases exceeds limit")
+	ErrLocked               = errors.New("keystore is locked")
+	ErrBadPassphrase        = errors.New("invalid passphrase or master key")
+	ErrUninitialized        = errors.New("keystore is not initialized")
+	ErrInitialized          = errors.New("keystore is already initialized")
+)
+
+// Store is a key store backed by Postgres. Private keys are only
+// ever written to the database in encrypted form.
+//
+// Whether a Store is locked is a property of the process; unlocking
+// one Core process does not unlock the others in its cluster.
+type Store struct {
+	db pg.DB
+
+	mu      sync.Mutex
+	dataKey *[keySize]byte // nil when locked
+	kdCache map[chainkd.XPub]chainkd.XPrv
+	edCache map[string]ed25519.PrivateKey // ed25519.PublicKeys must be turned into strings before being used as map keys
+}
+
+type XPub struct {
+	Alias *string      `json:"alias"`
+	XPub  chainkd.XPub `json:"xpub"`
+}
+
+type Pub struct {
+	Alias *string           `json:"alias"`
+	Pub   ed25519.PublicKey `json:"pub"`
+}
+
+// New returns a locked Store using db.
+func New(db pg.DB) *Store {
+	return &Store{
+		db:      db,
+		kdCache: make(map[chainkd.XPub]chainkd.XPrv),
+		edCache: make(map[string]ed25519.PrivateKey),
+	}
+}
+
+// Init initializes the keystore with a new data key sealed under
+// passphrase, and unlocks it. It fails with ErrInitialized if the
+// keystore has already been initialized, by this or any other
+// process in the cluster.
+func (s *Store) Init(ctx context.Context, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.WithDetail(ErrBadPassphrase, "passphrase is empty")
+	}
+	return s.initialize(ctx, kdfScrypt, func(salt []byte, n, r, p int) ([]byte, error) {
+		return scrypt.Key(passphrase, salt, n, r, p, keySize)
+	})
+}
+
+// InitWithKey is like Init, but seals the data key under masterKey,
+// which must be MasterKeySize random bytes. See UnlockWithKey.
+func (s *Store) InitWithKey(ctx context.Context, masterKey []byte) error {
+	if len(masterKey) != MasterKeySize {
+		return errors.WithDetailf(ErrBadPassphrase, "master key must be %d bytes", MasterKeySize)
+	}
+	return s.initialize(ctx, kdfRaw, func([]byte, int, int, int) ([]byte, error) {
+		return masterKey, nil
+	})
+}
+
+// Unlock derives the master key from passphrase and uses it to
+// decrypt the keystore's data key. It fails with ErrUninitialized
+// if the keystore has never been initialized; see Init.
+func (s *Store) Unlock(ctx context.Context, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.WithDetail(ErrBadPassphrase, "passphrase is empty")
+	}
+	return s.unlock(ctx, kdfScrypt, func(salt []byte, n, r, p int) ([]byte, error) {
+		return scrypt.Key(passphrase, salt, n, r, p, keySize)
+	})
+}
+
+// UnlockWithKey is like Unlock, but uses masterKey, which must be
+// MasterKeySize random bytes, as the master key directly. It's
+// intended for master keys provided in a file, so that a Core can
+// unlock its keystore without an operator present.
+func (s *Store) UnlockWithKey(ctx context.Context, masterKey []byte) error {
+	if len(masterKey) != MasterKeySize {
+		return errors.WithDetailf(ErrBadPassphrase, "master key must be %d bytes", MasterKeySize)
+	}
+	return s.unlock(ctx, kdfRaw, func([]byte, int, int, int) ([]byte, error) {
+		return masterKey, nil
+	})
+}
+
+// ReadMasterKeyFile reads a master key for UnlockWithKey from the
+// file at path. The file must contain the key either as raw bytes
+// or hex-encoded.
+func ReadMasterKeyFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading master key file")
+	}
+	if s := strings.TrimSpace(string(b)); len(s) == 2*MasterKeySize {
+		if k, err := hex.DecodeString(s); err == nil {
+			return k, nil
+		}
+	}
+	return b, nil
+}
+
+func (s *Store) unlock(ctx context.Context, kdf string, masterKey func(salt []byte, n, r, p int) ([]byte, error)) error {
+	var (
+		storedKDF string
+		salt      []byte
+		n, r, p   int
+		sealed    []byte
+	)
+	const q = `SELECT kdf, salt, scrypt_n, scrypt_r, scrypt_p, sealed_key FROM keystore`
+	err := s.db.QueryRowContext(ctx, q).Scan(&storedKDF, &salt, &n, &r, &p, &sealed)
+	if err == sql.ErrNoRows {
+		return ErrUninitialized
+	} else if err != nil {
+		return errors.Wrap(err, "reading keystore")
+	}
+	if storedKDF != kdf {
+		return errors.WithDetailf(ErrBadPassphrase, "keystore uses a %s master key", storedKDF)
+	}
+	mk, err := masterKey(salt, n, r, p)
+	if err != nil {
+		return errors.Wrap(err, "deriving master key")
+	}
+	dk, err := open(mk, sealed, masterAD)
+	if err != nil || len(dk) != keySize {
+		return ErrBadPassphrase
+	}
+	s.setDataKey(dk)
+	return nil
+}
+
+// initialize creates the keystore's data key, seals it under the
+// master key, and unlocks the Store with it.
+func (s *Store) initialize(ctx context.Context, kdf string, masterKey func(salt []byte, n, r, p int) ([]byte, error)) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM keystore)`).Scan(&exists)
+	if err != nil {
+		return errors.Wrap(err, "reading keystore")
+	}
+	if exists {
+		return ErrInitialized
+	}
+
+	salt, err := randomBytes(saltSize)
+	if err != nil {
+		return err
+	}
+	mk, err := masterKey(salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return errors.Wrap(err, "deriving master key")
+	}
+	dk, err := randomBytes(keySize)
+	if err != nil {
+		return err
+	}
+	defer zero(dk)
+	sealed, err := seal(mk, dk, masterAD)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+		INSERT INTO keystore (kdf, salt, scrypt_n, scrypt_r, scrypt_p, sealed_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (singleton) DO NOTHING
+	`
+	res, err := s.db.ExecContext(ctx, q, kdf, salt, scryptN, scryptR, scryptP, sealed)
+	if err != nil {
+		return errors.Wrap(err, "storing data key")
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "storing data key")
+	}
+	if inserted == 0 {
+		// Another process initialized the keystore first.
+		return ErrInitialized
+	}
+	s.setDataKey(dk)
+	return nil
+}
+
+// setDataKey copies dk into the Store's data key, unlocking it.
+func (s *Store) setDataKey(dk []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dataKey == nil {
+		s.dataKey = new([keySize]byte)
+	}
+	copy(s.dataKey[:], dk)
+	zero(dk)
+}
+
+// Lock discards the data key and all decrypted private keys held in
+// memory. Until the Store is unlocked again, operations that need a
+// private key fail with ErrLocked.
+func (s *Store) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dataKey != nil {
+		zero(s.dataKey[:])
+		s.dataKey = nil
+	}
+	for xpub := range s.kdCache {
+		delete(s.kdCache, xpub)
+	}
+	// Cached ed25519 keys may still be in use by a concurrent Sign,
+	// so they're dropped rather than zeroed.
+	for pub := range s.edCache {
+		delete(s.edCache, pub)
+	}
+}
+
+// Locked reports whether the Store is locked.
+func (s *Store) Locked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dataKey == nil
+}
+
+// sealKey encrypts prv under the data key. It binds the ciphertext
+// to the key's type and public key, so that a sealed private key
+// can't be swapped onto another row.
+func (s *Store) sealKey(keyType string, pub, prv []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dataKey == nil {
+		return nil, ErrLocked
+	}
+	return seal(s.dataKey[:], prv, keyAD(keyType, pub))
+}
+
+// openKey decrypts a private key sealed by sealKey.
+// The caller must hold s.mu.
+func (s *Store) openKey(keyType string, pub, sealed []byte) ([]byte, error) {
+	if s.dataKey == nil {
+		return nil, ErrLocked
+	}
+	prv, err := open(s.dataKey[:], sealed, keyAD(keyType, pub))
+	return prv, errors.Wrap(err, "decrypting private key")
+}
+
+// loadSealedKey reads the sealed private key for pub.
+func (s *Store) loadSealedKey(ctx context.Context, keyType string, pub []byte) ([]byte, error) {
+	var sealed []byte
+	const q = `SELECT sealed_prv FROM keystore_keys WHERE pub = $1 AND key_type = $2`
+	err := s.db.QueryRowContext(ctx, q, pub, keyType).Scan(&sealed)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoKey
+	}
+	return sealed, errors.Wrap(err, "reading private key")
+}
+
+func keyAD(keyType string, pub []byte) []byte {
+	ad := append([]byte(keyType), 0)
+	return append(ad, pub...)
+}
+
+// seal encrypts and authenticates plaintext and ad with AES-256-GCM
+// under key, returning the nonce followed by the ciphertext.
+func seal(key, plaintext, ad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+// open decrypts and authenticates a message produced by seal.
+func open(key, sealed, ad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed message too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, ad)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, errors.Wrap(err, "reading random bytes")
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
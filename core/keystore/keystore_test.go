@@ -0,0 +1,314 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc/legacy"
+	"chain/testutil"
+)
+
+func TestLockUnlock(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	ks := New(db)
+
+	if !ks.Locked() {
+		t.Fatal("expected new keystore to be locked")
+	}
+	_, err := ks.XCreate(ctx, "")
+	if err != ErrLocked {
+		t.Fatalf("XCreate on locked keystore got error %v, want %v", err, ErrLocked)
+	}
+
+	passphrase := []byte("correct horse battery staple")
+	err = ks.Unlock(ctx, passphrase)
+	if err != ErrUninitialized {
+		t.Fatalf("Unlock on uninitialized keystore got error %v, want %v", err, ErrUninitialized)
+	}
+	err = ks.Init(ctx, passphrase)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	xpub, err := ks.XCreate(ctx, "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Private keys must not be stored in the clear.
+	var sealed []byte
+	err = db.QueryRowContext(ctx, `SELECT sealed_prv FROM keystore_keys WHERE pub = $1`, xpub.XPub.Bytes()).Scan(&sealed)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	ks.mu.Lock()
+	xprv := ks.kdCache[xpub.XPub]
+	ks.mu.Unlock()
+	if bytes.Contains(sealed, xprv[:32]) {
+		t.Error("sealed private key contains the plaintext key")
+	}
+
+	ks.Lock()
+	if !ks.Locked() {
+		t.Fatal("expected keystore to be locked")
+	}
+	msg := []byte("message")
+	_, err = ks.XSign(ctx, xpub.XPub, nil, msg)
+	if err != ErrLocked {
+		t.Fatalf("XSign on locked keystore got error %v, want %v", err, ErrLocked)
+	}
+
+	// Another process using the same database must supply the
+	// same passphrase.
+	ks2 := New(db)
+	err = ks2.Init(ctx, []byte("another passphrase"))
+	if err != ErrInitialized {
+		t.Fatalf("Init on initialized keystore got error %v, want %v", err, ErrInitialized)
+	}
+	if !ks2.Locked() {
+		t.Fatal("expected failed Init to leave the keystore locked")
+	}
+	err = ks2.Unlock(ctx, []byte("wrong"))
+	if errors.Root(err) != ErrBadPassphrase {
+		t.Fatalf("Unlock with wrong passphrase got error %v, want %v", err, ErrBadPassphrase)
+	}
+	err = ks2.UnlockWithKey(ctx, make([]byte, MasterKeySize))
+	if errors.Root(err) != ErrBadPassphrase {
+		t.Fatalf("UnlockWithKey on passphrase keystore got error %v, want %v", err, ErrBadPassphrase)
+	}
+	err = ks2.Unlock(ctx, passphrase)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	sig, err := ks2.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !xpub.XPub.Verify(msg, sig) {
+		t.Error("expected verify to succeed")
+	}
+}
+
+func TestUnlockWithKey(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	ks := New(db)
+
+	masterKey := bytes.Repeat([]byte{0x01}, MasterKeySize)
+	err := ks.InitWithKey(ctx, masterKey[:16])
+	if errors.Root(err) != ErrBadPassphrase {
+		t.Fatalf("InitWithKey with short key got error %v, want %v", err, ErrBadPassphrase)
+	}
+	err = ks.InitWithKey(ctx, masterKey)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	pub, err := ks.Create(ctx, "block_key")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, created, err := ks.GetOrCreate(ctx, "block_key")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if created || !bytes.Equal(got.Pub, pub.Pub) {
+		t.Errorf("GetOrCreate(block_key) = %x, %t; want %x, false", got.Pub, created, pub.Pub)
+	}
+
+	ks2 := New(db)
+	err = ks2.UnlockWithKey(ctx, bytes.Repeat([]byte{0x02}, MasterKeySize))
+	if errors.Root(err) != ErrBadPassphrase {
+		t.Fatalf("UnlockWithKey with wrong key got error %v, want %v", err, ErrBadPassphrase)
+	}
+	err = ks2.UnlockWithKey(ctx, masterKey)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	bh := &legacy.BlockHeader{Version: 1, Height: 2}
+	sig, err := ks2.Sign(ctx, pub.Pub, bh)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	h := bh.Hash()
+	if !ed25519.Verify(pub.Pub, h.Bytes(), sig) {
+		t.Error("expected verify to succeed")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	_, db1 := pgtest.NewDB(t, pgtest.SchemaPath)
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	src, dst := New(db1), New(db2)
+	err := src.Init(ctx, []byte("source passphrase"))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = dst.Init(ctx, []byte("destination passphrase"))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	xpub, err := src.XCreate(ctx, "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	exported, err := src.Export(ctx, xpub.XPub.Bytes(), []byte("export passphrase"), false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Block keys are only exported when asked for.
+	blockKey, err := src.Create(ctx, "block_key")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = src.Export(ctx, blockKey.Pub, []byte("export passphrase"), false)
+	if err != ErrBlockKey {
+		t.Fatalf("Export of block key got error %v, want %v", err, ErrBlockKey)
+	}
+	_, err = src.Export(ctx, blockKey.Pub, []byte("export passphrase"), true)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	err = dst.Import(ctx, exported, []byte("export passphrase"), "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	xpubs, _, err := dst.ListKeys(ctx, []string{"alice"}, "", 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(xpubs) != 1 || xpubs[0].XPub != xpub.XPub {
+		t.Fatalf("ListKeys after import got %v, want [%x]", xpubs, xpub.XPub.Bytes())
+	}
+	msg := []byte("message")
+	sig, err := dst.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !xpub.XPub.Verify(msg, sig) {
+		t.Error("expected verify with imported key to succeed")
+	}
+}
+
+func TestListSignEvents(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	ks := New(db)
+	err := ks.Init(ctx, []byte("passphrase"))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	xpub, err := ks.XCreate(ctx, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	path := [][]byte{{0x01}, {0x02, 0x03}}
+	for _, msg := range []string{"one", "two", "three"} {
+		_, err = ks.XSign(ctx, xpub.XPub, path, []byte(msg))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	events, after, err := ks.ListSignEvents(ctx, "", 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(events) != 2 || string(events[0].Message) != "three" || string(events[1].Message) != "two" {
+		t.Fatalf("ListSignEvents got %v, want events for three and two", events)
+	}
+	if !bytes.Equal(events[0].Pub, xpub.XPub.Bytes()) || len(events[0].Path) != 2 || !bytes.Equal(events[0].Path[1], path[1]) {
+		t.Errorf("ListSignEvents got event %+v, want pub %x and path %x", events[0], xpub.XPub.Bytes(), path)
+	}
+	events, _, err = ks.ListSignEvents(ctx, after, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(events) != 1 || string(events[0].Message) != "one" {
+		t.Fatalf("ListSignEvents second page got %v, want event for one", events)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, keySize)
+	sealed, err := seal(key, []byte("secret"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := open(key, sealed, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "secret" {
+		t.Errorf("open got %q, want %q", got, "secret")
+	}
+
+	_, err = open(key, sealed, []byte("other ad"))
+	if err == nil {
+		t.Error("expected open with wrong additional data to fail")
+	}
+	_, err = open(bytes.Repeat([]byte{0x02}, keySize), sealed, []byte("ad"))
+	if err == nil {
+		t.Error("expected open with wrong key to fail")
+	}
+	_, err = open(key, sealed[:4], []byte("ad"))
+	if err == nil {
+		t.Error("expected open of truncated message to fail")
+	}
+}
+
+func TestOpenExport(t *testing.T) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphrase := []byte("export passphrase")
+	exported, err := sealExport(typeChainKD, xpub.Bytes(), xprv.Bytes(), nil, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := openExport(exported, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, xprv.Bytes()) {
+		t.Errorf("openExport got %x, want %x", got, xprv.Bytes())
+	}
+
+	_, otherPub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched, err := sealExport(typeChainKD, otherPub.Bytes(), xprv.Bytes(), nil, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badVersion := *exported
+	badVersion.Version = 2
+	badCost := *exported
+	badCost.ScryptN = 1 << 30
+
+	cases := []struct {
+		key        *ExportedKey
+		passphrase string
+	}{
+		{exported, "wrong passphrase"},
+		{mismatched, string(passphrase)},
+		{&badVersion, string(passphrase)},
+		{&badCost, string(passphrase)},
+	}
+	for i, c := range cases {
+		_, err := openExport(c.key, []byte(c.passphrase))
+		if errors.Root(err) != ErrBadExport {
+			t.Errorf("case %d: got error %v, want %v", i, err, ErrBadExport)
+		}
+	}
+}
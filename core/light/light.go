@@ -0,0 +1,233 @@
+// Package light implements header-only replication for Chain Cores
+// that don't store the full blockchain. A light Core verifies the
+// signatures on every block header and stores only the headers and
+// the transactions, proven to be included in their blocks, that pay
+// to the control programs it watches.
+package light
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+
+	"chain/core/rpc"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/validation"
+)
+
+// MaxHeaders is the maximum number of block headers returned by a
+// single call to /rpc/get-block-headers.
+const MaxHeaders = 1000
+
+const requestTimeout = 30 * time.Second
+
+// ErrBadHeader is returned when a peer serves a block header or
+// transaction proof that doesn't verify.
+var ErrBadHeader = errors.New("invalid block header")
+
+// HeadersRequest is the request body of /rpc/get-block-headers.
+// It asks for up to Count headers starting at Height, along with
+// proofs of inclusion for the transactions in those blocks that
+// have an output paying to any of ControlPrograms.
+type HeadersRequest struct {
+	Height          uint64               `json:"height"`
+	Count           int                  `json:"count"`
+	ControlPrograms []chainjson.HexBytes `json:"control_programs,omitempty"`
+}
+
+// Header is a block header along with the watched transactions
+// included in the block.
+type Header struct {
+	Header       *legacy.BlockHeader `json:"header"`
+	Transactions []*ProvenTx         `json:"transactions,omitempty"`
+}
+
+// ProvenTx is a transaction along with the merkle path proving its
+// inclusion in a block's transactions root.
+type ProvenTx struct {
+	Tx       *legacy.Tx    `json:"transaction"`
+	Position int           `json:"position"`
+	Path     bc.MerklePath `json:"path"`
+}
+
+// Syncer replicates verified block headers from a remote Core.
+type Syncer struct {
+	db             pg.DB
+	peer           *rpc.Client
+	initialBlockID bc.Hash
+	programs       func() [][]string
+
+	mu     sync.Mutex
+	latest *legacy.BlockHeader
+}
+
+// New initializes a new Syncer to replicate block headers from peer.
+// The first header, at height 1, must hash to initialBlockID. Every
+// later header must be signed according to the previous header's
+// next consensus program.
+//
+// The programs function is called before each request to retrieve
+// the current set of watched control programs, as 1-tuples of
+// hex-encoded programs.
+//
+// To begin replicating headers, the caller must call Run.
+func New(db pg.DB, peer *rpc.Client, initialBlockID bc.Hash, programs func() [][]string) *Syncer {
+	return &Syncer{
+		db:             db,
+		peer:           peer,
+		initialBlockID: initialBlockID,
+		programs:       programs,
+	}
+}
+
+// Height returns the height of the most recent verified block
+// header.
+func (s *Syncer) Height() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest == nil {
+		return 0
+	}
+	return s.latest.Height
+}
+
+// Run replicates block headers from the peer until ctx is canceled.
+// It reports its health through health.
+func (s *Syncer) Run(ctx context.Context, health func(error)) {
+	var nfailures uint
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf(ctx, "Deposed, light sync exiting")
+			return
+		default:
+		}
+
+		err := s.sync(ctx)
+		if errors.Root(err) == context.DeadlineExceeded {
+			// The peer had no new blocks before the
+			// request timed out.
+			continue
+		} else if err != nil {
+			health(err)
+			log.Error(ctx, err)
+			nfailures++
+			time.Sleep(backoffDur(nfailures))
+			continue
+		}
+		health(nil)
+		nfailures = 0
+	}
+}
+
+// sync requests the headers following the latest verified header,
+// verifies them and stores them.
+func (s *Syncer) sync(ctx context.Context) error {
+	s.mu.Lock()
+	prev := s.latest
+	s.mu.Unlock()
+
+	if prev == nil {
+		var err error
+		prev, err = latestHeader(ctx, s.db)
+		if err != nil {
+			return errors.Wrap(err, "loading latest header")
+		}
+		s.mu.Lock()
+		s.latest = prev
+		s.mu.Unlock()
+	}
+
+	req := HeadersRequest{Height: 1, Count: MaxHeaders}
+	if prev != nil {
+		req.Height = prev.Height + 1
+	}
+	for _, tup := range s.programs() {
+		prog, err := hex.DecodeString(tup[0])
+		if err != nil {
+			return errors.Wrap(err, "decoding watched program")
+		}
+		req.ControlPrograms = append(req.ControlPrograms, prog)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var headers []*Header
+	err := s.peer.Call(reqCtx, "/rpc/get-block-headers", req, &headers)
+	if err != nil {
+		return errors.Wrap(err, "getting block headers")
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	err = verifyHeaders(prev, headers, s.initialBlockID)
+	if err != nil {
+		return err
+	}
+	err = saveHeaders(ctx, s.db, headers)
+	if err != nil {
+		return errors.Wrap(err, "saving headers")
+	}
+
+	s.mu.Lock()
+	s.latest = headers[len(headers)-1].Header
+	s.mu.Unlock()
+	return nil
+}
+
+// verifyHeaders checks that headers form a valid chain extending
+// prev, and that each of their transactions is included in its
+// block. If prev is nil, headers must begin with the initial block.
+func verifyHeaders(prev *legacy.BlockHeader, headers []*Header, initialBlockID bc.Hash) error {
+	var prevBlock *bc.Block
+	if prev != nil {
+		prevBlock = legacy.MapBlock(&legacy.Block{BlockHeader: *prev})
+	}
+	for _, h := range headers {
+		if h.Header == nil {
+			return errors.WithDetail(ErrBadHeader, "missing header")
+		}
+		b := legacy.MapBlock(&legacy.Block{BlockHeader: *h.Header})
+		err := validation.ValidateBlockHeader(b, prevBlock)
+		if err != nil {
+			return errors.Sub(ErrBadHeader, errors.Wrapf(err, "validating header at height %d", b.Height))
+		}
+		if b.Height == 1 {
+			if b.ID != initialBlockID {
+				return errors.WithDetailf(ErrBadHeader, "initial block %x doesn't match blockchain ID %x", b.ID.Bytes(), initialBlockID.Bytes())
+			}
+		} else {
+			err = validation.ValidateBlockSig(b, prevBlock.NextConsensusProgram)
+			if err != nil {
+				return errors.Sub(ErrBadHeader, errors.Wrapf(err, "validating signature at height %d", b.Height))
+			}
+		}
+		for _, ptx := range h.Transactions {
+			if ptx.Tx == nil {
+				return errors.WithDetailf(ErrBadHeader, "missing transaction at height %d", b.Height)
+			}
+			err = bc.VerifyTxInclusion(ptx.Tx.ID, ptx.Path, *b.TransactionsRoot)
+			if err != nil {
+				return errors.Sub(ErrBadHeader, errors.Wrapf(err, "transaction %x at height %d", ptx.Tx.ID.Bytes(), b.Height))
+			}
+		}
+		prevBlock = b
+	}
+	return nil
+}
+
+func backoffDur(n uint) time.Duration {
+	if n > 33 {
+		n = 33 // cap to about 10s
+	}
+	d := rand.Int63n(1 << n)
+	return time.Duration(d)
+}
@@ -0,0 +1,83 @@
+package light
+
+import (
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/vm"
+	"chain/protocol/vm/vmutil"
+)
+
+func TestVerifyHeaders(t *testing.T) {
+	prog, err := vmutil.BlockMultiSigProgram(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watched := legacy.NewTx(legacy.TxData{
+		Version: 1,
+		Outputs: []*legacy.TxOutput{legacy.NewTxOutput(bc.AssetID{}, 1, []byte{byte(vm.OP_TRUE)}, nil)},
+	})
+	other := legacy.NewTx(legacy.TxData{Version: 1, ReferenceData: []byte("other")})
+	txs := []*bc.Tx{other.Tx, watched.Tx}
+	path, err := bc.TxMerklePath(txs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h1 := makeHeader(t, nil, prog, nil)
+	h2 := makeHeader(t, h1, prog, txs)
+	h3 := makeHeader(t, h2, []byte{byte(vm.OP_FALSE)}, nil)
+	h4 := makeHeader(t, h3, prog, nil)
+	initialID := h1.Hash()
+
+	proven := &ProvenTx{Tx: watched, Position: 1, Path: path}
+	forged := &ProvenTx{Tx: other, Position: 1, Path: path}
+
+	cases := []struct {
+		prev    *legacy.BlockHeader
+		headers []*Header
+		initial bc.Hash
+		ok      bool
+	}{
+		{nil, []*Header{{Header: h1}, {Header: h2, Transactions: []*ProvenTx{proven}}}, initialID, true},
+		{h1, []*Header{{Header: h2}, {Header: h3}}, initialID, true},
+		{nil, []*Header{{Header: h1}}, bc.Hash{}, false},                                   // wrong blockchain
+		{nil, []*Header{{Header: h2}}, initialID, false},                                   // doesn't start at the initial block
+		{h1, []*Header{{Header: h3}}, initialID, false},                                    // skips a block
+		{h1, []*Header{{Header: h2, Transactions: []*ProvenTx{forged}}}, initialID, false}, // bad proof
+		{h3, []*Header{{Header: h4}}, initialID, false},                                    // unsatisfied consensus program
+	}
+	for i, c := range cases {
+		err := verifyHeaders(c.prev, c.headers, c.initial)
+		if c.ok && err != nil {
+			t.Errorf("case %d: unexpected error %s", i, err)
+		} else if !c.ok && errors.Root(err) != ErrBadHeader {
+			t.Errorf("case %d: got error %v, want %v", i, err, ErrBadHeader)
+		}
+	}
+}
+
+func makeHeader(t *testing.T, prev *legacy.BlockHeader, prog []byte, txs []*bc.Tx) *legacy.BlockHeader {
+	root, err := bc.MerkleRoot(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &legacy.BlockHeader{
+		Version:     1,
+		Height:      1,
+		TimestampMS: 1,
+		BlockCommitment: legacy.BlockCommitment{
+			TransactionsMerkleRoot: root,
+			ConsensusProgram:       prog,
+		},
+	}
+	if prev != nil {
+		h.Height = prev.Height + 1
+		h.PreviousBlockHash = prev.Hash()
+		h.TimestampMS = prev.TimestampMS + 1
+	}
+	return h
+}
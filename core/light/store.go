@@ -0,0 +1,129 @@
+package light
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+)
+
+// ErrBadCursor is returned when a malformed cursor is provided to
+// ListTransactions.
+var ErrBadCursor = errors.New("malformed pagination parameter after")
+
+// Tx is a watched transaction that has been proven to be included
+// in a verified block.
+type Tx struct {
+	ID          bc.Hash    `json:"id"`
+	BlockHeight uint64     `json:"block_height"`
+	Position    int        `json:"position"`
+	Tx          *legacy.Tx `json:"transaction"`
+}
+
+// GetHeader returns the verified block header at the provided
+// height. If there is no such header, it returns an error that
+// wraps pg.ErrUserInputNotFound.
+func (s *Syncer) GetHeader(ctx context.Context, height uint64) (*legacy.BlockHeader, error) {
+	const q = `SELECT header FROM light_block_headers WHERE height = $1`
+	var h legacy.BlockHeader
+	err := s.db.QueryRowContext(ctx, q, height).Scan(&h)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "no verified header at height %d", height)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "select query")
+	}
+	return &h, nil
+}
+
+// ListTransactions returns up to limit watched transactions, in
+// blockchain order, following the position described by the cursor
+// after. It also returns a cursor for the position following the
+// last returned transaction. An empty cursor begins at the start of
+// the blockchain.
+func (s *Syncer) ListTransactions(ctx context.Context, after string, limit int) ([]*Tx, string, error) {
+	var afterHeight uint64
+	afterPos := -1
+	if after != "" {
+		_, err := fmt.Sscanf(after, "%d:%d", &afterHeight, &afterPos)
+		if err != nil {
+			return nil, "", errors.Sub(ErrBadCursor, err)
+		}
+	}
+
+	const q = `
+		SELECT block_height, position, data FROM light_transactions
+		WHERE (block_height, position) > ($1, $2)
+		ORDER BY block_height, position
+		LIMIT $3
+	`
+	var txs []*Tx
+	err := pg.ForQueryRows(ctx, s.db, q, afterHeight, afterPos, limit, func(height uint64, pos int, data []byte) error {
+		tx := new(legacy.Tx)
+		err := tx.UnmarshalText([]byte(hex.EncodeToString(data)))
+		if err != nil {
+			return errors.Wrap(err, "decoding transaction")
+		}
+		txs = append(txs, &Tx{ID: tx.ID, BlockHeight: height, Position: pos, Tx: tx})
+		afterHeight, afterPos = height, pos
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return txs, fmt.Sprintf("%d:%d", afterHeight, afterPos), nil
+}
+
+func latestHeader(ctx context.Context, db pg.DB) (*legacy.BlockHeader, error) {
+	const q = `SELECT header FROM light_block_headers ORDER BY height DESC LIMIT 1`
+	var h legacy.BlockHeader
+	err := db.QueryRowContext(ctx, q).Scan(&h)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "select query")
+	}
+	return &h, nil
+}
+
+// saveHeaders stores verified headers and their transactions.
+// Each block's transactions are stored before its header, so the
+// presence of a header implies the presence of its transactions.
+func saveHeaders(ctx context.Context, db pg.DB, headers []*Header) error {
+	const (
+		txq = `
+			INSERT INTO light_transactions (block_height, position, tx_hash, data)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (block_height, position) DO NOTHING
+		`
+		headerq = `
+			INSERT INTO light_block_headers (height, block_hash, header)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (height) DO NOTHING
+		`
+	)
+	for _, h := range headers {
+		for _, ptx := range h.Transactions {
+			var buf bytes.Buffer
+			_, err := ptx.Tx.WriteTo(&buf)
+			if err != nil {
+				return errors.Wrap(err, "serializing transaction")
+			}
+			_, err = db.ExecContext(ctx, txq, h.Header.Height, ptx.Position, ptx.Tx.ID, buf.Bytes())
+			if err != nil {
+				return errors.Wrap(err, "insert transaction")
+			}
+		}
+		_, err := db.ExecContext(ctx, headerq, h.Header.Height, h.Header.Hash(), h.Header)
+		if err != nil {
+			return errors.Wrap(err, "insert header")
+		}
+	}
+	return nil
+}
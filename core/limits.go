@@ -0,0 +1,307 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chain/core/config"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/authn"
+	"chain/net/http/authz"
+	"chain/net/http/limit"
+)
+
+// errQuotaExceeded is returned when a client has used up a daily
+// request quota.
+var errQuotaExceeded = errors.New("daily request quota exceeded")
+
+// Route classes for request limits. A request limit may also name
+// a single route, or "*" for all routes.
+const (
+	// routeClassQuery is the routes that read the Core's data,
+	// such as /list-transactions. Some of them are expensive.
+	routeClassQuery = "query"
+
+	// routeClassTransact is the routes that build, sign, and
+	// submit transactions.
+	routeClassTransact = "transact"
+)
+
+var transactRoutes = map[string]bool{
+	"/build-transaction":              true,
+	"/submit-transaction":             true,
+	"/sign-transaction":               true,
+	"/create-signing-session":         true,
+	"/add-signing-session-signatures": true,
+	"/mockhsm/sign-transaction":       true,
+	"/keystore/sign-transaction":      true,
+}
+
+// routeMatches reports whether the route path is in class,
+// a route class, a route, or "*".
+func routeMatches(class, path string) bool {
+	switch class {
+	case "*":
+		return true
+	case routeClassQuery:
+		return strings.HasPrefix(path, "/list-") || strings.HasPrefix(path, "/get-")
+	case routeClassTransact:
+		return transactRoutes[path]
+	}
+	return class == path
+}
+
+// cleanRequestLimitTuple validates and canonicalizes a
+// request_limit tuple: (policy, route class, requests per second,
+// requests per day). The policy may be "*" for all policies. A
+// limit of 0 means no limit.
+func cleanRequestLimitTuple(tup []string) error {
+	if tup[0] != "*" && !containsString(Policies, tup[0]) {
+		return errors.WithDetailf(config.ErrConfigOp, "Unknown policy %q.", tup[0])
+	}
+	switch class := tup[1]; {
+	case class == "*", class == routeClassQuery, class == routeClassTransact:
+	case policyByRoute[class] != nil:
+	default:
+		return errors.WithDetailf(config.ErrConfigOp, "Route class must be *, %s, %s, or a route.", routeClassQuery, routeClassTransact)
+	}
+	var sum int
+	for i, what := range []string{"Requests per second", "Requests per day"} {
+		n, err := strconv.Atoi(tup[2+i])
+		if err != nil || n < 0 {
+			return errors.WithDetailf(config.ErrConfigOp, "%s must be a non-negative integer.", what)
+		}
+		tup[2+i] = strconv.Itoa(n)
+		sum += n
+	}
+	if sum == 0 {
+		return errors.WithDetailf(config.ErrConfigOp, "A request limit needs a rate, a daily quota, or both.")
+	}
+	return nil
+}
+
+func containsString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// requestLimitRule is a configured request limit.
+type requestLimitRule struct {
+	key           string // the rule's tuple, identifying its quota counts
+	policy, class string
+	rate          *limit.BucketLimiter // nil if no rate limit
+	quota         int                  // requests per day; 0 if no quota
+}
+
+// requestLimiter applies the request limits configured in the
+// request_limit option. Limits apply to each client separately:
+// to each access token, client certificate subject, JWT subject,
+// or, for other requests, remote address. A request is subject to
+// every limit whose policy is one of the policies of the grants
+// allowing it, and a request refused by any of them counts against
+// none of them.
+//
+// Daily quotas are counted in Postgres, so they are shared by every
+// process in a cluster and survive restarts. Rate limits are kept
+// in memory by each process: a cluster of n processes allows up to
+// n times the configured rate.
+type requestLimiter struct {
+	db     pg.DB
+	tuples func() [][]string
+
+	mu       sync.Mutex
+	rules    map[string]*requestLimitRule // by tuple
+	pruneDay time.Time                    // when old quota counts were last deleted
+}
+
+func newRequestLimiter(db pg.DB, tuples func() [][]string) *requestLimiter {
+	return &requestLimiter{
+		db:     db,
+		tuples: tuples,
+		rules:  make(map[string]*requestLimitRule),
+	}
+}
+
+// matching returns the rules that apply to req.
+func (l *requestLimiter) matching(req *http.Request) []*requestLimitRule {
+	policies := authz.Policies(req.Context())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var rules []*requestLimitRule
+	for _, tup := range l.tuples() {
+		if len(tup) != 4 || (tup[0] != "*" && !containsString(policies, tup[0])) || !routeMatches(tup[1], req.URL.Path) {
+			continue
+		}
+		// Keep the state of each rule for as long as it's
+		// configured. Changing a rule's limits starts it over.
+		key := strings.Join(tup, " ")
+		r, ok := l.rules[key]
+		if !ok {
+			r = &requestLimitRule{key: key, policy: tup[0], class: tup[1]}
+			perSecond, _ := strconv.Atoi(tup[2])
+			if perSecond > 0 {
+				r.rate = limit.NewBucketLimiter(perSecond, 2*perSecond)
+			}
+			r.quota, _ = strconv.Atoi(tup[3])
+			l.rules[key] = r
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func (l *requestLimiter) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rules := l.matching(req)
+		if len(rules) == 0 {
+			next.ServeHTTP(w, req)
+			return
+		}
+		ctx := req.Context()
+		id := clientID(req)
+
+		// Count the request against every rule, undoing the counts
+		// if any rule refuses it.
+		var undo []func()
+		refuse := func(err error) {
+			for _, f := range undo {
+				f()
+			}
+			errorFormatter.Write(ctx, w, err)
+		}
+
+		for _, r := range rules {
+			if r.rate == nil {
+				continue
+			}
+			ok, wait, cancel := r.rate.Reserve(id)
+			if !ok {
+				limit.SetRetryAfter(w, wait)
+				refuse(errors.WithDetailf(errRateLimited, "rate limit for policy %s, route class %s", r.policy, r.class))
+				return
+			}
+			undo = append(undo, cancel)
+		}
+
+		// Report the quota with the fewest requests left.
+		var (
+			now   = time.Now().UTC()
+			day   = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+			reset = day.AddDate(0, 0, 1)
+			least = -1
+		)
+		for _, r := range rules {
+			if r.quota == 0 {
+				continue
+			}
+			ok, remaining, err := l.takeQuota(ctx, r, id, day)
+			if err != nil {
+				refuse(err)
+				return
+			}
+			if least == -1 || remaining < least {
+				least = remaining
+				h := w.Header()
+				h.Set("Chain-Quota-Limit", strconv.Itoa(r.quota))
+				h.Set("Chain-Quota-Remaining", strconv.Itoa(remaining))
+				h.Set("Chain-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+			}
+			if !ok {
+				limit.SetRetryAfter(w, reset.Sub(now))
+				refuse(errors.WithDetailf(errQuotaExceeded, "daily quota for policy %s, route class %s", r.policy, r.class))
+				return
+			}
+			r := r
+			undo = append(undo, func() { l.returnQuota(ctx, r, id, day) })
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// takeQuota counts a request by client against r's daily quota for
+// day, if the quota allows it. It returns whether the request was
+// counted and how many more requests the quota allows that day.
+func (l *requestLimiter) takeQuota(ctx context.Context, r *requestLimitRule, client string, day time.Time) (ok bool, remaining int, err error) {
+	l.pruneQuotas(ctx, day)
+
+	const q = `
+		INSERT INTO request_quota_counts (rule, client, day, count) VALUES ($1, $2, $3, 1)
+		ON CONFLICT (rule, client, day) DO UPDATE SET count = request_quota_counts.count + 1
+			WHERE request_quota_counts.count < $4
+		RETURNING count
+	`
+	var n int
+	err = l.db.QueryRowContext(ctx, q, r.key, client, day.Format("2006-01-02"), r.quota).Scan(&n)
+	if err == sql.ErrNoRows {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, errors.Wrap(err, "counting request against daily quota")
+	}
+	return true, r.quota - n, nil
+}
+
+// returnQuota undoes a takeQuota for a request that was refused
+// by another rule. Failure is logged; it only makes the quota
+// stricter.
+func (l *requestLimiter) returnQuota(ctx context.Context, r *requestLimitRule, client string, day time.Time) {
+	const q = `
+		UPDATE request_quota_counts SET count = count - 1
+		WHERE rule = $1 AND client = $2 AND day = $3 AND count > 0
+	`
+	// The request may already have been canceled.
+	_, err := l.db.ExecContext(context.Background(), q, r.key, client, day.Format("2006-01-02"))
+	if err != nil {
+		log.Printkv(ctx, log.KeyError, err, "at", "returning request to daily quota")
+	}
+}
+
+// pruneQuotas deletes the quota counts of days before day, the
+// first time it sees each day.
+func (l *requestLimiter) pruneQuotas(ctx context.Context, day time.Time) {
+	l.mu.Lock()
+	if !day.After(l.pruneDay) {
+		l.mu.Unlock()
+		return
+	}
+	l.pruneDay = day
+	l.mu.Unlock()
+
+	const q = `DELETE FROM request_quota_counts WHERE day < $1`
+	_, err := l.db.ExecContext(ctx, q, day.Format("2006-01-02"))
+	if err != nil {
+		log.Printkv(ctx, log.KeyError, err, "at", "deleting old request quota counts")
+	}
+}
+
+// clientID identifies the client making req, for request limits.
+func clientID(req *http.Request) string {
+	ctx := req.Context()
+	if token := authn.Token(ctx); token != "" {
+		return "token:" + token
+	}
+	if certs := authn.X509Certs(ctx); len(certs) > 0 {
+		return "cert:" + formatSubject(certs[0].Subject)
+	}
+	if claims := authn.JWT(ctx); claims != nil {
+		return "jwt:" + claims.Subject
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "addr:" + host
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chain/core/config"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/net/http/authz"
+)
+
+func TestCleanRequestLimitTuple(t *testing.T) {
+	cases := []struct {
+		tup     []string
+		want    []string
+		wantErr error
+	}{
+		{[]string{"client-readonly", "query", "10", "0100"}, []string{"client-readonly", "query", "10", "100"}, nil},
+		{[]string{"*", "/list-transactions", "0", "5"}, []string{"*", "/list-transactions", "0", "5"}, nil},
+		{[]string{"client-readwrite", "transact", "3", "0"}, []string{"client-readwrite", "transact", "3", "0"}, nil},
+		{[]string{"nope", "query", "1", "1"}, nil, config.ErrConfigOp},
+		{[]string{"*", "/not-a-route", "1", "1"}, nil, config.ErrConfigOp},
+		{[]string{"*", "*", "-1", "1"}, nil, config.ErrConfigOp},
+		{[]string{"*", "*", "1.5", "1"}, nil, config.ErrConfigOp},
+		{[]string{"*", "*", "0", "0"}, nil, config.ErrConfigOp},
+	}
+	for _, c := range cases {
+		tup := append([]string(nil), c.tup...)
+		err := cleanRequestLimitTuple(tup)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("clean(%v) error = %v, want %v", c.tup, err, c.wantErr)
+			continue
+		}
+		if err == nil && !equalStrings(tup, c.want) {
+			t.Errorf("clean(%v) = %v, want %v", c.tup, tup, c.want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRequestLimiter(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	tuples := [][]string{
+		{"client-readonly", "query", "0", "3"},
+		{"client-readonly", "/list-transactions", "1", "0"},
+		{"client-readwrite", "transact", "0", "100"},
+		{"client-readwrite", "/submit-transaction", "0", "1"},
+	}
+	limiter := newRequestLimiter(db, func() [][]string { return tuples })
+	h := limiter.handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	// Every request is from the same remote address, with a grant
+	// for the given policy.
+	do := func(policy, path string) *httptest.ResponseRecorder {
+		authorizer := authz.NewAuthorizer(
+			staticLoader{{GuardType: "any", Policy: policy}},
+			map[string][]string{"/": {policy}},
+		)
+		req := httptest.NewRequest("POST", path, nil)
+		req.RequestURI = path
+		req, err := authorizer.Authorize(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, want := range []string{"2", "1"} {
+		rec := do("client-readonly", "/list-transactions")
+		if rec.Code != 200 || rec.Header().Get("Chain-Quota-Limit") != "3" || rec.Header().Get("Chain-Quota-Remaining") != want {
+			t.Fatalf("request %d: status %d, headers %v", i, rec.Code, rec.Header())
+		}
+	}
+
+	// The rate limit for /list-transactions, with a burst of
+	// twice the rate, applies too. Refused requests don't count
+	// against the quota.
+	rec := do("client-readonly", "/list-transactions")
+	if rec.Code != 429 || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("over rate: status %d, headers %v; want 429 with Retry-After", rec.Code, rec.Header())
+	}
+
+	rec = do("client-readonly", "/list-accounts")
+	if rec.Code != 200 || rec.Header().Get("Chain-Quota-Remaining") != "0" {
+		t.Fatalf("third request: status %d, headers %v", rec.Code, rec.Header())
+	}
+	rec = do("client-readonly", "/list-assets")
+	if rec.Code != 429 || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("over quota: status %d, headers %v; want 429 with Retry-After", rec.Code, rec.Header())
+	}
+
+	// Other policies have their own limits.
+	rec = do("client-readwrite", "/list-assets")
+	if rec.Code != 200 || rec.Header().Get("Chain-Quota-Limit") != "" {
+		t.Fatalf("readwrite query: status %d, headers %v", rec.Code, rec.Header())
+	}
+	rec = do("client-readwrite", "/build-transaction")
+	if rec.Code != 200 || rec.Header().Get("Chain-Quota-Remaining") != "99" {
+		t.Fatalf("readwrite build: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	// A request refused by one quota doesn't count against the
+	// others.
+	rec = do("client-readwrite", "/submit-transaction")
+	if rec.Code != 200 || rec.Header().Get("Chain-Quota-Remaining") != "0" {
+		t.Fatalf("readwrite submit: status %d, headers %v", rec.Code, rec.Header())
+	}
+	rec = do("client-readwrite", "/submit-transaction")
+	if rec.Code != 429 {
+		t.Fatalf("readwrite submit over quota: status %d, want 429", rec.Code)
+	}
+	rec = do("client-readwrite", "/build-transaction")
+	if rec.Code != 200 || rec.Header().Get("Chain-Quota-Remaining") != "97" {
+		t.Fatalf("readwrite build after refusal: status %d, headers %v", rec.Code, rec.Header())
+	}
+}
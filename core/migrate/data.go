@@ -81,4 +81,214 @@ var migrations = []migration{
 		ALTER TABLE ONLY core_id
 			ADD CONSTRAINT core_id_pkey PRIMARY KEY (singleton);
 	`},
+	{Name: `2017-07-05.0.core.light-client.sql`, SQL: `
+		CREATE TABLE light_block_headers (
+			height bigint NOT NULL,
+			block_hash bytea NOT NULL,
+			header bytea NOT NULL
+		);
+		ALTER TABLE ONLY light_block_headers
+			ADD CONSTRAINT light_block_headers_pkey PRIMARY KEY (height);
+		CREATE TABLE light_transactions (
+			block_height bigint NOT NULL,
+			"position" integer NOT NULL,
+			tx_hash bytea NOT NULL,
+			data bytea NOT NULL
+		);
+		ALTER TABLE ONLY light_transactions
+			ADD CONSTRAINT light_transactions_pkey PRIMARY KEY (block_height, "position");
+	`},
+	{Name: `2017-07-06.0.core.keystore.sql`, SQL: `
+		CREATE TABLE keystore (
+			singleton boolean DEFAULT true NOT NULL,
+			kdf text NOT NULL,
+			salt bytea NOT NULL,
+			scrypt_n integer NOT NULL,
+			scrypt_r integer NOT NULL,
+			scrypt_p integer NOT NULL,
+			sealed_key bytea NOT NULL,
+			CONSTRAINT keystore_singleton CHECK (singleton)
+		);
+		ALTER TABLE ONLY keystore
+			ADD CONSTRAINT keystore_pkey PRIMARY KEY (singleton);
+		CREATE SEQUENCE keystore_keys_sort_id_seq
+			START WITH 1
+			INCREMENT BY 1
+			NO MINVALUE
+			NO MAXVALUE
+			CACHE 1;
+		CREATE TABLE keystore_keys (
+			pub bytea NOT NULL,
+			sealed_prv bytea NOT NULL,
+			alias text,
+			key_type text NOT NULL,
+			sort_id bigint DEFAULT nextval('keystore_keys_sort_id_seq'::regclass) NOT NULL
+		);
+		ALTER TABLE ONLY keystore_keys
+			ADD CONSTRAINT keystore_keys_pkey PRIMARY KEY (pub);
+		ALTER TABLE ONLY keystore_keys
+			ADD CONSTRAINT keystore_keys_alias_key UNIQUE (alias);
+		ALTER TABLE ONLY keystore_keys
+			ADD CONSTRAINT keystore_keys_sort_id_key UNIQUE (sort_id);
+		CREATE SEQUENCE keystore_sign_events_id_seq
+			START WITH 1
+			INCREMENT BY 1
+			NO MINVALUE
+			NO MAXVALUE
+			CACHE 1;
+		CREATE TABLE keystore_sign_events (
+			id bigint DEFAULT nextval('keystore_sign_events_id_seq'::regclass) NOT NULL,
+			key_type text NOT NULL,
+			pub bytea NOT NULL,
+			path bytea[] NOT NULL,
+			message bytea NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		ALTER TABLE ONLY keystore_sign_events
+			ADD CONSTRAINT keystore_sign_events_pkey PRIMARY KEY (id);
+	`},
+	{Name: `2017-07-10.0.core.signing-sessions.sql`, SQL: `
+		CREATE TABLE signing_sessions (
+			id text DEFAULT next_chain_id('sess'::text) NOT NULL,
+			alias text,
+			template jsonb NOT NULL,
+			version bigint DEFAULT 0 NOT NULL,
+			expires_at timestamp with time zone NOT NULL,
+			tx_id bytea,
+			client_token text,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		ALTER TABLE ONLY signing_sessions
+			ADD CONSTRAINT signing_sessions_pkey PRIMARY KEY (id);
+		ALTER TABLE ONLY signing_sessions
+			ADD CONSTRAINT signing_sessions_alias_key UNIQUE (alias);
+		ALTER TABLE ONLY signing_sessions
+			ADD CONSTRAINT signing_sessions_client_token_key UNIQUE (client_token);
+	`},
+	{Name: `2017-07-11.0.core.signer-path-prefix.sql`, SQL: `
+		ALTER TABLE signers ADD COLUMN path_prefix bytea[];
+	`},
+	{Name: `2017-07-12.0.core.mockhsm-policies-and-sign-events.sql`, SQL: `
+		ALTER TABLE mockhsm ADD COLUMN policy jsonb;
+		CREATE SEQUENCE mockhsm_sign_events_id_seq
+			START WITH 1
+			INCREMENT BY 1
+			NO MINVALUE
+			NO MAXVALUE
+			CACHE 1;
+		CREATE TABLE mockhsm_sign_events (
+			id bigint DEFAULT nextval('mockhsm_sign_events_id_seq'::regclass) NOT NULL,
+			key_type text NOT NULL,
+			pub bytea NOT NULL,
+			key_alias text,
+			path bytea[] NOT NULL,
+			message bytea NOT NULL,
+			access_token text,
+			policy_violation text,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		ALTER TABLE ONLY mockhsm_sign_events
+			ADD CONSTRAINT mockhsm_sign_events_pkey PRIMARY KEY (id);
+		CREATE INDEX mockhsm_sign_events_key_alias_idx ON mockhsm_sign_events USING btree (key_alias, id);
+		CREATE FUNCTION reject_modification() RETURNS trigger
+			LANGUAGE plpgsql
+			AS $$
+		BEGIN
+			RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+		END;
+		$$;
+		CREATE TRIGGER mockhsm_sign_events_append_only BEFORE UPDATE OR DELETE ON mockhsm_sign_events
+			FOR EACH ROW EXECUTE PROCEDURE reject_modification();
+	`},
+	{Name: `2017-07-13.0.core.signer-hardened-xpubs.sql`, SQL: `
+		ALTER TABLE signers ADD COLUMN hardened_xpubs bytea[];
+	`},
+	{Name: `2017-07-14.0.core.asset-issuance-rules.sql`, SQL: `
+		ALTER TABLE assets ADD COLUMN issuance_rules jsonb;
+		ALTER TABLE annotated_assets ADD COLUMN issuance_rules jsonb;
+	`},
+	{Name: `2017-07-15.0.query.asset-supply.sql`, SQL: `
+		CREATE TABLE asset_supply (
+			asset_id bytea NOT NULL,
+			block_height bigint NOT NULL,
+			block_timestamp bigint NOT NULL,
+			issued bigint NOT NULL,
+			retired bigint NOT NULL,
+			total_issued bigint NOT NULL,
+			total_retired bigint NOT NULL
+		);
+		ALTER TABLE ONLY asset_supply
+			ADD CONSTRAINT asset_supply_pkey PRIMARY KEY (asset_id, block_height);
+		CREATE INDEX asset_supply_asset_id_block_timestamp_idx ON asset_supply USING btree (asset_id, block_timestamp);
+
+		INSERT INTO asset_supply
+			(asset_id, block_height, block_timestamp, issued, retired, total_issued, total_retired)
+		SELECT asset_id, block_height, qb.timestamp, issued, retired,
+			SUM(issued) OVER w, SUM(retired) OVER w
+		FROM (
+			SELECT asset_id, block_height, SUM(issued) AS issued, SUM(retired) AS retired
+			FROM (
+				SELECT i.asset_id, t.block_height, i.amount AS issued, 0 AS retired
+				FROM annotated_inputs i JOIN annotated_txs t ON t.tx_hash = i.tx_hash
+				WHERE i.type = 'issue'
+				UNION ALL
+				SELECT asset_id, block_height, 0, amount
+				FROM annotated_outputs
+				WHERE type = 'retire'
+			) AS changes
+			GROUP BY asset_id, block_height
+		) AS blocks
+		JOIN query_blocks qb ON qb.height = block_height
+		WINDOW w AS (PARTITION BY asset_id ORDER BY block_height);
+	`},
+	{Name: "2017-07-16.0.core.access-token-expiry.sql", SQL: `
+		ALTER TABLE access_tokens
+			ADD COLUMN expires_at timestamp with time zone,
+			ADD COLUMN previous_hashed_secret bytea,
+			ADD COLUMN previous_expires_at timestamp with time zone,
+			ADD COLUMN rotated_at timestamp with time zone,
+			ADD COLUMN last_used_at timestamp with time zone,
+			ADD COLUMN last_used_addr text;
+	`},
+	{Name: "2017-07-17.0.core.audit-events.sql", SQL: `
+		CREATE SEQUENCE audit_events_id_seq
+			START WITH 1
+			INCREMENT BY 1
+			NO MINVALUE
+			NO MAXVALUE
+			CACHE 1;
+		CREATE TABLE audit_events (
+			id bigint DEFAULT nextval('audit_events_id_seq'::regclass) NOT NULL,
+			route text NOT NULL,
+			request_id text NOT NULL,
+			access_token text,
+			cert_subject text,
+			jwt_subject text,
+			remote_addr text NOT NULL,
+			request_digest bytea NOT NULL,
+			outcome text NOT NULL,
+			status_code integer NOT NULL,
+			error_code text,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		ALTER TABLE ONLY audit_events
+			ADD CONSTRAINT audit_events_pkey PRIMARY KEY (id);
+		CREATE INDEX audit_events_created_at_idx ON audit_events USING btree (created_at);
+		CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE PROCEDURE reject_modification();
+	`},
+	{Name: "2017-07-18.0.core.request-quota-counts.sql", SQL: `
+		CREATE TABLE request_quota_counts (
+			rule text NOT NULL,
+			client text NOT NULL,
+			day date NOT NULL,
+			count integer NOT NULL
+		);
+		ALTER TABLE ONLY request_quota_counts
+			ADD CONSTRAINT request_quota_counts_pkey PRIMARY KEY (rule, client, day);
+	`},
+	{Name: "2017-07-19.0.core.snapshot-chunk-hashes.sql", SQL: `
+		ALTER TABLE snapshots ADD COLUMN chunk_hashes bytea;
+		ALTER TABLE ONLY snapshots ALTER COLUMN data SET STORAGE EXTERNAL;
+	`},
 }
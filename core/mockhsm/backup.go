@@ -0,0 +1,417 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/scrypt"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// backupVersion is the version of the Backup format produced by
+// ExportKeys.
+const backupVersion = 1
+
+// Parameters for scrypt, used to derive a backup's encryption key
+// from its passphrase.
+const (
+	backupScryptN  = 1 << 15
+	backupScryptR  = 8
+	backupScryptP  = 1
+	backupSaltSize = 16
+	backupKeySize  = 32
+)
+
+// maxBackupScryptMemory bounds the memory scrypt may use to decrypt
+// a backup, so that a crafted backup can't exhaust the Core's
+// memory.
+const maxBackupScryptMemory = 256 << 20
+
+var (
+	// ErrBadBackup is returned when a backup is malformed, was
+	// encrypted with a different passphrase, or has been modified.
+	ErrBadBackup = errors.New("invalid key backup")
+
+	ErrBadPassphrase = errors.New("invalid backup passphrase")
+)
+
+// Possible values of ImportedKey.Status.
+const (
+	ImportStatusImported    = "imported"
+	ImportStatusExists      = "exists"
+	ImportStatusWouldImport = "would_import"
+)
+
+// Backup holds every key in an HSM, encrypted under a key derived
+// from a passphrase with scrypt. The key types, aliases, and public
+// keys are stored in the clear, so a backup can be inspected without
+// its passphrase, but they are authenticated along with the sealed
+// private keys: a backup whose key list has been changed won't
+// decrypt.
+type Backup struct {
+	Version   int                `json:"version"`
+	CreatedAt time.Time          `json:"created_at"`
+	Keys      []*BackupKey       `json:"keys"`
+	Salt      chainjson.HexBytes `json:"salt"`
+	ScryptN   int                `json:"scrypt_n"`
+	ScryptR   int                `json:"scrypt_r"`
+	ScryptP   int                `json:"scrypt_p"`
+	Sealed    chainjson.HexBytes `json:"sealed_keys"`
+}
+
+// BackupKey describes one key in a Backup. It holds no secrets.
+type BackupKey struct {
+	KeyType string             `json:"key_type"`
+	Alias   *string            `json:"alias"`
+	Pub     chainjson.HexBytes `json:"pub"`
+}
+
+// backupSecret is the sealed part of a key in a Backup. The sealed
+// list has one backupSecret for each BackupKey, in the same order.
+type backupSecret struct {
+	Prv    chainjson.HexBytes `json:"prv"`
+	Policy *Policy            `json:"policy,omitempty"`
+}
+
+// ImportedKey reports what ImportKeys did with one key in a backup.
+type ImportedKey struct {
+	*BackupKey
+	Status string `json:"status"`
+}
+
+// ExportKeys returns a Backup of the keys in the HSM, encrypted
+// under a key derived from passphrase. Signing policies are included
+// with their keys. Block-signing keys are left out unless
+// includeBlockKeys is true.
+func (h *HSM) ExportKeys(ctx context.Context, passphrase []byte, includeBlockKeys bool) (*Backup, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.WithDetail(ErrBadPassphrase, "passphrase is empty")
+	}
+
+	var (
+		keys      []*BackupKey
+		secrets   []*backupSecret
+		policyErr error
+	)
+	const q = `
+		SELECT key_type, pub, prv, alias, policy FROM mockhsm
+		WHERE $1 OR key_type = 'chain_kd'
+		ORDER BY sort_id
+	`
+	err := pg.ForQueryRows(ctx, h.db, q, includeBlockKeys, func(keyType string, pub, prv []byte, alias sql.NullString, policy []byte) {
+		k := &BackupKey{KeyType: keyType, Pub: pub}
+		if alias.Valid {
+			k.Alias = &alias.String
+		}
+		keys = append(keys, k)
+		s := &backupSecret{Prv: prv}
+		var perr error
+		s.Policy, perr = decodePolicy(policy)
+		if perr != nil && policyErr == nil {
+			policyErr = perr
+		}
+		secrets = append(secrets, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if policyErr != nil {
+		return nil, policyErr
+	}
+	return sealBackup(keys, secrets, passphrase, time.Now())
+}
+
+// ImportKeys decrypts b with passphrase, checks every key in it, and
+// stores the keys the HSM doesn't already have. If dryRun is true,
+// nothing is stored. Keys are reported in the order of b.Keys.
+//
+// Keys that are already in the HSM are left alone, even if their
+// alias or policy differ from the backup. If a key's alias belongs
+// to a different key in the HSM, no keys are imported. Importing is
+// not atomic, but it is idempotent: if it fails partway, importing
+// the same backup again finishes the job.
+func (h *HSM) ImportKeys(ctx context.Context, b *Backup, passphrase []byte, dryRun bool) ([]*ImportedKey, error) {
+	secrets, err := openBackup(b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, s := range secrets {
+			zero(s.Prv)
+		}
+	}()
+
+	var (
+		pubs    [][]byte
+		aliases []string
+	)
+	for _, k := range b.Keys {
+		pubs = append(pubs, k.Pub)
+		if k.Alias != nil {
+			aliases = append(aliases, *k.Alias)
+		}
+	}
+	var (
+		existingPubs    = make(map[string]bool)
+		existingAliases = make(map[string][]byte)
+	)
+	const q = `SELECT pub, alias FROM mockhsm WHERE pub = ANY($1) OR alias = ANY($2)`
+	err = pg.ForQueryRows(ctx, h.db, q, pq.ByteaArray(pubs), pq.StringArray(aliases), func(pub []byte, alias sql.NullString) {
+		existingPubs[string(pub)] = true
+		if alias.Valid {
+			existingAliases[alias.String] = pub
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ImportedKey, 0, len(b.Keys))
+	for _, k := range b.Keys {
+		status := ImportStatusWouldImport
+		if existingPubs[string(k.Pub)] {
+			status = ImportStatusExists
+		} else if k.Alias != nil {
+			if pub, ok := existingAliases[*k.Alias]; ok {
+				return nil, errors.WithDetailf(ErrDuplicateKeyAlias, "alias %q belongs to key %x", *k.Alias, pub)
+			}
+		}
+		result = append(result, &ImportedKey{BackupKey: k, Status: status})
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	for i, r := range result {
+		if r.Status == ImportStatusExists {
+			continue
+		}
+		err = h.insertBackupKey(ctx, r.BackupKey, secrets[i])
+		if err != nil {
+			return nil, err
+		}
+		r.Status = ImportStatusImported
+	}
+	return result, nil
+}
+
+func (h *HSM) insertBackupKey(ctx context.Context, k *BackupKey, s *backupSecret) error {
+	var policy []byte
+	if s.Policy != nil {
+		var err error
+		policy, err = json.Marshal(s.Policy)
+		if err != nil {
+			return errors.Wrap(err, "marshaling policy")
+		}
+	}
+	var alias sql.NullString
+	if k.Alias != nil {
+		alias = sql.NullString{String: *k.Alias, Valid: true}
+	}
+	const q = `
+		INSERT INTO mockhsm (pub, prv, alias, key_type, policy)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (pub) DO NOTHING
+	`
+	_, err := h.db.ExecContext(ctx, q, []byte(k.Pub), []byte(s.Prv), alias, k.KeyType, policy)
+	if pg.IsUniqueViolation(err) {
+		return errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias.String)
+	}
+	return errors.Wrap(err, "storing imported key")
+}
+
+// sealBackup encrypts secrets, one for each key in keys, under a key
+// derived from passphrase.
+func sealBackup(keys []*BackupKey, secrets []*backupSecret, passphrase []byte, now time.Time) (*Backup, error) {
+	b := &Backup{
+		Version:   backupVersion,
+		CreatedAt: now.UTC().Truncate(time.Second),
+		Keys:      keys,
+		Salt:      make([]byte, backupSaltSize),
+		ScryptN:   backupScryptN,
+		ScryptR:   backupScryptR,
+		ScryptP:   backupScryptP,
+	}
+	if b.Keys == nil {
+		b.Keys = []*BackupKey{}
+	}
+	_, err := rand.Read(b.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading random bytes")
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling keys")
+	}
+	defer zero(plaintext)
+
+	aead, err := backupAEAD(b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading random bytes")
+	}
+	b.Sealed = aead.Seal(nonce, nonce, plaintext, backupAD(b))
+	return b, nil
+}
+
+// openBackup decrypts b and checks that each private key in it
+// corresponds to its public key.
+func openBackup(b *Backup, passphrase []byte) ([]*backupSecret, error) {
+	if b == nil {
+		return nil, errors.WithDetail(ErrBadBackup, "backup is missing")
+	}
+	if b.Version != backupVersion {
+		return nil, errors.WithDetailf(ErrBadBackup, "unsupported version %d", b.Version)
+	}
+	if b.ScryptN <= 1 || b.ScryptR <= 0 || b.ScryptP <= 0 {
+		return nil, errors.WithDetail(ErrBadBackup, "invalid scrypt parameters")
+	}
+	if 128*int64(b.ScryptN)*int64(b.ScryptR) > maxBackupScryptMemory || b.ScryptP > 16 {
+		return nil, errors.WithDetail(ErrBadBackup, "scrypt parameters too large")
+	}
+	aead, err := backupAEAD(b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Sealed) < aead.NonceSize() {
+		return nil, errors.WithDetail(ErrBadBackup, "sealed keys too short")
+	}
+	nonce, ciphertext := b.Sealed[:aead.NonceSize()], b.Sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, backupAD(b))
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadBackup, "wrong passphrase or corrupt backup")
+	}
+	defer zero(plaintext)
+
+	var secrets []*backupSecret
+	err = json.Unmarshal(plaintext, &secrets)
+	if err != nil {
+		return nil, errors.Sub(ErrBadBackup, err)
+	}
+	if len(secrets) != len(b.Keys) {
+		return nil, errors.WithDetailf(ErrBadBackup, "%d keys listed but %d sealed", len(b.Keys), len(secrets))
+	}
+
+	seen := make(map[string]bool)
+	for i, k := range b.Keys {
+		if seen[string(k.Pub)] {
+			return nil, errors.WithDetailf(ErrBadBackup, "key %x listed twice", []byte(k.Pub))
+		}
+		seen[string(k.Pub)] = true
+
+		prv := secrets[i].Prv
+		var pub []byte
+		switch k.KeyType {
+		case "chain_kd":
+			if len(prv) != len(chainkd.XPrv{}) {
+				return nil, errors.WithDetailf(ErrBadBackup, "key %x: wrong private key size", []byte(k.Pub))
+			}
+			var xprv chainkd.XPrv
+			copy(xprv[:], prv)
+			pub = xprv.XPub().Bytes()
+		case "ed25519":
+			if len(prv) != ed25519.PrivateKeySize {
+				return nil, errors.WithDetailf(ErrBadBackup, "key %x: wrong private key size", []byte(k.Pub))
+			}
+			if secrets[i].Policy != nil {
+				return nil, errors.WithDetailf(ErrBadBackup, "key %x: ed25519 keys can't have policies", []byte(k.Pub))
+			}
+			pub = ed25519.PrivateKey(prv).Public().(ed25519.PublicKey)
+		default:
+			return nil, errors.WithDetailf(ErrBadBackup, "unknown key type %q", k.KeyType)
+		}
+		if !bytes.Equal(pub, k.Pub) {
+			return nil, errors.WithDetailf(ErrBadBackup, "key %x: private key doesn't match public key", []byte(k.Pub))
+		}
+		if p := secrets[i].Policy; p != nil {
+			err = p.validate()
+			if err != nil {
+				return nil, errors.Sub(ErrBadBackup, err)
+			}
+		}
+	}
+	return secrets, nil
+}
+
+// backupAEAD returns the cipher that seals b's keys, keyed by
+// passphrase and b's scrypt parameters.
+func backupAEAD(b *Backup, passphrase []byte) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.WithDetail(ErrBadPassphrase, "passphrase is empty")
+	}
+	key, err := scrypt.Key(passphrase, b.Salt, b.ScryptN, b.ScryptR, b.ScryptP, backupKeySize)
+	if err != nil {
+		return nil, errors.Sub(ErrBadBackup, err)
+	}
+	defer zero(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// backupAD returns the additional data authenticated along with b's
+// sealed keys: a hash of everything else in b, so that none of it
+// can be changed without detection.
+func backupAD(b *Backup) []byte {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+
+	writeInt := func(n int64) {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], uint64(n))
+		h.Write(buf[:])
+	}
+	writeBytes := func(p []byte) {
+		writeInt(int64(len(p)))
+		h.Write(p)
+	}
+
+	writeInt(int64(b.Version))
+	writeInt(b.CreatedAt.Unix())
+	writeBytes(b.Salt)
+	writeInt(int64(b.ScryptN))
+	writeInt(int64(b.ScryptR))
+	writeInt(int64(b.ScryptP))
+	writeInt(int64(len(b.Keys)))
+	for _, k := range b.Keys {
+		writeBytes([]byte(k.KeyType))
+		if k.Alias == nil {
+			writeInt(-1)
+		} else {
+			writeBytes([]byte(*k.Alias))
+		}
+		writeBytes(k.Pub)
+	}
+
+	ad := []byte("chain mockhsm backup v1\x00")
+	var sum [32]byte
+	h.Read(sum[:])
+	return append(ad, sum[:]...)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
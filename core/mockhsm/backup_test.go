@@ -0,0 +1,219 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/testutil"
+)
+
+func TestExportImportKeys(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	hsm := New(db)
+
+	xpub, err := hsm.XCreate(ctx, "treasury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = hsm.SetPolicy(ctx, xpub.XPub, &Policy{NotAfter: &notAfter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := hsm.Create(ctx, "block_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passphrase := []byte("correct horse battery staple")
+	backup, err := hsm.ExportKeys(ctx, passphrase, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.Keys) != 1 || !bytes.Equal(backup.Keys[0].Pub, xpub.XPub.Bytes()) {
+		t.Fatalf("exported %d keys without block keys, want only %x", len(backup.Keys), xpub.XPub.Bytes())
+	}
+	backup, err = hsm.ExportKeys(ctx, passphrase, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.Keys) != 2 {
+		t.Fatalf("exported %d keys, want 2", len(backup.Keys))
+	}
+
+	// Round-trip the backup through JSON, as corectl does.
+	b, err := json.Marshal(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup = new(Backup)
+	err = json.Unmarshal(b, backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	hsm2 := New(db2)
+
+	_, err = hsm2.ImportKeys(ctx, backup, []byte("wrong"), false)
+	if errors.Root(err) != ErrBadBackup {
+		t.Errorf("import with wrong passphrase got error %v, want %v", err, ErrBadBackup)
+	}
+
+	got, err := hsm2.ImportKeys(ctx, backup, passphrase, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range got {
+		if k.Status != ImportStatusWouldImport {
+			t.Errorf("dry run status of %x = %q, want %q", []byte(k.Pub), k.Status, ImportStatusWouldImport)
+		}
+	}
+	xpubs, _, err := hsm2.ListKeys(ctx, nil, "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xpubs) != 0 {
+		t.Fatalf("dry run imported %d keys", len(xpubs))
+	}
+
+	got, err = hsm2.ImportKeys(ctx, backup, passphrase, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range got {
+		if k.Status != ImportStatusImported {
+			t.Errorf("status of %x = %q, want %q", []byte(k.Pub), k.Status, ImportStatusImported)
+		}
+	}
+
+	xpubs, _, err = hsm2.ListKeys(ctx, []string{"treasury"}, "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xpubs) != 1 {
+		t.Fatalf("found %d keys with alias treasury, want 1", len(xpubs))
+	}
+	if xpubs[0].XPub != xpub.XPub {
+		t.Errorf("imported xpub = %x, want %x", xpubs[0].XPub.Bytes(), xpub.XPub.Bytes())
+	}
+	if !testutil.DeepEqual(xpubs[0].Policy, &Policy{NotAfter: &notAfter}) {
+		t.Errorf("imported policy = %+v, want not_after %s", xpubs[0].Policy, notAfter)
+	}
+
+	msg := []byte("restored")
+	sig, err := hsm2.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.XPub.Verify(msg, sig) {
+		t.Error("signature by imported chain_kd key does not verify")
+	}
+	key, err := hsm2.loadEd25519Key(ctx, pub.Pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub.Pub, msg, ed25519.Sign(key.prv, msg)) {
+		t.Error("signature by imported ed25519 key does not verify")
+	}
+
+	// Importing again changes nothing.
+	got, err = hsm2.ImportKeys(ctx, backup, passphrase, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range got {
+		if k.Status != ImportStatusExists {
+			t.Errorf("second import status of %x = %q, want %q", []byte(k.Pub), k.Status, ImportStatusExists)
+		}
+	}
+}
+
+func TestImportKeysAliasConflict(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	hsm := New(db)
+	_, err := hsm.XCreate(ctx, "treasury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := hsm.ExportKeys(ctx, []byte("passphrase"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	hsm2 := New(db2)
+	_, err = hsm2.XCreate(ctx, "treasury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = hsm2.ImportKeys(ctx, backup, []byte("passphrase"), true)
+	if errors.Root(err) != ErrDuplicateKeyAlias {
+		t.Errorf("import with taken alias got error %v, want %v", err, ErrDuplicateKeyAlias)
+	}
+}
+
+func TestOpenBackupTampered(t *testing.T) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alias := "treasury"
+	passphrase := []byte("passphrase")
+
+	cases := []struct {
+		name   string
+		tamper func(*Backup)
+	}{
+		{"alias", func(b *Backup) { other := "other"; b.Keys[0].Alias = &other }},
+		{"key type", func(b *Backup) { b.Keys[0].KeyType = "ed25519" }},
+		{"created at", func(b *Backup) { b.CreatedAt = b.CreatedAt.Add(time.Second) }},
+		{"dropped key", func(b *Backup) { b.Keys = nil }},
+		{"sealed keys", func(b *Backup) { b.Sealed[len(b.Sealed)-1] ^= 1 }},
+		{"version", func(b *Backup) { b.Version = 2 }},
+		{"scrypt", func(b *Backup) { b.ScryptN = 1 << 30 }},
+	}
+	for _, c := range cases {
+		keys := []*BackupKey{{KeyType: "chain_kd", Alias: &alias, Pub: xpub.Bytes()}}
+		secrets := []*backupSecret{{Prv: xprv.Bytes()}}
+		b, err := sealBackup(keys, secrets, passphrase, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = openBackup(b, passphrase)
+		if err != nil {
+			t.Fatalf("%s: opening untampered backup: %v", c.name, err)
+		}
+
+		c.tamper(b)
+		_, err = openBackup(b, passphrase)
+		if errors.Root(err) != ErrBadBackup {
+			t.Errorf("%s: got error %v, want %v", c.name, err, ErrBadBackup)
+		}
+	}
+
+	// A backup sealed with a private key that doesn't match its
+	// public key is rejected too.
+	other, _, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []*BackupKey{{KeyType: "chain_kd", Pub: xpub.Bytes()}}
+	b, err := sealBackup(keys, []*backupSecret{{Prv: other.Bytes()}}, passphrase, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = openBackup(b, passphrase)
+	if errors.Root(err) != ErrBadBackup {
+		t.Errorf("mismatched key: got error %v, want %v", err, ErrBadBackup)
+	}
+}
@@ -0,0 +1,131 @@
+package mockhsm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/authn"
+)
+
+// SignEvent records one request to the HSM to sign a message,
+// whether or not the key's policy allowed it. Events are kept in an
+// append-only table: they are never updated or deleted, even when
+// their key is deleted.
+type SignEvent struct {
+	ID       string               `json:"id"`
+	KeyType  string               `json:"key_type"`
+	Pub      chainjson.HexBytes   `json:"pub"`
+	KeyAlias *string              `json:"key_alias"`
+	Path     []chainjson.HexBytes `json:"derivation_path"`
+
+	// MessageHash is the signed message. Core only asks the HSM to
+	// sign 32-byte hashes: of a signature program for transactions,
+	// and of the block header for blocks.
+	MessageHash chainjson.HexBytes `json:"message_hash"`
+
+	// AccessToken is the ID of the access token the request was
+	// authenticated with, if any.
+	AccessToken string    `json:"access_token,omitempty"`
+	Time        time.Time `json:"time"`
+
+	// PolicyViolation, if not empty, says why the key's policy
+	// forbade the signature. No signature was made.
+	PolicyViolation string `json:"policy_violation,omitempty"`
+}
+
+// recordSignEvent stores a sign event for the key with the given
+// type, pub, and alias. If violation is not nil, the event records
+// that the key's policy forbade the signature.
+func (h *HSM) recordSignEvent(ctx context.Context, keyType string, pub []byte, alias sql.NullString, path [][]byte, msg []byte, violation error) error {
+	if path == nil {
+		path = [][]byte{}
+	}
+	var reason sql.NullString
+	if violation != nil {
+		reason = sql.NullString{String: errors.Detail(violation), Valid: true}
+	}
+	token := authn.Token(ctx)
+	const q = `
+		INSERT INTO mockhsm_sign_events
+			(key_type, pub, key_alias, path, message, access_token, policy_violation)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := h.db.ExecContext(ctx, q, keyType, pub, alias, pq.ByteaArray(path), msg,
+		sql.NullString{String: token, Valid: token != ""}, reason)
+	return errors.Wrap(err, "recording sign event")
+}
+
+// ListSignEvents returns sign events, most recent first, starting
+// after the event with ID after. If aliases is not empty, only
+// events for keys with those aliases are returned.
+func (h *HSM) ListSignEvents(ctx context.Context, aliases []string, after string, limit int) ([]*SignEvent, string, error) {
+	if len(aliases) > listKeyMaxAliases {
+		return nil, "", errors.WithDetailf(ErrTooManyAliasesToList, "max: %d", listKeyMaxAliases)
+	}
+
+	var (
+		zafter int64
+		err    error
+	)
+	if after != "" {
+		zafter, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(ErrInvalidAfter, "value: %q", after)
+		}
+	}
+
+	var (
+		events []*SignEvent
+		params []interface{}
+	)
+	q := `
+		SELECT id, key_type, pub, key_alias, path, message,
+			access_token, created_at, policy_violation
+		FROM mockhsm_sign_events WHERE true
+	`
+	if len(aliases) > 0 {
+		params = append(params, pq.StringArray(aliases))
+		q += fmt.Sprintf(" AND key_alias = ANY($%d)", len(params))
+	}
+	if zafter != 0 {
+		params = append(params, zafter)
+		q += fmt.Sprintf(" AND id < $%d", len(params))
+	}
+	q += fmt.Sprintf(" ORDER BY id DESC LIMIT %d", limit)
+
+	consumeRow := func(id int64, keyType string, pub []byte, alias sql.NullString, path pq.ByteaArray, msg []byte, token sql.NullString, ts time.Time, violation sql.NullString) {
+		ev := &SignEvent{
+			ID:              strconv.FormatInt(id, 10),
+			KeyType:         keyType,
+			Pub:             pub,
+			MessageHash:     msg,
+			AccessToken:     token.String,
+			Time:            ts,
+			PolicyViolation: violation.String,
+			Path:            make([]chainjson.HexBytes, 0, len(path)),
+		}
+		if alias.Valid {
+			ev.KeyAlias = &alias.String
+		}
+		for _, p := range path {
+			ev.Path = append(ev.Path, p)
+		}
+		events = append(events, ev)
+		zafter = id
+	}
+	params = append(params, consumeRow)
+
+	err = pg.ForQueryRows(ctx, h.db, q, params...)
+	if err != nil {
+		return nil, "", err
+	}
+	return events, strconv.FormatInt(zafter, 10), nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/lib/pq"
 
@@ -33,13 +34,27 @@ type HSM struct {
 	db pg.DB
 
 	cacheMu sync.Mutex
-	kdCache map[chainkd.XPub]chainkd.XPrv
-	edCache map[string]ed25519.PrivateKey // ed25519.PublicKeys must be turned into strings before being used as map keys
+	kdCache map[chainkd.XPub]*kdKey
+	edCache map[string]*edKey // ed25519.PublicKeys must be turned into strings before being used as map keys
+}
+
+// kdKey holds the immutable parts of a chain_kd key. Its policy can
+// be changed by any process sharing the database, so it isn't cached;
+// see loadPolicy.
+type kdKey struct {
+	xprv  chainkd.XPrv
+	alias sql.NullString
+}
+
+type edKey struct {
+	prv   ed25519.PrivateKey
+	alias sql.NullString
 }
 
 type XPub struct {
-	Alias *string      `json:"alias"`
-	XPub  chainkd.XPub `json:"xpub"`
+	Alias  *string      `json:"alias"`
+	XPub   chainkd.XPub `json:"xpub"`
+	Policy *Policy      `json:"policy,omitempty"`
 }
 
 type Pub struct {
@@ -50,8 +65,8 @@ type Pub struct {
 func New(db pg.DB) *HSM {
 	return &HSM{
 		db:      db,
-		kdCache: make(map[chainkd.XPub]chainkd.XPrv),
-		edCache: make(map[string]ed25519.PrivateKey),
+		kdCache: make(map[chainkd.XPub]*kdKey),
+		edCache: make(map[string]*edKey),
 	}
 }
 
@@ -159,7 +174,7 @@ func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limi
 		params []interface{}
 	)
 	q := `
-		SELECT pub, alias, sort_id FROM mockhsm
+		SELECT pub, alias, policy, sort_id FROM mockhsm
 		WHERE key_type = 'chain_kd'
 	`
 
@@ -175,13 +190,19 @@ func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limi
 
 	q += fmt.Sprintf(" ORDER BY sort_id DESC LIMIT %d", limit)
 
-	consumeRow := func(b []byte, alias sql.NullString, sortID int64) {
+	var policyErr error
+	consumeRow := func(b []byte, alias sql.NullString, policy []byte, sortID int64) {
 		var hdxpub chainkd.XPub
 		copy(hdxpub[:], b)
 		xpub := &XPub{XPub: hdxpub}
 		if alias.Valid {
 			xpub.Alias = &alias.String
 		}
+		var perr error
+		xpub.Policy, perr = decodePolicy(policy)
+		if perr != nil && policyErr == nil {
+			policyErr = perr
+		}
 		xpubs = append(xpubs, xpub)
 		zafter = sortID
 	}
@@ -191,41 +212,114 @@ func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limi
 	if err != nil {
 		return nil, "", err
 	}
+	if policyErr != nil {
+		return nil, "", policyErr
+	}
 
 	return xpubs, strconv.FormatInt(zafter, 10), nil
 }
 
-func (h *HSM) loadChainKDKey(ctx context.Context, xpub chainkd.XPub) (xprv chainkd.XPrv, err error) {
+func (h *HSM) loadChainKDKey(ctx context.Context, xpub chainkd.XPub) (*kdKey, error) {
 	h.cacheMu.Lock()
 	defer h.cacheMu.Unlock()
 
-	if xprv, ok := h.kdCache[xpub]; ok {
-		return xprv, nil
+	if key, ok := h.kdCache[xpub]; ok {
+		return key, nil
+	}
+
+	var (
+		key kdKey
+		b   []byte
+	)
+	const q = `SELECT prv, alias FROM mockhsm WHERE pub = $1 AND key_type='chain_kd'`
+	err := h.db.QueryRowContext(ctx, q, xpub.Bytes()).Scan(&b, &key.alias)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, err
 	}
+	copy(key.xprv[:], b)
+	h.kdCache[xpub] = &key
+	return &key, nil
+}
 
+// loadPolicy reads the current signing policy of the chain_kd key
+// xpub from the database. It is read on every signature, so a policy
+// set through any Core in a cluster applies at once everywhere. If
+// the key has been deleted, it returns ErrNoKey and drops the key
+// from the cache.
+func (h *HSM) loadPolicy(ctx context.Context, xpub chainkd.XPub) (*Policy, error) {
 	var b []byte
-	err = h.db.QueryRowContext(ctx, "SELECT prv FROM mockhsm WHERE pub = $1 AND key_type='chain_kd'", xpub.Bytes()).Scan(&b)
+	const q = `SELECT policy FROM mockhsm WHERE pub = $1 AND key_type='chain_kd'`
+	err := h.db.QueryRowContext(ctx, q, xpub.Bytes()).Scan(&b)
 	if err == sql.ErrNoRows {
-		return xprv, ErrNoKey
+		h.cacheMu.Lock()
+		delete(h.kdCache, xpub)
+		h.cacheMu.Unlock()
+		return nil, ErrNoKey
 	}
 	if err != nil {
-		return xprv, err
+		return nil, err
 	}
-	copy(xprv[:], b)
-	h.kdCache[xpub] = xprv
-	return xprv, nil
+	return decodePolicy(b)
 }
 
 // XSign looks up the xprv given the xpub, optionally derives a new
 // xprv with the given path (but does not store the new xprv), and
-// signs the given msg.
+// signs the given msg. The key's policy must allow it. Every
+// request, including those the policy forbids, is recorded as a
+// SignEvent.
 func (h *HSM) XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg []byte) ([]byte, error) {
-	xprv, err := h.loadChainKDKey(ctx, xpub)
+	return h.xsign(ctx, xpub, path, 0, msg, nil, false)
+}
+
+// XSignTx is like XSign, for a msg that is the hash of a signature
+// predicate for a transaction. The first hardened elements of path
+// are derived with hardened derivation.
+//
+// If msg is the hash of a predicate derived from tx (see
+// txbuilder.InferredSigHashes), the amounts in tx are checked
+// against the key's policy. Otherwise the caller must pass a nil tx:
+// msg may then commit to any transaction, so a key with a signing
+// policy refuses to sign it.
+func (h *HSM) XSignTx(ctx context.Context, xpub chainkd.XPub, path [][]byte, hardened int, msg []byte, tx *legacy.Tx) ([]byte, error) {
+	return h.xsign(ctx, xpub, path, hardened, msg, tx, true)
+}
+
+// XDeriveHardened returns the xpub derived from xpub along path
+// with hardened derivation, which can't be done without the xprv.
+func (h *HSM) XDeriveHardened(ctx context.Context, xpub chainkd.XPub, path [][]byte) (chainkd.XPub, error) {
+	key, err := h.loadChainKDKey(ctx, xpub)
+	if err != nil {
+		return chainkd.XPub{}, err
+	}
+	return key.xprv.DeriveHardened(path, len(path)).XPub(), nil
+}
+
+func (h *HSM) xsign(ctx context.Context, xpub chainkd.XPub, path [][]byte, hardened int, msg []byte, tx *legacy.Tx, forTx bool) ([]byte, error) {
+	key, err := h.loadChainKDKey(ctx, xpub)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := h.loadPolicy(ctx, xpub)
 	if err != nil {
 		return nil, err
 	}
+	violation := policy.check(time.Now(), path, tx)
+	if violation == nil && policy != nil && forTx && tx == nil {
+		violation = errors.WithDetail(ErrPolicyViolation, "key has a signing policy and may only sign predicates derived from the transaction")
+	}
+	err = h.recordSignEvent(ctx, "chain_kd", xpub.Bytes(), key.alias, path, msg, violation)
+	if err != nil {
+		return nil, err
+	}
+	if violation != nil {
+		return nil, violation
+	}
+	xprv := key.xprv
 	if len(path) > 0 {
-		xprv = xprv.Derive(path)
+		xprv = xprv.DeriveHardened(path, hardened)
 	}
 	return xprv.Sign(msg), nil
 }
@@ -238,38 +332,45 @@ func (h *HSM) DeleteChainKDKey(ctx context.Context, xpub chainkd.XPub) error {
 	return err
 }
 
-func (h *HSM) loadEd25519Key(ctx context.Context, pub ed25519.PublicKey) (prv ed25519.PrivateKey, err error) {
+func (h *HSM) loadEd25519Key(ctx context.Context, pub ed25519.PublicKey) (*edKey, error) {
 	h.cacheMu.Lock()
 	defer h.cacheMu.Unlock()
 
 	pubStr := string(pub)
 
-	if prv, ok := h.edCache[pubStr]; ok {
-		return prv, nil
+	if key, ok := h.edCache[pubStr]; ok {
+		return key, nil
 	}
 
-	err = h.db.QueryRowContext(ctx, "SELECT prv FROM mockhsm WHERE pub = $1 AND key_type='ed25519'", []byte(pub)).Scan(&prv)
+	var key edKey
+	const q = `SELECT prv, alias FROM mockhsm WHERE pub = $1 AND key_type='ed25519'`
+	err := h.db.QueryRowContext(ctx, q, []byte(pub)).Scan(&key.prv, &key.alias)
 	if err == sql.ErrNoRows {
-		return prv, ErrNoKey
+		return nil, ErrNoKey
 	}
 	if err != nil {
-		return prv, err
+		return nil, err
 	}
-	h.edCache[pubStr] = prv
-	return prv, nil
+	h.edCache[pubStr] = &key
+	return &key, nil
 }
 
-// Sign looks up the prv given the pub and signs the given msg.
+// Sign looks up the prv given the pub and signs the given msg. The
+// request is recorded as a SignEvent.
 func (h *HSM) Sign(ctx context.Context, pub ed25519.PublicKey, bh *legacy.BlockHeader) ([]byte, error) {
-	prv, err := h.loadEd25519Key(ctx, pub)
+	key, err := h.loadEd25519Key(ctx, pub)
 	if err != nil {
 		return nil, err
 	}
 
 	// ed25519.Sign will panic if prv is the wrong size. Protect against that.
-	if len(prv) != ed25519.PrivateKeySize {
+	if len(key.prv) != ed25519.PrivateKeySize {
 		return nil, ErrInvalidKeySize
 	}
 	msg := bh.Hash()
-	return ed25519.Sign(prv, msg.Bytes()), nil
+	err = h.recordSignEvent(ctx, "ed25519", pub, key.alias, nil, msg.Bytes(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(key.prv, msg.Bytes()), nil
 }
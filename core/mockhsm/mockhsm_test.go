@@ -3,12 +3,15 @@ package mockhsm
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 
 	"chain/crypto/ed25519"
 	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
 	"chain/errors"
+	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/testutil"
 )
@@ -217,3 +220,150 @@ func BenchmarkSign(b *testing.B) {
 		}
 	}
 }
+
+func TestSignPolicy(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	hsm := New(db)
+	xpub, err := hsm.XCreate(ctx, "limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another Core sharing the database, with the key already cached.
+	other := New(db)
+	msg := []byte("sighash")
+	_, err = other.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	assetID := bc.AssetID{V0: 1}
+	tx := legacy.NewTx(legacy.TxData{
+		Version: 1,
+		Inputs: []*legacy.TxInput{
+			legacy.NewSpendInput(nil, bc.Hash{}, assetID, 60, 0, nil, bc.Hash{}, nil),
+			legacy.NewSpendInput(nil, bc.Hash{V0: 1}, assetID, 50, 0, nil, bc.Hash{}, nil),
+		},
+	})
+	past := time.Now().Add(-time.Hour)
+	err = hsm.SetPolicy(ctx, xpub.XPub, &Policy{
+		PathPrefixes: [][]chainjson.HexBytes{{{1}}},
+		MaxAmounts:   map[bc.AssetID]uint64{assetID: 100},
+		NotBefore:    &past,
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	xpubs, _, err := hsm.ListKeys(ctx, []string{"limited"}, "", 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(xpubs) != 1 || xpubs[0].Policy == nil || xpubs[0].Policy.MaxAmounts[assetID] != 100 {
+		t.Fatalf("ListKeys got %+v, want key with policy", xpubs)
+	}
+
+	cases := []struct {
+		path [][]byte
+		tx   *legacy.Tx
+		ok   bool
+	}{
+		{path: [][]byte{{1}, {2}}, tx: legacy.NewTx(legacy.TxData{Version: 1}), ok: true},
+		{path: [][]byte{{2}, {2}}, tx: legacy.NewTx(legacy.TxData{Version: 1}), ok: false},
+		{path: [][]byte{{1}, {2}}, tx: tx, ok: false},
+		{path: [][]byte{{1}, {2}}, tx: nil, ok: false},
+	}
+	for i, c := range cases {
+		sig, err := hsm.XSignTx(ctx, xpub.XPub, c.path, 0, msg, c.tx)
+		if c.ok {
+			if err != nil {
+				testutil.FatalErr(t, err)
+			}
+			if !xpub.XPub.Derive(c.path).Verify(msg, sig) {
+				t.Errorf("case %d: signature does not verify", i)
+			}
+		} else if errors.Root(err) != ErrPolicyViolation {
+			t.Errorf("case %d: got error %v, want %v", i, err, ErrPolicyViolation)
+		}
+	}
+
+	future := time.Now().Add(time.Hour)
+	err = hsm.SetPolicy(ctx, xpub.XPub, &Policy{NotBefore: &future})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = hsm.XSign(ctx, xpub.XPub, nil, msg)
+	if errors.Root(err) != ErrPolicyViolation {
+		t.Errorf("XSign before policy window got error %v, want %v", err, ErrPolicyViolation)
+	}
+	_, err = other.XSign(ctx, xpub.XPub, nil, msg)
+	if errors.Root(err) != ErrPolicyViolation {
+		t.Errorf("XSign on another Core got error %v, want %v", err, ErrPolicyViolation)
+	}
+
+	err = hsm.SetPolicy(ctx, xpub.XPub, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = hsm.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+}
+
+func TestListSignEvents(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	hsm := New(db)
+	xpub, err := hsm.XCreate(ctx, "audited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := hsm.XCreate(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := [][]byte{{1}}
+	for _, msg := range []string{"one", "two"} {
+		_, err = hsm.XSign(ctx, xpub.XPub, path, []byte(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = hsm.XSign(ctx, other.XPub, nil, []byte("three"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, after, err := hsm.ListSignEvents(ctx, nil, "", 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(events) != 2 || string(events[0].MessageHash) != "three" || string(events[1].MessageHash) != "two" {
+		t.Fatalf("ListSignEvents got %+v, want events for three and two", events)
+	}
+	events, _, err = hsm.ListSignEvents(ctx, nil, after, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(events) != 1 || string(events[0].MessageHash) != "one" {
+		t.Fatalf("ListSignEvents second page got %+v, want event for one", events)
+	}
+	if !testutil.DeepEqual(events[0].Path, []chainjson.HexBytes{{1}}) || *events[0].KeyAlias != "audited" {
+		t.Errorf("event = %+v, want path %x and alias audited", events[0], path)
+	}
+
+	events, _, err = hsm.ListSignEvents(ctx, []string{"audited"}, "", 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(events) != 2 {
+		t.Errorf("ListSignEvents for alias got %d events, want 2", len(events))
+	}
+
+	_, err = db.ExecContext(ctx, `DELETE FROM mockhsm_sign_events`)
+	if err == nil {
+		t.Error("deleting sign events succeeded, want error")
+	}
+}
@@ -0,0 +1,146 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+)
+
+var (
+	ErrPolicyViolation = errors.New("signing policy violation")
+	ErrInvalidPolicy   = errors.New("invalid signing policy")
+)
+
+// Policy restricts what a key may sign. The zero Policy allows
+// anything.
+type Policy struct {
+	// PathPrefixes, if not empty, limits signing to derivation paths
+	// that begin with one of the listed prefixes.
+	PathPrefixes [][]chainjson.HexBytes `json:"path_prefixes,omitempty"`
+
+	// MaxAmounts limits, for each listed asset, the total amount a
+	// transaction signed by the key may spend or issue in its
+	// inputs. A key with amount limits can only sign transactions
+	// through XSignTx, which is given the transaction.
+	//
+	// Through XSignTx, a key with any policy only signs predicates
+	// derived from the transaction, so that a client can't pass
+	// off a predicate committing to a different transaction.
+	MaxAmounts map[bc.AssetID]uint64 `json:"max_amounts,omitempty"`
+
+	// NotBefore and NotAfter, if set, bound the time window in
+	// which the key may sign.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+}
+
+func (p *Policy) validate() error {
+	if p.NotBefore != nil && p.NotAfter != nil && p.NotAfter.Before(*p.NotBefore) {
+		return errors.WithDetail(ErrInvalidPolicy, "not_after is before not_before")
+	}
+	return nil
+}
+
+// check returns an ErrPolicyViolation if p forbids signing at time
+// now with the key derived along path. If tx is nil, the
+// transaction being signed is unknown, and any amount limits
+// forbid signing.
+func (p *Policy) check(now time.Time, path [][]byte, tx *legacy.Tx) error {
+	if p == nil {
+		return nil
+	}
+	if p.NotBefore != nil && now.Before(*p.NotBefore) {
+		return errors.WithDetailf(ErrPolicyViolation, "key may not sign before %s", p.NotBefore.Format(time.RFC3339))
+	}
+	if p.NotAfter != nil && now.After(*p.NotAfter) {
+		return errors.WithDetailf(ErrPolicyViolation, "key may not sign after %s", p.NotAfter.Format(time.RFC3339))
+	}
+	if len(p.PathPrefixes) > 0 && !p.allowsPath(path) {
+		return errors.WithDetail(ErrPolicyViolation, "derivation path not allowed for key")
+	}
+	if len(p.MaxAmounts) > 0 {
+		if tx == nil {
+			return errors.WithDetail(ErrPolicyViolation, "key has amount limits and may only sign transactions")
+		}
+		spent := make(map[bc.AssetID]uint64)
+		for _, in := range tx.Inputs {
+			assetID := in.AssetID()
+			sum := spent[assetID] + in.Amount()
+			if sum < spent[assetID] {
+				return errors.WithDetailf(ErrPolicyViolation, "amount of asset %x overflows", assetID.Bytes())
+			}
+			spent[assetID] = sum
+		}
+		for assetID, max := range p.MaxAmounts {
+			if spent[assetID] > max {
+				return errors.WithDetailf(ErrPolicyViolation, "amount %d of asset %x exceeds limit %d", spent[assetID], assetID.Bytes(), max)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Policy) allowsPath(path [][]byte) bool {
+	for _, prefix := range p.PathPrefixes {
+		if len(prefix) > len(path) {
+			continue
+		}
+		match := true
+		for i, elem := range prefix {
+			if !bytes.Equal(elem, path[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPolicy sets the signing policy of the chain_kd key xpub. A nil
+// policy removes any restrictions.
+func (h *HSM) SetPolicy(ctx context.Context, xpub chainkd.XPub, policy *Policy) error {
+	var b []byte
+	if policy != nil {
+		err := policy.validate()
+		if err != nil {
+			return err
+		}
+		b, err = json.Marshal(policy)
+		if err != nil {
+			return errors.Wrap(err, "marshaling policy")
+		}
+	}
+
+	const q = `UPDATE mockhsm SET policy=$1 WHERE pub=$2 AND key_type='chain_kd'`
+	res, err := h.db.ExecContext(ctx, q, b, xpub.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "setting key policy")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		return errors.WithDetailf(ErrNoKey, "xpub: %x", xpub.Bytes())
+	}
+	return nil
+}
+
+func decodePolicy(b []byte) (*Policy, error) {
+	if b == nil {
+		return nil, nil
+	}
+	p := new(Policy)
+	err := json.Unmarshal(b, p)
+	return p, errors.Wrap(err, "decoding key policy")
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/state"
+)
+
+// maxStateReplay limits how many blocks may be replayed on top of
+// a stored snapshot, or the last reconstructed state, to reconstruct
+// the state tree at a past height. Snapshots are stored about once
+// an hour, so states further than this from the one before them can
+// be reached only by asking for heights in increasing order.
+const maxStateReplay = 1000
+
+var errNoStateAtHeight = errors.New("state unavailable at height")
+
+type txProofRequest struct {
+	ID          bc.Hash `json:"id"`
+	BlockHeight uint64  `json:"block_height"`
+}
+
+type txProofResponse struct {
+	ID               bc.Hash       `json:"id"`
+	BlockHeight      uint64        `json:"block_height"`
+	BlockID          bc.Hash       `json:"block_id"`
+	TransactionsRoot bc.Hash       `json:"transactions_root"`
+	Position         int           `json:"position"`
+	Path             bc.MerklePath `json:"path"`
+}
+
+// getTransactionProof returns a merkle path proving that a
+// transaction is included in the transactions root of a block.
+// If no block height is provided, the block is found through the
+// transaction index.
+//
+// POST /get-transaction-proof
+func (a *API) getTransactionProof(ctx context.Context, req txProofRequest) (*txProofResponse, error) {
+	height := req.BlockHeight
+	if height == 0 {
+		const q = `SELECT block_height FROM annotated_txs WHERE tx_hash = $1`
+		err := a.db.QueryRowContext(ctx, q, req.ID.Bytes()).Scan(&height)
+		if err == sql.ErrNoRows {
+			return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction %x has not been indexed; provide block_height", req.ID.Bytes())
+		} else if err != nil {
+			return nil, errors.Wrap(err, "looking up transaction block")
+		}
+	}
+	if height > a.chain.Height() {
+		return nil, errors.WithDetailf(protocol.ErrTheDistantFuture, "block %d has not been committed", height)
+	}
+
+	b, err := a.chain.GetBlock(ctx, height)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting block")
+	}
+	txs := make([]*bc.Tx, 0, len(b.Transactions))
+	pos := -1
+	for i, tx := range b.Transactions {
+		txs = append(txs, tx.Tx)
+		if tx.ID == req.ID {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction %x is not in block %d", req.ID.Bytes(), height)
+	}
+
+	path, err := bc.TxMerklePath(txs, pos)
+	if err != nil {
+		return nil, errors.Wrap(err, "computing merkle path")
+	}
+	return &txProofResponse{
+		ID:               req.ID,
+		BlockHeight:      height,
+		BlockID:          b.Hash(),
+		TransactionsRoot: b.TransactionsMerkleRoot,
+		Position:         pos,
+		Path:             path,
+	}, nil
+}
+
+type outputProofRequest struct {
+	OutputID    bc.Hash `json:"output_id"`
+	BlockHeight uint64  `json:"block_height"`
+}
+
+type outputProofResponse struct {
+	BlockHeight uint64  `json:"block_height"`
+	BlockID     bc.Hash `json:"block_id"`
+	AssetsRoot  bc.Hash `json:"assets_root"`
+	*bc.StateProof
+}
+
+// getOutputProof returns a proof that an output is or is not
+// unspent, by proving its membership or non-membership in the state
+// tree as of a block. If no block height is provided, the latest
+// block is used.
+//
+// POST /get-output-proof
+func (a *API) getOutputProof(ctx context.Context, req outputProofRequest) (*outputProofResponse, error) {
+	b, snapshot, err := a.stateAtHeight(ctx, req.BlockHeight)
+	if err != nil {
+		return nil, err
+	}
+	return &outputProofResponse{
+		BlockHeight: b.Height,
+		BlockID:     b.Hash(),
+		AssetsRoot:  b.AssetsMerkleRoot,
+		StateProof:  snapshot.Tree.Prove(req.OutputID),
+	}, nil
+}
+
+// stateAtHeight returns the block at the provided height and the
+// state snapshot resulting from it. A height of 0 means the latest
+// block. Past states are reconstructed by replaying blocks on top of
+// the last reconstructed state or the nearest stored snapshot, one
+// at a time. Callers must not modify the returned snapshot.
+func (a *API) stateAtHeight(ctx context.Context, height uint64) (*legacy.Block, *state.Snapshot, error) {
+	b, snapshot := a.chain.State()
+	if b == nil {
+		return nil, nil, errors.WithDetail(errNoStateAtHeight, "the blockchain is empty")
+	}
+	if height == 0 || height == b.Height {
+		return b, snapshot, nil
+	}
+	if height > b.Height {
+		return nil, nil, errors.WithDetailf(protocol.ErrTheDistantFuture, "block %d has not been committed", height)
+	}
+
+	a.pastStateMu.Lock()
+	defer a.pastStateMu.Unlock()
+
+	var (
+		snapshotHeight uint64
+		err            error
+	)
+	if a.pastBlock != nil && a.pastBlock.Height <= height && height-a.pastBlock.Height <= maxStateReplay {
+		if a.pastBlock.Height == height {
+			return a.pastBlock, a.pastState, nil
+		}
+		b, snapshot, snapshotHeight = a.pastBlock, state.Copy(a.pastState), a.pastBlock.Height
+	} else {
+		snapshot, snapshotHeight, err = a.store.SnapshotBefore(ctx, height)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "getting snapshot")
+		}
+		if height-snapshotHeight > maxStateReplay {
+			return nil, nil, errors.WithDetailf(errNoStateAtHeight, "no snapshot is available close enough to block %d", height)
+		}
+	}
+	for h := snapshotHeight + 1; h <= height; h++ {
+		b, err = a.chain.GetBlock(ctx, h)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "getting block")
+		}
+		err = snapshot.ApplyBlock(legacy.MapBlock(b))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "applying block")
+		}
+	}
+	if snapshotHeight == height {
+		b, err = a.chain.GetBlock(ctx, height)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "getting block")
+		}
+	}
+	if b.AssetsMerkleRoot != snapshot.Tree.RootHash() {
+		return nil, nil, errors.Wrapf(errNoStateAtHeight, "reconstructed state doesn't match block %d", height)
+	}
+	a.pastBlock, a.pastState = b, snapshot
+	return b, snapshot, nil
+}
@@ -21,8 +21,13 @@ func (a *API) listAccounts(ctx context.Context, in requestQuery) (page, error) {
 	}
 	after := in.After
 
+	filt, err := scopedFilter(ctx, "accounts", in.Filter)
+	if err != nil {
+		return page{}, err
+	}
+
 	// Use the filter engine for querying account tags.
-	accounts, after, err := a.indexer.Accounts(ctx, in.Filter, in.FilterParams, after, limit)
+	accounts, after, err := a.indexer.Accounts(ctx, filt, in.FilterParams, after, limit)
 	if err != nil {
 		return page{}, errors.Wrap(err, "running acc query")
 	}
@@ -48,8 +53,13 @@ func (a *API) listAssets(ctx context.Context, in requestQuery) (page, error) {
 	}
 	after := in.After
 
+	filt, err := scopedFilter(ctx, "assets", in.Filter)
+	if err != nil {
+		return page{}, err
+	}
+
 	// Use the query engine for querying asset tags.
-	assets, after, err := a.indexer.Assets(ctx, in.Filter, in.FilterParams, after, limit)
+	assets, after, err := a.indexer.Assets(ctx, filt, in.FilterParams, after, limit)
 	if err != nil {
 		return page{}, errors.Wrap(err, "running asset query")
 	}
@@ -88,8 +98,13 @@ func (a *API) listBalances(ctx context.Context, in requestQuery) (result page, e
 		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
 	}
 
+	filt, err := scopedFilter(ctx, "outputs", in.Filter)
+	if err != nil {
+		return result, err
+	}
+
 	// TODO(jackson): paginate this endpoint.
-	balances, err := a.indexer.Balances(ctx, in.Filter, in.FilterParams, sumBy, timestampMS)
+	balances, err := a.indexer.Balances(ctx, filt, in.FilterParams, sumBy, timestampMS)
 	if err != nil {
 		return result, err
 	}
@@ -138,7 +153,12 @@ func (a *API) listTransactions(ctx context.Context, in requestQuery) (result pag
 		}
 	}
 
-	txns, nextAfter, err := a.indexer.Transactions(ctx, in.Filter, in.FilterParams, after, limit, in.AscLongPoll)
+	filt, err := scopedFilter(ctx, "transactions", in.Filter)
+	if err != nil {
+		return result, err
+	}
+
+	txns, nextAfter, err := a.indexer.Transactions(ctx, filt, in.FilterParams, after, limit, in.AscLongPoll)
 	if err != nil {
 		return result, errors.Wrap(err, "running tx query")
 	}
@@ -198,7 +218,11 @@ func (a *API) listUnspentOutputs(ctx context.Context, in requestQuery) (result p
 	} else if timestampMS > math.MaxInt64 {
 		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
 	}
-	outputs, nextAfter, err := a.indexer.Outputs(ctx, in.Filter, in.FilterParams, timestampMS, after, limit)
+	filt, err := scopedFilter(ctx, "outputs", in.Filter)
+	if err != nil {
+		return result, err
+	}
+	outputs, nextAfter, err := a.indexer.Outputs(ctx, filt, in.FilterParams, timestampMS, after, limit)
 	if err != nil {
 		return result, errors.Wrap(err, "querying outputs")
 	}
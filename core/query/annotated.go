@@ -77,6 +77,11 @@ type AccountKey struct {
 	RootXPub              chainkd.XPub         `json:"root_xpub"`
 	AccountXPub           chainkd.XPub         `json:"account_xpub"`
 	AccountDerivationPath []chainjson.HexBytes `json:"account_derivation_path"`
+
+	// AccountDerivationHardened is true if AccountXPub is derived
+	// from RootXPub with hardened derivation, and so can't be
+	// rederived from RootXPub alone.
+	AccountDerivationHardened bool `json:"account_derivation_hardened,omitempty"`
 }
 
 type AnnotatedAsset struct {
@@ -88,6 +93,7 @@ type AnnotatedAsset struct {
 	Definition      *json.RawMessage   `json:"definition"`
 	Tags            *json.RawMessage   `json:"tags"`
 	IsLocal         Bool               `json:"is_local"`
+	IssuanceRules   *json.RawMessage   `json:"issuance_rules,omitempty"`
 }
 
 type AssetKey struct {
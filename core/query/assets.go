@@ -3,6 +3,7 @@ package query
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -20,12 +21,16 @@ func (ind *Indexer) SaveAnnotatedAsset(ctx context.Context, asset *AnnotatedAsse
 
 	const q = `
 		INSERT INTO annotated_assets
-			(id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local)
-		VALUES($1, $2, $3, $4, $5, $6, $7::jsonb, $8::jsonb, $9)
+			(id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local, issuance_rules)
+		VALUES($1, $2, $3, $4, $5, $6, $7::jsonb, $8::jsonb, $9, $10::jsonb)
 		ON CONFLICT (id) DO UPDATE SET sort_id = $2, tags = $8::jsonb
 	`
+	var rules sql.NullString
+	if asset.IssuanceRules != nil {
+		rules = sql.NullString{String: string(*asset.IssuanceRules), Valid: true}
+	}
 	_, err = ind.db.ExecContext(ctx, q, asset.ID, sortID, asset.Alias, []byte(asset.IssuanceProgram),
-		keysJSON, asset.Quorum, string(*asset.Definition), string(*asset.Tags), bool(asset.IsLocal))
+		keysJSON, asset.Quorum, string(*asset.Definition), string(*asset.Tags), bool(asset.IsLocal), rules)
 	return errors.Wrap(err, "saving annotated asset")
 }
 
@@ -55,7 +60,7 @@ func (ind *Indexer) Assets(ctx context.Context, filt string, vals []interface{},
 		aa := new(AnnotatedAsset)
 
 		var sortID string
-		var keysJSON []byte
+		var keysJSON, rulesJSON []byte
 
 		err := rows.Scan(
 			&aa.ID,
@@ -67,6 +72,7 @@ func (ind *Indexer) Assets(ctx context.Context, filt string, vals []interface{},
 			&aa.Definition,
 			&aa.Tags,
 			&aa.IsLocal,
+			&rulesJSON,
 		)
 		if err != nil {
 			return nil, "", errors.Wrap(err, "scanning annotated asset row")
@@ -76,6 +82,11 @@ func (ind *Indexer) Assets(ctx context.Context, filt string, vals []interface{},
 			return nil, "", errors.Wrap(err, "unmarshaling asset keys json")
 		}
 
+		if len(rulesJSON) > 0 {
+			rules := json.RawMessage(rulesJSON)
+			aa.IssuanceRules = &rules
+		}
+
 		after = sortID
 		assets = append(assets, aa)
 	}
@@ -91,7 +102,7 @@ func constructAssetsQuery(expr string, vals []interface{}, after string, limit i
 	var buf bytes.Buffer
 
 	buf.WriteString("SELECT ")
-	buf.WriteString("id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local")
+	buf.WriteString("id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local, issuance_rules")
 	buf.WriteString(" FROM annotated_assets AS ast")
 	buf.WriteString(" WHERE ")
 
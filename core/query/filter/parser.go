@@ -52,6 +52,43 @@ func Parse(predicate string, tbl *SQLTable, vals []interface{}) (p Predicate, er
 	}, nil
 }
 
+// And returns a filter matching what all of filters match.
+// Each filter is parsed on its own and enclosed in parentheses,
+// so none of them can change how the others are grouped.
+// Empty filters are skipped.
+func And(filters ...string) (string, error) {
+	return join(binaryOps["AND"], filters)
+}
+
+// Or is like And, but returns a filter matching what
+// any of filters match.
+func Or(filters ...string) (string, error) {
+	return join(binaryOps["OR"], filters)
+}
+
+func join(op *binaryOp, filters []string) (string, error) {
+	var joined expr
+	for _, filt := range filters {
+		e, _, err := parse(filt)
+		if err != nil {
+			return "", errors.WithDetail(ErrBadFilter, err.Error())
+		}
+		if e == nil {
+			continue
+		}
+		e = parenExpr{inner: e}
+		if joined == nil {
+			joined = e
+		} else {
+			joined = binaryExpr{op: op, l: joined, r: e}
+		}
+	}
+	if joined == nil {
+		return "", nil
+	}
+	return joined.String(), nil
+}
+
 // Field is a type for simple expressions that simply access an attribute of
 // the queried object. They're used for GROUP BYs.
 type Field struct {
@@ -3,6 +3,7 @@ package filter
 import (
 	"testing"
 
+	"chain/errors"
 	"chain/testutil"
 )
 
@@ -154,3 +155,32 @@ func TestParseInvalid(t *testing.T) {
 		}
 	}
 }
+
+func TestAndOr(t *testing.T) {
+	got, err := And("a = $1 OR b = $2", "", "c = 'x'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(a = $1 OR b = $2) AND (c = 'x')"; got != want {
+		t.Errorf("And = %q want %q", got, want)
+	}
+
+	got, err = Or("a = 1", "b = 2 AND c = 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(a = 1) OR (b = 2 AND c = 3)"; got != want {
+		t.Errorf("Or = %q want %q", got, want)
+	}
+
+	got, err = And("", "")
+	if err != nil || got != "" {
+		t.Errorf("And of empty filters = %q, %v want empty", got, err)
+	}
+
+	// Unbalanced parentheses can't regroup the other filters.
+	_, err = And("alias = $1) OR (alias = 'victim'", "tags.team = 'payments'")
+	if errors.Root(err) != ErrBadFilter {
+		t.Errorf("And with unbalanced filter err = %v want %v", err, ErrBadFilter)
+	}
+}
@@ -75,7 +75,10 @@ func (ind *Indexer) IndexTransactions(ctx context.Context, b *legacy.Block) erro
 		return err
 	}
 	err = ind.insertAnnotatedInputs(ctx, b, txs)
-	return err
+	if err != nil {
+		return err
+	}
+	return ind.insertAssetSupply(ctx, b, txs)
 }
 
 func (ind *Indexer) insertBlock(ctx context.Context, b *legacy.Block) error {
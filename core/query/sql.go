@@ -2,6 +2,7 @@ package query
 
 import (
 	"chain/core/query/filter"
+	"chain/errors"
 )
 
 var (
@@ -91,3 +92,29 @@ var (
 		},
 	}
 )
+
+var tablesByIndex = map[string]*filter.SQLTable{
+	"accounts":     accountsTable,
+	"assets":       assetsTable,
+	"outputs":      outputsTable,
+	"transactions": transactionsTable,
+}
+
+// ValidateFixedFilter checks that filt is a well-formed filter over
+// the named index ("accounts", "assets", "outputs" or
+// "transactions") without placeholders, so that it can be joined to
+// another filter without changing the meaning of its placeholders.
+func ValidateFixedFilter(index, filt string) error {
+	tbl, ok := tablesByIndex[index]
+	if !ok {
+		return errors.WithDetailf(filter.ErrBadFilter, "unknown index %q", index)
+	}
+	p, err := filter.Parse(filt, tbl, nil)
+	if err != nil {
+		return err
+	}
+	if p.Parameters > 0 {
+		return errors.WithDetail(filter.ErrBadFilter, "filter can't have placeholders")
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+)
+
+// AssetSupply is the amount of an asset issued and retired on the
+// blockchain up to some point in time.
+type AssetSupply struct {
+	AssetID     bc.AssetID `json:"asset_id"`
+	Issued      uint64     `json:"issued"`
+	Retired     uint64     `json:"retired"`
+	Outstanding uint64     `json:"outstanding"`
+
+	// BlockHeight is the height of the last block up to the point
+	// in time that issued or retired the asset, or zero if none
+	// has.
+	BlockHeight uint64 `json:"block_height"`
+}
+
+// AssetSupplyRange is the change in the supply of an asset over a
+// range of time.
+type AssetSupplyRange struct {
+	AssetID bc.AssetID `json:"asset_id"`
+
+	// Issued and Retired are the amounts of the asset issued and
+	// retired in blocks in the range.
+	Issued  uint64 `json:"issued"`
+	Retired uint64 `json:"retired"`
+
+	// Start is the supply just before the start of the range, and
+	// End is the supply at its end.
+	Start *AssetSupply `json:"start"`
+	End   *AssetSupply `json:"end"`
+}
+
+// AssetSupply returns the supply of the asset as of the latest block
+// with a timestamp at or before timestampMS.
+func (ind *Indexer) AssetSupply(ctx context.Context, assetID bc.AssetID, timestampMS uint64) (*AssetSupply, error) {
+	const q = `
+		SELECT block_height, total_issued, total_retired FROM asset_supply
+		WHERE asset_id = $1 AND block_timestamp <= $2
+		ORDER BY block_timestamp DESC, block_height DESC LIMIT 1
+	`
+	s := &AssetSupply{AssetID: assetID}
+	err := ind.db.QueryRowContext(ctx, q, assetID, timestampMS).Scan(&s.BlockHeight, &s.Issued, &s.Retired)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "querying asset supply")
+	}
+	s.Outstanding = s.Issued - s.Retired
+	return s, nil
+}
+
+// AssetSupplyRange returns the change in the supply of the asset in
+// blocks with timestamps from startMS to endMS, inclusive.
+func (ind *Indexer) AssetSupplyRange(ctx context.Context, assetID bc.AssetID, startMS, endMS uint64) (*AssetSupplyRange, error) {
+	r := &AssetSupplyRange{AssetID: assetID, Start: &AssetSupply{AssetID: assetID}}
+	var err error
+	if startMS > 0 {
+		r.Start, err = ind.AssetSupply(ctx, assetID, startMS-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r.End, err = ind.AssetSupply(ctx, assetID, endMS)
+	if err != nil {
+		return nil, err
+	}
+	if r.End.BlockHeight > r.Start.BlockHeight {
+		r.Issued = r.End.Issued - r.Start.Issued
+		r.Retired = r.End.Retired - r.Start.Retired
+	}
+	return r, nil
+}
+
+// insertAssetSupply adds the amounts of each asset issued and
+// retired in b to the running totals of its supply.
+func (ind *Indexer) insertAssetSupply(ctx context.Context, b *legacy.Block, annotatedTxs []*AnnotatedTx) error {
+	var (
+		assetIDs []bc.AssetID
+		seen     = make(map[bc.AssetID]bool)
+		issued   = make(map[bc.AssetID]uint64)
+		retired  = make(map[bc.AssetID]uint64)
+	)
+	add := func(m map[bc.AssetID]uint64, assetID bc.AssetID, amount uint64) {
+		if !seen[assetID] {
+			seen[assetID] = true
+			assetIDs = append(assetIDs, assetID)
+		}
+		m[assetID] += amount
+	}
+	for _, tx := range annotatedTxs {
+		for _, in := range tx.Inputs {
+			if in.Type == "issue" {
+				add(issued, in.AssetID, in.Amount)
+			}
+		}
+		for _, out := range tx.Outputs {
+			if out.Type == "retire" {
+				add(retired, out.AssetID, out.Amount)
+			}
+		}
+	}
+	if len(assetIDs) == 0 {
+		return nil
+	}
+
+	var (
+		ids            pq.ByteaArray
+		issuedAmounts  pq.Int64Array
+		retiredAmounts pq.Int64Array
+	)
+	for _, assetID := range assetIDs {
+		ids = append(ids, assetID.Bytes())
+		issuedAmounts = append(issuedAmounts, int64(issued[assetID]))
+		retiredAmounts = append(retiredAmounts, int64(retired[assetID]))
+	}
+
+	const q = `
+		INSERT INTO asset_supply
+			(asset_id, block_height, block_timestamp, issued, retired, total_issued, total_retired)
+		SELECT t.asset_id, $1, $2, t.issued, t.retired,
+			t.issued + COALESCE(prev.total_issued, 0), t.retired + COALESCE(prev.total_retired, 0)
+		FROM unnest($3::bytea[], $4::bigint[], $5::bigint[]) AS t(asset_id, issued, retired)
+		LEFT JOIN LATERAL (
+			SELECT total_issued, total_retired FROM asset_supply s
+			WHERE s.asset_id = t.asset_id AND s.block_height < $1
+			ORDER BY s.block_height DESC LIMIT 1
+		) prev ON true
+		ON CONFLICT (asset_id, block_height) DO NOTHING
+	`
+	_, err := ind.db.ExecContext(ctx, q, b.Height, b.TimestampMS, ids, issuedAmounts, retiredAmounts)
+	return errors.Wrap(err, "updating asset supply")
+}
@@ -0,0 +1,67 @@
+package query
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/prottest"
+)
+
+func TestAssetSupply(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	indexer := NewIndexer(db, prottest.NewChain(t), nil)
+
+	assetID := bc.NewAssetID([32]byte{1})
+	blocks := []struct {
+		height, timestampMS uint64
+		txs                 []*AnnotatedTx
+	}{
+		{2, 1000, []*AnnotatedTx{{
+			Inputs: []*AnnotatedInput{{Type: "issue", AssetID: assetID, Amount: 100}},
+		}}},
+		{3, 2000, []*AnnotatedTx{{
+			Outputs: []*AnnotatedOutput{{Type: "retire", AssetID: assetID, Amount: 30}},
+		}, {
+			Inputs: []*AnnotatedInput{{Type: "issue", AssetID: assetID, Amount: 5}},
+		}}},
+	}
+	for _, blk := range blocks {
+		b := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: blk.height, TimestampMS: blk.timestampMS}}
+		err := indexer.insertAssetSupply(ctx, b, blk.txs)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		timestampMS           uint64
+		issued, retired, want uint64
+	}{
+		{999, 0, 0, 0},
+		{1000, 100, 0, 100},
+		{1999, 100, 0, 100},
+		{math.MaxInt64, 105, 30, 75},
+	}
+	for _, c := range cases {
+		got, err := indexer.AssetSupply(ctx, assetID, c.timestampMS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Issued != c.issued || got.Retired != c.retired || got.Outstanding != c.want {
+			t.Errorf("AssetSupply(%d) = %+v, want issued %d retired %d outstanding %d", c.timestampMS, got, c.issued, c.retired, c.want)
+		}
+	}
+
+	r, err := indexer.AssetSupplyRange(ctx, assetID, 1500, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Issued != 5 || r.Retired != 30 {
+		t.Errorf("AssetSupplyRange = issued %d retired %d, want issued 5 retired 30", r.Issued, r.Retired)
+	}
+}
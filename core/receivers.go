@@ -5,6 +5,10 @@ import (
 	"sync"
 	"time"
 
+	"chain/core/account"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 )
 
@@ -36,3 +40,46 @@ func (a *API) createAccountReceiver(ctx context.Context, ins []struct {
 	wg.Wait()
 	return responses
 }
+
+// deriveAccountKey returns the derivation path, derived keys and
+// control program of an account at a key index. If a control
+// program is given instead of a key index, it must be one of the
+// account's, and its key index is looked up.
+//
+// POST /derive-account-key
+func (a *API) deriveAccountKey(ctx context.Context, in struct {
+	AccountID      string             `json:"account_id"`
+	AccountAlias   string             `json:"account_alias"`
+	KeyIndex       *uint64            `json:"key_index"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+}) (*account.ReceiverKey, error) {
+	switch {
+	case len(in.ControlProgram) > 0:
+		return a.accounts.FindReceiverKey(ctx, in.AccountID, in.AccountAlias, in.ControlProgram)
+	case in.KeyIndex != nil:
+		return a.accounts.DeriveKey(ctx, in.AccountID, in.AccountAlias, *in.KeyIndex)
+	default:
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "either key_index or control_program must be provided")
+	}
+}
+
+// POST /list-account-receivers
+func (a *API) listAccountReceivers(ctx context.Context, in requestQuery) (page, error) {
+	limit := in.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	keys, after, err := a.accounts.ListReceivers(ctx, in.AccountID, in.AccountAlias, in.After, limit)
+	if err != nil {
+		return page{}, errors.Wrap(err, "listing account receivers")
+	}
+
+	out := in
+	out.After = after
+	return page{
+		Items:    httpjson.Array(keys),
+		LastPage: len(keys) < limit,
+		Next:     out,
+	}, nil
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 
+	"chain/core/fetch"
+	"chain/core/txdb"
 	chainjson "chain/encoding/json"
 	"chain/errors"
+	"chain/log"
 	"chain/net/http/httpjson"
 	"chain/protocol/bc"
 )
@@ -29,6 +33,68 @@ func (a *API) getBlockRPC(ctx context.Context, height uint64) (chainjson.HexByte
 	return rawBlock, nil
 }
 
+// getBlocksRPC returns consecutive blocks starting at the height
+// given by the from query parameter, as a stream of length-prefixed
+// raw blocks (see fetch.WriteBlockFrame). It returns at most count
+// blocks and stops adding blocks once the response reaches
+// fetch.MaxBatchBytes, but returns at least one block, waiting if
+// necessary until one is created. It stops before any block larger
+// than fetch.MaxFrameSize, which the client must download with
+// /rpc/get-block.
+//
+// Like getSnapshotRPC, this handler doesn't use the httpjson.Handler
+// format so that it can return raw bytes on the wire. The response
+// is gzip-compressed if the client accepts it.
+func (a *API) getBlocksRPC(rw http.ResponseWriter, req *http.Request) {
+	if a.config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+	ctx := req.Context()
+
+	query := req.URL.Query()
+	from, err := strconv.ParseUint(query.Get("from"), 10, 64)
+	if err != nil || from == 0 {
+		errorFormatter.Write(ctx, rw, errors.WithDetail(httpjson.ErrBadRequest, "from must be a positive block height"))
+		return
+	}
+	count := fetch.MaxBatchBlocks
+	if s := query.Get("count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			errorFormatter.Write(ctx, rw, errors.WithDetail(httpjson.ErrBadRequest, "count must be a positive integer"))
+			return
+		}
+		if n < count {
+			count = n
+		}
+	}
+
+	err = <-a.chain.BlockSoonWaiter(ctx, from)
+	if err != nil {
+		errorFormatter.Write(ctx, rw, errors.Wrapf(err, "waiting for block at height %d", from))
+		return
+	}
+
+	blocks, err := a.store.GetRawBlocks(ctx, from, count, fetch.MaxBatchBytes)
+	if err != nil {
+		errorFormatter.Write(ctx, rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	for _, b := range blocks {
+		if len(b) > fetch.MaxFrameSize {
+			break
+		}
+		err = fetch.WriteBlockFrame(rw, b)
+		if err != nil {
+			// The client will see a truncated stream.
+			log.Error(ctx, err)
+			return
+		}
+	}
+}
+
 type snapshotInfoResp struct {
 	Height       uint64  `json:"height"`
 	Size         uint64  `json:"size"`
@@ -69,3 +135,43 @@ func (a *API) getSnapshotRPC(rw http.ResponseWriter, req *http.Request) {
 	rw.Header().Set("Content-Type", "application/x-protobuf")
 	rw.Write(data)
 }
+
+// getSnapshotManifestRPC returns a description of how the snapshot at
+// the provided height is split into chunks, including the hash of
+// each chunk. Non-generators use it to download a snapshot in
+// verifiable, resumable pieces through /rpc/get-snapshot-chunk.
+func (a *API) getSnapshotManifestRPC(ctx context.Context, height uint64) (*txdb.SnapshotManifest, error) {
+	return a.store.GetSnapshotManifest(ctx, height)
+}
+
+type snapshotChunkReq struct {
+	Height uint64 `json:"height"`
+	Index  int    `json:"index"`
+}
+
+// getSnapshotChunkRPC returns a single chunk of the raw protobuf
+// snapshot at the provided height.
+//
+// Like getSnapshotRPC, this handler doesn't use the httpjson.Handler
+// format so that it can return raw protobuf bytes on the wire.
+func (a *API) getSnapshotChunkRPC(rw http.ResponseWriter, req *http.Request) {
+	if a.config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+
+	var chunkReq snapshotChunkReq
+	err := json.NewDecoder(req.Body).Decode(&chunkReq)
+	if err != nil {
+		errorFormatter.Write(req.Context(), rw, httpjson.ErrBadRequest)
+		return
+	}
+
+	data, err := a.store.GetSnapshotChunk(req.Context(), chunkReq.Height, chunkReq.Index)
+	if err != nil {
+		errorFormatter.Write(req.Context(), rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/x-protobuf")
+	rw.Write(data)
+}
@@ -76,13 +76,19 @@ func (c *Client) Call(ctx context.Context, path string, request, response interf
 }
 
 // CallRaw calls a remote procedure on another node, specified by the path. It
-// returns a io.ReadCloser of the raw response body.
+// returns a io.ReadCloser of the raw response body. The path may include
+// a query string.
 func (c *Client) CallRaw(ctx context.Context, path string, request interface{}) (io.ReadCloser, error) {
 	u, err := url.Parse(c.BaseURL)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
-	u.Path = path
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	u.Path = ref.Path
+	u.RawQuery = ref.RawQuery
 
 	var bodyReader io.Reader
 	if request != nil {
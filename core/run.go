@@ -2,8 +2,6 @@ package core
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"errors"
 	"net"
 	"net/http"
@@ -12,16 +10,20 @@ import (
 	"chain/core/accesstoken"
 	"chain/core/account"
 	"chain/core/asset"
+	"chain/core/audit"
 	"chain/core/config"
 	"chain/core/fetch"
 	"chain/core/generator"
 	"chain/core/leader"
+	"chain/core/light"
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/rpc"
+	"chain/core/signsession"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
+	"chain/core/txsigner"
 	"chain/database/pg"
 	"chain/database/sinkdb"
 	"chain/env"
@@ -40,13 +42,13 @@ const (
 // RunOption describes a runtime configuration option.
 type RunOption func(*API)
 
-// UseTLS configures the Core to use TLS with the given config
-// when communicating between Core processes.
-// If c is nil, TLS is disabled.
-func UseTLS(c *tls.Config) RunOption {
+// UseTLS configures the Core to use TLS with the certificate
+// and trusted roots in t when communicating between Core processes.
+// Certificates reloaded by t take effect for new connections.
+// If t is nil, TLS is disabled.
+func UseTLS(t *TLSReloader) RunOption {
 	return func(a *API) {
-		a.httpClient = new(http.Client)
-		a.httpClient.Transport = &http.Transport{
+		transport := &http.Transport{
 			DialContext: (&net.Dialer{
 				Timeout:   30 * time.Second,
 				KeepAlive: 30 * time.Second,
@@ -54,19 +56,14 @@ func UseTLS(c *tls.Config) RunOption {
 			}).DialContext,
 			MaxIdleConns:          100,
 			IdleConnTimeout:       90 * time.Second,
-			TLSClientConfig:       c,
 			TLSHandshakeTimeout:   10 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
 		}
-
-		if c != nil {
-			// TODO(kr): set Leaf in TLSConfig and use that here.
-			x509Cert, err := x509.ParseCertificate(c.Certificates[0].Certificate[0])
-			if err != nil {
-				panic(err)
-			}
-			a.internalSubj = x509Cert.Subject
+		if t != nil {
+			transport.DialTLS = t.DialTLS
+			a.internalSubj = t.Leaf().Subject
 		}
+		a.httpClient = &http.Client{Transport: transport}
 	}
 }
 
@@ -89,7 +86,8 @@ func GeneratorLocal(gen *generator.Generator) RunOption {
 }
 
 // GeneratorRemote configures the launched Core to fetch blocks from
-// the provided remote generator.
+// the provided remote generator. Blocks are also fetched from any
+// peers configured through the block_peer configuration option.
 func GeneratorRemote(client *rpc.Client) RunOption {
 	return func(a *API) {
 		if a.generator != nil {
@@ -97,7 +95,6 @@ func GeneratorRemote(client *rpc.Client) RunOption {
 		}
 		a.remoteGenerator = client
 		a.submitter = &txbuilder.RemoteGenerator{Peer: client}
-		a.replicator = fetch.New(client)
 	}
 }
 
@@ -107,6 +104,15 @@ func IndexTransactions(b bool) RunOption {
 	return func(a *API) { a.indexTxs = b }
 }
 
+// LightClient configures whether a Core that isn't the generator
+// should sync only verified block headers, and the transactions
+// paying to the control programs configured through the
+// watch_program configuration option, instead of the full
+// blockchain.
+func LightClient(b bool) RunOption {
+	return func(a *API) { a.lightClient = b }
+}
+
 // RateLimit adds a rate-limiting restriction, using keyFn to extract the
 // key to rate limit on. It will allow up to burst requests in the bucket
 // and will refill the bucket at perSecond tokens per second.
@@ -129,6 +135,7 @@ func RunUnconfigured(ctx context.Context, confOpts *config.Options, db pg.DB, sd
 		db:           db,
 		sdb:          sdb,
 		accessTokens: &accesstoken.CredentialStore{DB: db},
+		audit:        &audit.Store{DB: db},
 		grants:       authz.NewStore(sdb, GrantPrefix),
 		options:      confOpts,
 		mux:          http.NewServeMux(),
@@ -186,8 +193,10 @@ func Run(
 		assets:       assets,
 		accounts:     accounts,
 		txFeeds:      &txfeed.Tracker{DB: db},
+		signSessions: &signsession.Store{DB: db},
 		indexer:      indexer,
 		accessTokens: &accesstoken.CredentialStore{DB: db},
+		audit:        &audit.Store{DB: db},
 		grants:       authz.NewStore(sdb, GrantPrefix),
 		config:       conf,
 		options:      confOpts,
@@ -203,8 +212,22 @@ func Run(
 		return nil, errors.New("no generator configured")
 	}
 
-	if a.replicator != nil {
+	a.txSigner = &txsigner.Client{
+		URLs: confOpts.ListFunc("tx_signer"),
+		BaseClient: rpc.Client{
+			BlockchainID: conf.BlockchainId.String(),
+			CoreID:       conf.Id,
+			Client:       a.httpClient,
+		},
+	}
+
+	if a.remoteGenerator != nil {
+		a.replicator = fetch.New(a.remoteGenerator, confOpts.ListFunc("block_peer"))
 		go a.replicator.PollRemoteHeight(ctx)
+
+		if a.lightClient {
+			a.light = light.New(db, a.remoteGenerator, *conf.BlockchainId, confOpts.ListFunc("watch_program"))
+		}
 	}
 
 	if a.indexTxs {
@@ -221,6 +244,9 @@ func Run(
 	// GC old submitted txs periodically.
 	go cleanUpSubmittedTxs(ctx, a.db)
 
+	// GC long-expired signing sessions periodically.
+	go a.signSessions.CleanUp(ctx, cleanUpSigningSessionsPeriod)
+
 	// When this cored becomes leader, run a.lead to perform
 	// leader-only Core duties.
 	a.leader = leader.Run(ctx, db, routableAddress, a.lead)
@@ -234,6 +260,13 @@ func Run(
 // lead is called by the core/leader package when this cored instance
 // becomes leader of the Core.
 func (a *API) lead(ctx context.Context) {
+	if a.light != nil {
+		// Light clients don't store blocks or state, so there's
+		// nothing to recover or process.
+		go a.light.Run(ctx, a.healthSetter("fetch"))
+		return
+	}
+
 	if !a.config.IsGenerator {
 		// If don't have any blocks, bootstrap from the generator's
 		// latest snapshot.
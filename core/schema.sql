@@ -122,6 +122,16 @@ END;
 $$;
 
 
+
+CREATE FUNCTION reject_modification() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+	RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$;
+
+
 SET default_tablespace = '';
 
 SET default_with_oids = false;
@@ -132,7 +142,13 @@ CREATE TABLE access_tokens (
     sort_id text DEFAULT next_chain_id('at'::text),
     type access_token_type,
     hashed_secret bytea NOT NULL,
-    created timestamp with time zone DEFAULT now() NOT NULL
+    created timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone,
+    previous_hashed_secret bytea,
+    previous_expires_at timestamp with time zone,
+    rotated_at timestamp with time zone,
+    last_used_at timestamp with time zone,
+    last_used_addr text
 );
 
 
@@ -199,7 +215,8 @@ CREATE TABLE annotated_assets (
     quorum integer NOT NULL,
     definition jsonb NOT NULL,
     tags jsonb NOT NULL,
-    local boolean NOT NULL
+    local boolean NOT NULL,
+    issuance_rules jsonb
 );
 
 
@@ -264,6 +281,18 @@ CREATE TABLE annotated_txs (
 
 
 
+CREATE TABLE asset_supply (
+    asset_id bytea NOT NULL,
+    block_height bigint NOT NULL,
+    block_timestamp bigint NOT NULL,
+    issued bigint NOT NULL,
+    retired bigint NOT NULL,
+    total_issued bigint NOT NULL,
+    total_retired bigint NOT NULL
+);
+
+
+
 CREATE TABLE asset_tags (
     asset_id bytea NOT NULL,
     tags jsonb
@@ -282,7 +311,8 @@ CREATE TABLE assets (
     definition bytea NOT NULL,
     alias text,
     first_block_height bigint,
-    vm_version bigint NOT NULL
+    vm_version bigint NOT NULL,
+    issuance_rules jsonb
 );
 
 
@@ -296,6 +326,32 @@ CREATE SEQUENCE assets_key_index_seq
 
 
 
+CREATE SEQUENCE audit_events_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+
+CREATE TABLE audit_events (
+    id bigint DEFAULT nextval('audit_events_id_seq'::regclass) NOT NULL,
+    route text NOT NULL,
+    request_id text NOT NULL,
+    access_token text,
+    cert_subject text,
+    jwt_subject text,
+    remote_addr text NOT NULL,
+    request_digest bytea NOT NULL,
+    outcome text NOT NULL,
+    status_code integer NOT NULL,
+    error_code text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+
 CREATE TABLE block_processors (
     name text NOT NULL,
     height bigint DEFAULT 0 NOT NULL
@@ -357,6 +413,58 @@ CREATE TABLE generator_pending_block (
 
 
 
+CREATE TABLE keystore (
+    singleton boolean DEFAULT true NOT NULL,
+    kdf text NOT NULL,
+    salt bytea NOT NULL,
+    scrypt_n integer NOT NULL,
+    scrypt_r integer NOT NULL,
+    scrypt_p integer NOT NULL,
+    sealed_key bytea NOT NULL,
+    CONSTRAINT keystore_singleton CHECK (singleton)
+);
+
+
+
+CREATE SEQUENCE keystore_keys_sort_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+
+CREATE TABLE keystore_keys (
+    pub bytea NOT NULL,
+    sealed_prv bytea NOT NULL,
+    alias text,
+    key_type text NOT NULL,
+    sort_id bigint DEFAULT nextval('keystore_keys_sort_id_seq'::regclass) NOT NULL
+);
+
+
+
+CREATE SEQUENCE keystore_sign_events_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+
+CREATE TABLE keystore_sign_events (
+    id bigint DEFAULT nextval('keystore_sign_events_id_seq'::regclass) NOT NULL,
+    key_type text NOT NULL,
+    pub bytea NOT NULL,
+    path bytea[] NOT NULL,
+    message bytea NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+
 CREATE TABLE leader (
     singleton boolean DEFAULT true NOT NULL,
     leader_key text NOT NULL,
@@ -367,6 +475,23 @@ CREATE TABLE leader (
 
 
 
+CREATE TABLE light_block_headers (
+    height bigint NOT NULL,
+    block_hash bytea NOT NULL,
+    header bytea NOT NULL
+);
+
+
+
+CREATE TABLE light_transactions (
+    block_height bigint NOT NULL,
+    "position" integer NOT NULL,
+    tx_hash bytea NOT NULL,
+    data bytea NOT NULL
+);
+
+
+
 CREATE TABLE migrations (
     filename text NOT NULL,
     hash text NOT NULL,
@@ -389,7 +514,31 @@ CREATE TABLE mockhsm (
     prv bytea NOT NULL,
     alias text,
     sort_id bigint DEFAULT nextval('mockhsm_sort_id_seq'::regclass) NOT NULL,
-    key_type text DEFAULT 'chain_kd'::text NOT NULL
+    key_type text DEFAULT 'chain_kd'::text NOT NULL,
+    policy jsonb
+);
+
+
+
+CREATE SEQUENCE mockhsm_sign_events_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+
+CREATE TABLE mockhsm_sign_events (
+    id bigint DEFAULT nextval('mockhsm_sign_events_id_seq'::regclass) NOT NULL,
+    key_type text NOT NULL,
+    pub bytea NOT NULL,
+    key_alias text,
+    path bytea[] NOT NULL,
+    message bytea NOT NULL,
+    access_token text,
+    policy_violation text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
 );
 
 
@@ -401,6 +550,15 @@ CREATE TABLE query_blocks (
 
 
 
+CREATE TABLE request_quota_counts (
+    rule text NOT NULL,
+    client text NOT NULL,
+    day date NOT NULL,
+    count integer NOT NULL
+);
+
+
+
 CREATE TABLE signed_blocks (
     block_height bigint NOT NULL,
     block_hash bytea NOT NULL
@@ -414,7 +572,9 @@ CREATE TABLE signers (
     key_index bigint NOT NULL,
     quorum integer NOT NULL,
     client_token text,
-    xpubs bytea[] NOT NULL
+    xpubs bytea[] NOT NULL,
+    path_prefix bytea[],
+    hardened_xpubs bytea[]
 );
 
 
@@ -432,11 +592,26 @@ ALTER SEQUENCE signers_key_index_seq OWNED BY signers.key_index;
 
 
 
+CREATE TABLE signing_sessions (
+    id text DEFAULT next_chain_id('sess'::text) NOT NULL,
+    alias text,
+    template jsonb NOT NULL,
+    version bigint DEFAULT 0 NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    tx_id bytea,
+    client_token text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+
 CREATE TABLE snapshots (
     height bigint NOT NULL,
     data bytea NOT NULL,
-    created_at timestamp without time zone DEFAULT now()
+    created_at timestamp without time zone DEFAULT now(),
+    chunk_hashes bytea
 );
+ALTER TABLE ONLY snapshots ALTER COLUMN data SET STORAGE EXTERNAL;
 
 
 
@@ -517,6 +692,11 @@ ALTER TABLE ONLY annotated_txs
 
 
 
+ALTER TABLE ONLY asset_supply
+    ADD CONSTRAINT asset_supply_pkey PRIMARY KEY (asset_id, block_height);
+
+
+
 ALTER TABLE ONLY asset_tags
     ADD CONSTRAINT asset_tags_asset_id_key UNIQUE (asset_id);
 
@@ -537,6 +717,11 @@ ALTER TABLE ONLY assets
 
 
 
+ALTER TABLE ONLY audit_events
+    ADD CONSTRAINT audit_events_pkey PRIMARY KEY (id);
+
+
+
 ALTER TABLE ONLY block_processors
     ADD CONSTRAINT block_processors_name_key UNIQUE (name);
 
@@ -567,11 +752,46 @@ ALTER TABLE ONLY generator_pending_block
 
 
 
+ALTER TABLE ONLY keystore_keys
+    ADD CONSTRAINT keystore_keys_alias_key UNIQUE (alias);
+
+
+
+ALTER TABLE ONLY keystore_keys
+    ADD CONSTRAINT keystore_keys_pkey PRIMARY KEY (pub);
+
+
+
+ALTER TABLE ONLY keystore_keys
+    ADD CONSTRAINT keystore_keys_sort_id_key UNIQUE (sort_id);
+
+
+
+ALTER TABLE ONLY keystore
+    ADD CONSTRAINT keystore_pkey PRIMARY KEY (singleton);
+
+
+
+ALTER TABLE ONLY keystore_sign_events
+    ADD CONSTRAINT keystore_sign_events_pkey PRIMARY KEY (id);
+
+
+
 ALTER TABLE ONLY leader
     ADD CONSTRAINT leader_singleton_key UNIQUE (singleton);
 
 
 
+ALTER TABLE ONLY light_block_headers
+    ADD CONSTRAINT light_block_headers_pkey PRIMARY KEY (height);
+
+
+
+ALTER TABLE ONLY light_transactions
+    ADD CONSTRAINT light_transactions_pkey PRIMARY KEY (block_height, "position");
+
+
+
 ALTER TABLE ONLY migrations
     ADD CONSTRAINT migrations_pkey PRIMARY KEY (filename);
 
@@ -587,11 +807,21 @@ ALTER TABLE ONLY mockhsm
 
 
 
+ALTER TABLE ONLY mockhsm_sign_events
+    ADD CONSTRAINT mockhsm_sign_events_pkey PRIMARY KEY (id);
+
+
+
 ALTER TABLE ONLY query_blocks
     ADD CONSTRAINT query_blocks_pkey PRIMARY KEY (height);
 
 
 
+ALTER TABLE ONLY request_quota_counts
+    ADD CONSTRAINT request_quota_counts_pkey PRIMARY KEY (rule, client, day);
+
+
+
 ALTER TABLE ONLY signers
     ADD CONSTRAINT signers_client_token_key UNIQUE (client_token);
 
@@ -602,6 +832,21 @@ ALTER TABLE ONLY signers
 
 
 
+ALTER TABLE ONLY signing_sessions
+    ADD CONSTRAINT signing_sessions_alias_key UNIQUE (alias);
+
+
+
+ALTER TABLE ONLY signing_sessions
+    ADD CONSTRAINT signing_sessions_client_token_key UNIQUE (client_token);
+
+
+
+ALTER TABLE ONLY signing_sessions
+    ADD CONSTRAINT signing_sessions_pkey PRIMARY KEY (id);
+
+
+
 ALTER TABLE ONLY mockhsm
     ADD CONSTRAINT sort_id_index UNIQUE (sort_id);
 
@@ -648,6 +893,18 @@ CREATE INDEX annotated_txs_data_idx ON annotated_txs USING gin (data jsonb_path_
 
 
 
+CREATE INDEX asset_supply_asset_id_block_timestamp_idx ON asset_supply USING btree (asset_id, block_timestamp);
+
+
+
+CREATE INDEX audit_events_created_at_idx ON audit_events USING btree (created_at);
+
+
+
+CREATE INDEX mockhsm_sign_events_key_alias_idx ON mockhsm_sign_events USING btree (key_alias, id);
+
+
+
 CREATE INDEX query_blocks_timestamp_idx ON query_blocks USING btree ("timestamp");
 
 
@@ -656,6 +913,14 @@ CREATE UNIQUE INDEX signed_blocks_block_height_idx ON signed_blocks USING btree
 
 
 
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events FOR EACH ROW EXECUTE PROCEDURE reject_modification();
+
+
+
+CREATE TRIGGER mockhsm_sign_events_append_only BEFORE UPDATE OR DELETE ON mockhsm_sign_events FOR EACH ROW EXECUTE PROCEDURE reject_modification();
+
+
+
 
 insert into migrations (filename, hash) values ('2017-02-03.0.core.schema-snapshot.sql', '1d55668affe0be9f3c19ead9d67bc75cfd37ec430651434d0f2af2706d9f08cd');
 insert into migrations (filename, hash) values ('2017-02-07.0.query.non-null-alias.sql', '17028a0bdbc95911e299dc65fe641184e54c87a0d07b3c576d62d023b9a8defc');
@@ -668,3 +933,15 @@ insert into migrations (filename, hash) values ('2017-04-17.0.core.null-token-ty
 insert into migrations (filename, hash) values ('2017-04-27.0.generator.pending-block-height.sql', 'bfe4fe5eec143e4367a91fd952cb5e3879f1c311f649ec13bfe95b202e94d4ec');
 insert into migrations (filename, hash) values ('2017-05-08.0.core.drop-redundant-indexes.sql', '5140e53b287b058c57ddf361d61cff3d3d1cbc3259a9de413b11574a71d09bec');
 insert into migrations (filename, hash) values ('2017-06-28.0.core.coreid.sql', 'a147b93ba1bf404265efedde066532c937070a87e15123b1d9277daba431ee01');
+insert into migrations (filename, hash) values ('2017-07-05.0.core.light-client.sql', 'ca083cb6087a29b5be1a64c8bb2827b536278f474a5d0b19061f6858435f63b7');
+insert into migrations (filename, hash) values ('2017-07-06.0.core.keystore.sql', '8a5067e17e96e245d2c4cabb4d7581befd4d4724779bc46618740f8567aa4393');
+insert into migrations (filename, hash) values ('2017-07-10.0.core.signing-sessions.sql', '4877b1e15f831391a52502b535660c9ca4d944e13b8ef820ec760b0394220cfa');
+insert into migrations (filename, hash) values ('2017-07-11.0.core.signer-path-prefix.sql', 'dcef1060a249106f47167fdfd4abe2e43af8c29679b6302a33fa88a955f8957d');
+insert into migrations (filename, hash) values ('2017-07-12.0.core.mockhsm-policies-and-sign-events.sql', '0fc868f3fba0def99a802d613bb6e35fb9a030653084c2d6fcf19093055fb63d');
+insert into migrations (filename, hash) values ('2017-07-13.0.core.signer-hardened-xpubs.sql', '64e90415691f48eb79f5fcb67e8ae0c1a002dda9ba9c9b5893f7af0466b7d8b4');
+insert into migrations (filename, hash) values ('2017-07-14.0.core.asset-issuance-rules.sql', '16f37e3e8c1b9a50d34dd970a7a50bbd86fc059fc5d3e6e0e114e2e2880d875f');
+insert into migrations (filename, hash) values ('2017-07-15.0.query.asset-supply.sql', 'a7c5832352b69d7f253b8f5da9888ff4dc40007f44429b8bcea7837b3c2c4d88');
+insert into migrations (filename, hash) values ('2017-07-16.0.core.access-token-expiry.sql', 'e0a05fc737da0af5d97015b735be2acfdbe6518596e545d69663d86ba88a86f1');
+insert into migrations (filename, hash) values ('2017-07-17.0.core.audit-events.sql', 'd81a06032a8463bece2a28d054df7fcfaa66459779f080a8212e5f85212b230b');
+insert into migrations (filename, hash) values ('2017-07-18.0.core.request-quota-counts.sql', 'c66f8284a331002dcd7d8ea7821c4bb9550072e3fd862153b73682cdffefc091');
+insert into migrations (filename, hash) values ('2017-07-19.0.core.snapshot-chunk-hashes.sql', '83505145742a680d9ebb3835ef3eb6efa69fe2e63216a551ff32c1df7345966b');
@@ -0,0 +1,274 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+
+	"chain/core/query"
+	"chain/core/query/filter"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/net/http/authz"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// errOutOfScope is returned when a request allowed only by scoped
+// grants touches a resource outside all of their scopes.
+var errOutOfScope = errors.New("request is outside the scope of its grants")
+
+// grantScope restricts an authorization grant to some of the
+// Core's resources. Each field is a filter over the index of the
+// same name, as in the corresponding list endpoint, without
+// placeholders. A scoped grant allows nothing for a field left
+// empty; for example, a grant scoped only to accounts can't issue
+// assets.
+type grantScope struct {
+	// Accounts are the accounts the grant may see, retag, and spend
+	// from.
+	Accounts string `json:"accounts,omitempty"`
+
+	// Assets are the assets the grant may see, retag, and issue.
+	Assets string `json:"assets,omitempty"`
+
+	// Transactions are the transactions the grant may see.
+	Transactions string `json:"transactions,omitempty"`
+
+	// Outputs are the outputs the grant may see, including in
+	// balances.
+	Outputs string `json:"outputs,omitempty"`
+}
+
+func (s *grantScope) filters() map[string]string {
+	return map[string]string{
+		"accounts":     s.Accounts,
+		"assets":       s.Assets,
+		"transactions": s.Transactions,
+		"outputs":      s.Outputs,
+	}
+}
+
+func (s *grantScope) validate() error {
+	var empty = true
+	for index, filt := range s.filters() {
+		if filt == "" {
+			continue
+		}
+		empty = false
+		err := query.ValidateFixedFilter(index, filt)
+		if err != nil {
+			return errors.WithDetailf(err, "in %s scope", index)
+		}
+	}
+	if empty {
+		return errors.WithDetail(httpjson.ErrBadRequest, "scope must have at least one filter")
+	}
+	return nil
+}
+
+// requestScopes returns the scopes of the grants that allowed the
+// request with context ctx, and whether the request is restricted
+// to them.
+func requestScopes(ctx context.Context) ([]*grantScope, bool) {
+	raw, ok := authz.Scopes(ctx)
+	if !ok {
+		return nil, false
+	}
+	var scopes []*grantScope
+	for _, b := range raw {
+		s := new(grantScope)
+		err := json.Unmarshal(b, s)
+		if err != nil {
+			// Stored scopes are validated when the grant is created,
+			// so this shouldn't happen. Allow nothing for the grant.
+			continue
+		}
+		scopes = append(scopes, s)
+	}
+	return scopes, true
+}
+
+// scopeFilter joins the filters of scopes over index into one
+// filter matching anything any of them match. It returns the empty
+// string if none of them has a filter over index.
+func scopeFilter(scopes []*grantScope, index string) (string, error) {
+	var filters []string
+	for _, s := range scopes {
+		filters = append(filters, s.filters()[index])
+	}
+	return filter.Or(filters...)
+}
+
+// scopedFilter restricts filt, a filter over index from a request
+// with context ctx, to the scopes of the request's grants.
+// The filters are combined after parsing, so filt can't escape
+// the scopes by unbalancing parentheses.
+func scopedFilter(ctx context.Context, index, filt string) (string, error) {
+	scopes, restricted := requestScopes(ctx)
+	if !restricted {
+		return filt, nil
+	}
+	scope, err := scopeFilter(scopes, index)
+	if err != nil {
+		return "", err
+	}
+	if scope == "" {
+		return "", errors.WithDetailf(errOutOfScope, "no %s are in scope", index)
+	}
+	if filt == "" {
+		return scope, nil
+	}
+	return filter.And(filt, scope)
+}
+
+// checkAccountScope checks that the account whose field ("id" or
+// "alias") has the given value is in scope for the request with
+// context ctx.
+func (a *API) checkAccountScope(ctx context.Context, field, value string) error {
+	if _, restricted := requestScopes(ctx); !restricted {
+		return nil
+	}
+	filt, err := scopedFilter(ctx, "accounts", field+"=$1")
+	if err != nil {
+		return err
+	}
+	accounts, _, err := a.indexer.Accounts(ctx, filt, []interface{}{value}, "", 1)
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return errors.WithDetailf(errOutOfScope, "account %s %s is not in scope", field, value)
+	}
+	return nil
+}
+
+// checkAssetScope is like checkAccountScope, for assets.
+func (a *API) checkAssetScope(ctx context.Context, field, value string) error {
+	if _, restricted := requestScopes(ctx); !restricted {
+		return nil
+	}
+	filt, err := scopedFilter(ctx, "assets", field+"=$1")
+	if err != nil {
+		return err
+	}
+	assets, _, err := a.indexer.Assets(ctx, filt, []interface{}{value}, "", 1)
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return errors.WithDetailf(errOutOfScope, "asset %s %s is not in scope", field, value)
+	}
+	return nil
+}
+
+// scopeRef returns the field and value identifying a resource
+// given by either ID or alias, for checkAccountScope or
+// checkAssetScope.
+func scopeRef(id, alias *string) (field, value string) {
+	if id != nil {
+		return "id", *id
+	}
+	if alias != nil {
+		return "alias", *alias
+	}
+	return "id", ""
+}
+
+// checkSpendScope checks that the request with context ctx may
+// spend the given outputs and issue the given assets.
+func (a *API) checkSpendScope(ctx context.Context, spent []bc.Hash, issued []bc.AssetID) error {
+	if _, restricted := requestScopes(ctx); !restricted {
+		return nil
+	}
+	if len(spent) > 0 {
+		accountIDs, err := a.accounts.OutputAccounts(ctx, spent)
+		if err != nil {
+			return err
+		}
+		for _, id := range accountIDs {
+			err = a.checkAccountScope(ctx, "id", id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, assetID := range issued {
+		err := a.checkAssetScope(ctx, "id", assetID.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkActionsScope checks the accounts, outputs, and assets named
+// by the actions of a build request against the scopes of the
+// request with context ctx. It runs before the actions are built,
+// so that a request can't reserve outputs it may not spend.
+func (a *API) checkActionsScope(ctx context.Context, req *buildRequest) error {
+	if _, restricted := requestScopes(ctx); !restricted {
+		return nil
+	}
+	var (
+		spent  []bc.Hash
+		issued []bc.AssetID
+	)
+	for i, act := range req.Actions {
+		typ, _ := act["type"].(string)
+		switch typ {
+		case "spend_account":
+			id, _ := act["account_id"].(string)
+			err := a.checkAccountScope(ctx, "id", id)
+			if err != nil {
+				return errors.WithDetailf(err, "on action %d", i)
+			}
+		case "spend_account_unspent_output":
+			var outputID bc.Hash
+			err := remarshal(act["output_id"], &outputID)
+			if err != nil {
+				return errors.WithDetailf(errBadAction, "bad output_id on action %d", i)
+			}
+			spent = append(spent, outputID)
+		case "issue":
+			// filterAliases may have replaced the asset alias with
+			// a bc.AssetID, so go through JSON to handle either.
+			var assetID bc.AssetID
+			err := remarshal(act["asset_id"], &assetID)
+			if err != nil {
+				return errors.WithDetailf(errBadAction, "bad asset_id on action %d", i)
+			}
+			issued = append(issued, assetID)
+		}
+	}
+	return a.checkSpendScope(ctx, spent, issued)
+}
+
+func remarshal(v, dst interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+// checkTemplateScope checks the spends and issuances of a
+// transaction template against the scopes of the request with
+// context ctx.
+func (a *API) checkTemplateScope(ctx context.Context, tpl *txbuilder.Template) error {
+	if _, restricted := requestScopes(ctx); !restricted || tpl.Transaction == nil {
+		return nil
+	}
+	var (
+		spent  []bc.Hash
+		issued []bc.AssetID
+	)
+	tx := tpl.Transaction.Tx
+	for _, id := range tx.InputIDs {
+		if sp, err := tx.Spend(id); err == nil {
+			spent = append(spent, *sp.SpentOutputId)
+		} else if iss, err := tx.Issuance(id); err == nil {
+			issued = append(issued, *iss.Value.AssetId)
+		}
+	}
+	return a.checkSpendScope(ctx, spent, issued)
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"chain/core/query/filter"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/net/http/authz"
+	"chain/net/http/httperror"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+)
+
+type staticLoader []*authz.Grant
+
+func (l staticLoader) Load(context.Context, []string) ([]*authz.Grant, error) {
+	return l, nil
+}
+
+func TestScopedFilter(t *testing.T) {
+	payments := []byte(`{"accounts":"tags.team='payments'","outputs":"account_tags.team='payments'"}`)
+	treasury := []byte(`{"accounts":"tags.team='treasury'"}`)
+
+	cases := []struct {
+		grants  []*authz.Grant
+		index   string
+		filt    string
+		want    string
+		wantErr error
+	}{{
+		grants: []*authz.Grant{{GuardType: "any"}},
+		index:  "accounts",
+		filt:   "alias=$1",
+		want:   "alias=$1",
+	}, {
+		grants: []*authz.Grant{{GuardType: "any", Scope: payments}},
+		index:  "accounts",
+		filt:   "alias=$1",
+		want:   "(alias = $1) AND ((tags.team = 'payments'))",
+	}, {
+		// A filter can't close the parentheses around it
+		// to escape the scope.
+		grants:  []*authz.Grant{{GuardType: "any", Scope: payments}},
+		index:   "accounts",
+		filt:    "alias=$1) OR (alias='victim'",
+		wantErr: filter.ErrBadFilter,
+	}, {
+		grants: []*authz.Grant{{GuardType: "any", Scope: payments}},
+		index:  "outputs",
+		want:   "(account_tags.team = 'payments')",
+	}, {
+		grants: []*authz.Grant{{GuardType: "any", Scope: payments}, {GuardType: "any", Scope: treasury}},
+		index:  "accounts",
+		want:   "(tags.team = 'payments') OR (tags.team = 'treasury')",
+	}, {
+		// An unscoped grant makes the request unrestricted.
+		grants: []*authz.Grant{{GuardType: "any", Scope: payments}, {GuardType: "any"}},
+		index:  "assets",
+		filt:   "alias=$1",
+		want:   "alias=$1",
+	}, {
+		// Guards that don't match don't contribute their scopes.
+		grants: []*authz.Grant{{GuardType: "any", Scope: payments}, {GuardType: "localhost"}},
+		index:  "accounts",
+		want:   "(tags.team = 'payments')",
+	}, {
+		grants:  []*authz.Grant{{GuardType: "any", Scope: payments}},
+		index:   "assets",
+		wantErr: errOutOfScope,
+	}}
+	for i, c := range cases {
+		authorizer := authz.NewAuthorizer(staticLoader(c.grants), map[string][]string{"/": {"client-readwrite"}})
+		req, err := http.NewRequest("POST", "/list-accounts", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RequestURI = req.URL.Path
+		req, err = authorizer.Authorize(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := scopedFilter(req.Context(), c.index, c.filt)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: error = %v, want %v", i, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Errorf("case %d: filter = %q, want %q", i, got, c.want)
+		}
+	}
+}
+
+func TestGrantScopeValidate(t *testing.T) {
+	cases := []struct {
+		scope   grantScope
+		wantErr error
+	}{
+		{grantScope{Accounts: "tags.team='payments'"}, nil},
+		{grantScope{Assets: "id='abcd'", Transactions: "inputs(account_tags.team='payments')"}, nil},
+		{grantScope{}, httpjson.ErrBadRequest},
+		{grantScope{Accounts: "tags.team=$1"}, filter.ErrBadFilter},
+		{grantScope{Outputs: "tags.team="}, filter.ErrBadFilter},
+	}
+	for i, c := range cases {
+		err := c.scope.validate()
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: error = %v, want %v", i, err, c.wantErr)
+		}
+	}
+}
+
+func TestForwardScopedSubmit(t *testing.T) {
+	payments := []byte(`{"accounts":"tags.team='payments'"}`)
+	authorizer := authz.NewAuthorizer(staticLoader{{GuardType: "any", Scope: payments}}, map[string][]string{"/": {"client-readwrite"}})
+	req, err := http.NewRequest("POST", "/submit-transaction", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RequestURI = req.URL.Path
+	req, err = authorizer.Authorize(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The scope has no assets, so the issuance is out of scope
+	// and nothing is forwarded to the leader.
+	tx := legacy.NewTx(legacy.TxData{
+		Version: 1,
+		Inputs: []*legacy.TxInput{
+			legacy.NewIssuanceInput([]byte{1}, 100, nil, bc.Hash{}, []byte{1}, nil, nil),
+		},
+	})
+	resp, err := new(API).forwardScopedSubmit(req.Context(), submitArg{Transactions: []txbuilder.Template{{Transaction: tx}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := resp.([]interface{})
+	if len(got) != 1 {
+		t.Fatalf("got %d responses, want 1", len(got))
+	}
+	if r, ok := got[0].(httperror.Response); !ok || r.ChainCode != "CH013" {
+		t.Errorf("response = %#v, want error CH013", got[0])
+	}
+}
@@ -0,0 +1,97 @@
+package signers
+
+import (
+	"context"
+	"encoding/binary"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/errors"
+)
+
+// ErrBadHardenedXPub is returned by CheckHardenedXPubs when a
+// hardened xpub isn't derived from its xpub, or that can't be
+// checked.
+var ErrBadHardenedXPub = errors.New("hardened xpub could not be verified")
+
+// A HardenedDeriver derives xpub along path with hardened
+// derivation, using a private key held locally. It returns
+// false if it doesn't hold the private key for xpub.
+type HardenedDeriver func(ctx context.Context, xpub chainkd.XPub, path [][]byte) (chainkd.XPub, bool, error)
+
+// CheckHardenedXPubs checks that each of hardenedXPubs is the
+// xpub at the same position in xpubs derived along path with
+// hardened derivation. A wrong hardened xpub would make the
+// signer's keys unspendable.
+//
+// Each xpub is derived by the first of derive that holds its
+// private key. If none of them does, proofs must hold, at the
+// same position, a signature of HardenedXPubProofHash by the
+// private key of the xpub itself. Only the holder of that key can
+// vouch for the derivation; a signature by the hardened xpub's
+// own key would show just that its signer holds some key.
+func CheckHardenedXPubs(ctx context.Context, xpubs []chainkd.XPub, path [][]byte, hardenedXPubs []chainkd.XPub, proofs [][]byte, derive []HardenedDeriver) error {
+	if len(hardenedXPubs) == 0 {
+		return nil
+	}
+	if len(path) == 0 || len(hardenedXPubs) != len(xpubs) {
+		return errors.Wrap(ErrBadHardened)
+	}
+	if len(proofs) > 0 && len(proofs) != len(xpubs) {
+		return errors.WithDetail(ErrBadHardenedXPub, "there must be a proof for each xpub, or none")
+	}
+
+	for i, xpub := range xpubs {
+		checked := false
+		for _, d := range derive {
+			want, ok, err := d(ctx, xpub, path)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if want != hardenedXPubs[i] {
+				return errors.WithDetailf(ErrBadHardenedXPub, "hardened xpub %d is not xpub %d derived along the path", i, i)
+			}
+			checked = true
+			break
+		}
+		if checked {
+			continue
+		}
+		if len(proofs) == 0 {
+			return errors.WithDetailf(ErrBadHardenedXPub, "the private key for xpub %d isn't held here, so a proof is required", i)
+		}
+		h := HardenedXPubProofHash(xpub, path, hardenedXPubs[i])
+		if !xpub.Verify(h[:], proofs[i]) {
+			return errors.WithDetailf(ErrBadHardenedXPub, "proof %d doesn't verify", i)
+		}
+	}
+	return nil
+}
+
+// HardenedXPubProofHash returns the hash that is signed by the
+// private key of xpub to attest that hardened is xpub derived
+// along path with hardened derivation. It is the SHA3-256 hash of the
+// string "ChainHardenedXPub", xpub, the number of elements in path,
+// each element of path prefixed with its length, and hardened,
+// with numbers encoded as uvarints.
+func HardenedXPubProofHash(xpub chainkd.XPub, path [][]byte, hardened chainkd.XPub) [32]byte {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+
+	var buf [binary.MaxVarintLen64]byte
+	h.Write([]byte("ChainHardenedXPub"))
+	h.Write(xpub[:])
+	h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(path)))])
+	for _, p := range path {
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(p)))])
+		h.Write(p)
+	}
+	h.Write(hardened[:])
+
+	var hash [32]byte
+	h.Read(hash[:])
+	return hash
+}
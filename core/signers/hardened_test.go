@@ -0,0 +1,56 @@
+package signers
+
+import (
+	"context"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+)
+
+func TestCheckHardenedXPubs(t *testing.T) {
+	ctx := context.Background()
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherXPub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := [][]byte{{0x2c, 0, 0, 0x80}, {0x01}}
+	accountXPrv := xprv.DeriveHardened(path, len(path))
+	accountXPub := accountXPrv.XPub()
+
+	local := func(ctx context.Context, k chainkd.XPub, p [][]byte) (chainkd.XPub, bool, error) {
+		if k != xpub {
+			return chainkd.XPub{}, false, nil
+		}
+		return xprv.DeriveHardened(p, len(p)).XPub(), true, nil
+	}
+	h := HardenedXPubProofHash(xpub, path, accountXPub)
+	proof := xprv.Sign(h[:])
+
+	cases := []struct {
+		hardened chainkd.XPub
+		proofs   [][]byte
+		derive   []HardenedDeriver
+		want     error
+	}{
+		{hardened: accountXPub, derive: []HardenedDeriver{local}},
+		{hardened: otherXPub, derive: []HardenedDeriver{local}, want: ErrBadHardenedXPub},
+		{hardened: accountXPub, proofs: [][]byte{proof}},
+		{hardened: accountXPub, want: ErrBadHardenedXPub},
+		{hardened: otherXPub, proofs: [][]byte{proof}, want: ErrBadHardenedXPub},
+		// A proof over a different path doesn't verify.
+		{hardened: accountXPub, proofs: [][]byte{xprv.Sign(make([]byte, 32))}, want: ErrBadHardenedXPub},
+		// Nor does one by the account xpub's own key.
+		{hardened: accountXPub, proofs: [][]byte{accountXPrv.Sign(h[:])}, want: ErrBadHardenedXPub},
+	}
+	for i, c := range cases {
+		err := CheckHardenedXPubs(ctx, []chainkd.XPub{xpub}, path, []chainkd.XPub{c.hardened}, c.proofs, c.derive)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: err = %v want %v", i, err, c.want)
+		}
+	}
+}
@@ -48,6 +48,11 @@ var (
 	// ErrDupeXPub is returned by create when the same xpub
 	// appears twice in a single call.
 	ErrDupeXPub = errors.New("xpubs cannot contain the same key more than once")
+
+	// ErrBadHardened is returned by Create when hardened xpubs
+	// are provided without a path prefix, or their number doesn't
+	// match the number of xpubs.
+	ErrBadHardened = errors.New("hardened derivation requires a path prefix and a hardened xpub for each xpub")
 )
 
 // Signer is the abstract concept of a signer,
@@ -59,14 +64,49 @@ type Signer struct {
 	XPubs    []chainkd.XPub
 	Quorum   int
 	KeyIndex uint64
+
+	// PathPrefix, if not empty, replaces the key space and key
+	// index at the start of the path of every key derived for the
+	// signer. It lets xpubs from external wallets be used with the
+	// derivation paths those wallets expect.
+	PathPrefix [][]byte
+
+	// HardenedXPubs, if not empty, holds for each of XPubs the xpub
+	// derived from it along PathPrefix with hardened derivation.
+	// The rest of each path is derived from HardenedXPubs, so that
+	// the leak of one derived private key exposes only this
+	// signer's keys, not XPubs.
+	HardenedXPubs []chainkd.XPub
+}
+
+// HardenedLevels returns the number of elements at the start of the
+// signer's paths that are derived with hardened derivation.
+func (s *Signer) HardenedLevels() int {
+	if len(s.HardenedXPubs) == 0 {
+		return 0
+	}
+	return len(s.PathPrefix)
+}
+
+// DeriveXPubs returns the signer's keys derived along path, which
+// must be a path returned by Path for s.
+func (s *Signer) DeriveXPubs(path [][]byte) []chainkd.XPub {
+	if n := s.HardenedLevels(); n > 0 {
+		return chainkd.DeriveXPubs(s.HardenedXPubs, path[n:])
+	}
+	return chainkd.DeriveXPubs(s.XPubs, path)
 }
 
 // Path returns the complete path for derived keys
 func Path(s *Signer, ks keySpace, itemIndexes ...uint64) [][]byte {
 	var path [][]byte
-	signerPath := [9]byte{byte(ks)}
-	binary.LittleEndian.PutUint64(signerPath[1:], s.KeyIndex)
-	path = append(path, signerPath[:])
+	if len(s.PathPrefix) > 0 {
+		path = append(path, s.PathPrefix...)
+	} else {
+		signerPath := [9]byte{byte(ks)}
+		binary.LittleEndian.PutUint64(signerPath[1:], s.KeyIndex)
+		path = append(path, signerPath[:])
+	}
 	for _, idx := range itemIndexes {
 		var idxBytes [8]byte
 		binary.LittleEndian.PutUint64(idxBytes[:], idx)
@@ -75,13 +115,24 @@ func Path(s *Signer, ks keySpace, itemIndexes ...uint64) [][]byte {
 	return path
 }
 
-// Create creates and stores a Signer in the database
-func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quorum int, clientToken string) (*Signer, error) {
+// Create creates and stores a Signer in the database.
+// If pathPrefix is empty, keys are derived with the signer's
+// key space and key index instead. If hardenedXPubs is not empty,
+// it must hold xpubs[i] derived along pathPrefix with hardened
+// derivation for each i. Create can't check this, since hardened
+// derivation needs the private keys; callers must check it first
+// with CheckHardenedXPubs.
+func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quorum int, pathPrefix [][]byte, hardenedXPubs []chainkd.XPub, clientToken string) (*Signer, error) {
 	if len(xpubs) == 0 {
 		return nil, errors.Wrap(ErrNoXPubs)
 	}
+	if len(hardenedXPubs) == 0 {
+		hardenedXPubs = nil
+	} else if len(pathPrefix) == 0 || len(hardenedXPubs) != len(xpubs) {
+		return nil, errors.Wrap(ErrBadHardened)
+	}
 
-	sort.Sort(sortKeys(xpubs)) // this transforms the input slice
+	sort.Sort(sortKeys{xpubs, hardenedXPubs}) // this transforms the input slices
 	for i := 1; i < len(xpubs); i++ {
 		if bytes.Equal(xpubs[i][:], xpubs[i-1][:]) {
 			return nil, errors.WithDetailf(ErrDupeXPub, "duplicated key=%x", xpubs[i])
@@ -92,20 +143,28 @@ func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quo
 		return nil, errors.Wrap(ErrBadQuorum)
 	}
 
-	var xpubBytes [][]byte
+	var xpubBytes, hardenedBytes [][]byte
 	for _, key := range xpubs {
 		key := key
 		xpubBytes = append(xpubBytes, key[:])
 	}
+	for _, key := range hardenedXPubs {
+		key := key
+		hardenedBytes = append(hardenedBytes, key[:])
+	}
 
 	nullToken := sql.NullString{
 		String: clientToken,
 		Valid:  clientToken != "",
 	}
 
+	if len(pathPrefix) == 0 {
+		pathPrefix = nil
+	}
+
 	const q = `
-		INSERT INTO signers (id, type, xpubs, quorum, client_token)
-		VALUES (next_chain_id($1::text), $2, $3, $4, $5)
+		INSERT INTO signers (id, type, xpubs, quorum, client_token, path_prefix, hardened_xpubs)
+		VALUES (next_chain_id($1::text), $2, $3, $4, $5, $6, $7)
 		ON CONFLICT (client_token) DO NOTHING
 		RETURNING id, key_index
   `
@@ -113,7 +172,7 @@ func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quo
 		id       string
 		keyIndex uint64
 	)
-	err := db.QueryRowContext(ctx, q, typeIDMap[typ], typ, pq.ByteaArray(xpubBytes), quorum, nullToken).
+	err := db.QueryRowContext(ctx, q, typeIDMap[typ], typ, pq.ByteaArray(xpubBytes), quorum, nullToken, pq.ByteaArray(pathPrefix), pq.ByteaArray(hardenedBytes)).
 		Scan(&id, &keyIndex)
 	if err == sql.ErrNoRows && clientToken != "" {
 		return findByClientToken(ctx, db, clientToken)
@@ -123,11 +182,13 @@ func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quo
 	}
 
 	return &Signer{
-		ID:       id,
-		Type:     typ,
-		XPubs:    xpubs,
-		Quorum:   quorum,
-		KeyIndex: keyIndex,
+		ID:            id,
+		Type:          typ,
+		XPubs:         xpubs,
+		Quorum:        quorum,
+		KeyIndex:      keyIndex,
+		PathPrefix:    pathPrefix,
+		HardenedXPubs: hardenedXPubs,
 	}, nil
 }
 
@@ -147,16 +208,17 @@ func New(id, typ string, xpubs [][]byte, quorum int, keyIndex uint64) (*Signer,
 
 func findByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Signer, error) {
 	const q = `
-		SELECT id, type, xpubs, quorum, key_index
+		SELECT id, type, xpubs, quorum, key_index, path_prefix, hardened_xpubs
 		FROM signers WHERE client_token=$1
 	`
 
 	var (
-		s         Signer
-		xpubBytes [][]byte
+		s             Signer
+		xpubBytes     [][]byte
+		hardenedBytes [][]byte
 	)
 	err := db.QueryRowContext(ctx, q, clientToken).
-		Scan(&s.ID, &s.Type, (*pq.ByteaArray)(&xpubBytes), &s.Quorum, &s.KeyIndex)
+		Scan(&s.ID, &s.Type, (*pq.ByteaArray)(&xpubBytes), &s.Quorum, &s.KeyIndex, (*pq.ByteaArray)(&s.PathPrefix), (*pq.ByteaArray)(&hardenedBytes))
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
@@ -165,8 +227,13 @@ func findByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Sign
 	if err != nil {
 		return nil, errors.WithDetail(errors.New("bad xpub in databse"), errors.Detail(err))
 	}
+	hardened, err := ConvertKeys(hardenedBytes)
+	if err != nil {
+		return nil, errors.WithDetail(errors.New("bad hardened xpub in databse"), errors.Detail(err))
+	}
 
 	s.XPubs = keys
+	s.HardenedXPubs = hardened
 
 	return &s, nil
 }
@@ -175,13 +242,14 @@ func findByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Sign
 // using the type and id.
 func Find(ctx context.Context, db pg.DB, typ, id string) (*Signer, error) {
 	const q = `
-		SELECT id, type, xpubs, quorum, key_index
+		SELECT id, type, xpubs, quorum, key_index, path_prefix, hardened_xpubs
 		FROM signers WHERE id=$1
 	`
 
 	var (
-		s         Signer
-		xpubBytes [][]byte
+		s             Signer
+		xpubBytes     [][]byte
+		hardenedBytes [][]byte
 	)
 	err := db.QueryRowContext(ctx, q, id).Scan(
 		&s.ID,
@@ -189,6 +257,8 @@ func Find(ctx context.Context, db pg.DB, typ, id string) (*Signer, error) {
 		(*pq.ByteaArray)(&xpubBytes),
 		&s.Quorum,
 		&s.KeyIndex,
+		(*pq.ByteaArray)(&s.PathPrefix),
+		(*pq.ByteaArray)(&hardenedBytes),
 	)
 	if err == sql.ErrNoRows {
 		return nil, errors.Wrap(pg.ErrUserInputNotFound)
@@ -205,8 +275,13 @@ func Find(ctx context.Context, db pg.DB, typ, id string) (*Signer, error) {
 	if err != nil {
 		return nil, errors.WithDetail(errors.New("bad xpub in databse"), errors.Detail(err))
 	}
+	hardened, err := ConvertKeys(hardenedBytes)
+	if err != nil {
+		return nil, errors.WithDetail(errors.New("bad hardened xpub in databse"), errors.Detail(err))
+	}
 
 	s.XPubs = keys
+	s.HardenedXPubs = hardened
 
 	return &s, nil
 }
@@ -215,25 +290,31 @@ func Find(ctx context.Context, db pg.DB, typ, id string) (*Signer, error) {
 // the provided type.
 func List(ctx context.Context, db pg.DB, typ, prev string, limit int) ([]*Signer, string, error) {
 	const q = `
-		SELECT id, type, xpubs, quorum, key_index
+		SELECT id, type, xpubs, quorum, key_index, path_prefix, hardened_xpubs
 		FROM signers WHERE type=$1 AND ($2='' OR $2<id)
 		ORDER BY id ASC LIMIT $3
 	`
 
 	var signers []*Signer
 	err := pg.ForQueryRows(ctx, db, q, typ, prev, limit,
-		func(id, typ string, xpubs pq.ByteaArray, quorum int, keyIndex uint64) error {
+		func(id, typ string, xpubs pq.ByteaArray, quorum int, keyIndex uint64, pathPrefix, hardenedXPubs pq.ByteaArray) error {
 			keys, err := ConvertKeys(xpubs)
 			if err != nil {
 				return errors.WithDetail(errors.New("bad xpub in databse"), errors.Detail(err))
 			}
+			hardened, err := ConvertKeys(hardenedXPubs)
+			if err != nil {
+				return errors.WithDetail(errors.New("bad hardened xpub in databse"), errors.Detail(err))
+			}
 
 			signers = append(signers, &Signer{
-				ID:       id,
-				Type:     typ,
-				XPubs:    keys,
-				Quorum:   quorum,
-				KeyIndex: keyIndex,
+				ID:            id,
+				Type:          typ,
+				XPubs:         keys,
+				Quorum:        quorum,
+				KeyIndex:      keyIndex,
+				PathPrefix:    pathPrefix,
+				HardenedXPubs: hardened,
 			})
 			return nil
 		},
@@ -264,8 +345,17 @@ func ConvertKeys(xpubs [][]byte) ([]chainkd.XPub, error) {
 	return xkeys, nil
 }
 
-type sortKeys []chainkd.XPub
+// sortKeys sorts xpubs, keeping hardened, if it's not nil, in the
+// same order.
+type sortKeys struct {
+	xpubs, hardened []chainkd.XPub
+}
 
-func (s sortKeys) Len() int           { return len(s) }
-func (s sortKeys) Less(i, j int) bool { return bytes.Compare(s[i][:], s[j][:]) < 0 }
-func (s sortKeys) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortKeys) Len() int           { return len(s.xpubs) }
+func (s sortKeys) Less(i, j int) bool { return bytes.Compare(s.xpubs[i][:], s.xpubs[j][:]) < 0 }
+func (s sortKeys) Swap(i, j int) {
+	s.xpubs[i], s.xpubs[j] = s.xpubs[j], s.xpubs[i]
+	if s.hardened != nil {
+		s.hardened[i], s.hardened[j] = s.hardened[j], s.hardened[i]
+	}
+}
@@ -75,7 +75,7 @@ func TestCreate(t *testing.T) {
 	}}
 
 	for i, c := range cases {
-		s, gotErr := Create(ctx, db, c.typ, c.xpubs, c.quorum, "")
+		s, gotErr := Create(ctx, db, c.typ, c.xpubs, c.quorum, nil, nil, "")
 
 		if errors.Root(gotErr) != c.want {
 			t.Errorf("case %d: Create(%s, %v, %d) = %q want %q", i, c.typ, c.xpubs, c.quorum, errors.Root(gotErr), c.want)
@@ -117,6 +117,92 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestPath(t *testing.T) {
+	s := &Signer{KeyIndex: 2}
+	got := Path(s, AccountKeySpace, 3)
+	want := [][]byte{
+		{1, 2, 0, 0, 0, 0, 0, 0, 0},
+		{3, 0, 0, 0, 0, 0, 0, 0},
+	}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("Path = %x, want %x", got, want)
+	}
+
+	s.PathPrefix = [][]byte{{0x2c}, {0x00, 0x01}}
+	got = Path(s, AccountKeySpace, 3)
+	want = [][]byte{
+		{0x2c},
+		{0x00, 0x01},
+		{3, 0, 0, 0, 0, 0, 0, 0},
+	}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("Path with prefix = %x, want %x", got, want)
+	}
+}
+
+func TestDeriveXPubs(t *testing.T) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Signer{XPubs: []chainkd.XPub{xpub}, PathPrefix: [][]byte{{0x2c}, {0x01}}}
+	path := Path(s, AccountKeySpace, 3)
+	if s.HardenedLevels() != 0 {
+		t.Errorf("HardenedLevels = %d, want 0", s.HardenedLevels())
+	}
+	if got, want := s.DeriveXPubs(path)[0], xprv.Derive(path).XPub(); got != want {
+		t.Errorf("DeriveXPubs = %x, want %x", got[:], want[:])
+	}
+
+	s.HardenedXPubs = []chainkd.XPub{xprv.DeriveHardened(s.PathPrefix, 2).XPub()}
+	if s.HardenedLevels() != 2 {
+		t.Errorf("hardened HardenedLevels = %d, want 2", s.HardenedLevels())
+	}
+	if got, want := s.DeriveXPubs(path)[0], xprv.DeriveHardened(path, 2).XPub(); got != want {
+		t.Errorf("hardened DeriveXPubs = %x, want %x", got[:], want[:])
+	}
+}
+
+func TestCreateHardened(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	prefix := [][]byte{{0x2c}, {0x01}}
+	xpubs := []chainkd.XPub{dummyXPub, testutil.TestXPub}
+	hardened := []chainkd.XPub{testutil.TestXPub.Child([]byte{1}), dummyXPub.Child([]byte{2})}
+
+	_, err := Create(ctx, db, "account", xpubs, 1, nil, hardened, "")
+	if errors.Root(err) != ErrBadHardened {
+		t.Errorf("Create without path prefix got error %v, want %v", err, ErrBadHardened)
+	}
+	_, err = Create(ctx, db, "account", xpubs, 1, prefix, hardened[:1], "")
+	if errors.Root(err) != ErrBadHardened {
+		t.Errorf("Create with too few hardened xpubs got error %v, want %v", err, ErrBadHardened)
+	}
+
+	wantPairs := map[chainkd.XPub]chainkd.XPub{
+		xpubs[0]: hardened[0],
+		xpubs[1]: hardened[1],
+	}
+	s, err := Create(ctx, db, "account", xpubs, 1, prefix, hardened, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	s, err = Find(ctx, db, "account", s.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(s.HardenedXPubs) != len(s.XPubs) {
+		t.Fatalf("found %d hardened xpubs, want %d", len(s.HardenedXPubs), len(s.XPubs))
+	}
+	// Sorting the xpubs keeps each paired with its hardened xpub.
+	for i, xpub := range s.XPubs {
+		if want := wantPairs[xpub]; s.HardenedXPubs[i] != want {
+			t.Errorf("hardened xpub for %x = %x, want %x", xpub[:], s.HardenedXPubs[i][:], want[:])
+		}
+	}
+}
+
 func TestCreateIdempotency(t *testing.T) {
 	ctx := context.Background()
 	db := pgtest.NewTx(t)
@@ -128,6 +214,8 @@ func TestCreateIdempotency(t *testing.T) {
 		"account",
 		[]chainkd.XPub{testutil.TestXPub},
 		1,
+		nil,
+		nil,
 		clientToken,
 	)
 
@@ -141,6 +229,8 @@ func TestCreateIdempotency(t *testing.T) {
 		"account",
 		[]chainkd.XPub{testutil.TestXPub},
 		1,
+		nil,
+		nil,
 		clientToken,
 	)
 
@@ -247,6 +337,8 @@ func createFixture(ctx context.Context, db pg.DB, t testing.TB) *Signer {
 		"account",
 		[]chainkd.XPub{testutil.TestXPub},
 		1,
+		nil,
+		nil,
 		clientToken,
 	)
 
@@ -0,0 +1,285 @@
+// Package signsession coordinates the signing of a transaction
+// template by several parties, as needed to spend from accounts or
+// issue assets whose signers have a quorum greater than one.
+//
+// A session holds a template. Each participant fetches it, signs it
+// with their own keys, and submits it back; the signatures they add
+// are verified and merged into the session's template. Once every
+// witness component's quorum is met, the session is complete and
+// Core can submit its transaction. A session expires at the max
+// time of its transaction, after which it accepts no signatures.
+package signsession
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+// Session statuses.
+const (
+	StatusPending   = "pending"
+	StatusComplete  = "complete"
+	StatusSubmitted = "submitted"
+	StatusExpired   = "expired"
+)
+
+// cleanUpAge is how long after their expiration sessions are kept.
+const cleanUpAge = 24 * time.Hour
+
+var (
+	ErrDuplicateAlias = errors.New("duplicate signing session alias")
+	ErrNoMaxTime      = errors.New("template has no max time")
+	ErrExpired        = errors.New("signing session expired")
+)
+
+// Store stores signing sessions in Postgres.
+type Store struct {
+	DB pg.DB
+}
+
+// Session is a template being signed by several parties.
+type Session struct {
+	ID        string                     `json:"id"`
+	Alias     *string                    `json:"alias"`
+	Status    string                     `json:"status"`
+	Template  *txbuilder.Template        `json:"template"`
+	Witnesses []*txbuilder.WitnessStatus `json:"witness_components"`
+	ExpiresAt time.Time                  `json:"expires_at"`
+	TxID      *bc.Hash                   `json:"transaction_id,omitempty"`
+
+	// version is incremented on every update, so that concurrent
+	// updates don't overwrite each other's signatures.
+	version int64
+}
+
+// Create starts a signing session for tpl, after verifying any
+// signatures it already holds. If clientToken is not empty and a
+// session with the same client token exists, Create returns that
+// session instead.
+func (s *Store) Create(ctx context.Context, tpl *txbuilder.Template, alias, clientToken string) (*Session, error) {
+	if tpl.Transaction == nil {
+		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+	if tpl.Transaction.MaxTime == 0 {
+		return nil, errors.Wrap(ErrNoMaxTime)
+	}
+	err := txbuilder.VerifySignatures(tpl)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(tpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling template")
+	}
+
+	const q = `
+		INSERT INTO signing_sessions (alias, template, expires_at, client_token)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (client_token) DO NOTHING
+		RETURNING id
+	`
+	var id string
+	expiresAt := time.Unix(0, int64(tpl.Transaction.MaxTime)*int64(time.Millisecond))
+	err = s.DB.QueryRowContext(ctx, q,
+		sql.NullString{String: alias, Valid: alias != ""}, b, expiresAt,
+		sql.NullString{String: clientToken, Valid: clientToken != ""},
+	).Scan(&id)
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.WithDetailf(ErrDuplicateAlias, "value: %q", alias)
+	} else if err == sql.ErrNoRows && clientToken != "" {
+		return s.find(ctx, "client_token", clientToken)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "inserting signing session")
+	}
+	return s.find(ctx, "id", id)
+}
+
+// Find returns the session with the given id or, if id is empty,
+// the given alias.
+func (s *Store) Find(ctx context.Context, id, alias string) (*Session, error) {
+	if id != "" {
+		return s.find(ctx, "id", id)
+	}
+	return s.find(ctx, "alias", alias)
+}
+
+func (s *Store) find(ctx context.Context, col, val string) (*Session, error) {
+	q := `
+		SELECT id, alias, template, expires_at, tx_id, version
+		FROM signing_sessions WHERE ` + col + `=$1
+	`
+	sess, err := scanSession(s.DB.QueryRowContext(ctx, q, val))
+	if err == sql.ErrNoRows {
+		err = errors.Sub(pg.ErrUserInputNotFound, err)
+		return nil, errors.WithDetailf(err, "%s: %s", col, val)
+	}
+	return sess, err
+}
+
+// List returns sessions in reverse order of creation, starting after
+// the session with ID after.
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*Session, string, error) {
+	const q = `
+		SELECT id, alias, template, expires_at, tx_id, version
+		FROM signing_sessions
+		WHERE ($1='' OR id < $1) ORDER BY id DESC LIMIT $2
+	`
+	rows, err := s.DB.QueryContext(ctx, q, after, limit)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing signing sessions")
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0, limit)
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		after = sess.ID
+		sessions = append(sessions, sess)
+	}
+	return sessions, after, errors.Wrap(rows.Err())
+}
+
+// AddSignatures verifies the signatures in tpl that the session
+// with the given id or alias lacks, and adds them to it. It returns
+// the updated session.
+//
+// Signatures can't be added to an expired session. Adding them to a
+// session that has already been submitted has no effect.
+func (s *Store) AddSignatures(ctx context.Context, id, alias string, tpl *txbuilder.Template) (*Session, error) {
+	for {
+		sess, err := s.Find(ctx, id, alias)
+		if err != nil {
+			return nil, err
+		}
+		switch sess.Status {
+		case StatusSubmitted:
+			return sess, nil
+		case StatusExpired:
+			return nil, errors.WithDetailf(ErrExpired, "expired at %s", sess.ExpiresAt.Format(time.RFC3339))
+		}
+
+		n, err := txbuilder.MergeSignatures(sess.Template, tpl)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return sess, nil
+		}
+		b, err := json.Marshal(sess.Template)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshaling template")
+		}
+
+		const q = `
+			UPDATE signing_sessions SET template=$1, version=version+1
+			WHERE id=$2 AND version=$3
+		`
+		res, err := s.DB.ExecContext(ctx, q, b, sess.ID, sess.version)
+		if err != nil {
+			return nil, errors.Wrap(err, "updating signing session")
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if affected == 1 {
+			sess.version++
+			sess.setStatus()
+			return sess, nil
+		}
+		// Another participant's signatures were added concurrently.
+		// Merge ours into the new version of the session.
+	}
+}
+
+// MarkSubmitted records that the transaction of sess has been
+// submitted.
+func (s *Store) MarkSubmitted(ctx context.Context, sess *Session) error {
+	txID := sess.Template.Transaction.ID
+	const q = `UPDATE signing_sessions SET tx_id=$1 WHERE id=$2`
+	_, err := s.DB.ExecContext(ctx, q, txID.Bytes(), sess.ID)
+	if err != nil {
+		return errors.Wrap(err, "marking signing session submitted")
+	}
+	sess.TxID = &txID
+	sess.setStatus()
+	return nil
+}
+
+// CleanUp periodically deletes sessions that expired more than a day
+// ago. It blocks until ctx is canceled.
+func (s *Store) CleanUp(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	for {
+		select {
+		case <-ticker.C:
+			const q = `DELETE FROM signing_sessions WHERE expires_at < $1`
+			_, err := s.DB.ExecContext(ctx, q, time.Now().Add(-cleanUpAge))
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+type scanner interface {
+	Scan(...interface{}) error
+}
+
+func scanSession(row scanner) (*Session, error) {
+	var (
+		sess  Session
+		alias sql.NullString
+		b     []byte
+		txID  []byte
+	)
+	err := row.Scan(&sess.ID, &alias, &b, &sess.ExpiresAt, &txID, &sess.version)
+	if err != nil {
+		return nil, err
+	}
+	if alias.Valid {
+		sess.Alias = &alias.String
+	}
+	if txID != nil {
+		var b32 [32]byte
+		copy(b32[:], txID)
+		h := bc.NewHash(b32)
+		sess.TxID = &h
+	}
+	sess.Template = new(txbuilder.Template)
+	err = json.Unmarshal(b, sess.Template)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshaling template of signing session %s", sess.ID)
+	}
+	sess.setStatus()
+	return &sess, nil
+}
+
+func (sess *Session) setStatus() {
+	var complete bool
+	sess.Witnesses, complete = txbuilder.SigningStatus(sess.Template)
+	switch {
+	case sess.TxID != nil:
+		sess.Status = StatusSubmitted
+	case time.Now().After(sess.ExpiresAt):
+		sess.Status = StatusExpired
+	case complete:
+		sess.Status = StatusComplete
+	default:
+		sess.Status = StatusPending
+	}
+}
@@ -0,0 +1,145 @@
+package signsession
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/testutil"
+)
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	store := &Store{DB: pgtest.NewTx(t)}
+
+	var (
+		xprvs []chainkd.XPrv
+		xpubs []chainkd.XPub
+	)
+	for i := 0; i < 3; i++ {
+		xprv, xpub, err := chainkd.NewXKeys(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xprvs = append(xprvs, xprv)
+		xpubs = append(xpubs, xpub)
+	}
+	tpl := newTemplate(xpubs, time.Now().Add(time.Hour))
+
+	sess, err := store.Create(ctx, tpl, "treasury", "token")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if sess.Status != StatusPending {
+		t.Errorf("new session status = %s, want %s", sess.Status, StatusPending)
+	}
+	again, err := store.Create(ctx, tpl, "treasury", "token")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if again.ID != sess.ID {
+		t.Errorf("Create with same client token got session %s, want %s", again.ID, sess.ID)
+	}
+	_, err = store.Create(ctx, tpl, "treasury", "")
+	if errors.Root(err) != ErrDuplicateAlias {
+		t.Errorf("Create with duplicate alias got error %v, want %v", err, ErrDuplicateAlias)
+	}
+
+	for i, want := range []string{StatusPending, StatusComplete} {
+		sess, err = store.AddSignatures(ctx, "", "treasury", sign(t, sess.Template, xprvs[i], xpubs[i]))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if sess.Status != want {
+			t.Errorf("status after %d signatures = %s, want %s", i+1, sess.Status, want)
+		}
+	}
+	sess, err = store.Find(ctx, sess.ID, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(sess.Witnesses) != 1 || len(sess.Witnesses[0].Signed) != 2 || !sess.Witnesses[0].Satisfied {
+		t.Errorf("stored witness status = %+v, want 2 signatures, satisfied", sess.Witnesses)
+	}
+
+	err = store.MarkSubmitted(ctx, sess)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	sessions, _, err := store.List(ctx, "", 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(sessions) != 1 || sessions[0].Status != StatusSubmitted || *sessions[0].TxID != tpl.Transaction.ID {
+		t.Errorf("List got %+v, want one submitted session", sessions)
+	}
+}
+
+func TestExpiredSession(t *testing.T) {
+	ctx := context.Background()
+	store := &Store{DB: pgtest.NewTx(t)}
+
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := newTemplate([]chainkd.XPub{xpub}, time.Now().Add(-time.Minute))
+	sess, err := store.Create(ctx, tpl, "", "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if sess.Status != StatusExpired {
+		t.Errorf("session status = %s, want %s", sess.Status, StatusExpired)
+	}
+	_, err = store.AddSignatures(ctx, sess.ID, "", sign(t, sess.Template, xprv, xpub))
+	if errors.Root(err) != ErrExpired {
+		t.Errorf("AddSignatures to expired session got error %v, want %v", err, ErrExpired)
+	}
+}
+
+func newTemplate(xpubs []chainkd.XPub, maxTime time.Time) *txbuilder.Template {
+	tpl := &txbuilder.Template{
+		Transaction: legacy.NewTx(legacy.TxData{
+			Version: 1,
+			Inputs: []*legacy.TxInput{
+				legacy.NewSpendInput(nil, bc.Hash{}, bc.AssetID{}, 123, 0, nil, bc.Hash{}, nil),
+			},
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(bc.AssetID{}, 123, []byte{10, 11, 12}, nil),
+			},
+			MaxTime: bc.Millis(maxTime),
+		}),
+	}
+	si := &txbuilder.SigningInstruction{}
+	si.AddWitnessKeys(xpubs, [][]byte{{1}}, 2)
+	tpl.SigningInstructions = []*txbuilder.SigningInstruction{si}
+	return tpl
+}
+
+// sign returns a copy of tpl signed with xprv, as a participant
+// would produce it.
+func sign(t *testing.T, tpl *txbuilder.Template, xprv chainkd.XPrv, xpub chainkd.XPub) *txbuilder.Template {
+	b, err := json.Marshal(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := new(txbuilder.Template)
+	err = json.Unmarshal(b, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = txbuilder.Sign(context.Background(), cp, []chainkd.XPub{xpub}, func(_ context.Context, _ chainkd.XPub, path [][]byte, hardened int, _ *chainkd.XPub, h [32]byte) ([]byte, error) {
+		return xprv.DeriveHardened(path, hardened).Sign(h[:]), nil
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	return cp
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chain/core/leader"
+	"chain/core/signsession"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+const cleanUpSigningSessionsPeriod = 15 * time.Minute
+
+// POST /create-signing-session
+func (a *API) createSigningSession(ctx context.Context, in struct {
+	Alias    string              `json:"alias"`
+	Template *txbuilder.Template `json:"template"`
+
+	// ClientToken is the application's unique token for the session.
+	// Duplicate create requests with the same client_token will only
+	// create one session.
+	ClientToken string `json:"client_token"`
+}) (json.RawMessage, error) {
+	if a.leader.State() != leader.Leading {
+		var resp json.RawMessage
+		err := a.forwardToLeader(ctx, "/create-signing-session", in, &resp)
+		return resp, err
+	}
+	if in.Template == nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "missing template")
+	}
+	sess, err := a.signSessions.Create(ctx, in.Template, in.Alias, in.ClientToken)
+	if err != nil {
+		return nil, err
+	}
+	return a.submitSigningSession(ctx, sess)
+}
+
+// POST /get-signing-session
+func (a *API) getSigningSession(ctx context.Context, in struct {
+	ID    string `json:"id,omitempty"`
+	Alias string `json:"alias,omitempty"`
+}) (*signsession.Session, error) {
+	return a.signSessions.Find(ctx, in.ID, in.Alias)
+}
+
+// POST /list-signing-sessions
+func (a *API) listSigningSessions(ctx context.Context, in requestQuery) (page, error) {
+	limit := in.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	sessions, after, err := a.signSessions.List(ctx, in.After, limit)
+	if err != nil {
+		return page{}, errors.Wrap(err, "listing signing sessions")
+	}
+
+	out := in
+	out.After = after
+	return page{
+		Items:    httpjson.Array(sessions),
+		LastPage: len(sessions) < limit,
+		Next:     out,
+	}, nil
+}
+
+// addSigningSessionSignatures adds the signatures in a template
+// signed by one of a session's participants to the session. If
+// that completes the session, its transaction is submitted.
+//
+// POST /add-signing-session-signatures
+func (a *API) addSigningSessionSignatures(ctx context.Context, in struct {
+	ID       string              `json:"id,omitempty"`
+	Alias    string              `json:"alias,omitempty"`
+	Template *txbuilder.Template `json:"template"`
+}) (json.RawMessage, error) {
+	if a.leader.State() != leader.Leading {
+		var resp json.RawMessage
+		err := a.forwardToLeader(ctx, "/add-signing-session-signatures", in, &resp)
+		return resp, err
+	}
+	if in.Template == nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "missing template")
+	}
+	sess, err := a.signSessions.AddSignatures(ctx, in.ID, in.Alias, in.Template)
+	if err != nil {
+		return nil, err
+	}
+	return a.submitSigningSession(ctx, sess)
+}
+
+// submitSigningSession submits the transaction of sess if its
+// signatures are complete, and returns sess encoded as JSON.
+// If submission fails, the session keeps its signatures, and
+// submission is attempted again on the next call to
+// /add-signing-session-signatures.
+func (a *API) submitSigningSession(ctx context.Context, sess *signsession.Session) (json.RawMessage, error) {
+	if sess.Status == signsession.StatusComplete {
+		err := a.finalizeTxWait(ctx, sess.Template, "none")
+		if err != nil {
+			return nil, errors.Wrapf(err, "submitting signing session %s", sess.ID)
+		}
+		err = a.signSessions.MarkSubmitted(ctx, sess)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(sess)
+}
@@ -1,10 +1,16 @@
 package core
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"io/ioutil"
+	stdnet "net"
 	"os"
+	"sync"
+	"time"
 
 	"chain/errors"
 	"chain/net"
@@ -12,6 +18,65 @@ import (
 
 var ErrNoTLS = errors.New("no TLS configuration available")
 
+// prevCertGrace is how long the previous local cert
+// stays trusted after a new one is loaded.
+const prevCertGrace = time.Hour
+
+// TLSOptions holds TLS settings in addition to
+// the certificate and key.
+type TLSOptions struct {
+	// RootCAs names a file containing a list of
+	// trusted root CA certs. See TLSConfig.
+	RootCAs string
+
+	// CRL names a file containing PEM- or DER-encoded
+	// certificate revocation lists. Client certificates
+	// listed as revoked are not accepted for authentication.
+	CRL string
+
+	// MinVersion and CipherSuites override the defaults
+	// from net.DefaultTLSConfig, if set.
+	MinVersion   uint16
+	CipherSuites []uint16
+}
+
+// TLSReloader holds a TLS certificate and key, trusted
+// root CAs, and revocation lists, all read from files.
+// Reload reads the files again, so certificates can be
+// rotated without restarting the process.
+// Configs returned by Config and connections
+// made with DialTLS use the most recently loaded files.
+type TLSReloader struct {
+	certFile, keyFile string
+	opts              TLSOptions
+
+	mu         sync.RWMutex // protects the following
+	cert       *tls.Certificate
+	leaf       *x509.Certificate
+	roots      *x509.CertPool      // trusted CAs and leaf
+	crlSigners []*x509.Certificate // trusted CAs and the chain of leaf
+	crls       []*pkix.CertificateList
+	modTimes   map[string]time.Time
+
+	// prevLeaf is the cert loaded before leaf, or nil.
+	// Until prevUntil, it is trusted along with roots.
+	prevLeaf      *x509.Certificate
+	prevUntil     time.Time
+	rootsWithPrev *x509.CertPool
+}
+
+// LoadTLS reads a PEM-encoded X.509 certificate and private key
+// from certFile and keyFile, along with the files named in opts.
+// See TLSConfig for how the files are interpreted.
+func LoadTLS(certFile, keyFile string, opts TLSOptions) (*TLSReloader, error) {
+	r := &TLSReloader{certFile: certFile, keyFile: keyFile, opts: opts}
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 // TLSConfig returns a TLS config suitable for use
 // as a Chain Core client and server.
 // It reads a PEM-encoded X.509 certificate and private key
@@ -30,6 +95,213 @@ var ErrNoTLS = errors.New("no TLS configuration available")
 // and the environment vars are both unset,
 // TLSConfig returns ErrNoTLS.
 func TLSConfig(certFile, keyFile, rootCAs string) (*tls.Config, error) {
+	r, err := LoadTLS(certFile, keyFile, TLSOptions{RootCAs: rootCAs})
+	if err != nil {
+		return nil, err
+	}
+	return r.Config(), nil
+}
+
+// Reload reads the certificate, key, root CA, and
+// revocation list files again. If any of them can't be
+// read, Reload returns an error and keeps using the
+// previously loaded files.
+func (r *TLSReloader) Reload() error {
+	modTimes := r.statFiles()
+
+	cert, certErr := ioutil.ReadFile(r.certFile)
+	key, keyErr := ioutil.ReadFile(r.keyFile)
+	if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
+		cert, key = []byte(os.Getenv("TLSCRT")), []byte(os.Getenv("TLSKEY"))
+	} else if certErr != nil {
+		return certErr
+	} else if keyErr != nil {
+		return keyErr
+	}
+	if len(cert) == 0 && len(key) == 0 {
+		return ErrNoTLS
+	}
+
+	pair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	// X509KeyPair doesn't keep a copy of the leaf cert,
+	// so we need to parse it again here.
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	pair.Leaf = leaf
+
+	cas, err := loadRootCAs(r.opts.RootCAs)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	crlSigners := cas
+	for _, der := range pair.Certificate[1:] {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		crlSigners = append(crlSigners, c)
+	}
+	crls, err := loadCRLs(r.opts.CRL)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Peers in the cluster use the same identity, and so
+	// the same cert, so we automatically trust the local cert.
+	// This makes misconfiguation impossible.
+	// While certs are being rotated across the cluster,
+	// some peers will still present the old one, so we
+	// trust that too, for a while, until the next reload.
+	roots := certPool(cas, leaf)
+	r.prevLeaf, r.rootsWithPrev = nil, nil
+	if r.leaf != nil && !r.leaf.Equal(leaf) {
+		r.prevLeaf = r.leaf
+		r.prevUntil = time.Now().Add(prevCertGrace)
+		r.rootsWithPrev = certPool(cas, leaf, r.prevLeaf)
+	}
+
+	r.cert = &pair
+	r.leaf = leaf
+	r.roots = roots
+	r.crlSigners = crlSigners
+	r.crls = crls
+	r.modTimes = modTimes
+	return nil
+}
+
+func certPool(cas []*x509.Certificate, more ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, c := range cas {
+		pool.AddCert(c)
+	}
+	for _, c := range more {
+		pool.AddCert(c)
+	}
+	return pool
+}
+
+// ReloadIfChanged calls Reload if any of the files
+// have been modified since they were last loaded.
+// It reports whether it reloaded the files.
+func (r *TLSReloader) ReloadIfChanged() (bool, error) {
+	modTimes := r.statFiles()
+	r.mu.RLock()
+	changed := false
+	for name, t := range modTimes {
+		if !t.Equal(r.modTimes[name]) {
+			changed = true
+		}
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	return true, r.Reload()
+}
+
+func (r *TLSReloader) statFiles() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, name := range []string{r.certFile, r.keyFile, r.opts.RootCAs, r.opts.CRL} {
+		if name == "" {
+			continue
+		}
+		var t time.Time // zero if the file doesn't exist
+		if fi, err := os.Stat(name); err == nil {
+			t = fi.ModTime()
+		}
+		modTimes[name] = t
+	}
+	return modTimes
+}
+
+// Leaf returns the currently loaded certificate.
+func (r *TLSReloader) Leaf() *x509.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.leaf
+}
+
+// RootCAs returns the currently trusted root CAs.
+// They are used to verify both server and client certs.
+func (r *TLSReloader) RootCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rootsLocked()
+}
+
+func (r *TLSReloader) rootsLocked() *x509.CertPool {
+	if r.prevLeaf != nil && time.Now().Before(r.prevUntil) {
+		return r.rootsWithPrev
+	}
+	return r.roots
+}
+
+// Revoked reports whether cert appears in any of the loaded
+// revocation lists from the CA that issued it.
+// Issuer is the cert that signed cert in its verified chain.
+// A list also applies if it's signed by one of the trusted CAs,
+// or one of the certs in the local cert's chain, with the name
+// of cert's issuer. That way, the local cert is checked against
+// its CA's list even though it's trusted directly.
+func (r *TLSReloader) Revoked(cert, issuer *x509.Certificate) bool {
+	r.mu.RLock()
+	crls := r.crls
+	signers := append([]*x509.Certificate{issuer}, r.crlSigners...)
+	r.mu.RUnlock()
+	for _, crl := range crls {
+		if !crlIssuedFor(crl, cert, signers) {
+			continue
+		}
+		for _, rc := range crl.TBSCertList.RevokedCertificates {
+			if rc.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// crlIssuedFor reports whether crl is signed by one of signers
+// with the name of the issuer of cert.
+func crlIssuedFor(crl *pkix.CertificateList, cert *x509.Certificate, signers []*x509.Certificate) bool {
+	for _, s := range signers {
+		if bytes.Equal(s.RawSubject, cert.RawIssuer) && s.CheckCRLSignature(crl) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Config returns a TLS config suitable for use
+// as a Chain Core client and server.
+// As a server, it presents the currently loaded
+// cert and requests client certs from the currently
+// trusted CAs. As a client, it presents the currently
+// loaded cert, but it verifies servers using the roots
+// trusted when Config was called; use DialTLS to
+// make connections that use the current roots.
+func (r *TLSReloader) Config() *tls.Config {
+	config := r.config()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return r.config(), nil
+	}
+	config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.cert, nil
+	}
+	return config
+}
+
+func (r *TLSReloader) config() *tls.Config {
 	config := net.DefaultTLSConfig()
 
 	// This is the default set of protocols for package http.
@@ -41,62 +313,139 @@ func TLSConfig(certFile, keyFile, rootCAs string) (*tls.Config, error) {
 	//NextProtos: []string{"http/1.1", "h2"},
 	config.ClientAuth = tls.RequestClientCert
 
-	cert, certErr := ioutil.ReadFile(certFile)
-	key, keyErr := ioutil.ReadFile(keyFile)
-	if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
-		cert, key = []byte(os.Getenv("TLSCRT")), []byte(os.Getenv("TLSKEY"))
-	} else if certErr != nil {
-		return nil, certErr
-	} else if keyErr != nil {
-		return nil, keyErr
+	if r.opts.MinVersion != 0 {
+		config.MinVersion = r.opts.MinVersion
 	}
-	if len(cert) == 0 && len(key) == 0 {
-		return nil, ErrNoTLS
+	config.CipherSuites = r.opts.CipherSuites
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	config.Certificates = []tls.Certificate{*r.cert}
+	config.RootCAs = r.rootsLocked()
+	config.ClientCAs = config.RootCAs
+
+	// Servers' certs are verified by the TLS handshake;
+	// client certs are verified by package authn.
+	config.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+		for _, chain := range chains {
+			if r.Revoked(chain[0], chainIssuer(chain)) {
+				return errors.New("tls: certificate has been revoked")
+			}
+		}
+		return nil
 	}
+	return config
+}
 
-	var err error
-	config.Certificates = make([]tls.Certificate, 1)
-	config.Certificates[0], err = tls.X509KeyPair(cert, key)
-	if err != nil {
-		return nil, errors.Wrap(err)
+// chainIssuer returns the cert that signed the first
+// cert in chain, which is the cert itself if it's trusted
+// directly.
+func chainIssuer(chain []*x509.Certificate) *x509.Certificate {
+	if len(chain) > 1 {
+		return chain[1]
 	}
+	return chain[0]
+}
 
-	config.RootCAs, err = loadRootCAs(rootCAs)
+// DialTLS connects to addr and performs a TLS handshake,
+// using the currently loaded cert and trusted roots.
+// It is suitable for use as http.Transport.DialTLS.
+func (r *TLSReloader) DialTLS(network, addr string) (stdnet.Conn, error) {
+	const timeout = 10 * time.Second // same as the http.Transport handshake timeout
+	config := r.config()
+	host, _, err := stdnet.SplitHostPort(addr)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
-
-	// This TLS config is used by cored peers to dial each other,
-	// and by corectl to dial cored.
-	// All those processes have the same identity,
-	// so we automatically trust the local cert,
-	// with the expectation that the peer will also be using it.
-	// This makes misconfiguation impossible.
-	// (For some reason, X509KeyPair doesn't keep a copy of the leaf cert,
-	// so we need to parse it again here.)
-	x509Cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	config.ServerName = host
+	dialer := &stdnet.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	}
+	conn, err := dialer.Dial(network, addr)
 	if err != nil {
-		return nil, errors.Wrap(err)
+		return nil, err
 	}
-	config.RootCAs.AddCert(x509Cert)
-	config.ClientCAs = config.RootCAs
-	return config, err
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
 }
 
 // loadRootCAs reads a list of PEM-encoded X.509 certificates from name.
-// If name is the empty string, it returns a new, empty cert pool.
-func loadRootCAs(name string) (*x509.CertPool, error) {
+// If name is the empty string, it returns no certificates.
+func loadRootCAs(name string) ([]*x509.Certificate, error) {
 	if name == "" {
-		return x509.NewCertPool(), nil
+		return nil, nil
 	}
-	pem, err := ioutil.ReadFile(name)
+	data, err := ioutil.ReadFile(name)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
-	pool := x509.NewCertPool()
-	ok := pool.AppendCertsFromPEM(pem)
-	if !ok {
+	// Like x509.CertPool.AppendCertsFromPEM, skip
+	// anything that isn't a parsable cert.
+	var certs []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
 		return nil, errors.Wrap(errors.New("cannot parse certs"))
 	}
-	return pool, nil
+	return certs, nil
+}
+
+// loadCRLs reads certificate revocation lists from name.
+// The file may hold one DER-encoded CRL or any number of
+// PEM-encoded CRLs. If name is the empty string,
+// it returns no CRLs.
+func loadCRLs(name string) ([]*pkix.CertificateList, error) {
+	if name == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var crls []*pkix.CertificateList
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseDERCRL(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		crls = append(crls, crl)
+	}
+	if len(crls) == 0 {
+		crl, err := x509.ParseDERCRL(data)
+		if err != nil {
+			return nil, errors.Wrap(errors.New("cannot parse CRLs"))
+		}
+		crls = append(crls, crl)
+	}
+	return crls, nil
 }
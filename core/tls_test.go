@@ -0,0 +1,357 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert returns a cert for 127.0.0.1 with the given serial
+// number, signed by parent, or a CA cert if parent is nil.
+func newTestCert(t *testing.T, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []stdnet.IP{stdnet.ParseIP("127.0.0.1")},
+	}
+	signer := &testCert{tmpl, key}
+	if parent == nil {
+		tmpl.Subject.CommonName = "test CA"
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		tmpl.ExtKeyUsage = nil
+		tmpl.IPAddresses = nil
+	} else {
+		signer = parent
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer.cert, &key.PublicKey, signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert, key}
+}
+
+func writePEM(t *testing.T, name, typ string, der []byte) {
+	err := ioutil.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestCert writes c, followed by chain, and c's key to
+// certFile and keyFile, and gives them a modification time
+// of modTime.
+func writeTestCert(t *testing.T, c *testCert, certFile, keyFile string, modTime time.Time, chain ...*testCert) {
+	var certPEM []byte
+	for _, cc := range append([]*testCert{c}, chain...) {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cc.cert.Raw})...)
+	}
+	err := ioutil.WriteFile(certFile, certPEM, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	for _, name := range []string{certFile, keyFile} {
+		err = os.Chtimes(name, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	rootsFile := filepath.Join(dir, "roots.pem")
+
+	ca := newTestCert(t, 100, nil)
+	writePEM(t, rootsFile, "CERTIFICATE", ca.cert.Raw)
+	now := time.Now()
+	writeTestCert(t, newTestCert(t, 1, ca), certFile, keyFile, now.Add(-time.Minute))
+
+	r, err := LoadTLS(certFile, keyFile, TLSOptions{RootCAs: rootsFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	// serverSerial returns the serial number of the cert
+	// presented by the server, verified using r.
+	serverSerial := func() int64 {
+		conn, err := r.DialTLS("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.(*tls.Conn).ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if got := serverSerial(); got != 1 {
+		t.Errorf("server serial = %d want 1", got)
+	}
+
+	reloaded, err := r.ReloadIfChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded {
+		t.Error("ReloadIfChanged with no changes reloaded")
+	}
+
+	writeTestCert(t, newTestCert(t, 2, ca), certFile, keyFile, now)
+	reloaded, err = r.ReloadIfChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded {
+		t.Error("ReloadIfChanged after rotation did not reload")
+	}
+	if got := r.Leaf().SerialNumber.Int64(); got != 2 {
+		t.Errorf("leaf serial = %d want 2", got)
+	}
+	if got := serverSerial(); got != 2 {
+		t.Errorf("server serial = %d want 2", got)
+	}
+	cert, err := r.Config().GetClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf.SerialNumber.Int64() != 2 {
+		t.Errorf("client cert serial = %d want 2", cert.Leaf.SerialNumber)
+	}
+
+	// A bad cert file leaves the previous cert in use.
+	err = ioutil.WriteFile(certFile, []byte("garbage"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Reload()
+	if err == nil {
+		t.Error("Reload with bad cert err = nil, want error")
+	}
+	if got := r.Leaf().SerialNumber.Int64(); got != 2 {
+		t.Errorf("leaf serial after bad reload = %d want 2", got)
+	}
+}
+
+func TestTLSRevoked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	crlFile := filepath.Join(dir, "crl.pem")
+
+	ca := newTestCert(t, 100, nil)
+	otherCA := newTestCert(t, 200, nil)
+	revoked := newTestCert(t, 2, ca)
+	good := newTestCert(t, 3, ca)
+	writeTestCert(t, newTestCert(t, 1, ca), certFile, keyFile, time.Now())
+
+	now := time.Now()
+	crl, err := ca.cert.CreateCRL(rand.Reader, ca.key, []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(2), RevocationTime: now},
+	}, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, crlFile, "X509 CRL", crl)
+
+	r, err := LoadTLS(certFile, keyFile, TLSOptions{CRL: crlFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Revoked(revoked.cert, ca.cert) {
+		t.Error("Revoked(revoked) = false want true")
+	}
+	if r.Revoked(good.cert, ca.cert) {
+		t.Error("Revoked(good) = true want false")
+	}
+	// The CRL applies only to certs from the CA that signed it.
+	if r.Revoked(revoked.cert, otherCA.cert) {
+		t.Error("Revoked(revoked, other CA) = true want false")
+	}
+
+	// DER-encoded CRLs work too.
+	err = ioutil.WriteFile(crlFile, crl, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Revoked(revoked.cert, ca.cert) {
+		t.Error("Revoked(revoked) with DER CRL = false want true")
+	}
+}
+
+func TestTLSPrevCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	crlFile := filepath.Join(dir, "crl.pem")
+
+	// The CA isn't in ROOT_CA_CERTS, so the local certs
+	// are trusted only because they're local.
+	ca := newTestCert(t, 100, nil)
+	prev := newTestCert(t, 1, ca)
+	now := time.Now()
+	writeTestCert(t, prev, certFile, keyFile, now.Add(-time.Minute), ca)
+	crl, err := ca.cert.CreateCRL(rand.Reader, ca.key, nil, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, crlFile, "X509 CRL", crl)
+
+	r, err := LoadTLS(certFile, keyFile, TLSOptions{CRL: crlFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestCert(t, newTestCert(t, 2, ca), certFile, keyFile, now, ca)
+	err = r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted := func() bool {
+		_, err := prev.cert.Verify(x509.VerifyOptions{
+			Roots:     r.RootCAs(),
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		return err == nil
+	}
+	if !trusted() {
+		t.Error("previous cert not trusted after rotation")
+	}
+
+	// Revoking the previous cert takes effect even though
+	// it's trusted directly, so its verified chain is just
+	// itself.
+	crl, err = ca.cert.CreateCRL(rand.Reader, ca.key, []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(1), RevocationTime: now},
+	}, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, crlFile, "X509 CRL", crl)
+	r.mu.Lock()
+	r.crls, err = loadCRLs(crlFile)
+	r.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Revoked(prev.cert, prev.cert) {
+		t.Error("Revoked(prev, prev) = false want true")
+	}
+
+	// A peer still presenting the revoked cert is refused.
+	writeTestCert(t, prev, filepath.Join(dir, "prev.crt"), filepath.Join(dir, "prev.key"), now, ca)
+	peer, err := LoadTLS(filepath.Join(dir, "prev.crt"), filepath.Join(dir, "prev.key"), TLSOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", peer.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	conn, err := r.DialTLS("tcp", ln.Addr().String())
+	if err == nil {
+		conn.Close()
+		t.Error("DialTLS to peer with revoked cert succeeded")
+	}
+
+	// The previous cert is trusted only for a while,
+	// and only until the next reload.
+	r.mu.Lock()
+	r.prevUntil = time.Now().Add(-time.Second)
+	r.mu.Unlock()
+	if trusted() {
+		t.Error("previous cert trusted after grace period")
+	}
+	r.mu.Lock()
+	r.prevUntil = time.Now().Add(time.Hour)
+	r.mu.Unlock()
+	if !trusted() {
+		t.Fatal("previous cert not trusted in grace period")
+	}
+	err = r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trusted() {
+		t.Error("previous cert trusted after another reload")
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"chain/core/leader"
 	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg"
 	chainjson "chain/encoding/json"
 	"chain/errors"
@@ -50,6 +51,10 @@ func (a *API) buildSingle(ctx context.Context, req *buildRequest) (*txbuilder.Te
 	if err != nil {
 		return nil, err
 	}
+	err = a.checkActionsScope(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	actions := make([]txbuilder.Action, 0, len(req.Actions))
 	for i, act := range req.Actions {
 		typ, ok := act["type"].(string)
@@ -92,6 +97,11 @@ func (a *API) buildSingle(ctx context.Context, req *buildRequest) (*txbuilder.Te
 	if err != nil {
 		return nil, err
 	}
+	// The base transaction may spend outputs too.
+	err = a.checkTemplateScope(ctx, tpl)
+	if err != nil {
+		return nil, err
+	}
 
 	// ensure null is never returned for signing instructions
 	if tpl.SigningInstructions == nil {
@@ -106,6 +116,9 @@ func (a *API) build(ctx context.Context, buildReqs []*buildRequest) (interface{}
 	// reservations. Forward the build call to the leader process.
 	// TODO(jackson): Distribute reservations across cored processes.
 	if a.leader.State() != leader.Leading {
+		if _, restricted := requestScopes(ctx); restricted {
+			return a.forwardScopedBuild(ctx, buildReqs)
+		}
 		var resp interface{}
 		err := a.forwardToLeader(ctx, "/build-transaction", buildReqs, &resp)
 		return resp, err
@@ -134,6 +147,85 @@ func (a *API) build(ctx context.Context, buildReqs []*buildRequest) (interface{}
 	return responses, nil
 }
 
+// forwardScopedBuild forwards a build request restricted by scoped
+// grants to the leader. The leader sees the request as coming from
+// this process, not the client, so the scopes are checked here,
+// before forwarding the actions and after building the templates.
+func (a *API) forwardScopedBuild(ctx context.Context, buildReqs []*buildRequest) (interface{}, error) {
+	responses := make([]interface{}, len(buildReqs))
+	var forward []*buildRequest
+	for i, req := range buildReqs {
+		err := a.filterAliases(ctx, req)
+		if err == nil {
+			err = a.checkActionsScope(ctx, req)
+		}
+		if err != nil {
+			responses[i] = errorFormatter.Format(err)
+		} else {
+			forward = append(forward, req)
+		}
+	}
+	if len(forward) == 0 {
+		return responses, nil
+	}
+
+	var resp []json.RawMessage
+	err := a.forwardToLeader(ctx, "/build-transaction", forward, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) != len(forward) {
+		return nil, errors.New("leader returned the wrong number of templates")
+	}
+	for i := range responses {
+		if responses[i] != nil {
+			continue
+		}
+		r := resp[0]
+		resp = resp[1:]
+		responses[i] = r
+
+		var tpl txbuilder.Template
+		if json.Unmarshal(r, &tpl) != nil || tpl.Transaction == nil {
+			continue // an error from the leader
+		}
+		err = a.checkTemplateScope(ctx, &tpl)
+		if err != nil {
+			responses[i] = errorFormatter.Format(err)
+		}
+	}
+	return responses, nil
+}
+
+// signTemplates adds signatures to transaction templates by
+// requesting them from the remote signers configured with the
+// tx_signer option. Only keys in xpubs are used. Signers that don't
+// hold a key leave its signature slots empty.
+//
+// POST /sign-transaction
+func (a *API) signTemplates(ctx context.Context, x struct {
+	Txs   []*txbuilder.Template `json:"transactions"`
+	XPubs []chainkd.XPub        `json:"xpubs"`
+}) ([]interface{}, error) {
+	if len(a.txSigner.URLs()) == 0 {
+		return nil, errNoTxSigners
+	}
+	resp := make([]interface{}, 0, len(x.Txs))
+	for _, tx := range x.Txs {
+		err := a.checkTemplateScope(ctx, tx)
+		if err == nil {
+			err = txbuilder.Sign(ctx, tx, x.XPubs, a.txSigner.Sign)
+		}
+		if err != nil {
+			info := errorFormatter.Format(err)
+			resp = append(resp, info)
+		} else {
+			resp = append(resp, tx)
+		}
+	}
+	return resp, nil
+}
+
 func (a *API) submitSingle(ctx context.Context, tpl *txbuilder.Template, waitUntil string) (interface{}, error) {
 	if tpl.Transaction == nil {
 		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
@@ -297,9 +389,48 @@ type submitArg struct {
 	WaitUntil    string `json:"wait_until"` // values none, confirmed, processed. default: processed
 }
 
+// forwardScopedSubmit forwards a submit request restricted by
+// scoped grants to the leader, like forwardScopedBuild. Only the
+// transactions within the scopes are forwarded.
+func (a *API) forwardScopedSubmit(ctx context.Context, x submitArg) (interface{}, error) {
+	responses := make([]interface{}, len(x.Transactions))
+	var forward []txbuilder.Template
+	for i := range x.Transactions {
+		err := a.checkTemplateScope(ctx, &x.Transactions[i])
+		if err != nil {
+			responses[i] = errorFormatter.Format(err)
+		} else {
+			forward = append(forward, x.Transactions[i])
+		}
+	}
+	if len(forward) == 0 {
+		return responses, nil
+	}
+
+	x.Transactions = forward
+	var resp []json.RawMessage
+	err := a.forwardToLeader(ctx, "/submit-transaction", x, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) != len(forward) {
+		return nil, errors.New("leader returned the wrong number of responses")
+	}
+	for i := range responses {
+		if responses[i] == nil {
+			responses[i] = resp[0]
+			resp = resp[1:]
+		}
+	}
+	return responses, nil
+}
+
 // POST /submit-transaction
 func (a *API) submit(ctx context.Context, x submitArg) (interface{}, error) {
 	if a.leader.State() != leader.Leading {
+		if _, restricted := requestScopes(ctx); restricted {
+			return a.forwardScopedSubmit(ctx, x)
+		}
 		var resp json.RawMessage
 		err := a.forwardToLeader(ctx, "/submit-transaction", x, &resp)
 		return resp, err
@@ -322,6 +453,11 @@ func (a *API) submit(ctx context.Context, x submitArg) (interface{}, error) {
 			defer wg.Done()
 			defer batchRecover(subctx, &responses[i])
 
+			err := a.checkTemplateScope(subctx, &x.Transactions[i])
+			if err != nil {
+				responses[i] = err
+				return
+			}
 			tx, err := a.submitSingle(subctx, &x.Transactions[i], x.WaitUntil)
 			if err != nil {
 				responses[i] = err
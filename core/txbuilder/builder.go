@@ -24,6 +24,7 @@ type TemplateBuilder struct {
 	referenceData       []byte
 	rollbacks           []func()
 	callbacks           []func() error
+	txCallbacks         []func(*legacy.TxData) error
 }
 
 func (b *TemplateBuilder) AddInput(in *legacy.TxInput, sigInstruction *SigningInstruction) error {
@@ -43,6 +44,23 @@ func (b *TemplateBuilder) AddOutput(o *legacy.TxOutput) error {
 	return nil
 }
 
+// OutputIndex returns the position o will have among the outputs of
+// the built transaction, or -1 if o wasn't added with AddOutput. It
+// is only final once all actions have been built, so actions that
+// need it should call it from an OnBuild callback.
+func (b *TemplateBuilder) OutputIndex(o *legacy.TxOutput) int {
+	var n int
+	if b.base != nil {
+		n = len(b.base.Outputs)
+	}
+	for i, out := range b.outputs {
+		if out == o {
+			return n + i
+		}
+	}
+	return -1
+}
+
 func (b *TemplateBuilder) RestrictMinTime(t time.Time) {
 	if t.After(b.minTime) {
 		b.minTime = t
@@ -76,6 +94,14 @@ func (b *TemplateBuilder) OnBuild(buildFn func() error) {
 	b.callbacks = append(b.callbacks, buildFn)
 }
 
+// OnTx registers a function that will be run on the assembled
+// transaction, once its inputs, outputs, and time range are final.
+// It may fill in parts of the transaction, such as output reference
+// data, that depend on the IDs of its entries.
+func (b *TemplateBuilder) OnTx(txFn func(*legacy.TxData) error) {
+	b.txCallbacks = append(b.txCallbacks, txFn)
+}
+
 func (b *TemplateBuilder) setReferenceData(data []byte) error {
 	if b.base != nil && len(b.base.ReferenceData) != 0 && !bytes.Equal(b.base.ReferenceData, data) {
 		return errors.Wrap(ErrBadRefData)
@@ -139,6 +165,13 @@ func (b *TemplateBuilder) Build() (*Template, *legacy.TxData, error) {
 		tpl.SigningInstructions = append(tpl.SigningInstructions, instruction)
 		tx.Inputs = append(tx.Inputs, in)
 	}
+
+	for _, cb := range b.txCallbacks {
+		err := cb(tx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 	tpl.Transaction = legacy.NewTx(*tx)
 	return tpl, tx, nil
 }
@@ -0,0 +1,176 @@
+package txbuilder
+
+import (
+	"bytes"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+var (
+	ErrBadSignature     = errors.New("invalid signature")
+	ErrTemplateMismatch = errors.New("templates do not match")
+)
+
+// WitnessStatus describes the signatures collected so far for one
+// witness component of a template.
+type WitnessStatus struct {
+	Position  uint32         `json:"position"`
+	Component int            `json:"witness_component"`
+	Quorum    int            `json:"quorum"`
+	Signed    []chainkd.XPub `json:"signed_xpubs"`
+	Pending   []chainkd.XPub `json:"pending_xpubs"`
+	Satisfied bool           `json:"satisfied"`
+}
+
+// SigningStatus reports, for each witness component of tpl, which
+// keys have signed it and whether its quorum is met. It also reports
+// whether every component's quorum is met.
+func SigningStatus(tpl *Template) ([]*WitnessStatus, bool) {
+	statuses := make([]*WitnessStatus, 0, len(tpl.SigningInstructions))
+	complete := true
+	for _, sigInst := range tpl.SigningInstructions {
+		for j, sw := range sigInst.SignatureWitnesses {
+			st := &WitnessStatus{
+				Position:  sigInst.Position,
+				Component: j,
+				Quorum:    sw.Quorum,
+				Signed:    []chainkd.XPub{},
+				Pending:   []chainkd.XPub{},
+			}
+			for k, key := range sw.Keys {
+				if k < len(sw.Sigs) && len(sw.Sigs[k]) > 0 {
+					st.Signed = append(st.Signed, key.XPub)
+				} else {
+					st.Pending = append(st.Pending, key.XPub)
+				}
+			}
+			st.Satisfied = len(st.Signed) >= sw.Quorum
+			complete = complete && st.Satisfied
+			statuses = append(statuses, st)
+		}
+	}
+	return statuses, complete
+}
+
+// VerifySignatures checks every signature already present in tpl
+// against the key it claims to be from.
+func VerifySignatures(tpl *Template) error {
+	if tpl.Transaction == nil {
+		return errors.Wrap(ErrMissingRawTx)
+	}
+	for i, sigInst := range tpl.SigningInstructions {
+		for j, sw := range sigInst.SignatureWitnesses {
+			for k, sig := range sw.Sigs {
+				if len(sig) == 0 {
+					continue
+				}
+				if k >= len(sw.Keys) || !sw.verify(tpl, uint32(i), k, sig) {
+					return errors.WithDetailf(ErrBadSignature, "signature %d of witness component %d of input %d", k, j, i)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MergeSignatures copies into dst the signatures from src that dst
+// lacks, after verifying each of them. The two templates must be
+// for the same transaction and have the same signing instructions.
+// It returns the number of signatures added, and leaves the witness
+// arguments of dst's transaction up to date.
+func MergeSignatures(dst, src *Template) (int, error) {
+	if dst.Transaction == nil || src.Transaction == nil {
+		return 0, errors.Wrap(ErrMissingRawTx)
+	}
+	if dst.Transaction.ID != src.Transaction.ID {
+		return 0, errors.WithDetail(ErrTemplateMismatch, "transactions differ")
+	}
+	if len(dst.SigningInstructions) != len(src.SigningInstructions) {
+		return 0, errors.WithDetail(ErrTemplateMismatch, "signing instruction counts differ")
+	}
+
+	var added int
+	for i, dstInst := range dst.SigningInstructions {
+		srcInst := src.SigningInstructions[i]
+		if dstInst.Position != srcInst.Position || len(dstInst.SignatureWitnesses) != len(srcInst.SignatureWitnesses) || !sameArgs(dstInst.Arguments, srcInst.Arguments) {
+			return 0, errors.WithDetailf(ErrTemplateMismatch, "signing instruction %d differs", i)
+		}
+		for j, dsw := range dstInst.SignatureWitnesses {
+			ssw := srcInst.SignatureWitnesses[j]
+			if !dsw.sameKeys(ssw) {
+				return 0, errors.WithDetailf(ErrTemplateMismatch, "witness component %d of input %d differs", j, i)
+			}
+			if len(dsw.Program) == 0 {
+				dsw.Program = buildSigProgram(dst, dstInst.Position)
+			}
+			if len(dsw.Sigs) < len(dsw.Keys) {
+				newSigs := make([]chainjson.HexBytes, len(dsw.Keys))
+				copy(newSigs, dsw.Sigs)
+				dsw.Sigs = newSigs
+			}
+			for k, sig := range ssw.Sigs {
+				if k >= len(dsw.Keys) || len(sig) == 0 || len(dsw.Sigs[k]) > 0 {
+					continue
+				}
+				if !dsw.verify(dst, uint32(i), k, sig) {
+					return 0, errors.WithDetailf(ErrBadSignature, "signature %d of witness component %d of input %d", k, j, i)
+				}
+				dsw.Sigs[k] = sig
+				added++
+			}
+		}
+	}
+	return added, materializeWitnesses(dst)
+}
+
+// verify reports whether sig is a valid signature by the k'th key of
+// sw, for the witness component of the index'th signing instruction
+// of tpl. It fills in sw.Program if it's empty, as sign does.
+func (sw *signatureWitness) verify(tpl *Template, index uint32, k int, sig []byte) bool {
+	if len(sw.Program) == 0 {
+		sw.Program = buildSigProgram(tpl, tpl.SigningInstructions[index].Position)
+	}
+	var h [32]byte
+	sha3pool.Sum256(h[:], sw.Program)
+
+	return sw.Keys[k].derivedXPub().Verify(h[:], sig)
+}
+
+func (sw *signatureWitness) sameKeys(other *signatureWitness) bool {
+	if sw.Quorum != other.Quorum || len(sw.Keys) != len(other.Keys) {
+		return false
+	}
+	for i, key := range sw.Keys {
+		okey := other.Keys[i]
+		if key.XPub != okey.XPub || len(key.DerivationPath) != len(okey.DerivationPath) {
+			return false
+		}
+		if key.HardenedLevels != okey.HardenedLevels || (key.HardenedXPub == nil) != (okey.HardenedXPub == nil) {
+			return false
+		}
+		if key.HardenedXPub != nil && *key.HardenedXPub != *okey.HardenedXPub {
+			return false
+		}
+		for j, p := range key.DerivationPath {
+			if !bytes.Equal(p, okey.DerivationPath[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sameArgs(a, b []chainjson.HexBytes) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package txbuilder
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/testutil"
+)
+
+func TestMergeSignatures(t *testing.T) {
+	ctx := context.Background()
+	var (
+		xprvs []chainkd.XPrv
+		xpubs []chainkd.XPub
+	)
+	for i := 0; i < 3; i++ {
+		xprv, xpub, err := chainkd.NewXKeys(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xprvs = append(xprvs, xprv)
+		xpubs = append(xpubs, xpub)
+	}
+	path := [][]byte{{1, 0, 0, 0}}
+
+	tpl := &Template{
+		Transaction: legacy.NewTx(legacy.TxData{
+			Version: 1,
+			Inputs: []*legacy.TxInput{
+				legacy.NewSpendInput(nil, bc.Hash{}, bc.AssetID{}, 123, 0, nil, bc.Hash{}, nil),
+			},
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(bc.AssetID{}, 123, []byte{10, 11, 12}, nil),
+			},
+			MaxTime: 2,
+		}),
+	}
+	si := &SigningInstruction{Position: 0}
+	si.AddWitnessKeys(xpubs, path, 2)
+	tpl.SigningInstructions = []*SigningInstruction{si}
+
+	// Each participant signs their own copy of the template, as
+	// they would after fetching it from a signing session.
+	signed := func(i int) *Template {
+		cp := copyTemplate(t, tpl)
+		err := Sign(ctx, cp, xpubs[i:i+1], func(_ context.Context, xpub chainkd.XPub, path [][]byte, hardened int, _ *chainkd.XPub, h [32]byte) ([]byte, error) {
+			return xprvs[i].DeriveHardened(path, hardened).Sign(h[:]), nil
+		})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		return cp
+	}
+
+	session := copyTemplate(t, tpl)
+	statuses, complete := SigningStatus(session)
+	if complete || len(statuses) != 1 || len(statuses[0].Pending) != 3 {
+		t.Fatalf("SigningStatus of unsigned template = %+v, %t; want 3 pending keys, incomplete", statuses, complete)
+	}
+
+	n, err := MergeSignatures(session, signed(2))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 1 {
+		t.Errorf("MergeSignatures added %d signatures, want 1", n)
+	}
+	n, err = MergeSignatures(session, signed(2))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 0 {
+		t.Errorf("MergeSignatures of a duplicate added %d signatures, want 0", n)
+	}
+	_, complete = SigningStatus(session)
+	if complete {
+		t.Fatal("expected template with 1 of 2 signatures to be incomplete")
+	}
+
+	forged := signed(0)
+	forged.SigningInstructions[0].SignatureWitnesses[0].Sigs[0] = xprvs[1].Sign([]byte("other message"))
+	_, err = MergeSignatures(session, forged)
+	if errors.Root(err) != ErrBadSignature {
+		t.Fatalf("MergeSignatures of forged signature got error %v, want %v", err, ErrBadSignature)
+	}
+
+	other := signed(0)
+	other.SigningInstructions[0].SignatureWitnesses[0].Quorum = 1
+	_, err = MergeSignatures(session, other)
+	if errors.Root(err) != ErrTemplateMismatch {
+		t.Fatalf("MergeSignatures with different quorum got error %v, want %v", err, ErrTemplateMismatch)
+	}
+
+	_, err = MergeSignatures(session, signed(0))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	statuses, complete = SigningStatus(session)
+	if !complete {
+		t.Fatalf("expected template with 2 of 2 signatures to be complete, got %+v", statuses[0])
+	}
+	want := []chainkd.XPub{xpubs[0], xpubs[2]}
+	if !testutil.DeepEqual(statuses[0].Signed, want) {
+		t.Errorf("signed xpubs = %x, want %x", statuses[0].Signed, want)
+	}
+	if args := session.Transaction.Inputs[0].Arguments(); len(args) != 4 {
+		t.Errorf("got %d witness arguments, want 4", len(args))
+	}
+	err = VerifySignatures(copyTemplate(t, session))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+}
+
+func copyTemplate(t *testing.T, tpl *Template) *Template {
+	b, err := json.Marshal(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := new(Template)
+	err = json.Unmarshal(b, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cp
+}
@@ -38,6 +38,11 @@ func (t *Template) Hash(idx uint32) bc.Hash {
 type SigningInstruction struct {
 	Position           uint32              `json:"position"`
 	SignatureWitnesses []*signatureWitness `json:"witness_components,omitempty"`
+
+	// Arguments are extra witness arguments for the input, added
+	// after those of the signature witnesses. Issuance programs use
+	// them to choose among clauses and to locate outputs they check.
+	Arguments []chainjson.HexBytes `json:"arguments,omitempty"`
 }
 
 func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
@@ -47,6 +52,7 @@ func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
 			Type string
 			signatureWitness
 		} `json:"witness_components"`
+		Arguments []chainjson.HexBytes `json:"arguments"`
 	}
 	err := json.Unmarshal(b, &pre)
 	if err != nil {
@@ -54,6 +60,7 @@ func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
 	}
 
 	si.Position = pre.Position
+	si.Arguments = pre.Arguments
 	si.SignatureWitnesses = make([]*signatureWitness, 0, len(pre.SignatureWitnesses))
 	for i, w := range pre.SignatureWitnesses {
 		if w.Type != "signature" {
@@ -16,7 +16,12 @@ import (
 
 // SignFunc is the function passed into Sign that produces
 // a signature for a given xpub, derivation path, and hash.
-type SignFunc func(context.Context, chainkd.XPub, [][]byte, [32]byte) ([]byte, error)
+// The given number of elements at the start of the path are
+// derived with hardened derivation (see chainkd.XPrv.DeriveHardened).
+// If hardened isn't zero, hardenedXPub is the template's record of xpub
+// derived along those elements; signers that can't derive the key
+// themselves use it to check signatures.
+type SignFunc func(ctx context.Context, xpub chainkd.XPub, path [][]byte, hardened int, hardenedXPub *chainkd.XPub, hash [32]byte) ([]byte, error)
 
 // materializeWitnesses takes a filled in Template and "materializes"
 // each witness component, turning it into a vector of arguments for
@@ -44,6 +49,9 @@ func materializeWitnesses(txTemplate *Template) error {
 				return errors.WithDetailf(err, "error in witness component %d of input %d", j, i)
 			}
 		}
+		for _, arg := range sigInst.Arguments {
+			witness = append(witness, arg)
+		}
 
 		msg.SetInputArguments(sigInst.Position, witness)
 	}
@@ -72,6 +80,14 @@ type (
 	keyID struct {
 		XPub           chainkd.XPub         `json:"xpub"`
 		DerivationPath []chainjson.HexBytes `json:"derivation_path"`
+
+		// HardenedLevels is the number of elements at the start of
+		// DerivationPath that are derived with hardened derivation.
+		// If it's not zero, HardenedXPub is XPub derived along
+		// those elements, and the rest of the path is derived from
+		// it.
+		HardenedLevels int           `json:"hardened_levels,omitempty"`
+		HardenedXPub   *chainkd.XPub `json:"hardened_xpub,omitempty"`
 	}
 )
 
@@ -116,11 +132,13 @@ func (sw *signatureWitness) sign(ctx context.Context, tpl *Template, index uint3
 		if !contains(xpubs, keyID.XPub) {
 			continue
 		}
-		path := make([]([]byte), len(keyID.DerivationPath))
-		for i, p := range keyID.DerivationPath {
-			path[i] = p
+		if keyID.HardenedLevels < 0 || keyID.HardenedLevels > len(keyID.DerivationPath) {
+			return errors.WithDetailf(ErrBadWitnessComponent, "key %d has bad hardened_levels", i)
+		}
+		if keyID.HardenedLevels > 0 && keyID.HardenedXPub == nil {
+			return errors.WithDetailf(ErrBadWitnessComponent, "key %d has hardened_levels but no hardened_xpub", i)
 		}
-		sigBytes, err := signFn(ctx, keyID.XPub, path, h)
+		sigBytes, err := signFn(ctx, keyID.XPub, keyID.path(), keyID.HardenedLevels, keyID.HardenedXPub, h)
 		if err != nil {
 			return errors.WithDetailf(err, "computing signature %d", i)
 		}
@@ -129,6 +147,23 @@ func (sw *signatureWitness) sign(ctx context.Context, tpl *Template, index uint3
 	return nil
 }
 
+func (k keyID) path() [][]byte {
+	path := make([][]byte, len(k.DerivationPath))
+	for i, p := range k.DerivationPath {
+		path[i] = p
+	}
+	return path
+}
+
+// derivedXPub returns the xpub that signs for k.
+func (k keyID) derivedXPub() chainkd.XPub {
+	path := k.path()
+	if k.HardenedLevels > 0 && k.HardenedXPub != nil && k.HardenedLevels <= len(path) {
+		return k.HardenedXPub.Derive(path[k.HardenedLevels:])
+	}
+	return k.XPub.Derive(path)
+}
+
 func contains(list []chainkd.XPub, key chainkd.XPub) bool {
 	for _, k := range list {
 		if bytes.Equal(k[:], key[:]) {
@@ -138,6 +173,26 @@ func contains(list []chainkd.XPub, key chainkd.XPub) bool {
 	return false
 }
 
+// InferredSigHashes returns the hashes of the predicates Sign infers
+// for each of tpl's signing instructions. A hash not among them is
+// of a program supplied with the template, which may commit to a
+// different transaction than tpl.Transaction.
+func InferredSigHashes(tpl *Template) map[[32]byte]bool {
+	hashes := make(map[[32]byte]bool)
+	if tpl.Transaction == nil {
+		return hashes
+	}
+	for _, si := range tpl.SigningInstructions {
+		if int(si.Position) >= len(tpl.Transaction.Inputs) {
+			continue
+		}
+		var h [32]byte
+		sha3pool.Sum256(h[:], buildSigProgram(tpl, si.Position))
+		hashes[h] = true
+	}
+	return hashes
+}
+
 func buildSigProgram(tpl *Template, index uint32) []byte {
 	if !tpl.AllowAdditional {
 		h := tpl.Hash(index)
@@ -228,14 +283,27 @@ func (sw signatureWitness) MarshalJSON() ([]byte, error) {
 // list of keys derived by applying the derivation path to each of the
 // xpubs.
 func (si *SigningInstruction) AddWitnessKeys(xpubs []chainkd.XPub, path [][]byte, quorum int) {
+	si.AddHardenedWitnessKeys(xpubs, nil, path, 0, quorum)
+}
+
+// AddHardenedWitnessKeys is like AddWitnessKeys for keys whose first
+// hardened path elements are derived with hardened derivation.
+// hardenedXPubs[i] is xpubs[i] derived along those elements. If
+// hardened is zero, hardenedXPubs is ignored.
+func (si *SigningInstruction) AddHardenedWitnessKeys(xpubs, hardenedXPubs []chainkd.XPub, path [][]byte, hardened, quorum int) {
 	hexPath := make([]chainjson.HexBytes, 0, len(path))
 	for _, p := range path {
 		hexPath = append(hexPath, p)
 	}
 
 	keyIDs := make([]keyID, 0, len(xpubs))
-	for _, xpub := range xpubs {
-		keyIDs = append(keyIDs, keyID{xpub, hexPath})
+	for i, xpub := range xpubs {
+		k := keyID{XPub: xpub, DerivationPath: hexPath}
+		if hardened > 0 {
+			k.HardenedLevels = hardened
+			k.HardenedXPub = &hardenedXPubs[i]
+		}
+		keyIDs = append(keyIDs, k)
 	}
 
 	sw := &signatureWitness{
@@ -8,6 +8,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 
+	"chain/crypto/sha3pool"
 	chainjson "chain/encoding/json"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
@@ -46,6 +47,31 @@ func TestInferConstraints(t *testing.T) {
 	}
 }
 
+func TestInferredSigHashes(t *testing.T) {
+	tpl := &Template{
+		Transaction: legacy.NewTx(legacy.TxData{
+			Inputs: []*legacy.TxInput{
+				legacy.NewSpendInput(nil, bc.Hash{}, bc.AssetID{}, 123, 0, nil, bc.Hash{}, nil),
+			},
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(bc.AssetID{}, 123, []byte{10, 11, 12}, nil),
+			},
+		}),
+		SigningInstructions: []*SigningInstruction{{Position: 0}},
+	}
+	var derived, supplied [32]byte
+	sha3pool.Sum256(derived[:], buildSigProgram(tpl, 0))
+	sha3pool.Sum256(supplied[:], []byte{byte(vm.OP_TRUE)})
+
+	hashes := InferredSigHashes(tpl)
+	if !hashes[derived] {
+		t.Error("expected the hash of the derived program")
+	}
+	if hashes[supplied] {
+		t.Error("unexpected hash of a supplied program")
+	}
+}
+
 func TestWitnessJSON(t *testing.T) {
 	si := &SigningInstruction{
 		Position: 17,
@@ -59,6 +85,7 @@ func TestWitnessJSON(t *testing.T) {
 				Sigs: []chainjson.HexBytes{{8, 9, 10}},
 			},
 		},
+		Arguments: []chainjson.HexBytes{{1}, {}},
 	}
 
 	b, err := json.MarshalIndent(si, "", "  ")
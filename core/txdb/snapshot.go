@@ -3,10 +3,12 @@ package txdb
 import (
 	"context"
 	"database/sql"
+	"math"
 
 	"github.com/golang/protobuf/proto"
 
 	"chain/core/txdb/internal/storage"
+	"chain/crypto/sha3pool"
 	"chain/database/pg"
 	"chain/errors"
 	"chain/protocol/bc"
@@ -70,11 +72,13 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 		return errors.Wrap(err, "marshaling state snapshot")
 	}
 
+	// Hash the snapshot's chunks now, while it's in memory, so that
+	// serving its manifest to peers never needs to read it back.
 	const insertQ = `
-		INSERT INTO snapshots (height, data) VALUES($1, $2)
-		ON CONFLICT (height) DO UPDATE SET data = $2, created_at = NOW()
+		INSERT INTO snapshots (height, data, chunk_hashes) VALUES($1, $2, $3)
+		ON CONFLICT (height) DO UPDATE SET data = $2, chunk_hashes = $3, created_at = NOW()
 	`
-	_, err = db.ExecContext(ctx, insertQ, blockHeight, b)
+	_, err = db.ExecContext(ctx, insertQ, blockHeight, b, encodeChunkHashes(snapshotChunkHashes(b)))
 	if err != nil {
 		return errors.Wrap(err, "writing state snapshot to database")
 	}
@@ -85,15 +89,21 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 }
 
 func getStateSnapshot(ctx context.Context, db pg.DB) (*state.Snapshot, uint64, error) {
+	return getStateSnapshotBefore(ctx, db, math.MaxInt64)
+}
+
+// getStateSnapshotBefore returns the most recent state snapshot
+// at or below maxHeight.
+func getStateSnapshotBefore(ctx context.Context, db pg.DB, maxHeight uint64) (*state.Snapshot, uint64, error) {
 	const q = `
-		SELECT data, height FROM snapshots ORDER BY height DESC LIMIT 1
+		SELECT data, height FROM snapshots WHERE height <= $1 ORDER BY height DESC LIMIT 1
 	`
 	var (
 		data   []byte
 		height uint64
 	)
 
-	err := db.QueryRowContext(ctx, q).Scan(&data, &height)
+	err := db.QueryRowContext(ctx, q, maxHeight).Scan(&data, &height)
 	if err == sql.ErrNoRows {
 		return state.Empty(), 0, nil
 	} else if err != nil {
@@ -117,3 +127,131 @@ func getRawSnapshot(ctx context.Context, db pg.DB, height uint64) (data []byte,
 	}
 	return data, err
 }
+
+// SnapshotChunkSize is the size in bytes of the chunks that raw
+// snapshots are split into when transferred between Cores. The last
+// chunk of a snapshot may be smaller.
+const SnapshotChunkSize = 4 << 20 // 4MB
+
+// MaxSnapshotSize is the largest raw snapshot a Core will download
+// from a peer. Raw snapshots are stored in a single bytea column,
+// which Postgres limits to 1GB.
+const MaxSnapshotSize = 1 << 30 // 1GB
+
+// SnapshotManifest describes how a raw snapshot is split into
+// chunks for transfer, along with the hash of each chunk.
+type SnapshotManifest struct {
+	Height    uint64    `json:"height"`
+	Size      uint64    `json:"size"`
+	ChunkSize uint64    `json:"chunk_size"`
+	Chunks    []bc.Hash `json:"chunks"`
+}
+
+// ChunkRange returns the byte offsets of the chunk at index i.
+func (m *SnapshotManifest) ChunkRange(i int) (start, end uint64) {
+	start = uint64(i) * m.ChunkSize
+	end = start + m.ChunkSize
+	if end > m.Size {
+		end = m.Size
+	}
+	return start, end
+}
+
+// SnapshotChunkHash returns the hash used to verify a snapshot
+// chunk.
+func SnapshotChunkHash(chunk []byte) bc.Hash {
+	var b32 [32]byte
+	sha3pool.Sum256(b32[:], chunk)
+	return bc.NewHash(b32)
+}
+
+// snapshotChunkHashes returns the hash of each SnapshotChunkSize
+// chunk of the raw snapshot data.
+func snapshotChunkHashes(data []byte) []bc.Hash {
+	var hashes []bc.Hash
+	for start := 0; start < len(data); start += SnapshotChunkSize {
+		end := start + SnapshotChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		hashes = append(hashes, SnapshotChunkHash(data[start:end]))
+	}
+	return hashes
+}
+
+func encodeChunkHashes(hashes []bc.Hash) []byte {
+	b := make([]byte, 0, 32*len(hashes))
+	for _, h := range hashes {
+		b = append(b, h.Bytes()...)
+	}
+	return b
+}
+
+func decodeChunkHashes(b []byte) ([]bc.Hash, error) {
+	if len(b)%32 != 0 {
+		return nil, errors.New("chunk hashes length is not a multiple of 32")
+	}
+	var hashes []bc.Hash
+	for ; len(b) > 0; b = b[32:] {
+		var b32 [32]byte
+		copy(b32[:], b)
+		hashes = append(hashes, bc.NewHash(b32))
+	}
+	return hashes, nil
+}
+
+// getSnapshotManifest returns the manifest of the raw snapshot at
+// the provided height. The chunk hashes are computed when the
+// snapshot is saved. Snapshots saved before chunk hashes were
+// stored have them computed here, one chunk at a time, and saved.
+func getSnapshotManifest(ctx context.Context, db pg.DB, height uint64) (*SnapshotManifest, error) {
+	const q = `SELECT octet_length(data), chunk_hashes FROM snapshots WHERE height = $1`
+	var chunkHashes []byte
+	m := &SnapshotManifest{Height: height, ChunkSize: SnapshotChunkSize}
+	err := db.QueryRowContext(ctx, q, height).Scan(&m.Size, &chunkHashes)
+	if err == sql.ErrNoRows {
+		return nil, pg.ErrUserInputNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "retrieving snapshot manifest")
+	}
+
+	if chunkHashes != nil {
+		m.Chunks, err = decodeChunkHashes(chunkHashes)
+		return m, errors.Wrap(err, "decoding snapshot manifest")
+	}
+
+	for i := 0; uint64(i)*m.ChunkSize < m.Size; i++ {
+		chunk, err := getRawSnapshotChunk(ctx, db, height, i)
+		if err != nil {
+			return nil, err
+		}
+		m.Chunks = append(m.Chunks, SnapshotChunkHash(chunk))
+	}
+
+	const updateQ = `UPDATE snapshots SET chunk_hashes = $2 WHERE height = $1 AND octet_length(data) = $3`
+	_, err = db.ExecContext(ctx, updateQ, height, encodeChunkHashes(m.Chunks), m.Size)
+	return m, errors.Wrap(err, "saving snapshot manifest")
+}
+
+// getRawSnapshotChunk returns the i'th SnapshotChunkSize chunk of
+// the raw snapshot at the provided height.
+func getRawSnapshotChunk(ctx context.Context, db pg.DB, height uint64, i int) (data []byte, err error) {
+	if i < 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "snapshot at height %d has no chunk %d", height, i)
+	}
+
+	// Postgres's substring is 1-indexed. The snapshots table stores
+	// data uncompressed (STORAGE EXTERNAL), so this reads only the
+	// requested slice rather than the whole snapshot.
+	const q = `SELECT substring(data FROM $2 FOR $3) FROM snapshots WHERE height = $1`
+	err = db.QueryRowContext(ctx, q, height, uint64(i)*SnapshotChunkSize+1, SnapshotChunkSize).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, pg.ErrUserInputNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "retrieving snapshot chunk")
+	}
+	if len(data) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "snapshot at height %d has no chunk %d", height, i)
+	}
+	return data, nil
+}
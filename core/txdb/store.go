@@ -62,6 +62,14 @@ func (s *Store) LatestSnapshot(ctx context.Context) (*state.Snapshot, uint64, er
 	return getStateSnapshot(ctx, s.db)
 }
 
+// SnapshotBefore returns the most recent state snapshot stored in
+// the database at or below the provided height, and its
+// corresponding block height. If there is no such snapshot, it
+// returns an empty snapshot at height 0.
+func (s *Store) SnapshotBefore(ctx context.Context, height uint64) (*state.Snapshot, uint64, error) {
+	return getStateSnapshotBefore(ctx, s.db, height)
+}
+
 // LatestSnapshotInfo returns the height and size of the most recent
 // state snapshot stored in the database.
 func (s *Store) LatestSnapshotInfo(ctx context.Context) (height uint64, size uint64, err error) {
@@ -79,6 +87,21 @@ func (s *Store) GetSnapshot(ctx context.Context, height uint64) ([]byte, error)
 	return getRawSnapshot(ctx, s.db, height)
 }
 
+// GetSnapshotManifest returns the manifest describing how the state
+// snapshot at the provided height is split into chunks for transfer.
+// If no snapshot exists at the provided height, an error is returned.
+func (s *Store) GetSnapshotManifest(ctx context.Context, height uint64) (*SnapshotManifest, error) {
+	return getSnapshotManifest(ctx, s.db, height)
+}
+
+// GetSnapshotChunk returns the i'th chunk of the state snapshot
+// stored at the provided height, in Chain Core's binary protobuf
+// representation. Concatenating all of a snapshot's chunks in order
+// produces the same data as GetSnapshot.
+func (s *Store) GetSnapshotChunk(ctx context.Context, height uint64, i int) ([]byte, error) {
+	return getRawSnapshotChunk(ctx, s.db, height, i)
+}
+
 // SaveBlock persists a new block in the database.
 func (s *Store) SaveBlock(ctx context.Context, block *legacy.Block) error {
 	const q = `
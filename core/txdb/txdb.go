@@ -51,3 +51,38 @@ func (s *Store) GetRawBlock(ctx context.Context, height uint64) ([]byte, error)
 	err := s.db.QueryRowContext(ctx, q, height).Scan(&block)
 	return block, errors.Wrap(err, "querying blocks from the db")
 }
+
+// GetRawBlocks queries the database for up to count consecutive
+// blocks beginning at the provided height. It stops early once the
+// total size of the blocks reaches maxBytes, but always includes the
+// first block if it exists. The blocks are returned as raw bytes.
+func (s *Store) GetRawBlocks(ctx context.Context, height uint64, count int, maxBytes int) ([][]byte, error) {
+	const q = `
+		SELECT data FROM blocks
+		WHERE height >= $1 AND height < $2
+		ORDER BY height
+	`
+	rows, err := s.db.QueryContext(ctx, q, height, height+uint64(count))
+	if err != nil {
+		return nil, errors.Wrap(err, "querying blocks from the db")
+	}
+	defer rows.Close()
+
+	var (
+		blocks [][]byte
+		size   int
+	)
+	for rows.Next() {
+		var block []byte
+		err = rows.Scan(&block)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning block")
+		}
+		if len(blocks) > 0 && size+len(block) > maxBytes {
+			break
+		}
+		blocks = append(blocks, block)
+		size += len(block)
+	}
+	return blocks, errors.Wrap(rows.Err(), "end scan")
+}
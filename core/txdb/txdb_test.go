@@ -104,6 +104,61 @@ func TestGetRawBlock(t *testing.T) {
 	}
 }
 
+func TestGetRawBlocks(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	store := NewStore(dbtx)
+
+	var raws [][]byte
+	for h := uint64(1); h <= 4; h++ {
+		block := &legacy.Block{
+			BlockHeader: legacy.BlockHeader{
+				Version:     1,
+				Height:      h,
+				TimestampMS: h,
+			},
+		}
+		var buf bytes.Buffer
+		_, err := block.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raws = append(raws, buf.Bytes())
+		err = store.SaveBlock(ctx, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		height   uint64
+		count    int
+		maxBytes int
+		want     [][]byte
+	}{
+		{1, 10, 1 << 20, raws},
+		{2, 2, 1 << 20, raws[1:3]},
+		{2, 10, len(raws[1]) + len(raws[2]), raws[1:3]},
+		{3, 10, 1, raws[2:3]}, // always at least one block
+		{5, 10, 1 << 20, nil},
+	}
+	for _, c := range cases {
+		got, err := store.GetRawBlocks(ctx, c.height, c.count, c.maxBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("GetRawBlocks(%d, %d, %d) returned %d blocks, want %d", c.height, c.count, c.maxBytes, len(got), len(c.want))
+			continue
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], c.want[i]) {
+				t.Errorf("GetRawBlocks(%d, %d, %d)[%d] = %x, want %x", c.height, c.count, c.maxBytes, i, got[i], c.want[i])
+			}
+		}
+	}
+}
+
 func TestListenFinalizeBlocks(t *testing.T) {
 	dbURL, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	ctx, cancel := context.WithCancel(context.Background())
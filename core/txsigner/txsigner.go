@@ -0,0 +1,200 @@
+// Package txsigner implements the protocol Chain Core uses to request
+// signatures on transaction templates from remote signers, such as
+// HSMs running on separate, hardened hosts.
+//
+// A signer is an HTTP server that accepts POST requests at the path
+// /sign-hash with a JSON body of the form
+//
+//     {
+//       "xpub": "<hex-encoded root xpub>",
+//       "derivation_path": ["<hex-encoded path element>", ...],
+//       "hardened_levels": <number>,
+//       "hardened_xpub": "<hex-encoded xpub>",
+//       "hash": "<hex-encoded 32-byte hash>"
+//     }
+//
+// The hash is the SHA3-256 hash of a signature program from a
+// transaction template. The signer derives the private key for xpub
+// along derivation_path, using hardened derivation for the first
+// hardened_levels elements (zero if omitted), signs hash with it,
+// and responds with status 200 and a body of the form
+//
+//     {"signature": "<hex-encoded 64-byte signature>"}
+//
+// If hardened_levels isn't zero, hardened_xpub is required; it is
+// xpub derived along the hardened elements, as recorded in the
+// template, and the client checks signatures against it.
+//
+// If the signer doesn't hold the private key for xpub, it responds
+// with status 404. Any other non-2xx status means the signer failed.
+// Errors use the Chain error response format.
+//
+// Requests are authenticated with HTTP basic auth, using an access
+// token of the form <username>:<password>. Signers should be served
+// over HTTPS.
+package txsigner
+
+import (
+	"context"
+	"net/http"
+
+	"chain/core/rpc"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httperror"
+	"chain/net/http/httpjson"
+)
+
+// SignPath is the path at which signers serve signature requests.
+const SignPath = "/sign-hash"
+
+var (
+	// ErrNoKey is returned by a signer that doesn't hold the
+	// requested key.
+	ErrNoKey = errors.New("key not held by signer")
+
+	// ErrBadSignature is returned when a signer responds with a
+	// signature that doesn't verify.
+	ErrBadSignature = errors.New("signer returned an invalid signature")
+
+	errBadHash     = errors.New("hash must be 32 bytes")
+	errBadHardened = errors.New("hardened_levels must be between zero and the length of derivation_path")
+
+	// errNoHardenedXPub is returned when signing a path with
+	// hardened levels without the hardened xpub, so the signature
+	// couldn't be checked.
+	errNoHardenedXPub = errors.New("hardened_xpub is required with hardened_levels")
+)
+
+// Request is the body of a request to a signer.
+type Request struct {
+	XPub           chainkd.XPub         `json:"xpub"`
+	DerivationPath []chainjson.HexBytes `json:"derivation_path"`
+	HardenedLevels int                  `json:"hardened_levels,omitempty"`
+	HardenedXPub   *chainkd.XPub        `json:"hardened_xpub,omitempty"`
+	Hash           chainjson.HexBytes   `json:"hash"`
+}
+
+// Response is the body of a successful response from a signer.
+type Response struct {
+	Signature chainjson.HexBytes `json:"signature"`
+}
+
+// Client requests signatures from a list of remote signers.
+type Client struct {
+	// URLs is called on every Sign call to retrieve the URLs and
+	// access tokens of the signers, in order of preference.
+	URLs       func() [][]string
+	BaseClient rpc.Client
+}
+
+// Sign requests a signature of hash from each signer in turn, until
+// one of them provides a valid signature. Signers that don't hold
+// the key for xpub are skipped; if none of them holds it, Sign
+// returns a nil signature and a nil error, so that txbuilder.Sign
+// leaves the signature slot empty. Otherwise, if every signer
+// fails, Sign returns the last error.
+//
+// Every signature is checked against the key derived from xpub
+// along path or, for paths with hardened levels, from hardenedXPub
+// along the rest of path. A signature that doesn't verify counts
+// as a failure of its signer.
+//
+// Sign has the type txbuilder.SignFunc.
+func (c *Client) Sign(ctx context.Context, xpub chainkd.XPub, path [][]byte, hardened int, hardenedXPub *chainkd.XPub, hash [32]byte) ([]byte, error) {
+	if hardened < 0 || hardened > len(path) {
+		return nil, errBadHardened
+	}
+	req := Request{XPub: xpub, HardenedLevels: hardened, Hash: hash[:]}
+	for _, p := range path {
+		req.DerivationPath = append(req.DerivationPath, p)
+	}
+	pub := xpub.Derive(path)
+	if hardened > 0 {
+		if hardenedXPub == nil {
+			return nil, errNoHardenedXPub
+		}
+		req.HardenedXPub = hardenedXPub
+		pub = hardenedXPub.Derive(path[hardened:])
+	}
+
+	var lastErr error
+	for _, tup := range c.URLs() {
+		// make a copy of the base rpc client on the stack so we
+		// can modify it with the URL and access token
+		client := c.BaseClient
+		client.BaseURL = tup[0]
+		client.AccessToken = tup[1]
+
+		var resp Response
+		err := client.Call(ctx, SignPath, req, &resp)
+		if statusErr, ok := errors.Root(err).(rpc.ErrStatusCode); ok && statusErr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err == nil && !pub.Verify(hash[:], resp.Signature) {
+			err = ErrBadSignature
+		}
+		if err != nil {
+			lastErr = errors.Wrapf(err, "signer %s", tup[0])
+			log.Error(ctx, lastErr)
+			continue
+		}
+		return resp.Signature, nil
+	}
+	return nil, lastErr
+}
+
+var errorFormatter = httperror.Formatter{
+	Default:     httperror.Info{500, "CH000", "Chain API Error"},
+	IsTemporary: func(httperror.Info, error) bool { return false },
+	Errors: map[error]httperror.Info{
+		context.DeadlineExceeded: {408, "CH001", "Request timed out"},
+		httpjson.ErrBadRequest:   {400, "CH003", "Invalid request body"},
+		errBadHash:               {400, "CH003", "Invalid request body"},
+		errBadHardened:           {400, "CH003", "Invalid request body"},
+		errNoHardenedXPub:        {400, "CH003", "Invalid request body"},
+		ErrNoKey:                 {404, "CH820", "Key not held by signer"},
+	},
+}
+
+// Handler returns an HTTP handler serving the signer protocol at
+// SignPath. It signs with signFn, which must return a nil signature
+// and a nil error for keys it doesn't hold, as the mockhsm and
+// keystore signing functions in Chain Core do. The handler does not
+// authenticate requests; callers must wrap it to do so.
+func Handler(signFn txbuilder.SignFunc) http.Handler {
+	h, err := httpjson.Handler(func(ctx context.Context, req Request) (*Response, error) {
+		var hash [32]byte
+		if len(req.Hash) != len(hash) {
+			return nil, errBadHash
+		}
+		copy(hash[:], req.Hash)
+		if req.HardenedLevels < 0 || req.HardenedLevels > len(req.DerivationPath) {
+			return nil, errBadHardened
+		}
+		if req.HardenedLevels > 0 && req.HardenedXPub == nil {
+			return nil, errNoHardenedXPub
+		}
+		path := make([][]byte, 0, len(req.DerivationPath))
+		for _, p := range req.DerivationPath {
+			path = append(path, p)
+		}
+		sig, err := signFn(ctx, req.XPub, path, req.HardenedLevels, req.HardenedXPub, hash)
+		if err != nil {
+			return nil, err
+		}
+		if sig == nil {
+			return nil, ErrNoKey
+		}
+		return &Response{Signature: sig}, nil
+	}, errorFormatter.Write)
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(SignPath, h)
+	return mux
+}
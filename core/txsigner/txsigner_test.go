@@ -0,0 +1,103 @@
+package txsigner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chain/core/rpc"
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+	"chain/testutil"
+)
+
+func TestClientSign(t *testing.T) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherXPub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holder := httptest.NewServer(Handler(func(ctx context.Context, k chainkd.XPub, path [][]byte, hardened int, _ *chainkd.XPub, hash [32]byte) ([]byte, error) {
+		if k != xpub {
+			return nil, nil
+		}
+		return xprv.DeriveHardened(path, hardened).Sign(hash[:]), nil
+	}))
+	defer holder.Close()
+	stranger := httptest.NewServer(Handler(func(context.Context, chainkd.XPub, [][]byte, int, *chainkd.XPub, [32]byte) ([]byte, error) {
+		return nil, nil
+	}))
+	defer stranger.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+	liar := httptest.NewServer(Handler(func(context.Context, chainkd.XPub, [][]byte, int, *chainkd.XPub, [32]byte) ([]byte, error) {
+		return make([]byte, 64), nil
+	}))
+	defer liar.Close()
+
+	client := func(urls ...string) *Client {
+		var tups [][]string
+		for _, u := range urls {
+			tups = append(tups, []string{u, "user:pass"})
+		}
+		return &Client{URLs: func() [][]string { return tups }}
+	}
+
+	ctx := context.Background()
+	path := [][]byte{{0x01}, {0x02}}
+	hash := [32]byte{0xaa}
+
+	sig, err := client(broken.URL, stranger.URL, holder.URL).Sign(ctx, xpub, path, 0, nil, hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !xpub.Derive(path).Verify(hash[:], sig) {
+		t.Error("expected signature from failover signer to verify")
+	}
+
+	// No signer holds the key.
+	sig, err = client(stranger.URL, holder.URL).Sign(ctx, otherXPub, path, 0, nil, hash)
+	if err != nil || sig != nil {
+		t.Errorf("Sign with unknown key = %x, %v; want nil, nil", sig, err)
+	}
+
+	_, err = client(stranger.URL, broken.URL).Sign(ctx, xpub, path, 0, nil, hash)
+	if _, ok := errors.Root(err).(rpc.ErrStatusCode); !ok {
+		t.Errorf("Sign with failed signer got error %v, want status code error", err)
+	}
+
+	_, err = client(liar.URL).Sign(ctx, xpub, path, 0, nil, hash)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Sign with bad signature got error %v, want %v", err, ErrBadSignature)
+	}
+
+	// Signatures for hardened paths are checked against the
+	// hardened xpub.
+	hardenedXPub := xprv.DeriveHardened(path[:1], 1).XPub()
+	sig, err = client(holder.URL).Sign(ctx, xpub, path, 1, &hardenedXPub, hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !hardenedXPub.Derive(path[1:]).Verify(hash[:], sig) {
+		t.Error("expected hardened signature to verify")
+	}
+	_, err = client(liar.URL).Sign(ctx, xpub, path, 1, &hardenedXPub, hash)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Sign hardened with bad signature got error %v, want %v", err, ErrBadSignature)
+	}
+	_, err = client(holder.URL).Sign(ctx, xpub, path, 1, &otherXPub, hash)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Sign hardened with wrong hardened xpub got error %v, want %v", err, ErrBadSignature)
+	}
+	_, err = client(holder.URL).Sign(ctx, xpub, path, 1, nil, hash)
+	if errors.Root(err) != errNoHardenedXPub {
+		t.Errorf("Sign hardened without hardened xpub got error %v, want %v", err, errNoHardenedXPub)
+	}
+}
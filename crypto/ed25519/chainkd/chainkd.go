@@ -121,6 +121,21 @@ func (xprv XPrv) Derive(path [][]byte) XPrv {
 	return res
 }
 
+// DeriveHardened is like Derive, but uses hardened derivation for
+// the first n elements of path. Keys derived at or below a hardened
+// level can't be derived from the parent xpub, and the leak of one
+// of their private keys doesn't expose the parent xprv.
+//
+// The public key for such a path is the xpub of
+// xprv.DeriveHardened(path[:n], n) derived along path[n:].
+func (xprv XPrv) DeriveHardened(path [][]byte, n int) XPrv {
+	res := xprv
+	for i, p := range path {
+		res = res.Child(p, i < n)
+	}
+	return res
+}
+
 func (xpub XPub) Derive(path [][]byte) XPub {
 	res := xpub
 	for _, p := range path {
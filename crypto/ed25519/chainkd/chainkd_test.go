@@ -79,6 +79,29 @@ func TestChildKeys(t *testing.T) {
 	}
 }
 
+func TestDeriveHardened(t *testing.T) {
+	rootXPrv, err := NewXPrv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := [][]byte{{44}, {1}, {0, 0, 0, 7}}
+	msg := []byte("hardened")
+
+	if got, want := rootXPrv.DeriveHardened(path, 0), rootXPrv.Derive(path); got != want {
+		t.Errorf("DeriveHardened(path, 0) = %x, want Derive(path) = %x", got[:], want[:])
+	}
+
+	for n := 1; n <= len(path); n++ {
+		dprv := rootXPrv.DeriveHardened(path, n)
+		if dprv == rootXPrv.Derive(path) {
+			t.Errorf("DeriveHardened(path, %d) is the same as the non-hardened key", n)
+		}
+		accountXPub := rootXPrv.DeriveHardened(path[:n], n).XPub()
+		dpub := accountXPub.Derive(path[n:])
+		doverify(t, dpub, msg, dprv.Sign(msg), fmt.Sprintf("xpub hardened at %d levels", n), "derived xprv")
+	}
+}
+
 func doverify(t *testing.T, xpub XPub, msg, sig []byte, xpubdesc, xprvdesc string) {
 	if !xpub.Verify(msg, sig) {
 		t.Errorf("%s cannot verify signature from %s", xpubdesc, xprvdesc)
@@ -0,0 +1,130 @@
+package musig
+
+import (
+	"io"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/ecmath"
+	"chain/errors"
+)
+
+// An adaptor signature for the point T = tB, where B is the ed25519
+// base point, is a pair (R', s') such that (R'+T, s'+t) is a valid
+// ed25519 signature. Anyone who knows T can check an adaptor
+// signature, but only someone who knows t can complete it, and
+// anyone who sees both the adaptor signature and the completed
+// signature learns t. This ties publishing a signature to revealing
+// a secret, as in atomic swaps between chains.
+//
+// Adaptor signatures are encoded like ed25519 signatures, as R'
+// followed by s'.
+
+// AdaptorSign returns an adaptor signature of msg by the key with
+// the given secret scalar, for the adaptor point adaptor. Rand must
+// be a cryptographically secure source of randomness; if it is nil,
+// crypto/rand.Reader is used.
+//
+// Signer.SetAdaptor produces aggregate adaptor signatures.
+func AdaptorSign(secret ecmath.Scalar, msg []byte, adaptor [32]byte, rand io.Reader) ([]byte, error) {
+	var t ecmath.Point
+	if _, ok := t.Decode(adaptor); !ok {
+		return nil, errors.WithDetail(ErrBadKey, "adaptor is not a curve point")
+	}
+	var pub ecmath.Point
+	pub.ScMulBase(&secret)
+
+	nonce, err := newNonce(&secret, rand, adaptor[:], msg)
+	if err != nil {
+		return nil, err
+	}
+	var r, rt ecmath.Point
+	r.ScMulBase(&nonce)
+	rt.Add(&r, &t)
+
+	c := challenge(rt.Encode(), pub.Encode(), msg)
+	var s ecmath.Scalar
+	s.MulAdd(&c, &secret, &nonce)
+
+	re := r.Encode()
+	return append(re[:], s[:]...), nil
+}
+
+// VerifyAdaptor reports whether sig is a valid adaptor signature of
+// msg by pub for the adaptor point adaptor.
+func VerifyAdaptor(pub ed25519.PublicKey, msg []byte, adaptor [32]byte, sig []byte) bool {
+	if len(pub) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize || sig[63]&224 != 0 {
+		return false
+	}
+	var (
+		pubEnc, rEnc [32]byte
+		s            ecmath.Scalar
+		x, r, t      ecmath.Point
+	)
+	copy(pubEnc[:], pub)
+	copy(rEnc[:], sig[:32])
+	copy(s[:], sig[32:])
+	if _, ok := x.Decode(pubEnc); !ok {
+		return false
+	}
+	if _, ok := r.Decode(rEnc); !ok {
+		return false
+	}
+	if _, ok := t.Decode(adaptor); !ok {
+		return false
+	}
+
+	// Check that s'B = R' + cX, where c is the challenge for the
+	// completed nonce R'+T.
+	var rt ecmath.Point
+	rt.Add(&r, &t)
+	c := challenge(rt.Encode(), pubEnc, msg)
+
+	var lhs, rhs ecmath.Point
+	lhs.ScMulBase(&s)
+	rhs.ScMul(&x, &c)
+	rhs.Add(&rhs, &r)
+	return lhs.ConstTimeEqual(&rhs)
+}
+
+// Adapt completes the adaptor signature sig with the secret t of
+// its adaptor point, producing an ed25519 signature.
+func Adapt(sig []byte, t ecmath.Scalar) ([]byte, error) {
+	if len(sig) != ed25519.SignatureSize {
+		return nil, errors.WithDetailf(ErrBadSignature, "length %d", len(sig))
+	}
+	var rEnc [32]byte
+	copy(rEnc[:], sig[:32])
+	var r ecmath.Point
+	if _, ok := r.Decode(rEnc); !ok {
+		return nil, errors.WithDetail(ErrBadSignature, "nonce is not a curve point")
+	}
+	var tp ecmath.Point
+	tp.ScMulBase(&t)
+	r.Add(&r, &tp)
+
+	var s ecmath.Scalar
+	copy(s[:], sig[32:])
+	s.Add(&s, &t)
+
+	re := r.Encode()
+	return append(re[:], s[:]...), nil
+}
+
+// Extract returns the secret of the adaptor point adaptor, given an
+// adaptor signature and the signature completed from it.
+func Extract(adaptorSig, sig []byte, adaptor [32]byte) (ecmath.Scalar, error) {
+	if len(adaptorSig) != ed25519.SignatureSize || len(sig) != ed25519.SignatureSize {
+		return ecmath.Zero, errors.WithDetail(ErrBadSignature, "bad signature length")
+	}
+	var s, s0, t ecmath.Scalar
+	copy(s[:], sig[32:])
+	copy(s0[:], adaptorSig[32:])
+	t.Sub(&s, &s0)
+
+	var tp ecmath.Point
+	tp.ScMulBase(&t)
+	if tp.Encode() != adaptor {
+		return ecmath.Zero, errors.Wrap(ErrAdaptorMismatch)
+	}
+	return t, nil
+}
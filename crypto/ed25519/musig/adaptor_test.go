@@ -0,0 +1,95 @@
+package musig
+
+import (
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/ecmath"
+	"chain/errors"
+)
+
+func TestAdaptor(t *testing.T) {
+	msg := []byte("swap leg")
+	secrets, pubkeys := testKeys(t, 2, nil)
+	adaptorSecret, adaptor := testAdaptor(t)
+
+	presig, err := AdaptorSign(secrets[0], msg, adaptor, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyAdaptor(pubkeys[0], msg, adaptor, presig) {
+		t.Fatal("adaptor signature does not verify")
+	}
+	if VerifyAdaptor(pubkeys[1], msg, adaptor, presig) {
+		t.Error("adaptor signature verifies for another key")
+	}
+	if ed25519.Verify(pubkeys[0], msg, presig) {
+		t.Error("adaptor signature verifies as a complete signature")
+	}
+
+	sig, err := Adapt(presig, adaptorSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pubkeys[0], msg, sig) {
+		t.Fatal("completed adaptor signature does not verify")
+	}
+	got, err := Extract(presig, sig, adaptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(&adaptorSecret) {
+		t.Errorf("Extract = %x, want %x", got, adaptorSecret)
+	}
+
+	_, other := testAdaptor(t)
+	_, err = Extract(presig, sig, other)
+	if errors.Root(err) != ErrAdaptorMismatch {
+		t.Errorf("Extract with other adaptor got error %v, want %v", err, ErrAdaptorMismatch)
+	}
+}
+
+func TestAggregateAdaptor(t *testing.T) {
+	msg := []byte("swap leg")
+	secrets, pubkeys := testKeys(t, 3, nil)
+	adaptorSecret, adaptor := testAdaptor(t)
+
+	signers := testSigners(t, secrets, pubkeys, msg)
+	for _, s := range signers {
+		err := s.SetAdaptor(adaptor)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	presig := runProtocol(t, signers)
+
+	agg := signers[0].AggregateKey()
+	if !VerifyAdaptor(agg, msg, adaptor, presig) {
+		t.Fatal("aggregate adaptor signature does not verify")
+	}
+	sig, err := Adapt(presig, adaptorSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(agg, msg, sig) {
+		t.Fatal("completed aggregate adaptor signature does not verify")
+	}
+	got, err := Extract(presig, sig, adaptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(&adaptorSecret) {
+		t.Errorf("Extract = %x, want %x", got, adaptorSecret)
+	}
+}
+
+func testAdaptor(t *testing.T) (ecmath.Scalar, [32]byte) {
+	secrets, pubkeys := testKeys(t, 1, nil)
+	var p [32]byte
+	copy(p[:], pubkeys[0])
+
+	// Reduce the secret mod L, as Extract's result is.
+	var s ecmath.Scalar
+	s.Add(&secrets[0], &ecmath.Zero)
+	return s, p
+}
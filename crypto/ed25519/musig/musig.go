@@ -0,0 +1,393 @@
+// Package musig implements MuSig aggregation of ed25519 keys and
+// signatures, and ed25519 adaptor signatures.
+//
+// Several parties, each holding an ed25519 key, can combine their
+// public keys into one aggregate key and jointly produce signatures
+// for it. An aggregate signature is an ordinary ed25519 signature:
+// it verifies with ed25519.Verify, and so with the VM's CHECKSIG,
+// and reveals neither the number of parties nor their keys.
+//
+// Each key's contribution to the aggregate is weighted by a
+// coefficient that depends on all the keys, so that no party can
+// choose their key to cancel out the others'.
+//
+// Signing takes three rounds, run by a Signer for each party:
+//
+//   1. Each party sends the commitment to their nonce to the others.
+//   2. Once it has everyone's commitments, each party sends their
+//      nonce to the others.
+//   3. Once it has everyone's nonces, each party computes and sends
+//      their partial signature. Any party can then combine the
+//      partial signatures into the final signature.
+//
+// A Signer signs only once. Its nonce must never be reused: a
+// fresh Signer is needed for each message, including when a
+// signing attempt is abandoned.
+package musig
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"io"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/ecmath"
+	"chain/errors"
+)
+
+var (
+	ErrNoKeys          = errors.New("no keys")
+	ErrBadKey          = errors.New("invalid public key")
+	ErrWrongKey        = errors.New("secret does not match public key")
+	ErrBadCommitment   = errors.New("nonce does not match commitment")
+	ErrBadNonce        = errors.New("invalid nonce")
+	ErrBadPartial      = errors.New("invalid partial signature")
+	ErrParticipants    = errors.New("wrong number of participants")
+	ErrRound           = errors.New("signing round out of order")
+	ErrAlreadySigned   = errors.New("signer has already signed")
+	ErrBadSignature    = errors.New("invalid signature")
+	ErrAdaptorMismatch = errors.New("signature does not match adaptor")
+)
+
+// Domain separation tags for the hashes used by the protocol. The
+// signature challenge itself is the plain ed25519 one, so that
+// aggregate signatures verify as ed25519 signatures.
+const (
+	tagKeyList    = "ChainMuSig.keylist"
+	tagCoef       = "ChainMuSig.coefficient"
+	tagCommitment = "ChainMuSig.commitment"
+	tagNonce      = "ChainMuSig.nonce"
+)
+
+// AggregateKey returns the aggregate of pubkeys. The order of the
+// keys matters: every party must use the same order.
+func AggregateKey(pubkeys []ed25519.PublicKey) (ed25519.PublicKey, error) {
+	agg, err := aggregate(pubkeys)
+	if err != nil {
+		return nil, err
+	}
+	return agg.pubkey(), nil
+}
+
+// aggregation holds the decoded keys of a key aggregation, their
+// coefficients, and the resulting aggregate key.
+type aggregation struct {
+	keys  []ecmath.Point
+	coefs []ecmath.Scalar
+	key   ecmath.Point
+	list  ecmath.Scalar // hash of the key list
+}
+
+func (a *aggregation) pubkey() ed25519.PublicKey {
+	e := a.key.Encode()
+	return ed25519.PublicKey(e[:])
+}
+
+func aggregate(pubkeys []ed25519.PublicKey) (*aggregation, error) {
+	if len(pubkeys) == 0 {
+		return nil, errors.Wrap(ErrNoKeys)
+	}
+	a := &aggregation{
+		keys:  make([]ecmath.Point, len(pubkeys)),
+		coefs: make([]ecmath.Scalar, len(pubkeys)),
+		key:   ecmath.ZeroPoint,
+	}
+	list := make([][]byte, 0, len(pubkeys))
+	for i, pub := range pubkeys {
+		if len(pub) != ed25519.PublicKeySize {
+			return nil, errors.WithDetailf(ErrBadKey, "key %d has length %d", i, len(pub))
+		}
+		var e [32]byte
+		copy(e[:], pub)
+		if _, ok := a.keys[i].Decode(e); !ok {
+			return nil, errors.WithDetailf(ErrBadKey, "key %d is not a curve point", i)
+		}
+		list = append(list, pub)
+	}
+	a.list = hashToScalar(tagKeyList, list...)
+	for i, pub := range pubkeys {
+		a.coefs[i] = hashToScalar(tagCoef, a.list[:], pub)
+		var p ecmath.Point
+		p.ScMul(&a.keys[i], &a.coefs[i])
+		a.key.Add(&a.key, &p)
+	}
+	return a, nil
+}
+
+// Signer runs the signing protocol for one party.
+type Signer struct {
+	agg   *aggregation
+	index int
+	msg   []byte
+
+	secret     ecmath.Scalar
+	nonce      ecmath.Scalar
+	noncePoint [32]byte
+
+	commitments [][32]byte
+	nonces      []ecmath.Point
+	adaptor     *ecmath.Point
+
+	// r is the aggregate nonce, including the adaptor point, if any.
+	// It's set once all nonces are known.
+	r *ecmath.Point
+
+	signed bool
+}
+
+// NewSigner returns a Signer for the party whose key is
+// pubkeys[index] and whose secret scalar is secret, to sign msg
+// together with the holders of the other keys.
+//
+// The nonce is derived from 32 bytes read from rand, hashed with
+// secret, the key list, index, and msg. Rand must be a
+// cryptographically secure source of randomness; if it is nil,
+// crypto/rand.Reader is used. Hashing in the rest keeps nonces
+// distinct across messages and sets of signers, but it can't make
+// up for a faulty rand: if rand repeats, a co-signer who abandons a
+// signing attempt and restarts it with a different nonce of their
+// own gets two partial signatures with the same nonce, from which
+// they can compute secret.
+func NewSigner(pubkeys []ed25519.PublicKey, index int, secret ecmath.Scalar, msg []byte, rand io.Reader) (*Signer, error) {
+	agg, err := aggregate(pubkeys)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(pubkeys) {
+		return nil, errors.WithDetailf(ErrParticipants, "index %d out of range", index)
+	}
+	var pub ecmath.Point
+	pub.ScMulBase(&secret)
+	if !pub.ConstTimeEqual(&agg.keys[index]) {
+		return nil, errors.Wrap(ErrWrongKey)
+	}
+
+	s := &Signer{
+		agg:    agg,
+		index:  index,
+		msg:    append([]byte(nil), msg...),
+		secret: secret,
+	}
+	var idx [8]byte
+	binary.BigEndian.PutUint64(idx[:], uint64(index))
+	s.nonce, err = newNonce(&secret, rand, agg.list[:], idx[:], msg)
+	if err != nil {
+		return nil, err
+	}
+	var r ecmath.Point
+	r.ScMulBase(&s.nonce)
+	s.noncePoint = r.Encode()
+	return s, nil
+}
+
+// AggregateKey returns the aggregate key the signature will be
+// valid for.
+func (s *Signer) AggregateKey() ed25519.PublicKey {
+	return s.agg.pubkey()
+}
+
+// Commitment returns the commitment to s's nonce, to be sent to the
+// other parties in the first round.
+func (s *Signer) Commitment() [32]byte {
+	return commit(s.noncePoint)
+}
+
+// SetCommitments records the commitments of all the parties, in the
+// order of their keys, including s's own.
+func (s *Signer) SetCommitments(commitments [][32]byte) error {
+	if s.commitments != nil {
+		return errors.WithDetail(ErrRound, "commitments already set")
+	}
+	if len(commitments) != len(s.agg.keys) {
+		return errors.WithDetailf(ErrParticipants, "got %d commitments for %d keys", len(commitments), len(s.agg.keys))
+	}
+	if commitments[s.index] != s.Commitment() {
+		return errors.WithDetailf(ErrBadCommitment, "commitment %d is not this signer's", s.index)
+	}
+	s.commitments = append([][32]byte(nil), commitments...)
+	return nil
+}
+
+// Nonce returns s's nonce, to be sent to the other parties in the
+// second round. It may only be called once all commitments are set:
+// revealing a nonce before seeing everyone's commitments would let
+// another party choose their nonce in response.
+func (s *Signer) Nonce() ([32]byte, error) {
+	if s.commitments == nil {
+		return [32]byte{}, errors.WithDetail(ErrRound, "commitments not set")
+	}
+	return s.noncePoint, nil
+}
+
+// SetNonces records the nonces of all the parties, in the order of
+// their keys, after checking each against its commitment.
+func (s *Signer) SetNonces(nonces [][32]byte) error {
+	if s.commitments == nil {
+		return errors.WithDetail(ErrRound, "commitments not set")
+	}
+	if s.nonces != nil {
+		return errors.WithDetail(ErrRound, "nonces already set")
+	}
+	if len(nonces) != len(s.agg.keys) {
+		return errors.WithDetailf(ErrParticipants, "got %d nonces for %d keys", len(nonces), len(s.agg.keys))
+	}
+	points := make([]ecmath.Point, len(nonces))
+	for i, n := range nonces {
+		if commit(n) != s.commitments[i] {
+			return errors.WithDetailf(ErrBadCommitment, "nonce %d", i)
+		}
+		if _, ok := points[i].Decode(n); !ok {
+			return errors.WithDetailf(ErrBadNonce, "nonce %d is not a curve point", i)
+		}
+	}
+	s.nonces = points
+	return nil
+}
+
+// SetAdaptor makes s produce an adaptor signature for the point t
+// instead of a complete signature. All parties must set the same
+// adaptor point before signing. See Adapt and Extract.
+func (s *Signer) SetAdaptor(t [32]byte) error {
+	if s.signed {
+		return errors.Wrap(ErrAlreadySigned)
+	}
+	var p ecmath.Point
+	if _, ok := p.Decode(t); !ok {
+		return errors.WithDetail(ErrBadKey, "adaptor is not a curve point")
+	}
+	s.adaptor = &p
+	s.r = nil
+	return nil
+}
+
+// Sign returns s's partial signature, to be sent to the other
+// parties in the third round. It may only be called once: s's nonce
+// is erased afterward.
+func (s *Signer) Sign() ([32]byte, error) {
+	if s.signed {
+		return [32]byte{}, errors.Wrap(ErrAlreadySigned)
+	}
+	if s.nonces == nil {
+		return [32]byte{}, errors.WithDetail(ErrRound, "nonces not set")
+	}
+	c := s.challenge()
+
+	// s_i = r_i + c*a_i*x_i
+	var ax, partial ecmath.Scalar
+	ax.MulAdd(&s.agg.coefs[s.index], &s.secret, &ecmath.Zero)
+	partial.MulAdd(&c, &ax, &s.nonce)
+
+	s.nonce = ecmath.Zero
+	s.secret = ecmath.Zero
+	s.signed = true
+	return [32]byte(partial), nil
+}
+
+// Combine verifies the partial signatures of all the parties, in
+// the order of their keys, and combines them into a signature of
+// the message by the aggregate key.
+//
+// If an adaptor is set, the result is an adaptor signature, which
+// can be verified with VerifyAdaptor and completed with Adapt.
+func (s *Signer) Combine(partials [][32]byte) ([]byte, error) {
+	if s.nonces == nil {
+		return nil, errors.WithDetail(ErrRound, "nonces not set")
+	}
+	if len(partials) != len(s.agg.keys) {
+		return nil, errors.WithDetailf(ErrParticipants, "got %d partial signatures for %d keys", len(partials), len(s.agg.keys))
+	}
+	c := s.challenge()
+
+	var sum ecmath.Scalar
+	for i := range partials {
+		p := ecmath.Scalar(partials[i])
+
+		// Check that s_i*B = R_i + c*a_i*X_i.
+		var ca ecmath.Scalar
+		ca.MulAdd(&c, &s.agg.coefs[i], &ecmath.Zero)
+		var lhs, rhs ecmath.Point
+		lhs.ScMulBase(&p)
+		rhs.ScMul(&s.agg.keys[i], &ca)
+		rhs.Add(&rhs, &s.nonces[i])
+		if !lhs.ConstTimeEqual(&rhs) {
+			return nil, errors.WithDetailf(ErrBadPartial, "partial signature %d", i)
+		}
+		sum.Add(&sum, &p)
+	}
+
+	// An adaptor signature carries the aggregate of the parties'
+	// nonces alone; Adapt adds the adaptor point to it.
+	r := ecmath.ZeroPoint
+	for i := range s.nonces {
+		r.Add(&r, &s.nonces[i])
+	}
+	re := r.Encode()
+	return append(re[:], sum[:]...), nil
+}
+
+// challenge returns the ed25519 challenge H(R || X || m) for the
+// aggregate nonce R and the aggregate key X.
+func (s *Signer) challenge() ecmath.Scalar {
+	if s.r == nil {
+		r := ecmath.ZeroPoint
+		for i := range s.nonces {
+			r.Add(&r, &s.nonces[i])
+		}
+		if s.adaptor != nil {
+			r.Add(&r, s.adaptor)
+		}
+		s.r = &r
+	}
+	return challenge(s.r.Encode(), s.agg.key.Encode(), s.msg)
+}
+
+// challenge computes the challenge scalar exactly as ed25519
+// signing and verification do.
+func challenge(r, pub [32]byte, msg []byte) ecmath.Scalar {
+	h := sha512.New()
+	h.Write(r[:])
+	h.Write(pub[:])
+	h.Write(msg)
+	var d [64]byte
+	h.Sum(d[:0])
+	var c ecmath.Scalar
+	c.Reduce(&d)
+	return c
+}
+
+func commit(noncePoint [32]byte) [32]byte {
+	h := sha512.New512_256()
+	h.Write([]byte(tagCommitment))
+	h.Write(noncePoint[:])
+	var c [32]byte
+	h.Sum(c[:0])
+	return c
+}
+
+// newNonce returns a nonce derived from 32 bytes read from rand,
+// hashed with secret and parts.
+func newNonce(secret *ecmath.Scalar, rand io.Reader, parts ...[]byte) (ecmath.Scalar, error) {
+	if rand == nil {
+		rand = cryptorand.Reader
+	}
+	var entropy [32]byte
+	_, err := io.ReadFull(rand, entropy[:])
+	if err != nil {
+		return ecmath.Zero, errors.Wrap(err, "reading nonce entropy")
+	}
+	return hashToScalar(tagNonce, append([][]byte{secret[:], entropy[:]}, parts...)...), nil
+}
+
+func hashToScalar(tag string, parts ...[]byte) ecmath.Scalar {
+	h := sha512.New()
+	h.Write([]byte(tag))
+	for _, p := range parts {
+		h.Write(p)
+	}
+	var d [64]byte
+	h.Sum(d[:0])
+	var s ecmath.Scalar
+	s.Reduce(&d)
+	return s
+}
@@ -0,0 +1,342 @@
+package musig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/ed25519/ecmath"
+	"chain/errors"
+	"chain/protocol/vm"
+	"chain/protocol/vm/vmutil"
+)
+
+func TestSign(t *testing.T) {
+	msg := []byte("transaction sighash")
+	for n := 1; n <= 4; n++ {
+		secrets, pubkeys := testKeys(t, n, nil)
+		signers := testSigners(t, secrets, pubkeys, msg)
+		sig := runProtocol(t, signers)
+
+		agg, err := AggregateKey(pubkeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(agg, signers[0].AggregateKey()) {
+			t.Errorf("%d keys: AggregateKey = %x, Signer.AggregateKey = %x", n, agg, signers[0].AggregateKey())
+		}
+		if !ed25519.Verify(agg, msg, sig) {
+			t.Errorf("%d keys: aggregate signature does not verify", n)
+		}
+		if ed25519.Verify(agg, []byte("other message"), sig) {
+			t.Errorf("%d keys: aggregate signature verifies for another message", n)
+		}
+	}
+}
+
+func TestCheckSig(t *testing.T) {
+	secrets, pubkeys := testKeys(t, 3, nil)
+	agg, err := AggregateKey(pubkeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [32]byte
+	copy(msg[:], "thirty-two byte transaction hash")
+	sig := runProtocol(t, testSigners(t, secrets, pubkeys, msg[:]))
+
+	// The aggregate key can be used wherever a single key can, such
+	// as in a plain CHECKSIG program.
+	b := vmutil.NewBuilder()
+	b.AddData(msg[:]).AddData(agg).AddOp(vm.OP_CHECKSIG)
+	prog, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vm.Verify(&vm.Context{VMVersion: 1, Code: prog, Arguments: [][]byte{sig}})
+	if err != nil {
+		t.Errorf("CHECKSIG of aggregate signature: %v", err)
+	}
+
+	sig[40] ^= 1
+	err = vm.Verify(&vm.Context{VMVersion: 1, Code: prog, Arguments: [][]byte{sig}})
+	if err == nil {
+		t.Error("CHECKSIG of corrupted signature succeeded")
+	}
+}
+
+// TestVectors checks the protocol's output for fixed keys and
+// randomness, so that changes to its hashes or encodings are caught.
+func TestVectors(t *testing.T) {
+	cases := []struct {
+		n       int
+		msg     string
+		wantKey string
+		wantSig string
+	}{{
+		n:       1,
+		msg:     "",
+		wantKey: "6e04db1b06d2610978b5ac991b4cc25ae0dd8c4b6443747140cb4c95d62f3ef8",
+		wantSig: "a5bec00ebd2316d335ad00c20f7a00a7e5939b5b1ba284b9a3d886df19784b390df73113d0ba8f3f3582494bb54662975b97345cdac0855843471aad8415000d",
+	}, {
+		n:       2,
+		msg:     "hello",
+		wantKey: "660205a0b0acf696f3e8b2f4d58997a994bf7c269acb96a4a0692bc95537bbcf",
+		wantSig: "e608c5d193e5b8dc3800a8ba699f0a86f6f4c737b763f9bc2968508a9f0133889a32f6aed0cc69922d586b84f5b024bea0f5d93d66fd003d2025daa596635108",
+	}, {
+		n:       3,
+		msg:     "custody",
+		wantKey: "f886d535d8d03365d2d58dd9c3f87848726ab71a4f6d16d3e2713d7a798a3d24",
+		wantSig: "68818d286bae57fc888959909dcb2a8cbfbd6404a26894677d00f70e222c8ea410144c3894c3bc5a394a7e4f432fc381e8091773e69980f752c7a0418cc53605",
+	}}
+	for _, c := range cases {
+		secrets, pubkeys := testKeys(t, c.n, fixedReader(0))
+		agg, err := AggregateKey(pubkeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(agg); got != c.wantKey {
+			t.Errorf("%d keys: aggregate key = %s, want %s", c.n, got, c.wantKey)
+		}
+
+		signers := make([]*Signer, c.n)
+		for i := range signers {
+			signers[i], err = NewSigner(pubkeys, i, secrets[i], []byte(c.msg), fixedReader(byte(100+i)))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		sig := runProtocol(t, signers)
+		if got := hex.EncodeToString(sig); got != c.wantSig {
+			t.Errorf("%d keys: signature = %s, want %s", c.n, got, c.wantSig)
+		}
+	}
+}
+
+func TestAggregateKeyOrder(t *testing.T) {
+	_, pubkeys := testKeys(t, 2, nil)
+	a, err := AggregateKey(pubkeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := AggregateKey([]ed25519.PublicKey{pubkeys[1], pubkeys[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("aggregate keys of reordered keys are equal")
+	}
+
+	_, err = AggregateKey(nil)
+	if errors.Root(err) != ErrNoKeys {
+		t.Errorf("AggregateKey(nil) got error %v, want %v", err, ErrNoKeys)
+	}
+	_, err = AggregateKey([]ed25519.PublicKey{pubkeys[0][:31]})
+	if errors.Root(err) != ErrBadKey {
+		t.Errorf("AggregateKey of short key got error %v, want %v", err, ErrBadKey)
+	}
+}
+
+// TestNonceBindsSigners checks that the same key and randomness
+// give different nonces for different sets of signers.
+func TestNonceBindsSigners(t *testing.T) {
+	msg := []byte("msg")
+	secrets, pubkeys := testKeys(t, 3, nil)
+
+	a, err := NewSigner(pubkeys[:2], 0, secrets[0], msg, fixedReader(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSigner([]ed25519.PublicKey{pubkeys[0], pubkeys[2]}, 0, secrets[0], msg, fixedReader(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Commitment() == b.Commitment() {
+		t.Error("signers for different key lists have the same nonce")
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	msg := []byte("msg")
+	secrets, pubkeys := testKeys(t, 3, nil)
+
+	_, err := NewSigner(pubkeys, 0, secrets[1], msg, nil)
+	if errors.Root(err) != ErrWrongKey {
+		t.Errorf("NewSigner with wrong secret got error %v, want %v", err, ErrWrongKey)
+	}
+
+	signers := testSigners(t, secrets, pubkeys, msg)
+	_, err = signers[0].Nonce()
+	if errors.Root(err) != ErrRound {
+		t.Errorf("Nonce before commitments got error %v, want %v", err, ErrRound)
+	}
+	_, err = signers[0].Sign()
+	if errors.Root(err) != ErrRound {
+		t.Errorf("Sign before nonces got error %v, want %v", err, ErrRound)
+	}
+
+	commitments := make([][32]byte, len(signers))
+	for i, s := range signers {
+		commitments[i] = s.Commitment()
+	}
+	for _, s := range signers {
+		err = s.SetCommitments(commitments)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	nonces := make([][32]byte, len(signers))
+	for i, s := range signers {
+		nonces[i], err = s.Nonce()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A party that changes its nonce after committing is caught.
+	swapped := append([][32]byte(nil), nonces...)
+	swapped[1], swapped[2] = swapped[2], swapped[1]
+	err = signers[0].SetNonces(swapped)
+	if errors.Root(err) != ErrBadCommitment {
+		t.Errorf("SetNonces with swapped nonces got error %v, want %v", err, ErrBadCommitment)
+	}
+
+	for _, s := range signers {
+		err = s.SetNonces(nonces)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	partials := make([][32]byte, len(signers))
+	for i, s := range signers {
+		partials[i], err = s.Sign()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = signers[0].Sign()
+	if errors.Root(err) != ErrAlreadySigned {
+		t.Errorf("second Sign got error %v, want %v", err, ErrAlreadySigned)
+	}
+
+	bad := append([][32]byte(nil), partials...)
+	bad[2][0] ^= 1
+	_, err = signers[0].Combine(bad)
+	if errors.Root(err) != ErrBadPartial {
+		t.Errorf("Combine with corrupted partial signature got error %v, want %v", err, ErrBadPartial)
+	}
+	_, err = signers[0].Combine(partials[:2])
+	if errors.Root(err) != ErrParticipants {
+		t.Errorf("Combine with missing partial signature got error %v, want %v", err, ErrParticipants)
+	}
+}
+
+// testKeys returns n secret scalars and their public keys, derived
+// as chainkd keys. If r is nil, crypto/rand.Reader is used.
+func testKeys(t *testing.T, n int, r *fixed) ([]ecmath.Scalar, []ed25519.PublicKey) {
+	var (
+		secrets []ecmath.Scalar
+		pubkeys []ed25519.PublicKey
+	)
+	for i := 0; i < n; i++ {
+		var (
+			xprv chainkd.XPrv
+			err  error
+		)
+		if r == nil {
+			xprv, err = chainkd.NewXPrv(nil)
+		} else {
+			xprv, err = chainkd.NewXPrv(r)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s ecmath.Scalar
+		copy(s[:], xprv[:32])
+		secrets = append(secrets, s)
+		pubkeys = append(pubkeys, xprv.XPub().PublicKey())
+	}
+	return secrets, pubkeys
+}
+
+func testSigners(t *testing.T, secrets []ecmath.Scalar, pubkeys []ed25519.PublicKey, msg []byte) []*Signer {
+	signers := make([]*Signer, len(secrets))
+	for i, secret := range secrets {
+		var err error
+		signers[i], err = NewSigner(pubkeys, i, secret, msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signers
+}
+
+// runProtocol runs the three signing rounds among signers, passing
+// every message to every signer, and returns the combined signature.
+func runProtocol(t *testing.T, signers []*Signer) []byte {
+	commitments := make([][32]byte, len(signers))
+	for i, s := range signers {
+		commitments[i] = s.Commitment()
+	}
+	for _, s := range signers {
+		err := s.SetCommitments(commitments)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nonces := make([][32]byte, len(signers))
+	for i, s := range signers {
+		var err error
+		nonces[i], err = s.Nonce()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range signers {
+		err := s.SetNonces(nonces)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	partials := make([][32]byte, len(signers))
+	for i, s := range signers {
+		var err error
+		partials[i], err = s.Sign()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var sig []byte
+	for i, s := range signers {
+		got, err := s.Combine(partials)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && !bytes.Equal(got, sig) {
+			t.Fatalf("signer %d combined signature %x, signer 0 combined %x", i, got, sig)
+		}
+		sig = got
+	}
+	return sig
+}
+
+// fixed is a deterministic source of "random" bytes for test
+// vectors: the bytes seed, seed+1, seed+2, and so on.
+type fixed struct {
+	next byte
+}
+
+func fixedReader(seed byte) *fixed {
+	return &fixed{next: seed}
+}
+
+func (f *fixed) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = f.next
+		f.next++
+	}
+	return len(p), nil
+}
@@ -0,0 +1,123 @@
+package sinkdb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"chain/database/sinkdb/internal/sinkpb"
+	"chain/errors"
+)
+
+// backupVersion is the version of the Backup format
+// written by this package.
+const backupVersion = 1
+
+// ErrBadBackup is returned by Restore when a backup
+// is malformed or can't be restored.
+var ErrBadBackup = errors.New("invalid backup")
+
+// Backup is a copy of all the data in the store, including
+// the cluster membership, as of a single point in the raft log.
+type Backup struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Index is the raft log index of the last
+	// write included in the backup.
+	Index uint64 `json:"index"`
+
+	// Members lists the addresses of the cluster's members
+	// when the backup was made. It's for information only;
+	// Restore does not use it.
+	Members []string `json:"members"`
+
+	// Snapshot is the encoded data.
+	Snapshot []byte `json:"snapshot"`
+}
+
+// Backup returns a copy of all the data in the store.
+// It performs a linearizable read: the backup includes
+// all writes that happened before the call to Backup.
+func (db *DB) Backup(ctx context.Context) (*Backup, error) {
+	err := db.raft.WaitRead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, index, err := db.state.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	b := &Backup{
+		Version:   backupVersion,
+		CreatedAt: time.Now().UTC(),
+		Index:     index,
+		Snapshot:  data,
+	}
+	for _, addr := range db.state.Peers() {
+		b.Members = append(b.Members, addr)
+	}
+	sort.Strings(b.Members)
+	return b, nil
+}
+
+// Restore initializes a new single-node cluster,
+// with the local process as its only member,
+// holding the data in b.
+// The database must not already be part of a cluster.
+//
+// Each entry in rewriteAddrs replaces an allowed member
+// address (the key) with another (the value),
+// so that nodes moved to new addresses can join
+// the restored cluster.
+func (db *DB) Restore(b *Backup, rewriteAddrs map[string]string) error {
+	if b.Version != backupVersion {
+		return errors.WithDetailf(ErrBadBackup, "Unsupported backup version %d.", b.Version)
+	}
+	if b.Index == 0 {
+		return errors.WithDetail(ErrBadBackup, "The backup is empty.")
+	}
+	var snapshot sinkpb.Snapshot
+	err := proto.Unmarshal(b.Snapshot, &snapshot)
+	if err != nil {
+		return errors.Sub(ErrBadBackup, err)
+	}
+	if snapshot.State == nil {
+		snapshot.State = make(map[string][]byte)
+	}
+	if snapshot.Version == nil {
+		snapshot.Version = make(map[string]uint64)
+	}
+
+	// Remove all the old addresses before adding any new
+	// ones, so that addresses can be swapped.
+	type entry struct {
+		value   []byte
+		version uint64
+	}
+	moved := make(map[string]entry)
+	for old, new := range rewriteAddrs {
+		oldKey := allowedMemberPrefix + "/" + old
+		value, ok := snapshot.State[oldKey]
+		if new == "" {
+			return errors.WithDetailf(ErrBadBackup, "No new address for %q.", old)
+		} else if !ok {
+			return errors.WithDetailf(ErrBadBackup, "Address %q is not an allowed member.", old)
+		}
+		moved[allowedMemberPrefix+"/"+new] = entry{value, snapshot.Version[oldKey]}
+		delete(snapshot.State, oldKey)
+		delete(snapshot.Version, oldKey)
+	}
+	for key, e := range moved {
+		snapshot.State[key] = e.value
+		snapshot.Version[key] = e.version
+	}
+
+	data, err := proto.Marshal(&snapshot)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return db.raft.Restore(data, b.Index)
+}
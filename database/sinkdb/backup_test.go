@@ -0,0 +1,97 @@
+package sinkdb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"chain/database/sinkdb/internal/sinkpb"
+	"chain/errors"
+)
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+
+	dir1, err := ioutil.TempDir("", "sinkdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir1)
+	dir2, err := ioutil.TempDir("", "sinkdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir2)
+
+	sdb1, err := Open("", dir1, new(http.Client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdb1.Close()
+	err = sdb1.RaftService().Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sdb1.Exec(ctx, Set("/x", &sinkpb.Op{Key: "x"}), AddAllowedMember("10.0.0.1:1999"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := sdb1.Backup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sdb1.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sdb2, err := Open("", dir2, new(http.Client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdb2.Close()
+
+	bad := *backup
+	bad.Version = 99
+	err = sdb2.Restore(&bad, nil)
+	if errors.Root(err) != ErrBadBackup {
+		t.Errorf("Restore(version 99): got error %v, want %v", err, ErrBadBackup)
+	}
+	err = sdb2.Restore(backup, map[string]string{"10.9.9.9:1999": "10.0.0.2:1999"})
+	if errors.Root(err) != ErrBadBackup {
+		t.Errorf("Restore with unknown address: got error %v, want %v", err, ErrBadBackup)
+	}
+
+	err = sdb2.Restore(backup, map[string]string{"10.0.0.1:1999": "10.0.0.2:1999"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(sdb *DB) {
+		var v sinkpb.Op
+		ver, err := sdb.Get(ctx, "/x", &v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ver.Exists() || v.Key != "x" {
+			t.Errorf("restored /x = %v (exists %v), want x", v.Key, ver.Exists())
+		}
+		if sdb.state.IsAllowedMember("10.0.0.1:1999") || !sdb.state.IsAllowedMember("10.0.0.2:1999") {
+			t.Error("allowed member address was not rewritten")
+		}
+	}
+	check(sdb2)
+
+	// The restored data survives a restart.
+	err = sdb2.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdb3, err := Open("", dir2, new(http.Client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdb3.Close()
+	check(sdb3)
+}
@@ -6,10 +6,12 @@
 Package sinkpb is a generated protocol buffer package.
 
 It is generated from these files:
+
 	op.proto
 	snapshot.proto
 
 It has these top-level messages:
+
 	Op
 	Cond
 	Instruction
@@ -56,12 +58,16 @@ func (Op_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{
 type Cond_Type int32
 
 const (
-	Cond_KEY_EXISTS      Cond_Type = 0
-	Cond_NOT_KEY_EXISTS  Cond_Type = 1
-	Cond_VALUE_EQUAL     Cond_Type = 2
-	Cond_NOT_VALUE_EQUAL Cond_Type = 3
-	Cond_INDEX_EQUAL     Cond_Type = 4
-	Cond_NOT_INDEX_EQUAL Cond_Type = 5
+	Cond_KEY_EXISTS              Cond_Type = 0
+	Cond_NOT_KEY_EXISTS          Cond_Type = 1
+	Cond_VALUE_EQUAL             Cond_Type = 2
+	Cond_NOT_VALUE_EQUAL         Cond_Type = 3
+	Cond_INDEX_EQUAL             Cond_Type = 4
+	Cond_NOT_INDEX_EQUAL         Cond_Type = 5
+	Cond_PREFIX_EXISTS           Cond_Type = 6
+	Cond_NOT_PREFIX_EXISTS       Cond_Type = 7
+	Cond_PREFIX_DIGEST_EQUAL     Cond_Type = 8
+	Cond_NOT_PREFIX_DIGEST_EQUAL Cond_Type = 9
 )
 
 var Cond_Type_name = map[int32]string{
@@ -71,14 +77,22 @@ var Cond_Type_name = map[int32]string{
 	3: "NOT_VALUE_EQUAL",
 	4: "INDEX_EQUAL",
 	5: "NOT_INDEX_EQUAL",
+	6: "PREFIX_EXISTS",
+	7: "NOT_PREFIX_EXISTS",
+	8: "PREFIX_DIGEST_EQUAL",
+	9: "NOT_PREFIX_DIGEST_EQUAL",
 }
 var Cond_Type_value = map[string]int32{
-	"KEY_EXISTS":      0,
-	"NOT_KEY_EXISTS":  1,
-	"VALUE_EQUAL":     2,
-	"NOT_VALUE_EQUAL": 3,
-	"INDEX_EQUAL":     4,
-	"NOT_INDEX_EQUAL": 5,
+	"KEY_EXISTS":              0,
+	"NOT_KEY_EXISTS":          1,
+	"VALUE_EQUAL":             2,
+	"NOT_VALUE_EQUAL":         3,
+	"INDEX_EQUAL":             4,
+	"NOT_INDEX_EQUAL":         5,
+	"PREFIX_EXISTS":           6,
+	"NOT_PREFIX_EXISTS":       7,
+	"PREFIX_DIGEST_EQUAL":     8,
+	"NOT_PREFIX_DIGEST_EQUAL": 9,
 }
 
 func (x Cond_Type) String() string {
@@ -193,24 +207,27 @@ func init() {
 func init() { proto.RegisterFile("op.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 300 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x91, 0x51, 0x6b, 0xf2, 0x30,
-	0x14, 0x86, 0x4d, 0x1b, 0xfd, 0xbe, 0x1d, 0x45, 0xbb, 0xb3, 0x5d, 0x14, 0x76, 0x53, 0x3a, 0x06,
-	0x65, 0x8c, 0x5e, 0xb8, 0x5f, 0x20, 0x33, 0x17, 0x32, 0x51, 0x16, 0xeb, 0x70, 0x57, 0xa2, 0x36,
-	0x8c, 0xe2, 0x48, 0x42, 0x5b, 0x65, 0xfd, 0xbd, 0xfb, 0x23, 0x23, 0xad, 0xb2, 0xec, 0x76, 0x77,
-	0x39, 0xef, 0xf3, 0x84, 0x37, 0xe1, 0xc0, 0x7f, 0xa5, 0x63, 0x9d, 0xab, 0x52, 0x61, 0xa7, 0xc8,
-	0xe4, 0x5e, 0x6f, 0x43, 0x09, 0xce, 0x5c, 0xe3, 0x2d, 0xd0, 0xb2, 0xd2, 0xc2, 0x27, 0x01, 0x89,
-	0xfa, 0xc3, 0x41, 0xdc, 0xc0, 0x78, 0xae, 0xe3, 0xa4, 0xd2, 0x82, 0xd7, 0x10, 0x3d, 0x70, 0xf7,
-	0xa2, 0xf2, 0x9d, 0x80, 0x44, 0x17, 0xdc, 0x1c, 0xf1, 0x1a, 0xda, 0xc7, 0xcd, 0xc7, 0x41, 0xf8,
-	0x6e, 0x40, 0xa2, 0x1e, 0x6f, 0x86, 0xf0, 0x06, 0xa8, 0xb9, 0x85, 0xff, 0xc0, 0x5d, 0xb0, 0xc4,
-	0x6b, 0x21, 0x40, 0x67, 0xcc, 0xa6, 0x2c, 0x61, 0x1e, 0x09, 0xbf, 0x08, 0xd0, 0x27, 0x25, 0x53,
-	0xbc, 0xfb, 0x55, 0x79, 0x79, 0xae, 0x34, 0xec, 0x0f, 0xa5, 0x26, 0xcd, 0x64, 0x2a, 0x3e, 0x7d,
-	0x1a, 0x90, 0x88, 0xf2, 0x66, 0x08, 0x8f, 0xa7, 0xa7, 0xf4, 0x01, 0x9e, 0xd9, 0xdb, 0x9a, 0xad,
-	0x26, 0x8b, 0x64, 0xe1, 0xb5, 0x10, 0xa1, 0x3f, 0x9b, 0x27, 0x6b, 0x2b, 0x23, 0x38, 0x80, 0xee,
-	0xeb, 0x68, 0xba, 0x64, 0x6b, 0xf6, 0xb2, 0x1c, 0x4d, 0x3d, 0x07, 0xaf, 0x60, 0x60, 0x24, 0x3b,
-	0x74, 0x8d, 0x35, 0x99, 0x8d, 0xd9, 0xea, 0x14, 0xd0, 0xb3, 0x65, 0x87, 0xed, 0xf0, 0x1d, 0xba,
-	0x13, 0x59, 0x94, 0xf9, 0x61, 0x57, 0x66, 0x4a, 0xe2, 0x03, 0xc0, 0x4e, 0xc9, 0x34, 0x33, 0x43,
-	0xe1, 0x93, 0xc0, 0x8d, 0xba, 0xc3, 0x9e, 0xfd, 0x63, 0x6e, 0x71, 0xbc, 0x07, 0x50, 0x5a, 0xe4,
-	0x9b, 0xc6, 0x76, 0x6a, 0x1b, 0x7e, 0x56, 0xc2, 0x2d, 0xba, 0xed, 0xd4, 0xdb, 0x7c, 0xfc, 0x0e,
-	0x00, 0x00, 0xff, 0xff, 0xde, 0x11, 0x48, 0x9b, 0xd9, 0x01, 0x00, 0x00,
+	// 339 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x92, 0xdf, 0x6a, 0xfa, 0x30,
+	0x14, 0xc7, 0x4d, 0x5b, 0xab, 0x1e, 0xfd, 0x69, 0x3d, 0xfe, 0x86, 0x05, 0x6f, 0x4a, 0xc7, 0xa0,
+	0x8c, 0xd1, 0x0b, 0xf7, 0x04, 0x32, 0xb3, 0x51, 0x26, 0xba, 0xb5, 0x75, 0xb8, 0x2b, 0xf1, 0x4f,
+	0x19, 0xc5, 0x91, 0x04, 0xad, 0x63, 0xbe, 0xdf, 0x9e, 0x61, 0xcf, 0x33, 0x62, 0x2b, 0x8b, 0xb7,
+	0xbb, 0xcb, 0xf9, 0x7c, 0x3f, 0x39, 0x27, 0x24, 0x81, 0x2a, 0x17, 0xbe, 0xd8, 0xf2, 0x8c, 0xa3,
+	0xb9, 0x4b, 0xd9, 0x46, 0x2c, 0x5d, 0x06, 0xda, 0x44, 0xe0, 0x25, 0x18, 0xd9, 0x41, 0x24, 0x36,
+	0x71, 0x88, 0xd7, 0xec, 0xb7, 0xfc, 0x3c, 0xf4, 0x27, 0xc2, 0x8f, 0x0f, 0x22, 0x09, 0x8f, 0x21,
+	0x5a, 0xa0, 0x6f, 0x92, 0x83, 0xad, 0x39, 0xc4, 0xab, 0x85, 0x72, 0x89, 0xff, 0xa1, 0xfc, 0xb1,
+	0x78, 0xdf, 0x27, 0xb6, 0xee, 0x10, 0xaf, 0x11, 0xe6, 0x85, 0xdb, 0x03, 0x43, 0xee, 0xc2, 0x0a,
+	0xe8, 0x11, 0x8d, 0xad, 0x12, 0x02, 0x98, 0x43, 0x3a, 0xa2, 0x31, 0xb5, 0x88, 0xfb, 0xa5, 0x81,
+	0x71, 0xc7, 0xd9, 0x1a, 0xaf, 0xce, 0x46, 0xb6, 0x4f, 0x23, 0x65, 0xf6, 0x87, 0xa1, 0x92, 0xa6,
+	0x6c, 0x9d, 0x7c, 0xda, 0x86, 0x43, 0x3c, 0x23, 0xcc, 0x0b, 0xf7, 0x9b, 0x14, 0x67, 0x69, 0x02,
+	0x3c, 0xd2, 0xd7, 0x39, 0x9d, 0x05, 0x51, 0x1c, 0x59, 0x25, 0x44, 0x68, 0x8e, 0x27, 0xf1, 0x5c,
+	0x61, 0x04, 0x5b, 0x50, 0x7f, 0x19, 0x8c, 0xa6, 0x74, 0x4e, 0x9f, 0xa7, 0x83, 0x91, 0xa5, 0x61,
+	0x07, 0x5a, 0x52, 0x52, 0xa1, 0x2e, 0xad, 0x60, 0x3c, 0xa4, 0xb3, 0x02, 0x18, 0x27, 0x4b, 0x85,
+	0x65, 0x6c, 0xc3, 0xbf, 0xa7, 0x90, 0xde, 0x07, 0xb3, 0x53, 0x7b, 0x13, 0x2f, 0xa0, 0x2d, 0xbd,
+	0x73, 0x5c, 0xc1, 0x2e, 0x74, 0x0a, 0x34, 0x0c, 0x1e, 0x68, 0x14, 0x17, 0x2d, 0xaa, 0xd8, 0x83,
+	0xae, 0xe2, 0x9f, 0x85, 0x35, 0xf7, 0x0d, 0xea, 0x01, 0xdb, 0x65, 0xdb, 0xfd, 0x2a, 0x4b, 0x39,
+	0xc3, 0x1b, 0x80, 0x15, 0x67, 0xeb, 0x54, 0x16, 0x3b, 0x9b, 0x38, 0xba, 0x57, 0xef, 0x37, 0xd4,
+	0x2b, 0x0d, 0x95, 0x1c, 0xaf, 0x01, 0xb8, 0x48, 0xb6, 0x8b, 0xdc, 0xd6, 0x8e, 0x36, 0xfc, 0xbe,
+	0x79, 0xa8, 0xa4, 0x4b, 0xf3, 0xf8, 0x5d, 0x6e, 0x7f, 0x06, 0x00, 0xa3, 0xbd, 0x47, 0x84, 0x3a,
+	0x02, 0x00, 0x00,
 }
@@ -1,9 +1,12 @@
 package sinkdb
 
 import (
+	"strings"
+
 	"github.com/golang/protobuf/proto"
 
 	"chain/database/sinkdb/internal/sinkpb"
+	"chain/errors"
 )
 
 // Op represents a change to the data store.
@@ -64,6 +67,39 @@ func IfNotModified(v Version) Op {
 	}
 }
 
+// IfPrefixEmpty encodes a conditional to make an instruction
+// successful only if no key has the provided prefix.
+func IfPrefixEmpty(prefix string) Op {
+	return Op{
+		conds: []*sinkpb.Cond{{
+			Type: sinkpb.Cond_NOT_PREFIX_EXISTS,
+			Key:  prefix,
+		}},
+	}
+}
+
+// IfPrefixNotModified encodes a conditional to make an instruction
+// successful only if the keys with the provided prefix,
+// and their versions, are exactly those in items.
+// Items should be the result of List(prefix).
+// The instruction fails if any key with the prefix
+// has been set, added, or deleted since.
+func IfPrefixNotModified(prefix string, items []Item) Op {
+	for _, it := range items {
+		if !strings.HasPrefix(it.Key, prefix) {
+			err := errors.New("item key does not have prefix")
+			return Op{err: errors.Wrap(err, it.Key)}
+		}
+	}
+	return Op{
+		conds: []*sinkpb.Cond{{
+			Type:  sinkpb.Cond_PREFIX_DIGEST_EQUAL,
+			Key:   prefix,
+			Value: itemsDigest(items),
+		}},
+	}
+}
+
 // Delete encodes a delete operation for key.
 func Delete(key string) Op {
 	return Op{
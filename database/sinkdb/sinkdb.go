@@ -121,6 +121,71 @@ func (db *DB) GetStale(key string, v proto.Message) (Version, error) {
 	return ver, proto.Unmarshal(buf, v)
 }
 
+// Item is a key read from the store, with its
+// value and version.
+type Item struct {
+	Key     string
+	Value   []byte
+	Version Version
+}
+
+// Unmarshal unmarshals the item's value into v.
+func (it Item) Unmarshal(v proto.Message) error {
+	return proto.Unmarshal(it.Value, v)
+}
+
+// List performs a linearizable read of the keys with
+// the provided prefix, in order.
+func (db *DB) List(ctx context.Context, prefix string) ([]Item, error) {
+	return db.Range(ctx, prefix, prefixEnd(prefix))
+}
+
+// ListStale performs a non-linearizable read of the keys
+// with the provided prefix, in order. The items may be stale.
+func (db *DB) ListStale(prefix string) []Item {
+	return db.RangeStale(prefix, prefixEnd(prefix))
+}
+
+// Range performs a linearizable read of the keys k
+// with start <= k < end, in order.
+// If end is empty, there is no upper bound.
+func (db *DB) Range(ctx context.Context, start, end string) ([]Item, error) {
+	err := db.raft.WaitRead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.state.getRange(start, end), nil
+}
+
+// RangeStale performs a non-linearizable read of the keys k
+// with start <= k < end, in order. The items may be stale.
+// If end is empty, there is no upper bound.
+func (db *DB) RangeStale(start, end string) []Item {
+	return db.state.getRange(start, end) // read directly from state
+}
+
+// Watch returns a channel that receives a value after
+// each change to a key with the provided prefix
+// (or to the key itself) is applied to this node's
+// replica of the data.
+// Notifications are coalesced: a value received stands
+// for one or more changes since the last one.
+// Callers can use Watch to invalidate cached reads:
+// after a value is received, reads reflect the change.
+//
+// The watch stops when ctx is done.
+// The channel is never closed.
+func (db *DB) Watch(ctx context.Context, prefix string) <-chan struct{} {
+	c, stop := db.state.watch(prefix)
+	if done := ctx.Done(); done != nil {
+		go func() {
+			<-done
+			stop()
+		}()
+	}
+	return c
+}
+
 // RaftService returns the raft service used for replication.
 func (db *DB) RaftService() *raft.Service {
 	return db.raft
@@ -5,7 +5,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"reflect"
 	"testing"
+
+	"chain/database/sinkdb/internal/sinkpb"
 )
 
 func TestRestartDB(t *testing.T) {
@@ -54,3 +57,64 @@ func TestRestartDB(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+
+	raftDir, err := ioutil.TempDir("", "sinkdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(raftDir)
+
+	sdb, err := Open("", raftDir, new(http.Client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdb.Close()
+	err = sdb.RaftService().Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sdb.Exec(ctx,
+		Set("/test/b", &sinkpb.Op{Key: "b"}),
+		Set("/test/a", &sinkpb.Op{Key: "a"}),
+		Set("/testing", &sinkpb.Op{Key: "testing"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := sdb.List(ctx, "/test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, it := range items {
+		var v sinkpb.Op
+		err = it.Unmarshal(&v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !it.Version.Exists() || it.Version.Key() != it.Key {
+			t.Errorf("item %s has version %+v", it.Key, it.Version)
+		}
+		got = append(got, v.Key)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List(/test/) = %v want %v", got, want)
+	}
+	if stale := sdb.ListStale("/test/"); !reflect.DeepEqual(stale, items) {
+		t.Errorf("ListStale(/test/) = %v want %v", stale, items)
+	}
+
+	err = sdb.Exec(ctx, IfPrefixNotModified("/test/", items), Delete("/test/a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sdb.Exec(ctx, IfPrefixNotModified("/test/", items), Delete("/test/b"))
+	if err != ErrConflict {
+		t.Errorf("Exec with modified prefix: got error %v want %v", err, ErrConflict)
+	}
+}
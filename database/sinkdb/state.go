@@ -2,8 +2,11 @@ package sinkdb
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"io"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/golang/protobuf/proto"
@@ -25,14 +28,47 @@ type state struct {
 	peers        map[uint64]string // id -> addr
 	appliedIndex uint64
 	version      map[string]uint64 //key -> value index
+	watchers     map[*watcher]bool
+}
+
+// watcher receives notifications of changes
+// to keys with prefix.
+type watcher struct {
+	prefix string
+	c      chan struct{} // buffered, capacity 1
+}
+
+// notify sends to w.c without blocking.
+// If a notification is already pending, the new one
+// is coalesced with it.
+func (w *watcher) notify() {
+	select {
+	case w.c <- struct{}{}:
+	default:
+	}
 }
 
 // newState returns a new State.
 func newState() *state {
 	return &state{
-		state:   map[string][]byte{nextNodeID: []byte("2")},
-		peers:   make(map[uint64]string),
-		version: make(map[string]uint64),
+		state:    map[string][]byte{nextNodeID: []byte("2")},
+		peers:    make(map[uint64]string),
+		version:  make(map[string]uint64),
+		watchers: make(map[*watcher]bool),
+	}
+}
+
+// watch registers a watcher for changes to keys with
+// prefix. The returned function unregisters it.
+func (s *state) watch(prefix string) (c <-chan struct{}, stop func()) {
+	w := &watcher{prefix: prefix, c: make(chan struct{}, 1)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers[w] = true
+	return w.c, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.watchers, w)
 	}
 }
 
@@ -100,6 +136,11 @@ func (s *state) RestoreSnapshot(data []byte, index uint64) error {
 	if s.version == nil {
 		s.version = make(map[string]uint64)
 	}
+
+	// Any key might have changed.
+	for w := range s.watchers {
+		w.notify()
+	}
 	return errors.Wrap(err)
 }
 
@@ -162,6 +203,22 @@ func (s *state) Apply(data []byte, index uint64) (satisfied bool) {
 			if ok := (s.version[cond.Key] == cond.Index); ok != y {
 				return false
 			}
+		case sinkpb.Cond_NOT_PREFIX_EXISTS:
+			y = false
+			fallthrough
+		case sinkpb.Cond_PREFIX_EXISTS:
+			ok := len(s.scan(cond.Key, prefixEnd(cond.Key), 1)) > 0
+			if ok != y {
+				return false
+			}
+		case sinkpb.Cond_NOT_PREFIX_DIGEST_EQUAL:
+			y = false
+			fallthrough
+		case sinkpb.Cond_PREFIX_DIGEST_EQUAL:
+			items := s.scan(cond.Key, prefixEnd(cond.Key), 0)
+			if ok := bytes.Equal(itemsDigest(items), cond.Value); ok != y {
+				return false
+			}
 		default:
 			panic(errors.New("unknown condition type"))
 		}
@@ -177,6 +234,11 @@ func (s *state) Apply(data []byte, index uint64) (satisfied bool) {
 		default:
 			panic(errors.New("unknown operation type"))
 		}
+		for w := range s.watchers {
+			if strings.HasPrefix(op.Key, w.prefix) {
+				w.notify()
+			}
+		}
 	}
 	return true
 }
@@ -191,6 +253,67 @@ func (s *state) get(key string) ([]byte, Version) {
 	return b, Version{key, ok, n}
 }
 
+// getRange performs a provisional read of the keys k
+// with start <= k < end, in order.
+// If end is empty, there is no upper bound.
+func (s *state) getRange(start, end string) []Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scan(start, end, 0)
+}
+
+// scan returns up to limit items with keys in [start, end),
+// in order. A limit of 0 means no limit.
+// The caller must hold s.mu.
+func (s *state) scan(start, end string, limit int) []Item {
+	var keys []string
+	for k := range s.state {
+		if k >= start && (end == "" || k < end) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	items := make([]Item, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, Item{
+			Key:     k,
+			Value:   s.state[k],
+			Version: Version{k, true, s.version[k]},
+		})
+	}
+	return items
+}
+
+// prefixEnd returns the least key greater than
+// every key with the given prefix, or "" if there
+// is no such key.
+func prefixEnd(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return ""
+}
+
+// itemsDigest returns a hash of the keys and versions
+// of items. It changes whenever a key is set, added,
+// or deleted.
+func itemsDigest(items []Item) []byte {
+	h := sha256.New()
+	for _, it := range items {
+		h.Write(proto.EncodeVarint(uint64(len(it.Key))))
+		h.Write([]byte(it.Key))
+		h.Write(proto.EncodeVarint(it.Version.n))
+	}
+	return h.Sum(nil)
+}
+
 // AppliedIndex returns the raft log index (applied index) of current state
 func (s *state) AppliedIndex() uint64 {
 	s.mu.Lock()
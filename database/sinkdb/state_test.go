@@ -7,6 +7,10 @@ import (
 	"os"
 	"reflect"
 	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"chain/database/sinkdb/internal/sinkpb"
 )
 
 func TestRemovePeerAddr(t *testing.T) {
@@ -66,3 +70,164 @@ func TestAllowedMember(t *testing.T) {
 		t.Fatal("expected 5678 to not be a potential member")
 	}
 }
+
+func TestPrefixEnd(t *testing.T) {
+	cases := []struct{ prefix, want string }{
+		{"", ""},
+		{"/a/", "/a0"},
+		{"a\xff", "b"},
+		{"\xff\xff", ""},
+	}
+	for _, c := range cases {
+		if got := prefixEnd(c.prefix); got != c.want {
+			t.Errorf("prefixEnd(%q) = %q want %q", c.prefix, got, c.want)
+		}
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	s := newState()
+	for i, k := range []string{"/b/2", "/a/1", "/b/1", "/b0", "/c"} {
+		s.state[k] = []byte(k)
+		s.version[k] = uint64(i + 1)
+	}
+
+	var got []string
+	for _, it := range s.getRange("/b/", prefixEnd("/b/")) {
+		got = append(got, it.Key)
+	}
+	want := []string{"/b/1", "/b/2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getRange(/b/) keys = %v want %v", got, want)
+	}
+
+	got = nil
+	for _, it := range s.getRange("/b0", "/d") {
+		got = append(got, it.Key)
+	}
+	want = []string{"/b0", "/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getRange(/b0, /d) keys = %v want %v", got, want)
+	}
+}
+
+func TestApplyPrefixConditions(t *testing.T) {
+	s := newState()
+	var index uint64
+	apply := func(ops ...Op) bool {
+		all := All(ops...)
+		if all.err != nil {
+			t.Fatal(all.err)
+		}
+		data, err := proto.Marshal(&sinkpb.Instruction{
+			Conditions: all.conds,
+			Operations: all.effects,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		index++
+		return s.Apply(data, index)
+	}
+	value := &sinkpb.Op{Key: "v"}
+
+	if !apply(IfPrefixEmpty("/grants/"), Set("/grants/1", value)) {
+		t.Fatal("IfPrefixEmpty failed on empty prefix")
+	}
+	if apply(IfPrefixEmpty("/grants/"), Set("/grants/2", value)) {
+		t.Fatal("IfPrefixEmpty succeeded on non-empty prefix")
+	}
+
+	items := s.getRange("/grants/", prefixEnd("/grants/"))
+	if !apply(IfPrefixNotModified("/grants/", items), Set("/grants/2", value)) {
+		t.Fatal("IfPrefixNotModified failed on unmodified prefix")
+	}
+	// items is now out of date: /grants/2 was added.
+	if apply(IfPrefixNotModified("/grants/", items), Set("/grants/3", value)) {
+		t.Fatal("IfPrefixNotModified succeeded after a key was added")
+	}
+
+	items = s.getRange("/grants/", prefixEnd("/grants/"))
+	if !apply(Set("/other", value)) {
+		t.Fatal("unconditional Set failed")
+	}
+	if !apply(IfPrefixNotModified("/grants/", items), Delete("/grants/1")) {
+		t.Fatal("IfPrefixNotModified failed after a key outside the prefix changed")
+	}
+	if apply(IfPrefixNotModified("/grants/", items), Set("/grants/3", value)) {
+		t.Fatal("IfPrefixNotModified succeeded after a key was deleted")
+	}
+
+	items = s.getRange("/grants/", prefixEnd("/grants/"))
+	if !apply(Set("/grants/2", value)) {
+		t.Fatal("unconditional Set failed")
+	}
+	if apply(IfPrefixNotModified("/grants/", items), Set("/grants/3", value)) {
+		t.Fatal("IfPrefixNotModified succeeded after a key was set")
+	}
+
+	op := IfPrefixNotModified("/keys/", items)
+	if op.err == nil {
+		t.Error("IfPrefixNotModified with items outside the prefix: got no error")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s := newState()
+	c, stop := s.watch("/grants/")
+	other, _ := s.watch("/config/")
+
+	var index uint64
+	apply := func(op Op) {
+		data, err := proto.Marshal(&sinkpb.Instruction{Conditions: op.conds, Operations: op.effects})
+		if err != nil {
+			t.Fatal(err)
+		}
+		index++
+		s.Apply(data, index)
+	}
+	pending := func(c <-chan struct{}) bool {
+		select {
+		case <-c:
+			return true
+		default:
+			return false
+		}
+	}
+
+	apply(Set("/grants/a", &sinkpb.Op{}))
+	apply(Delete("/grants/b"))
+	if !pending(c) {
+		t.Error("no notification after changes to watched prefix")
+	}
+	if pending(c) {
+		t.Error("notifications were not coalesced")
+	}
+	if pending(other) {
+		t.Error("notification for a change outside the watched prefix")
+	}
+
+	// Unsatisfied instructions change nothing.
+	apply(All(IfNotExists("/grants/a"), Set("/grants/c", &sinkpb.Op{})))
+	if pending(c) {
+		t.Error("notification after an unsatisfied instruction")
+	}
+
+	data, _, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.RestoreSnapshot(data, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pending(c) || !pending(other) {
+		t.Error("no notification after restoring a snapshot")
+	}
+
+	stop()
+	apply(Set("/grants/d", &sinkpb.Op{}))
+	if pending(c) {
+		t.Error("notification after the watch stopped")
+	}
+}
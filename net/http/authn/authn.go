@@ -13,10 +13,15 @@ import (
 
 	"chain/core/accesstoken"
 	"chain/errors"
+	"chain/log"
 )
 
 const tokenExpiry = time.Minute * 5
 
+// useFlushDelay is how long uses of access tokens are collected
+// before being saved, so that a burst of requests is one write.
+const useFlushDelay = time.Second * 5
+
 // TODO(kr): This a hack. Please revisit this soon.
 // When compiled without localhost_auth, we want to avoid
 // running the loopback authenticator at all, except for
@@ -26,23 +31,48 @@ var loopbackOn = false
 type API struct {
 	tokens             *accesstoken.CredentialStore
 	crosscoreRPCPrefix string
-	rootCAs            *x509.CertPool
+	certs              CertVerifier // nil if client certs aren't accepted
+	jwt                *JWTVerifier // nil if JWTs aren't accepted
 
 	tokenMu  sync.Mutex // protects the following
 	tokenMap map[string]tokenResult
+
+	usesMu   sync.Mutex // protects the following
+	uses     map[string]accesstoken.Use
+	flushing bool
 }
 
 type tokenResult struct {
 	valid      bool
 	lastLookup time.Time
+	expiresAt  time.Time // zero if the token doesn't expire
+}
+
+// CertVerifier provides the trusted root CAs and revocation
+// status used to verify client certificates.
+// Both may change over time, as certificates are rotated.
+type CertVerifier interface {
+	RootCAs() *x509.CertPool
+
+	// Revoked reports whether cert has been revoked.
+	// Issuer is the cert that signed cert in a verified
+	// chain, or cert itself if it is trusted directly;
+	// in that case, Revoked must still check the lists
+	// of the CA that issued cert.
+	Revoked(cert, issuer *x509.Certificate) bool
 }
 
-func NewAPI(tokens *accesstoken.CredentialStore, crosscorePrefix string, rootCAs *x509.CertPool) *API {
+// NewAPI returns an API that authenticates requests with access
+// tokens, client certificates verified by certs (if certs is not
+// nil), and, if jwt is not nil, JWT bearer tokens verified by jwt.
+func NewAPI(tokens *accesstoken.CredentialStore, crosscorePrefix string, certs CertVerifier, jwt *JWTVerifier) *API {
 	return &API{
 		tokens:             tokens,
 		crosscoreRPCPrefix: crosscorePrefix,
 		tokenMap:           make(map[string]tokenResult),
-		rootCAs:            rootCAs,
+		uses:               make(map[string]accesstoken.Use),
+		certs:              certs,
+		jwt:                jwt,
 	}
 }
 
@@ -51,7 +81,7 @@ func NewAPI(tokens *accesstoken.CredentialStore, crosscorePrefix string, rootCAs
 func (a *API) Authenticate(req *http.Request) (*http.Request, error) {
 	var authnErrors []string
 
-	ctx, err := certAuthn(req, a.rootCAs)
+	ctx, err := certAuthn(req, a.certs)
 	if err != nil {
 		authnErrors = append(authnErrors, err.Error())
 	}
@@ -64,6 +94,13 @@ func (a *API) Authenticate(req *http.Request) (*http.Request, error) {
 		ctx = newContextWithToken(ctx, token)
 	}
 
+	claims, err := a.jwtAuthn(req)
+	if err != nil {
+		authnErrors = append(authnErrors, err.Error())
+	} else if claims != nil {
+		ctx = context.WithValue(ctx, jwtClaimsKey, claims)
+	}
+
 	local := a.localhostAuthn(req)
 	if local {
 		ctx = newContextWithLocalhost(ctx)
@@ -80,7 +117,7 @@ func (a *API) Authenticate(req *http.Request) (*http.Request, error) {
 
 	// if there is no authentication at all, we return an "unauthenticated" error,
 	// which may be helpful when debugging
-	if len(X509Certs(ctx)) < 1 && Token(ctx) == "" {
+	if len(X509Certs(ctx)) < 1 && Token(ctx) == "" && JWT(ctx) == nil {
 		err := errors.New("unauthenticated")
 		if len(authnErrors) > 0 {
 			err = errors.WithDetailf(err, "Invalid credentials: %s", strings.Join(authnErrors, "; "))
@@ -100,14 +137,17 @@ func (a *API) Authenticate(req *http.Request) (*http.Request, error) {
 // returned context, but the returned error is non-nil.
 // The caller should allow the connection to proceed
 // even if the error is non-nil.
-func certAuthn(req *http.Request, rootCAs *x509.CertPool) (context.Context, error) {
+func certAuthn(req *http.Request, verifier CertVerifier) (context.Context, error) {
 	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
 		certs := req.TLS.PeerCertificates
+		if verifier == nil {
+			return req.Context(), errors.New("client certificates not accepted")
+		}
 
 		// Same logic as serverHandshakeState.processCertsFromClient
 		// in $GOROOT/src/crypto/tls/handshake_server.go.
 		opts := x509.VerifyOptions{
-			Roots:         rootCAs,
+			Roots:         verifier.RootCAs(),
 			CurrentTime:   time.Now(),
 			Intermediates: x509.NewCertPool(),
 			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
@@ -115,7 +155,7 @@ func certAuthn(req *http.Request, rootCAs *x509.CertPool) (context.Context, erro
 		for _, cert := range certs[1:] {
 			opts.Intermediates.AddCert(cert)
 		}
-		_, err := certs[0].Verify(opts)
+		chains, err := certs[0].Verify(opts)
 		if err != nil {
 			// crypto/tls treats this as an error:
 			// errors.New("tls: failed to verify client's certificate: " + err.Error())
@@ -124,6 +164,18 @@ func certAuthn(req *http.Request, rootCAs *x509.CertPool) (context.Context, erro
 			return req.Context(), err
 		}
 
+		// The leaf is issued by the next cert in each chain,
+		// or by itself if it is a trusted root.
+		for _, chain := range chains {
+			issuer := chain[0]
+			if len(chain) > 1 {
+				issuer = chain[1]
+			}
+			if verifier.Revoked(certs[0], issuer) {
+				return req.Context(), errors.New("client certificate has been revoked")
+			}
+		}
+
 		return context.WithValue(req.Context(), x509CertsKey, certs), nil
 	}
 	return req.Context(), nil
@@ -146,27 +198,56 @@ func (a *API) tokenAuthn(req *http.Request) (string, error) {
 	if !ok {
 		return "", nil
 	}
-	return user, a.cachedTokenAuthnCheck(req.Context(), user, pw)
+	return user, a.cachedTokenAuthnCheck(req.Context(), user, pw, req.RemoteAddr)
+}
+
+// jwtAuthn checks the request for a JWT bearer token. It returns
+// the token's claims, or nil if there is no bearer token.
+func (a *API) jwtAuthn(req *http.Request) (*JWTClaims, error) {
+	const prefix = "Bearer "
+	auth := req.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return nil, nil
+	}
+	if a.jwt == nil {
+		return nil, errors.New("bearer tokens are not accepted")
+	}
+	claims, err := a.jwt.Verify(req.Context(), strings.TrimSpace(auth[len(prefix):]))
+	if d := errors.Detail(err); d != "" {
+		err = fmt.Errorf("%s: %s", err, d)
+	}
+	return claims, err
 }
 
-func (a *API) tokenAuthnCheck(ctx context.Context, user, pw string) (bool, error) {
+// tokenAuthnCheck checks the token and, if it's valid, records its
+// use in the background.
+func (a *API) tokenAuthnCheck(ctx context.Context, user, pw, remoteAddr string) (bool, time.Time, error) {
 	pwBytes, err := hex.DecodeString(pw)
 	if err != nil {
-		return false, nil
+		return false, time.Time{}, nil
 	}
-	return a.tokens.Check(ctx, user, pwBytes)
+	valid, expiresAt, err := a.tokens.Check(ctx, user, pwBytes)
+	if err == nil && valid {
+		a.recordUse(user, remoteAddr)
+	}
+	return valid, expiresAt, err
 }
 
-func (a *API) cachedTokenAuthnCheck(ctx context.Context, user, pw string) error {
+func (a *API) cachedTokenAuthnCheck(ctx context.Context, user, pw, remoteAddr string) error {
+	now := time.Now()
 	a.tokenMu.Lock()
 	res, ok := a.tokenMap[user+pw]
 	a.tokenMu.Unlock()
-	if !ok || time.Now().After(res.lastLookup.Add(tokenExpiry)) {
-		valid, err := a.tokenAuthnCheck(ctx, user, pw)
+	stale := !ok || now.After(res.lastLookup.Add(tokenExpiry))
+	if ok && !res.expiresAt.IsZero() && !now.Before(res.expiresAt) {
+		stale = true
+	}
+	if stale {
+		valid, expiresAt, err := a.tokenAuthnCheck(ctx, user, pw, remoteAddr)
 		if err != nil {
 			return errors.Wrap(err)
 		}
-		res = tokenResult{valid: valid, lastLookup: time.Now()}
+		res = tokenResult{valid: valid, lastLookup: now, expiresAt: expiresAt}
 		a.tokenMu.Lock()
 		a.tokenMap[user+pw] = res
 		a.tokenMu.Unlock()
@@ -176,3 +257,39 @@ func (a *API) cachedTokenAuthnCheck(ctx context.Context, user, pw string) error
 	}
 	return nil
 }
+
+// recordUse queues a use of the access token with the given ID to
+// be saved by a background goroutine, so that saving it doesn't
+// slow down the request.
+func (a *API) recordUse(id, remoteAddr string) {
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = h
+	}
+	a.usesMu.Lock()
+	defer a.usesMu.Unlock()
+	a.uses[id] = accesstoken.Use{ID: id, Addr: remoteAddr, Time: time.Now()}
+	if !a.flushing {
+		a.flushing = true
+		go a.flushUses()
+	}
+}
+
+func (a *API) flushUses() {
+	time.Sleep(useFlushDelay)
+
+	a.usesMu.Lock()
+	pending := a.uses
+	a.uses = make(map[string]accesstoken.Use)
+	a.flushing = false
+	a.usesMu.Unlock()
+
+	uses := make([]accesstoken.Use, 0, len(pending))
+	for _, u := range pending {
+		uses = append(uses, u)
+	}
+	ctx := context.Background()
+	err := a.tokens.RecordUses(ctx, uses)
+	if err != nil {
+		log.Printkv(ctx, log.KeyError, err, "at", "recording access token uses")
+	}
+}
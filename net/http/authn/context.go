@@ -11,8 +11,16 @@ const (
 	tokenKey key = iota
 	localhostKey
 	x509CertsKey
+	jwtClaimsKey
 )
 
+// JWT returns the claims of the JWT bearer token that
+// authenticated the request with context ctx, if there is one.
+func JWT(ctx context.Context) *JWTClaims {
+	c, _ := ctx.Value(jwtClaimsKey).(*JWTClaims)
+	return c
+}
+
 // X509Certs returns the cert stored in the context, if it exists.
 func X509Certs(ctx context.Context) []*x509.Certificate {
 	c, _ := ctx.Value(x509CertsKey).([]*x509.Certificate)
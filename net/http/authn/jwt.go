@@ -0,0 +1,429 @@
+package authn
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA384, crypto.SHA512
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/singleflight"
+
+	"chain/errors"
+)
+
+const (
+	// jwksRefresh is how often the key set is reloaded.
+	jwksRefresh = time.Hour
+
+	// jwksMinRefresh is the least time between reloads of the key
+	// set prompted by tokens signed with unknown keys, so that bad
+	// tokens can't make us hammer the key server.
+	jwksMinRefresh = time.Minute
+
+	// jwksTimeout limits how long a reload of the key set may take.
+	// Reloads happen while requests wait.
+	jwksTimeout = 10 * time.Second
+
+	// maxJWKSSize is the largest key set we'll read.
+	maxJWKSSize = 1 << 20 // 1MB
+
+	// jwtLeeway is the allowed clock skew between us and the issuer.
+	jwtLeeway = time.Minute
+)
+
+// ErrBadJWT is returned for a bearer token that isn't a valid JWT
+// from the configured issuer for the configured audience.
+var ErrBadJWT = errors.New("invalid bearer token")
+
+// JWTClaims are the claims of a verified JWT used to authenticate
+// a request.
+type JWTClaims struct {
+	Issuer  string
+	Subject string
+	Groups  []string
+}
+
+// JWTConfig configures a JWTVerifier.
+type JWTConfig struct {
+	// JWKS is the file path or http(s) URL of the JSON Web Key Set
+	// holding the issuer's signing keys.
+	JWKS string
+
+	// Issuer and Audience must match the iss and aud claims
+	// of every token.
+	Issuer   string
+	Audience string
+
+	// GroupsClaim is the name of the claim listing the groups
+	// of the token's subject. It defaults to "groups".
+	GroupsClaim string
+
+	// Client is used to fetch the key set from a URL.
+	// If nil, a client with a timeout of jwksTimeout is used.
+	Client *http.Client
+}
+
+// JWTVerifier verifies JWT bearer tokens signed by an
+// OpenID Connect provider or other issuer with a JWKS.
+type JWTVerifier struct {
+	config JWTConfig
+	now    func() time.Time
+
+	// refreshing shares one reload of the key set among
+	// all the requests that need it at once.
+	refreshing singleflight.Group
+
+	mu      sync.Mutex // protects the following
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewJWTVerifier returns a JWTVerifier with the given config.
+// It loads the key set right away, so a misconfigured key set
+// is reported at startup.
+func NewJWTVerifier(ctx context.Context, config JWTConfig) (*JWTVerifier, error) {
+	if config.JWKS == "" || config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("JWT verification requires a JWKS, issuer, and audience")
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: jwksTimeout}
+	}
+	v := &JWTVerifier{config: config, now: time.Now}
+	err := v.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify checks the signature and claims of token and returns
+// its claims.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.WithDetail(ErrBadJWT, "malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadJWT, "malformed header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadJWT, "malformed signature")
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	err = verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	err = decodeSegment(parts[1], &raw)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadJWT, "malformed claims")
+	}
+	var claims struct {
+		Iss string          `json:"iss"`
+		Sub string          `json:"sub"`
+		Aud json.RawMessage `json:"aud"`
+		Exp *int64          `json:"exp"`
+		Nbf *int64          `json:"nbf"`
+	}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadJWT, "malformed claims")
+	}
+
+	if claims.Iss != v.config.Issuer {
+		return nil, errors.WithDetailf(ErrBadJWT, "unexpected issuer %q", claims.Iss)
+	}
+	if !hasAudience(claims.Aud, v.config.Audience) {
+		return nil, errors.WithDetail(ErrBadJWT, "token is not for this audience")
+	}
+	now := v.now()
+	if claims.Exp == nil {
+		return nil, errors.WithDetail(ErrBadJWT, "token has no expiration")
+	}
+	if !now.Before(time.Unix(*claims.Exp, 0).Add(jwtLeeway)) {
+		return nil, errors.WithDetail(ErrBadJWT, "token has expired")
+	}
+	if claims.Nbf != nil && now.Add(jwtLeeway).Before(time.Unix(*claims.Nbf, 0)) {
+		return nil, errors.WithDetail(ErrBadJWT, "token is not valid yet")
+	}
+
+	var groups []string
+	if g, ok := raw[v.config.GroupsClaim]; ok {
+		err = json.Unmarshal(g, &groups)
+		if err != nil {
+			return nil, errors.WithDetailf(ErrBadJWT, "malformed %s claim", v.config.GroupsClaim)
+		}
+	}
+	return &JWTClaims{Issuer: claims.Iss, Subject: claims.Sub, Groups: groups}, nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// hasAudience reports whether aud, the JSON value of an aud claim,
+// either a string or an array of strings, contains want.
+func hasAudience(aud json.RawMessage, want string) bool {
+	var one string
+	if json.Unmarshal(aud, &one) == nil {
+		return one == want
+	}
+	var many []string
+	if json.Unmarshal(aud, &many) == nil {
+		for _, a := range many {
+			if a == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ecdsaBits gives the size of the curve each ECDSA algorithm
+// must be used with.
+var ecdsaBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return errors.WithDetailf(ErrBadJWT, "unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' {
+			break
+		}
+		if rsa.VerifyPKCS1v15(key, hash, digest, sig) != nil {
+			return errors.WithDetail(ErrBadJWT, "bad signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		bits := key.Curve.Params().BitSize
+		size := (bits + 7) / 8
+		if alg[0] != 'E' || bits != ecdsaBits[alg] || len(sig) != 2*size {
+			break
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.WithDetail(ErrBadJWT, "bad signature")
+		}
+		return nil
+	}
+	return errors.WithDetailf(ErrBadJWT, "algorithm %q doesn't match the signing key", alg)
+}
+
+// key returns the key with the given ID, reloading the key set
+// if it's due, or if the key is unknown and the key set hasn't
+// been reloaded recently (the issuer may have rotated its keys).
+func (v *JWTVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.lookup(kid)
+	since := v.now().Sub(v.fetched)
+	v.mu.Unlock()
+
+	if since >= jwksRefresh || (!ok && since >= jwksMinRefresh) {
+		err := v.sharedRefresh()
+		if err != nil && !ok {
+			return nil, err
+		}
+		if err == nil {
+			v.mu.Lock()
+			key, ok = v.lookup(kid)
+			v.mu.Unlock()
+		}
+	}
+	if !ok {
+		return nil, errors.WithDetailf(ErrBadJWT, "unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup must be called with v.mu held.
+func (v *JWTVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+// sharedRefresh reloads the key set, unless it was just reloaded,
+// joining a reload already in progress if there is one. The reload
+// isn't tied to any one request, so a canceled request can't make
+// it fail for the others waiting on it.
+func (v *JWTVerifier) sharedRefresh() error {
+	_, err := v.refreshing.Do("", func() (interface{}, error) {
+		v.mu.Lock()
+		fresh := v.now().Sub(v.fetched) < jwksMinRefresh
+		v.mu.Unlock()
+		if fresh {
+			return nil, nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), jwksTimeout)
+		defer cancel()
+		return nil, v.refresh(ctx)
+	})
+	return err
+}
+
+func (v *JWTVerifier) refresh(ctx context.Context) error {
+	data, err := v.loadJWKS(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "loading JWKS")
+	}
+	var keys map[string]crypto.PublicKey
+	if err == nil {
+		keys, err = parseJWKS(data)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	// Record failed attempts too, so that an unavailable key
+	// server isn't retried on every request.
+	v.fetched = v.now()
+	if err != nil {
+		return err
+	}
+	v.keys = keys
+	return nil
+}
+
+func (v *JWTVerifier) loadJWKS(ctx context.Context) ([]byte, error) {
+	src := v.config.JWKS
+	if !strings.HasPrefix(src, "https://") && !strings.HasPrefix(src, "http://") {
+		return ioutil.ReadFile(src)
+	}
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.config.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", src, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxJWKSSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxJWKSSize {
+		return nil, fmt.Errorf("GET %s: key set exceeds %d bytes", src, maxJWKSSize)
+	}
+	return data, nil
+}
+
+// parseJWKS parses the RSA and EC signing keys in a JSON Web Key
+// Set, by key ID. It ignores keys of other types and uses.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing JWKS")
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("bad RSA key %q in JWKS", k.Kid)
+			}
+			e = append(bytes.Repeat([]byte{0}, 4-len(e)), e...)
+			key = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(e[0])<<24 | int(e[1])<<16 | int(e[2])<<8 | int(e[3]),
+			}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if err1 != nil || err2 != nil || !curve.IsOnCurve(pub.X, pub.Y) {
+				return nil, fmt.Errorf("bad EC key %q in JWKS", k.Kid)
+			}
+			key = pub
+		default:
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,332 @@
+package authn
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chain/errors"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func padded(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// signJWT returns a JWT with the given header and claims,
+// signed with key.
+func signJWT(t *testing.T, key crypto.Signer, header, claims map[string]interface{}) string {
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(h) + "." + b64(c)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig = append(padded(r, size), padded(s, size)...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func TestJWTVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []interface{}{
+		map[string]string{
+			"kty": "RSA", "use": "sig", "kid": "rsa1",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		map[string]string{
+			"kty": "EC", "kid": "ec1", "crv": "P-256",
+			"x": b64(padded(ecKey.X, 32)), "y": b64(padded(ecKey.Y, 32)),
+		},
+		map[string]string{
+			"kty": "EC", "kid": "ec384", "crv": "P-384",
+			"x": b64(padded(ec384Key.X, 48)), "y": b64(padded(ec384Key.Y, 48)),
+		},
+		map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	err = ioutil.WriteFile(jwksFile, jwks, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	v, err := NewJWTVerifier(ctx, JWTConfig{
+		JWKS:     jwksFile,
+		Issuer:   "https://sso.example.com",
+		Audience: "chain-core",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1500000000, 0)
+	v.now = func() time.Time { return now }
+
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    "https://sso.example.com",
+			"aud":    []string{"other", "chain-core"},
+			"sub":    "alice",
+			"exp":    now.Add(time.Hour).Unix(),
+			"groups": []string{"treasury", "ops"},
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	rs256 := map[string]interface{}{"alg": "RS256", "kid": "rsa1"}
+
+	cases := []struct {
+		token   string
+		want    *JWTClaims
+		wantErr error
+	}{{
+		token: signJWT(t, rsaKey, rs256, claims(nil)),
+		want:  &JWTClaims{Issuer: "https://sso.example.com", Subject: "alice", Groups: []string{"treasury", "ops"}},
+	}, {
+		token: signJWT(t, ecKey, map[string]interface{}{"alg": "ES256", "kid": "ec1"}, claims(map[string]interface{}{"aud": "chain-core", "groups": nil})),
+		want:  &JWTClaims{Issuer: "https://sso.example.com", Subject: "alice"},
+	}, {
+		// signed with a key not in the set, under a known key ID
+		token:   signJWT(t, otherKey, rs256, claims(nil)),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   signJWT(t, rsaKey, map[string]interface{}{"alg": "RS256", "kid": "nope"}, claims(nil)),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   signJWT(t, rsaKey, map[string]interface{}{"alg": "ES256", "kid": "rsa1"}, claims(nil)),
+		wantErr: ErrBadJWT,
+	}, {
+		// ES256 is only for P-256 keys
+		token:   signJWT(t, ec384Key, map[string]interface{}{"alg": "ES256", "kid": "ec384"}, claims(nil)),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   signJWT(t, rsaKey, rs256, claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   signJWT(t, rsaKey, rs256, claims(map[string]interface{}{"aud": "other"})),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   signJWT(t, rsaKey, rs256, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   signJWT(t, rsaKey, rs256, claims(map[string]interface{}{"exp": nil})),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   signJWT(t, rsaKey, rs256, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   signJWT(t, rsaKey, map[string]interface{}{"alg": "none", "kid": "rsa1"}, claims(nil)),
+		wantErr: ErrBadJWT,
+	}, {
+		token:   "not.a-jwt",
+		wantErr: ErrBadJWT,
+	}}
+	for i, c := range cases {
+		got, err := v.Verify(ctx, c.token)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: error = %v (%s), want %v", i, err, errors.Detail(err), c.wantErr)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("case %d: claims = %+v, want %+v", i, got, c.want)
+		}
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := func(kid string, k *rsa.PrivateKey) map[string]string {
+		return map[string]string{"kty": "RSA", "kid": kid, "n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes())}
+	}
+	var (
+		mu      sync.Mutex // protects keys and fetches
+		keys    = []interface{}{jwk("k1", key1)}
+		fetches int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	getFetches := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return fetches
+	}
+	defer srv.Close()
+
+	ctx := context.Background()
+	v, err := NewJWTVerifier(ctx, JWTConfig{JWKS: srv.URL, Issuer: "iss", Audience: "aud"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v.now = func() time.Time { return now }
+	claims := map[string]interface{}{"iss": "iss", "aud": "aud", "sub": "bob", "exp": now.Add(time.Hour).Unix()}
+
+	// The issuer starts signing with a new key.
+	mu.Lock()
+	keys = append(keys, jwk("k2", key2))
+	mu.Unlock()
+	tok := signJWT(t, key2, map[string]interface{}{"alg": "RS256", "kid": "k2"}, claims)
+
+	// Just after a fetch, an unknown key doesn't cause another one.
+	_, err = v.Verify(ctx, tok)
+	if errors.Root(err) != ErrBadJWT {
+		t.Fatalf("error = %v, want %v", err, ErrBadJWT)
+	}
+	if n := getFetches(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+
+	now = now.Add(jwksMinRefresh)
+	_, err = v.Verify(ctx, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := getFetches(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+}
+
+func TestJWKSSharedRefresh(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []interface{}{
+		map[string]string{"kty": "RSA", "kid": "k1", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		fetches int32
+		release = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		w.Write(jwks)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	v, err := NewJWTVerifier(ctx, JWTConfig{JWKS: srv.URL, Issuer: "iss", Audience: "aud"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Add(jwksMinRefresh)
+	v.now = func() time.Time { return now }
+	tok := signJWT(t, key, map[string]interface{}{"alg": "RS256", "kid": "unknown"}, map[string]interface{}{"iss": "iss", "aud": "aud", "exp": now.Add(time.Hour).Unix()})
+
+	// Requests signed with an unknown key all wait on one reload.
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			v.Verify(ctx, tok)
+		}()
+	}
+	for atomic.LoadInt32(&fetches) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := atomic.LoadInt32(&fetches); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+}
+
+func TestJWKSTooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"keys": [`))
+		w.Write(bytes.Repeat([]byte(" "), maxJWKSSize))
+		w.Write([]byte(`]}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewJWTVerifier(context.Background(), JWTConfig{JWKS: srv.URL, Issuer: "iss", Audience: "aud"})
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("error = %v, want one about the key set's size", err)
+	}
+}
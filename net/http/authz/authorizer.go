@@ -12,6 +12,11 @@ import (
 
 var ErrNotAuthorized = errors.New("not authorized")
 
+type (
+	scopesKey   struct{}
+	policiesKey struct{}
+)
+
 // Loader loads all grants for any of the given policies.
 type Loader interface {
 	Load(ctx context.Context, policy []string) ([]*Grant, error)
@@ -29,45 +34,93 @@ func NewAuthorizer(l Loader, policyMap map[string][]string) *Authorizer {
 	}
 }
 
-func (a *Authorizer) Authorize(req *http.Request) error {
+// Authorize checks that req is allowed by a grant for one of the
+// policies of its route. It returns req with a context carrying
+// the policies of the grants allowing it; see Policies. If every
+// grant allowing req is scoped, the context also carries their
+// scopes; see Scopes.
+func (a *Authorizer) Authorize(req *http.Request) (*http.Request, error) {
 	policies, err := a.policiesByRoute(req.RequestURI)
 	if err != nil {
-		return errors.Wrap(err)
+		return req, errors.Wrap(err)
 	}
 
 	grants, err := a.loader.Load(req.Context(), policies)
 	if err != nil {
-		return errors.Wrap(err)
+		return req, errors.Wrap(err)
 	}
 
-	if !authorized(req.Context(), grants) {
-		return ErrNotAuthorized
+	ok, scopes, allowed := authorized(req.Context(), grants)
+	if !ok {
+		return req, ErrNotAuthorized
 	}
+	ctx := context.WithValue(req.Context(), policiesKey{}, allowed)
+	if scopes != nil {
+		ctx = context.WithValue(ctx, scopesKey{}, scopes)
+	}
+	return req.WithContext(ctx), nil
+}
 
-	return nil
+// Policies returns the policies of the grants that authorized
+// the request with context ctx.
+func Policies(ctx context.Context) []string {
+	p, _ := ctx.Value(policiesKey{}).([]string)
+	return p
 }
 
-func authorized(ctx context.Context, grants []*Grant) bool {
+// Scopes returns the scopes of the grants that authorized the
+// request with context ctx, and whether the request is restricted
+// to them. A request allowed by any unscoped grant is unrestricted.
+// The meaning of a scope is up to the application.
+func Scopes(ctx context.Context) ([][]byte, bool) {
+	scopes, ok := ctx.Value(scopesKey{}).([][]byte)
+	return scopes, ok
+}
+
+// authorized reports whether any of grants allows the request with
+// context ctx, and returns the policies of the grants that do. If
+// all of them are scoped, it also returns their scopes.
+func authorized(ctx context.Context, grants []*Grant) (bool, [][]byte, []string) {
+	var (
+		unscoped bool
+		scopes   [][]byte
+		policies []string
+	)
 	for _, g := range grants {
-		switch g.GuardType {
-		case "access_token":
-			if accessTokenGuardData(g) == authn.Token(ctx) {
-				return true
-			}
-		case "x509":
-			pattern := x509GuardData(g.GuardData)
-			certs := authn.X509Certs(ctx)
-			if len(certs) > 0 && matchesX509(pattern, certs[0].Subject) {
-				return true
-			}
-		case "localhost":
-			if authn.Localhost(ctx) {
-				return true
-			}
-		case "any":
-			return true
+		if !guardMatches(ctx, g) {
+			continue
+		}
+		if !containsString(policies, g.Policy) {
+			policies = append(policies, g.Policy)
+		}
+		if len(g.Scope) == 0 {
+			unscoped = true
+		} else {
+			scopes = append(scopes, g.Scope)
 		}
 	}
+	if unscoped {
+		scopes = nil
+	}
+	return len(policies) > 0, scopes, policies
+}
+
+func guardMatches(ctx context.Context, g *Grant) bool {
+	switch g.GuardType {
+	case "access_token":
+		return accessTokenGuardData(g) == authn.Token(ctx)
+	case "x509":
+		pattern := x509GuardData(g.GuardData)
+		certs := authn.X509Certs(ctx)
+		return len(certs) > 0 && matchesX509(pattern, certs[0].Subject)
+	case "jwt":
+		claims := authn.JWT(ctx)
+		return claims != nil && matchesJWT(jwtGuardData(g.GuardData), claims)
+	case "localhost":
+		return authn.Localhost(ctx)
+	case "any":
+		return true
+	}
 	return false
 }
 
@@ -51,6 +51,7 @@ type Grant struct {
 	Policy    string `protobuf:"bytes,3,opt,name=policy" json:"policy,omitempty"`
 	CreatedAt string `protobuf:"bytes,4,opt,name=created_at,json=createdAt" json:"created_at,omitempty"`
 	Protected bool   `protobuf:"varint,5,opt,name=protected" json:"protected,omitempty"`
+	Scope     []byte `protobuf:"bytes,6,opt,name=scope,proto3" json:"scope,omitempty"`
 }
 
 func (m *Grant) Reset()                    { *m = Grant{} }
@@ -93,6 +94,13 @@ func (m *Grant) GetProtected() bool {
 	return false
 }
 
+func (m *Grant) GetScope() []byte {
+	if m != nil {
+		return m.Scope
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*GrantList)(nil), "authz.GrantList")
 	proto.RegisterType((*Grant)(nil), "authz.Grant")
@@ -101,18 +109,18 @@ func init() {
 func init() { proto.RegisterFile("grant.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 193 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4e, 0x2f, 0x4a, 0xcc,
-	0x2b, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x4d, 0x2c, 0x2d, 0xc9, 0xa8, 0x52, 0x32,
-	0xe4, 0xe2, 0x74, 0x07, 0x89, 0xfa, 0x64, 0x16, 0x97, 0x08, 0xa9, 0x70, 0xb1, 0x81, 0x95, 0x14,
-	0x4b, 0x30, 0x2a, 0x30, 0x6b, 0x70, 0x1b, 0xf1, 0xe8, 0x81, 0x15, 0xe9, 0x81, 0x55, 0x04, 0x41,
-	0xe5, 0x94, 0x66, 0x31, 0x72, 0xb1, 0x82, 0x45, 0x84, 0x64, 0xb9, 0xb8, 0xd2, 0x4b, 0x13, 0x8b,
-	0x52, 0xe2, 0x4b, 0x2a, 0x0b, 0x52, 0x25, 0x18, 0x15, 0x18, 0x35, 0x38, 0x83, 0x38, 0xc1, 0x22,
-	0x21, 0x95, 0x05, 0xa9, 0x08, 0xe9, 0x94, 0xc4, 0x92, 0x44, 0x09, 0x26, 0x05, 0x46, 0x0d, 0x1e,
-	0xa8, 0xb4, 0x4b, 0x62, 0x49, 0xa2, 0x90, 0x18, 0x17, 0x5b, 0x41, 0x7e, 0x4e, 0x66, 0x72, 0xa5,
-	0x04, 0x33, 0x58, 0x27, 0x94, 0x07, 0xd2, 0x96, 0x5c, 0x94, 0x9a, 0x58, 0x92, 0x9a, 0x12, 0x9f,
-	0x58, 0x22, 0xc1, 0x02, 0x31, 0x15, 0x2a, 0xe2, 0x58, 0x22, 0x24, 0xc3, 0xc5, 0x09, 0xf2, 0x41,
-	0x6a, 0x72, 0x49, 0x6a, 0x8a, 0x04, 0xab, 0x02, 0xa3, 0x06, 0x47, 0x10, 0x42, 0x20, 0x89, 0x0d,
-	0xec, 0x3b, 0x63, 0x40, 0x00, 0x00, 0x00, 0xff, 0xff, 0xf3, 0x08, 0xbf, 0xea, 0xec, 0x00, 0x00,
-	0x00,
+	// 201 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x44, 0x8f, 0x31, 0x4b, 0x04, 0x31,
+	0x10, 0x85, 0x89, 0x67, 0x82, 0x99, 0xbb, 0x2a, 0x88, 0xa4, 0x50, 0x08, 0x87, 0x45, 0xaa, 0x05,
+	0xf5, 0x17, 0x08, 0x82, 0x8d, 0xd5, 0x62, 0x7f, 0x8c, 0x49, 0x38, 0x17, 0xc4, 0x84, 0xec, 0x6c,
+	0xb1, 0xfe, 0x2a, 0x7f, 0xe2, 0xb1, 0xb3, 0x81, 0x2d, 0xdf, 0xf7, 0xde, 0x9b, 0xe1, 0xc1, 0xfe,
+	0x5c, 0xf1, 0x97, 0xba, 0x52, 0x33, 0x65, 0x23, 0x71, 0xa2, 0xef, 0xbf, 0xe3, 0x13, 0xe8, 0xf7,
+	0x85, 0x7e, 0x0c, 0x23, 0x99, 0x47, 0x50, 0x1c, 0x19, 0xad, 0x70, 0x3b, 0xbf, 0x7f, 0x3e, 0x74,
+	0x1c, 0xea, 0x38, 0xd1, 0x37, 0xef, 0xf8, 0x2f, 0x40, 0x32, 0x31, 0x0f, 0x00, 0xe7, 0x09, 0x6b,
+	0x3c, 0xd1, 0x5c, 0x92, 0x15, 0x4e, 0x78, 0xdd, 0x6b, 0x26, 0x9f, 0x73, 0x49, 0x9b, 0x1d, 0x91,
+	0xd0, 0x5e, 0x39, 0xe1, 0x0f, 0xcd, 0x7e, 0x43, 0x42, 0x73, 0x07, 0xaa, 0xe4, 0x9f, 0x21, 0xcc,
+	0x76, 0xc7, 0xcd, 0xa6, 0x96, 0x5a, 0xa8, 0x09, 0x29, 0xc5, 0x13, 0x92, 0xbd, 0x5e, 0xaf, 0x36,
+	0xf2, 0x4a, 0xe6, 0x1e, 0xf4, 0xb2, 0x20, 0x05, 0x4a, 0xd1, 0x4a, 0x27, 0xfc, 0x4d, 0xbf, 0x01,
+	0x73, 0x0b, 0x72, 0x0c, 0xb9, 0x24, 0xab, 0xf8, 0xdd, 0x2a, 0xbe, 0x14, 0x6f, 0x7e, 0xb9, 0x0c,
+	0x00, 0x5c, 0x05, 0x74, 0x0b, 0x02, 0x01, 0x00, 0x00,
 }
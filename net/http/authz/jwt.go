@@ -0,0 +1,52 @@
+package authz
+
+import (
+	"encoding/json"
+
+	"chain/net/http/authn"
+)
+
+// JWTPattern is the guard data of a "jwt" grant. It matches
+// a token whose subject is Subject, if set, and whose subject
+// belongs to every group in Groups.
+type JWTPattern struct {
+	Subject string   `json:"subject,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+func jwtGuardData(data []byte) JWTPattern {
+	var v JWTPattern
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		// We should create only well-formed guard data,
+		// so this should not happen.
+		// (And if it does, it's our bug.)
+		panic(err)
+	}
+	return v
+}
+
+func matchesJWT(pat JWTPattern, c *authn.JWTClaims) bool {
+	if pat.Subject == "" && len(pat.Groups) == 0 {
+		// Don't let an empty pattern match every token.
+		return false
+	}
+	if !matchesString(pat.Subject, c.Subject) {
+		return false
+	}
+	for _, g := range pat.Groups {
+		if !containsString(c.Groups, g) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
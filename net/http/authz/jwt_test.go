@@ -0,0 +1,29 @@
+package authz
+
+import (
+	"testing"
+
+	"chain/net/http/authn"
+)
+
+func TestMatchesJWT(t *testing.T) {
+	claims := &authn.JWTClaims{Subject: "alice", Groups: []string{"treasury", "ops"}}
+	cases := []struct {
+		pat  JWTPattern
+		want bool
+	}{
+		{JWTPattern{Subject: "alice"}, true},
+		{JWTPattern{Subject: "bob"}, false},
+		{JWTPattern{Groups: []string{"ops"}}, true},
+		{JWTPattern{Groups: []string{"ops", "treasury"}}, true},
+		{JWTPattern{Groups: []string{"ops", "admin"}}, false},
+		{JWTPattern{Subject: "alice", Groups: []string{"treasury"}}, true},
+		{JWTPattern{Subject: "bob", Groups: []string{"treasury"}}, false},
+		{JWTPattern{}, false},
+	}
+	for _, c := range cases {
+		if got := matchesJWT(c.pat, claims); got != c.want {
+			t.Errorf("matchesJWT(%+v) = %v, want %v", c.pat, got, c.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package authz
 import (
 	"bytes"
 	"context"
+	"sync"
 	"time"
 
 	"chain/database/sinkdb"
@@ -16,26 +17,55 @@ import (
 type Store struct {
 	sdb       *sinkdb.DB
 	keyPrefix string
+
+	mu      sync.Mutex
+	changed <-chan struct{}     // nil until the first Load
+	cache   map[string][]*Grant // by policy
 }
 
 // NewStore returns a new *Store storing grants
 // in db under keyPrefix.
 // It implements the Loader interface.
 func NewStore(db *sinkdb.DB, keyPrefix string) *Store {
-	return &Store{db, keyPrefix}
+	return &Store{sdb: db, keyPrefix: keyPrefix}
 }
 
 // Load satisfies the Loader interface.
+//
+// It performs a stale read of the grants for each policy,
+// and caches them until they change on this node.
+// A grant that is deleted stops allowing requests as soon
+// as the deletion is applied to this node's replica.
 func (s *Store) Load(ctx context.Context, policy []string) ([]*Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changed == nil {
+		s.changed = s.sdb.Watch(context.Background(), s.keyPrefix)
+	}
+	select {
+	case <-s.changed:
+		s.cache = nil
+	default:
+	}
+	if s.cache == nil {
+		s.cache = make(map[string][]*Grant)
+	}
+
 	var grants []*Grant
 	for _, p := range policy {
-		var grantList GrantList
-		ver, err := s.sdb.GetStale(s.keyPrefix+p, &grantList)
-		if err != nil {
-			return nil, err
-		} else if ver.Exists() {
-			grants = append(grants, grantList.Grants...)
+		pgrants, ok := s.cache[p]
+		if !ok {
+			var grantList GrantList
+			ver, err := s.sdb.GetStale(s.keyPrefix+p, &grantList)
+			if err != nil {
+				return nil, err
+			} else if ver.Exists() {
+				pgrants = grantList.Grants
+			}
+			s.cache[p] = pgrants
 		}
+		grants = append(grants, pgrants...)
 	}
 	return grants, nil
 }
@@ -104,5 +134,5 @@ func (s *Store) Delete(policy string, delete func(*Grant) bool) sinkdb.Op {
 }
 
 func EqualGrants(a, b Grant) bool {
-	return a.GuardType == b.GuardType && bytes.Equal(a.GuardData, b.GuardData) && a.Protected == b.Protected
+	return a.GuardType == b.GuardType && bytes.Equal(a.GuardData, b.GuardData) && a.Protected == b.Protected && bytes.Equal(a.Scope, b.Scope)
 }
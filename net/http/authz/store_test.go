@@ -0,0 +1,47 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/sinkdb/sinkdbtest"
+)
+
+func TestStoreLoadAfterChange(t *testing.T) {
+	ctx := context.Background()
+	sdb := sinkdbtest.NewDB(t)
+	store := NewStore(sdb, "/test/grants/")
+
+	grants, err := store.Load(ctx, []string{"p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 0 {
+		t.Fatalf("Load = %v, want no grants", grants)
+	}
+
+	g := &Grant{Policy: "p", GuardType: "access_token", GuardData: []byte(`{"id":"a"}`)}
+	err = sdb.Exec(ctx, store.Save(ctx, g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err = store.Load(ctx, []string{"p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 1 || !EqualGrants(*grants[0], *g) {
+		t.Fatalf("Load after Save = %v, want [%v]", grants, g)
+	}
+
+	err = sdb.Exec(ctx, store.Delete("p", func(*Grant) bool { return true }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err = store.Load(ctx, []string{"p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 0 {
+		t.Fatalf("Load after Delete = %v, want no grants", grants)
+	}
+}
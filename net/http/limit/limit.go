@@ -2,7 +2,9 @@ package limit
 
 import (
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
@@ -27,6 +29,33 @@ func (b *BucketLimiter) Allow(id string) bool {
 	return b.bucket(id).Allow()
 }
 
+// Take is like Allow, but if the request with the given id isn't
+// allowed, it also returns how long until it would be.
+func (b *BucketLimiter) Take(id string) (bool, time.Duration) {
+	ok, wait, _ := b.Reserve(id)
+	return ok, wait
+}
+
+// Reserve is like Take, but if the request is allowed, it also
+// returns a function that gives its token back. It's for callers
+// that check other limits before serving the request.
+func (b *BucketLimiter) Reserve(id string) (ok bool, wait time.Duration, cancel func()) {
+	now := time.Now()
+	r := b.bucket(id).ReserveN(now, 1)
+	if !r.OK() {
+		// The burst is zero; nothing is ever allowed.
+		return false, time.Duration(1<<63 - 1), nil
+	}
+	// A reservation can only be canceled before it's due,
+	// so cancel it as of the time it was made.
+	cancel = func() { r.CancelAt(now) }
+	if d := r.DelayFrom(now); d > 0 {
+		cancel()
+		return false, d, nil
+	}
+	return true, 0, cancel
+}
+
 func (b *BucketLimiter) bucket(id string) *rate.Limiter {
 	b.bucketMu.Lock()
 	bucket, ok := b.buckets[id]
@@ -57,13 +86,21 @@ func Handler(next, limited http.Handler, freq, burst int, f func(*http.Request)
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	id := h.f(r)
-	if !h.limiter.Allow(id) {
+	if ok, wait := h.limiter.Take(id); !ok {
+		SetRetryAfter(w, wait)
 		h.limited.ServeHTTP(w, r)
 		return
 	}
 	h.next.ServeHTTP(w, r)
 }
 
+// SetRetryAfter sets the Retry-After header of w to d, rounded up
+// to whole seconds.
+func SetRetryAfter(w http.ResponseWriter, d time.Duration) {
+	secs := int64((d + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
+
 func RemoteAddrID(r *http.Request) string {
 	return r.RemoteAddr
 }
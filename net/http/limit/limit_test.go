@@ -0,0 +1,29 @@
+package limit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	b := NewBucketLimiter(1, 1)
+	if ok, _ := b.Take("a"); !ok {
+		t.Fatal("first take refused")
+	}
+	ok, wait := b.Take("a")
+	if ok || wait <= 0 || wait > time.Second {
+		t.Errorf("second take = %v, %v; want false and a wait of up to 1s", ok, wait)
+	}
+}
+
+func TestBucketReserve(t *testing.T) {
+	b := NewBucketLimiter(1, 1)
+	ok, _, cancel := b.Reserve("a")
+	if !ok {
+		t.Fatal("first reserve refused")
+	}
+	cancel()
+	if ok, _ := b.Take("a"); !ok {
+		t.Error("take after canceled reservation refused")
+	}
+}
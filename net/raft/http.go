@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/coreos/etcd/raft/raftpb"
 
@@ -77,6 +78,9 @@ func (sv *Service) serveMsg(w http.ResponseWriter, req *http.Request) {
 		errorFormatter.Write(req.Context(), w, err)
 		return
 	}
+	sv.contactMu.Lock()
+	sv.lastContact[m.From] = time.Now()
+	sv.contactMu.Unlock()
 
 	// If message is from node not in cluster, tell node to remove itself
 	if sv.state.Peers()[m.From] == "" {
@@ -32,6 +32,8 @@ import (
 // having to be active raft nodes.
 // (Raft isn't really meant for more than a handful
 // of consensus participants.)
+// The vendored etcd raft predates learner (non-voting)
+// members, which would be the natural way to do this.
 
 const (
 	tickDur           = 100 * time.Millisecond
@@ -109,6 +111,10 @@ type Service struct {
 	confMu    sync.Mutex
 	confState raftpb.ConfState
 
+	// When a message was last received from each peer, for Status.
+	contactMu   sync.Mutex
+	lastContact map[uint64]time.Time
+
 	// Current log position, accessed only from runUpdates goroutine
 	snapIndex uint64
 
@@ -202,6 +208,7 @@ func Start(laddr, dir string, httpClient *http.Client, state State) (*Service, e
 		wctxReq:             make(chan wctxReq),
 		client:              httpClient,
 		stop:                make(chan struct{}),
+		lastContact:         make(map[uint64]time.Time),
 		snapCount:           10000,
 		nSnapCatchupEntries: 10000,
 	}
@@ -311,6 +318,80 @@ func (sv *Service) Init() error {
 	return nil
 }
 
+// Restore initializes a new Raft cluster from a copy of the
+// replicated state, as returned by State.Snapshot, at the given
+// log index. It's for recovering a cluster that has lost
+// a majority of its members. The local node becomes the only
+// member of the new cluster; the restored state's peer list
+// is replaced.
+func (sv *Service) Restore(data []byte, index uint64) error {
+	const firstNodeID = 1
+	ctx := context.Background()
+
+	sv.startMu.Lock()
+	defer sv.startMu.Unlock()
+
+	if sv.raftNode != nil {
+		return ErrExistingCluster
+	}
+
+	err := sv.state.RestoreSnapshot(data, index)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	for id := range sv.state.Peers() {
+		sv.state.RemovePeerAddr(id)
+	}
+	sv.state.SetPeerAddr(firstNodeID, sv.laddr)
+	data, index, err = sv.state.Snapshot()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	log.Printkv(ctx, "raftid", firstNodeID, "at", "restoring", "index", index)
+	err = sv.writeID(sv.dir, firstNodeID)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(sv.walDir())
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	sv.wal, err = wal.Create(sv.walDir(), nil)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	sv.id = firstNodeID
+	raftSnap := raftpb.Snapshot{
+		Data: data,
+		Metadata: raftpb.SnapshotMetadata{
+			Index:     index,
+			Term:      1,
+			ConfState: raftpb.ConfState{Nodes: []uint64{sv.id}},
+		},
+	}
+	err = sv.raftStorage.ApplySnapshot(raftSnap)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	err = sv.saveSnapshot(&raftSnap)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	sv.confState = raftSnap.Metadata.ConfState
+	sv.snapIndex = index
+
+	raftNode := raft.RestartNode(sv.config())
+	err = raftNode.Campaign(ctx)
+	if err != nil {
+		log.Error(ctx, err, "election failed") // ok to continue
+	}
+	sv.raftNode = raftNode
+	sv.startLocked()
+	return nil
+}
+
 // Join connects to an existing Raft cluster.
 // bootURL gives the location of an existing cluster
 // for the local process to join. It can be either
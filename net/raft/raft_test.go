@@ -15,6 +15,7 @@ import (
 
 	"github.com/coreos/etcd/raft/raftpb"
 
+	"chain/errors"
 	"chain/net"
 )
 
@@ -248,6 +249,137 @@ func TestEvictMultiple(t *testing.T) {
 	}
 }
 
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+
+	nodeA, nodeB, nodeC := newTestCluster(ctx, t)
+	defer nodeA.cleanup()
+	defer nodeB.cleanup()
+	must(t, nodeB.service.WaitRead(ctx))
+	must(t, nodeC.service.WaitRead(ctx))
+
+	st, err := nodeA.service.Status()
+	must(t, err)
+	if st.State != "leader" || st.Leader != st.ID || st.Address != nodeA.addr {
+		t.Errorf("nodeA status = %+v, want leader at %s", st, nodeA.addr)
+	}
+	if len(st.Members) != 3 {
+		t.Fatalf("nodeA status has %d members, want 3", len(st.Members))
+	}
+	for _, m := range st.Members {
+		if m.Progress == nil {
+			t.Fatalf("leader status has no progress for member %d", m.ID)
+		}
+		if !m.Progress.Active {
+			t.Errorf("member %d is inactive", m.ID)
+		}
+		if m.Leader != (m.ID == st.ID) {
+			t.Errorf("member %d Leader = %v", m.ID, m.Leader)
+		}
+	}
+
+	st, err = nodeB.service.Status()
+	must(t, err)
+	if st.State != "follower" || st.Leader != nodeA.service.id {
+		t.Errorf("nodeB status = %+v, want follower of %d", st, nodeA.service.id)
+	}
+	for _, m := range st.Members {
+		if m.Progress != nil {
+			t.Errorf("follower status has progress for member %d", m.ID)
+		}
+	}
+
+	// A member that stops is soon reported inactive.
+	nodeC.cleanup()
+	time.Sleep(2 * electionTick * tickDur)
+	st, err = nodeA.service.Status()
+	must(t, err)
+	for _, m := range st.Members {
+		if want := m.ID != nodeC.service.id; m.Progress.Active != want {
+			t.Errorf("after stopping C, member %d Active = %v, want %v", m.ID, m.Progress.Active, want)
+		}
+	}
+}
+
+func TestTransferLeadership(t *testing.T) {
+	ctx := context.Background()
+
+	nodeA, nodeB, nodeC := newTestCluster(ctx, t)
+	defer nodeA.cleanup()
+	defer nodeB.cleanup()
+	defer nodeC.cleanup()
+	must(t, nodeB.service.WaitRead(ctx))
+	must(t, nodeC.service.WaitRead(ctx))
+
+	leader := func(n *testNode) uint64 {
+		st, err := n.service.Status()
+		must(t, err)
+		return st.Leader
+	}
+
+	// A follower can't move leadership to a third node.
+	err := nodeB.service.TransferLeadership(ctx, nodeC.addr)
+	if errors.Root(err) != ErrNotLeader {
+		t.Errorf("TransferLeadership from a follower: got error %v, want %v", err, ErrNotLeader)
+	}
+
+	must(t, nodeA.service.TransferLeadership(ctx, nodeB.addr))
+	if got, want := leader(nodeB), nodeB.service.id; got != want {
+		t.Errorf("after transfer to B, leader = %d, want %d", got, want)
+	}
+
+	// The new leader can be the one to ask.
+	must(t, nodeC.service.TransferLeadership(ctx, nodeC.addr))
+	if got, want := leader(nodeC), nodeC.service.id; got != want {
+		t.Errorf("after transfer to C, leader = %d, want %d", got, want)
+	}
+
+	must(t, nodeC.service.TransferLeadership(ctx, ""))
+	if got := leader(nodeC); got == nodeC.service.id || got == 0 {
+		t.Errorf("after transfer away from C, leader = %d", got)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+
+	// Back up a one-node cluster, then lose it.
+	nodeA := newTestNode(t)
+	must(t, nodeA.service.Init())
+	_, err := nodeA.service.Exec(ctx, set("/k", "v"))
+	must(t, err)
+	must(t, nodeA.service.WaitRead(ctx))
+	data, index, err := nodeA.state.Snapshot()
+	must(t, err)
+	nodeA.cleanup()
+
+	nodeR := newTestNode(t)
+	defer nodeR.cleanup()
+	must(t, nodeR.service.Restore(data, index))
+	if err := nodeR.service.Restore(data, index); err != ErrExistingCluster {
+		t.Errorf("second Restore: got error %v, want %v", err, ErrExistingCluster)
+	}
+
+	if got := nodeR.state.Data["/k"]; got != "v" {
+		t.Errorf("restored /k = %q, want %q", got, "v")
+	}
+	want := map[uint64]string{1: nodeR.addr}
+	if got := nodeR.state.Peers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored peers = %v, want %v", got, want)
+	}
+
+	// The restored cluster accepts writes and new members.
+	nodeB := newTestNode(t)
+	defer nodeB.cleanup()
+	_, err = nodeR.service.Exec(ctx, set("/allowed/"+nodeB.addr, "yes"))
+	must(t, err)
+	must(t, nodeB.service.Join("https://"+nodeR.addr))
+	must(t, nodeB.service.WaitRead(ctx))
+	if got := nodeB.state.Data["/k"]; got != "v" {
+		t.Errorf("joined node /k = %q, want %q", got, "v")
+	}
+}
+
 func TestLeaderEviction(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,204 @@
+package raft
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/coreos/etcd/raft"
+
+	"chain/errors"
+)
+
+var (
+	// ErrNotLeader is returned from TransferLeadership when the
+	// local node can't start the transfer because it's not the
+	// leader.
+	ErrNotLeader = errors.New("not the raft leader")
+
+	// ErrLeaderTransfer is returned from TransferLeadership when
+	// leadership did not move to the requested node in time.
+	ErrLeaderTransfer = errors.New("leadership transfer did not complete")
+)
+
+// Status describes the raft cluster as seen by the local node.
+type Status struct {
+	ID      uint64 `json:"id"`
+	Address string `json:"address"`
+
+	// State is one of "leader", "follower", "candidate",
+	// or "pre-candidate".
+	State  string `json:"state"`
+	Leader uint64 `json:"leader"`
+	Term   uint64 `json:"term"`
+
+	// CommitIndex is the last log index known to be committed.
+	// AppliedIndex is the last log index applied to the local
+	// replica of the data.
+	CommitIndex  uint64 `json:"commit_index"`
+	AppliedIndex uint64 `json:"applied_index"`
+
+	Members []Member `json:"members"`
+}
+
+// Member describes a member of the raft cluster.
+//
+// TODO: report non-voting learner members once the vendored
+// etcd raft supports them. Every member is a voter for now.
+type Member struct {
+	ID      uint64 `json:"id"`
+	Address string `json:"address"`
+	Leader  bool   `json:"leader"`
+
+	// Progress is the member's replication progress,
+	// as tracked by the leader. It's only known when
+	// the local node is the leader.
+	Progress *Progress `json:"progress,omitempty"`
+}
+
+// Progress describes how far a member's log has caught up
+// with the leader's.
+type Progress struct {
+	// MatchIndex is the last log index known to be
+	// replicated to the member. Lag is how many committed
+	// entries the member is missing.
+	MatchIndex uint64 `json:"match_index"`
+	NextIndex  uint64 `json:"next_index"`
+	Lag        uint64 `json:"lag"`
+
+	// State is "probe", "replicate", or "snapshot".
+	State string `json:"state"`
+
+	// Active is whether the leader has received a message
+	// from the member within the last election timeout.
+	Active bool `json:"active"`
+}
+
+var stateNames = map[raft.StateType]string{
+	raft.StateLeader:       "leader",
+	raft.StateFollower:     "follower",
+	raft.StateCandidate:    "candidate",
+	raft.StatePreCandidate: "pre-candidate",
+}
+
+var progressStateNames = map[raft.ProgressStateType]string{
+	raft.ProgressStateProbe:     "probe",
+	raft.ProgressStateReplicate: "replicate",
+	raft.ProgressStateSnapshot:  "snapshot",
+}
+
+// Status returns the status of the raft cluster as seen by
+// the local node. Members are sorted by ID.
+func (sv *Service) Status() (*Status, error) {
+	if !sv.initialized() {
+		return nil, ErrUninitialized
+	}
+
+	rs := sv.raftNode.Status()
+	peers := sv.state.Peers()
+	now := time.Now()
+	sv.contactMu.Lock()
+	defer sv.contactMu.Unlock()
+	st := &Status{
+		ID:           sv.id,
+		Address:      peers[sv.id],
+		State:        stateNames[rs.RaftState],
+		Leader:       rs.Lead,
+		Term:         rs.Term,
+		CommitIndex:  rs.Commit,
+		AppliedIndex: sv.state.AppliedIndex(),
+	}
+	for id, addr := range peers {
+		m := Member{ID: id, Address: addr, Leader: id == rs.Lead}
+		if pr, ok := rs.Progress[id]; ok {
+			m.Progress = &Progress{
+				MatchIndex: pr.Match,
+				NextIndex:  pr.Next,
+				State:      progressStateNames[pr.State],
+				Active:     id == sv.id || now.Sub(sv.lastContact[id]) < electionTick*tickDur,
+			}
+			if pr.Match < rs.Commit {
+				m.Progress.Lag = rs.Commit - pr.Match
+			}
+		}
+		st.Members = append(st.Members, m)
+	}
+	sort.Slice(st.Members, func(i, j int) bool {
+		return st.Members[i].ID < st.Members[j].ID
+	})
+	return st, nil
+}
+
+// TransferLeadership hands leadership of the cluster to the
+// member with the provided address, and waits for the new leader
+// to take over. Transferring leadership before stopping the
+// leader for maintenance avoids an election timeout.
+//
+// If nodeAddr is empty, TransferLeadership chooses the member
+// that is furthest caught up.
+//
+// The transfer must be requested on the current leader,
+// or on the member that is to become leader. Otherwise,
+// TransferLeadership returns ErrNotLeader.
+func (sv *Service) TransferLeadership(ctx context.Context, nodeAddr string) error {
+	const defaultTimeout = 10 * time.Second
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	if !sv.initialized() {
+		return ErrUninitialized
+	}
+
+	rs := sv.raftNode.Status()
+	var target uint64
+	if nodeAddr == "" {
+		if rs.RaftState != raft.StateLeader {
+			return errors.WithDetail(ErrNotLeader, "Choosing a new leader requires the current leader.")
+		}
+		for id, pr := range rs.Progress {
+			if id != sv.id && (target == 0 || pr.Match > rs.Progress[target].Match) {
+				target = id
+			}
+		}
+		if target == 0 {
+			return errors.WithDetail(ErrUnknownPeer, "The cluster has no other members.")
+		}
+	} else {
+		for id, addr := range sv.state.Peers() {
+			if addr == nodeAddr {
+				target = id
+			}
+		}
+		if target == 0 {
+			return errors.WithDetailf(ErrUnknownPeer, "The cluster has no peer with address %q.", nodeAddr)
+		}
+	}
+	if target == rs.Lead {
+		return nil // nothing to do
+	}
+	if rs.RaftState != raft.StateLeader && target != sv.id {
+		detail := "Request the transfer from the leader or from the new leader."
+		if addr := sv.state.Peers()[rs.Lead]; addr != "" {
+			detail = "Request the transfer from the leader, at " + addr + ", or from the new leader."
+		}
+		return errors.WithDetail(ErrNotLeader, detail)
+	}
+
+	sv.raftNode.TransferLeadership(ctx, rs.Lead, target)
+
+	ticks := time.NewTicker(tickDur)
+	defer ticks.Stop()
+	for {
+		select {
+		case <-ticks.C:
+			if sv.raftNode.Status().Lead == target {
+				return nil
+			}
+		case <-ctx.Done():
+			return errors.Sub(ErrLeaderTransfer, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package net
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	v, err := ParseTLSVersion("1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != tls.VersionTLS11 {
+		t.Errorf("ParseTLSVersion(1.1) = %#x want %#x", v, tls.VersionTLS11)
+	}
+
+	_, err = ParseTLSVersion("SSLv3")
+	if err == nil {
+		t.Error("ParseTLSVersion(SSLv3) err = nil, want error")
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    []uint16
+		wantErr bool
+	}{
+		{s: "", want: nil},
+		{
+			s:    "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+			want: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		},
+		{s: "TLS_RSA_WITH_RC4_128_SHA", wantErr: true},
+		{s: "bogus", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseCipherSuites(c.s)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseCipherSuites(%q) err = %v, want error %t", c.s, err, c.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseCipherSuites(%q) = %v want %v", c.s, got, c.want)
+		}
+	}
+}
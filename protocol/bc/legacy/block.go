@@ -71,7 +71,13 @@ func (b *Block) Scan(val interface{}) error {
 	}
 	buf := make([]byte, len(driverBuf))
 	copy(buf[:], driverBuf)
-	r := blockchain.NewReader(buf)
+	return b.UnmarshalBinary(buf)
+}
+
+// UnmarshalBinary fulfills the encoding.BinaryUnmarshaler interface.
+// The block retains references to data.
+func (b *Block) UnmarshalBinary(data []byte) error {
+	r := blockchain.NewReader(data)
 	err := b.readFrom(r)
 	if err != nil {
 		return err
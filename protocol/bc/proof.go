@@ -0,0 +1,221 @@
+package bc
+
+import (
+	"bytes"
+
+	"chain/crypto/sha3pool"
+	"chain/errors"
+)
+
+// ErrInvalidProof is returned when a merkle proof doesn't
+// verify against the expected root.
+var ErrInvalidProof = errors.New("invalid merkle proof")
+
+// MerkleStep is a single step of a merkle path. It holds the hash
+// of the sibling of the node on the path at some level of the tree,
+// and whether that sibling is the left child of their parent.
+type MerkleStep struct {
+	Sibling Hash `json:"sibling"`
+	Left    bool `json:"left"`
+}
+
+// MerklePath is a path from a leaf of a merkle tree to its root,
+// ordered from the leaf upward. It's used both for the transaction
+// merkle tree committed to by a block's TransactionsRoot and for
+// the patricia tree committed to by a block's AssetsMerkleRoot.
+type MerklePath []MerkleStep
+
+// Root returns the root hash obtained by combining leaf with each
+// sibling along p.
+func (p MerklePath) Root(leaf Hash) Hash {
+	h := leaf
+	for _, step := range p {
+		if step.Left {
+			h = interiorHash(step.Sibling, h)
+		} else {
+			h = interiorHash(h, step.Sibling)
+		}
+	}
+	return h
+}
+
+// LeafHash returns the hash of a leaf holding item, as it appears
+// in both the transaction merkle tree and the state patricia tree.
+func LeafHash(item []byte) (hash Hash) {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	h.Write(leafPrefix)
+	h.Write(item)
+	hash.ReadFrom(h)
+	return hash
+}
+
+func interiorHash(left, right Hash) (hash Hash) {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	h.Write(interiorPrefix)
+	left.WriteTo(h)
+	right.WriteTo(h)
+	hash.ReadFrom(h)
+	return hash
+}
+
+// TxMerklePath returns the merkle path from the i'th transaction
+// of transactions to the root computed by MerkleRoot.
+func TxMerklePath(transactions []*Tx, i int) (MerklePath, error) {
+	if i < 0 || i >= len(transactions) {
+		return nil, errors.New("transaction index out of range")
+	}
+	if len(transactions) == 1 {
+		return nil, nil
+	}
+
+	k := prevPowerOfTwo(len(transactions))
+	if i < k {
+		path, err := TxMerklePath(transactions[:k], i)
+		if err != nil {
+			return nil, err
+		}
+		sibling, err := MerkleRoot(transactions[k:])
+		if err != nil {
+			return nil, err
+		}
+		return append(path, MerkleStep{Sibling: sibling, Left: false}), nil
+	}
+
+	path, err := TxMerklePath(transactions[k:], i-k)
+	if err != nil {
+		return nil, err
+	}
+	sibling, err := MerkleRoot(transactions[:k])
+	if err != nil {
+		return nil, err
+	}
+	return append(path, MerkleStep{Sibling: sibling, Left: true}), nil
+}
+
+// VerifyTxInclusion verifies that path proves the inclusion of the
+// transaction with the given ID in a block with the given
+// transactions root.
+func VerifyTxInclusion(txID Hash, path MerklePath, root Hash) error {
+	if path.Root(LeafHash(txID.Bytes())) != root {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// StateProof proves that an output is or is not a member of the
+// state tree committed to by a block's AssetsMerkleRoot.
+//
+// If Member is true, Path proves membership of OutputID. Otherwise,
+// Prev and Next prove non-membership by showing the items that
+// would be adjacent to OutputID in the tree. Either may be nil if
+// OutputID would be the first or last item in the tree. If both are
+// nil, the proof asserts that the tree is empty.
+type StateProof struct {
+	OutputID Hash           `json:"output_id"`
+	Member   bool           `json:"member"`
+	Path     MerklePath     `json:"path,omitempty"`
+	Prev     *StateNeighbor `json:"prev,omitempty"`
+	Next     *StateNeighbor `json:"next,omitempty"`
+}
+
+// StateNeighbor is an item of the state tree along with its path
+// to the root.
+type StateNeighbor struct {
+	Item Hash       `json:"item"`
+	Path MerklePath `json:"path"`
+}
+
+// Verify checks p against the state tree root. It returns whether
+// p proves membership of p.OutputID. It returns ErrInvalidProof if
+// p doesn't prove what it claims.
+func (p *StateProof) Verify(root Hash) (member bool, err error) {
+	if p.Member {
+		if p.Path.Root(LeafHash(p.OutputID.Bytes())) != root {
+			return false, ErrInvalidProof
+		}
+		return true, nil
+	}
+
+	if p.Prev == nil && p.Next == nil {
+		// The root of an empty patricia tree is the zero hash.
+		if root != (Hash{}) {
+			return false, ErrInvalidProof
+		}
+		return false, nil
+	}
+
+	id := p.OutputID.Bytes()
+	var prevDirs, nextDirs []bool
+	if p.Prev != nil {
+		if bytes.Compare(p.Prev.Item.Bytes(), id) >= 0 {
+			return false, errors.Wrap(ErrInvalidProof, "prev item not before output")
+		}
+		if p.Prev.Path.Root(LeafHash(p.Prev.Item.Bytes())) != root {
+			return false, ErrInvalidProof
+		}
+		prevDirs = directions(p.Prev.Path)
+	}
+	if p.Next != nil {
+		if bytes.Compare(p.Next.Item.Bytes(), id) <= 0 {
+			return false, errors.Wrap(ErrInvalidProof, "next item not after output")
+		}
+		if p.Next.Path.Root(LeafHash(p.Next.Item.Bytes())) != root {
+			return false, ErrInvalidProof
+		}
+		nextDirs = directions(p.Next.Path)
+	}
+
+	// A leaf's position in the tree is determined by the
+	// left/right turns taken from the root to reach it, and
+	// items are ordered left to right. Check that no item can sit
+	// between prev and next.
+	switch {
+	case p.Prev == nil:
+		// next must be the leftmost leaf.
+		if !all(nextDirs, false) {
+			return false, errors.Wrap(ErrInvalidProof, "next item is not first")
+		}
+	case p.Next == nil:
+		// prev must be the rightmost leaf.
+		if !all(prevDirs, true) {
+			return false, errors.Wrap(ErrInvalidProof, "prev item is not last")
+		}
+	default:
+		// prev and next share a common ancestor, below which
+		// prev always goes right and next always goes left.
+		var k int
+		for k < len(prevDirs) && k < len(nextDirs) && prevDirs[k] == nextDirs[k] {
+			k++
+		}
+		if k == len(prevDirs) || k == len(nextDirs) || prevDirs[k] || !nextDirs[k] {
+			return false, errors.Wrap(ErrInvalidProof, "prev and next items not adjacent")
+		}
+		if !all(prevDirs[k+1:], true) || !all(nextDirs[k+1:], false) {
+			return false, errors.Wrap(ErrInvalidProof, "prev and next items not adjacent")
+		}
+	}
+	return false, nil
+}
+
+// directions returns the turns taken from the root to reach the
+// leaf at the bottom of p, ordered from the root downward. True
+// means a turn to the right.
+func directions(p MerklePath) []bool {
+	dirs := make([]bool, len(p))
+	for i, step := range p {
+		// A sibling on the left means the path went right.
+		dirs[len(p)-1-i] = step.Left
+	}
+	return dirs
+}
+
+func all(dirs []bool, v bool) bool {
+	for _, d := range dirs {
+		if d != v {
+			return false
+		}
+	}
+	return true
+}
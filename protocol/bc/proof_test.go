@@ -0,0 +1,95 @@
+package bc_test
+
+import (
+	"testing"
+
+	"chain/errors"
+	. "chain/protocol/bc"
+)
+
+func TestTxMerklePath(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		var txs []*Tx
+		for i := 0; i < n; i++ {
+			txs = append(txs, &Tx{ID: NewHash([32]byte{byte(i + 1)})})
+		}
+		root, err := MerkleRoot(txs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, tx := range txs {
+			path, err := TxMerklePath(txs, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = VerifyTxInclusion(tx.ID, path, root)
+			if err != nil {
+				t.Errorf("%d txs: tx %d: %s", n, i, err)
+			}
+
+			other := txs[(i+1)%n].ID
+			if n > 1 && VerifyTxInclusion(other, path, root) == nil {
+				t.Errorf("%d txs: path for tx %d verified for a different tx", n, i)
+			}
+		}
+	}
+
+	_, err := TxMerklePath(nil, 0)
+	if err == nil {
+		t.Error("expected error for out of range index")
+	}
+}
+
+func TestStateProofVerify(t *testing.T) {
+	a := NewHash([32]byte{0x10})
+	b := NewHash([32]byte{0x80})
+	mid := NewHash([32]byte{0x40})
+	root := MerklePath{{Sibling: LeafHash(b.Bytes())}}.Root(LeafHash(a.Bytes()))
+
+	cases := []struct {
+		proof      StateProof
+		wantMember bool
+		wantErr    error
+	}{{
+		// a is a member
+		proof:      StateProof{OutputID: a, Member: true, Path: MerklePath{{Sibling: LeafHash(b.Bytes())}}},
+		wantMember: true,
+	}, {
+		// mid falls between a and b
+		proof: StateProof{
+			OutputID: mid,
+			Prev:     &StateNeighbor{Item: a, Path: MerklePath{{Sibling: LeafHash(b.Bytes())}}},
+			Next:     &StateNeighbor{Item: b, Path: MerklePath{{Sibling: LeafHash(a.Bytes()), Left: true}}},
+		},
+	}, {
+		// b isn't the first item, so it can't prove nothing comes before it
+		proof: StateProof{
+			OutputID: NewHash([32]byte{0x01}),
+			Next:     &StateNeighbor{Item: b, Path: MerklePath{{Sibling: LeafHash(a.Bytes()), Left: true}}},
+		},
+		wantErr: ErrInvalidProof,
+	}, {
+		// neighbors out of order
+		proof: StateProof{
+			OutputID: mid,
+			Prev:     &StateNeighbor{Item: b, Path: MerklePath{{Sibling: LeafHash(a.Bytes()), Left: true}}},
+			Next:     &StateNeighbor{Item: a, Path: MerklePath{{Sibling: LeafHash(b.Bytes())}}},
+		},
+		wantErr: ErrInvalidProof,
+	}, {
+		// the tree isn't empty
+		proof:   StateProof{OutputID: mid},
+		wantErr: ErrInvalidProof,
+	}}
+
+	for i, c := range cases {
+		member, err := c.proof.Verify(root)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+		if member != c.wantMember {
+			t.Errorf("case %d: got member %t, want %t", i, member, c.wantMember)
+		}
+	}
+}
@@ -287,3 +287,96 @@ func (n *node) calcHash() {
 	n.hash = &hash
 	sha3pool.Put256(h)
 }
+
+// Prove returns a proof that item is or is not a member of t.
+// The proof can be verified against t's root hash using
+// bc.StateProof.Verify.
+func (t *Tree) Prove(item bc.Hash) *bc.StateProof {
+	proof := &bc.StateProof{OutputID: item}
+	if t.root == nil {
+		return proof
+	}
+
+	key := bitKey(item.Bytes())
+	if lookup(t.root, key) != nil {
+		proof.Member = true
+		proof.Path = merklePath(t.root, key)
+		return proof
+	}
+
+	prev, next := neighbors(t.root, key)
+	if prev != nil {
+		proof.Prev = &bc.StateNeighbor{
+			Item: bc.NewHash(byteKey32(prev.key)),
+			Path: merklePath(t.root, prev.key),
+		}
+	}
+	if next != nil {
+		proof.Next = &bc.StateNeighbor{
+			Item: bc.NewHash(byteKey32(next.key)),
+			Path: merklePath(t.root, next.key),
+		}
+	}
+	return proof
+}
+
+// merklePath returns the path from the leaf with the given key
+// up to n. The leaf must exist.
+func merklePath(n *node, key []uint8) bc.MerklePath {
+	var path bc.MerklePath
+	for !n.isLeaf {
+		bit := key[len(n.key)]
+		path = append(path, bc.MerkleStep{
+			Sibling: n.children[1-bit].Hash(),
+			Left:    bit == 1,
+		})
+		n = n.children[bit]
+	}
+
+	// Order the path from the leaf upward.
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// neighbors returns the leaves under n immediately before and
+// after key, which must not be in the tree. Either may be nil.
+func neighbors(n *node, key []uint8) (prev, next *node) {
+	for {
+		if n.isLeaf || !bytes.HasPrefix(key, n.key) {
+			// Every leaf under n is on the same side of key.
+			if bytes.Compare(n.key, key) < 0 {
+				return rightmost(n), next
+			}
+			return prev, leftmost(n)
+		}
+
+		bit := key[len(n.key)]
+		if bit == 1 {
+			prev = rightmost(n.children[0])
+		} else {
+			next = leftmost(n.children[1])
+		}
+		n = n.children[bit]
+	}
+}
+
+func leftmost(n *node) *node {
+	for !n.isLeaf {
+		n = n.children[0]
+	}
+	return n
+}
+
+func rightmost(n *node) *node {
+	for !n.isLeaf {
+		n = n.children[1]
+	}
+	return n
+}
+
+func byteKey32(bitKey []uint8) (b32 [32]byte) {
+	copy(b32[:], byteKey(bitKey))
+	return b32
+}
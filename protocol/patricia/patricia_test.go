@@ -597,3 +597,62 @@ func hashForNonLeaf(a, b bc.Hash) bc.Hash {
 func hashPtr(h bc.Hash) *bc.Hash {
 	return &h
 }
+
+func TestProve(t *testing.T) {
+	r := rand.New(rand.NewSource(12345))
+	randHash := func() bc.Hash {
+		var b32 [32]byte
+		r.Read(b32[:])
+		return bc.NewHash(b32)
+	}
+
+	tr := new(Tree)
+	var members []bc.Hash
+	for i := 0; i < 100; i++ {
+		h := randHash()
+		members = append(members, h)
+		err := tr.Insert(h.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := tr.RootHash()
+
+	for _, h := range members {
+		member, err := tr.Prove(h).Verify(root)
+		if err != nil {
+			t.Fatalf("verifying membership of %x: %s", h.Bytes(), err)
+		}
+		if !member {
+			t.Errorf("got non-membership for %x, want membership", h.Bytes())
+		}
+	}
+
+	nonmembers := []bc.Hash{bc.NewHash([32]byte{}), bc.NewHash([32]byte{0xff, 0xff, 0xff, 0xff})}
+	for i := 0; i < 100; i++ {
+		nonmembers = append(nonmembers, randHash())
+	}
+	for _, h := range nonmembers {
+		proof := tr.Prove(h)
+		member, err := proof.Verify(root)
+		if err != nil {
+			t.Fatalf("verifying non-membership of %x: %s", h.Bytes(), err)
+		}
+		if member {
+			t.Errorf("got membership for %x, want non-membership", h.Bytes())
+		}
+
+		// A non-membership proof must not verify for a member.
+		if proof.Prev != nil {
+			proof.OutputID = proof.Prev.Item
+			if _, err := proof.Verify(root); err == nil {
+				t.Errorf("non-membership proof verified for member %x", proof.OutputID.Bytes())
+			}
+		}
+	}
+
+	member, err := new(Tree).Prove(members[0]).Verify(bc.Hash{})
+	if err != nil || member {
+		t.Errorf("empty tree: got member=%t err=%v, want non-membership", member, err)
+	}
+}
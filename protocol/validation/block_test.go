@@ -48,6 +48,25 @@ func TestValidateBlock2Err(t *testing.T) {
 	}
 }
 
+func TestValidateBlockHeader(t *testing.T) {
+	b1 := newInitialBlock(t)
+	b2 := generate(t, b1)
+	err := ValidateBlockHeader(b2, b1)
+	if err != nil {
+		t.Errorf("ValidateBlockHeader(%v, %v) = %v, want nil", b2, b1, err)
+	}
+
+	b3 := generate(t, b2)
+	err = ValidateBlockHeader(b3, b1)
+	if err == nil {
+		t.Errorf("ValidateBlockHeader(%v, %v) = nil, want error", b3, b1)
+	}
+	err = ValidateBlockHeader(b3, nil)
+	if err == nil {
+		t.Errorf("ValidateBlockHeader(%v, nil) = nil, want error", b3)
+	}
+}
+
 func TestValidateBlockSig2(t *testing.T) {
 	b1 := newInitialBlock(t)
 	b2 := generate(t, b1)
@@ -454,19 +454,9 @@ func ValidateBlockSig(b *bc.Block, prog []byte) error {
 // ValidateBlock validates a block and the transactions within.
 // It does not run the consensus program; for that, see ValidateBlockSig.
 func ValidateBlock(b, prev *bc.Block, initialBlockID bc.Hash, validateTx func(*bc.Tx) error) error {
-	if b.Height > 1 {
-		if prev == nil {
-			return errors.WithDetailf(errNoPrevBlock, "height %d", b.Height)
-		}
-		err := validateBlockAgainstPrev(b, prev)
-		if err != nil {
-			return err
-		}
-	}
-
-	err := checkValidBlockHeader(b.BlockHeader)
+	err := ValidateBlockHeader(b, prev)
 	if err != nil {
-		return errors.Wrap(err, "checking block header")
+		return err
 	}
 
 	for i, tx := range b.Transactions {
@@ -498,6 +488,24 @@ func ValidateBlock(b, prev *bc.Block, initialBlockID bc.Hash, validateTx func(*b
 	return nil
 }
 
+// ValidateBlockHeader validates the header of a block against the
+// header of the previous block, without examining the block's
+// transactions. It's used by clients that sync only block headers.
+// It does not run the consensus program; for that, see
+// ValidateBlockSig.
+func ValidateBlockHeader(b, prev *bc.Block) error {
+	if b.Height > 1 {
+		if prev == nil {
+			return errors.WithDetailf(errNoPrevBlock, "height %d", b.Height)
+		}
+		err := validateBlockAgainstPrev(b, prev)
+		if err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(checkValidBlockHeader(b.BlockHeader), "checking block header")
+}
+
 func validateBlockAgainstPrev(b, prev *bc.Block) error {
 	if b.Version < prev.Version {
 		return errors.WithDetailf(errVersionRegression, "previous block verson %d, current block version %d", prev.Version, b.Version)